@@ -15,9 +15,11 @@ import (
 	"github.com/quikprint/backend/internal/database"
 	"github.com/quikprint/backend/internal/handlers"
 	"github.com/quikprint/backend/internal/middleware"
+	"github.com/quikprint/backend/internal/migrate"
 	"github.com/quikprint/backend/internal/repository"
 	"github.com/quikprint/backend/internal/services"
 	"github.com/quikprint/backend/internal/utils"
+	"github.com/quikprint/backend/migrations"
 )
 
 func main() {
@@ -33,6 +35,8 @@ func main() {
 	}
 	defer db.Pool.Close()
 
+	checkSchema(db, cfg.SchemaCheck)
+
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db.Pool)
 	categoryRepo := repository.NewCategoryRepository(db.Pool)
@@ -47,33 +51,96 @@ func main() {
 	heroSlideRepo := repository.NewHeroSlideRepository(db.Pool)
 	couponRepo := repository.NewCouponRepository(db.Pool)
 	shippingConfigRepo := repository.NewShippingConfigRepository(db.Pool)
+	notificationRepo := repository.NewNotificationRepository(db.Pool)
+	emailOutboxRepo := repository.NewEmailOutboxRepository(db.Pool)
+	webhookEventRepo := repository.NewWebhookEventRepository(db.Pool)
+	refundRepo := repository.NewRefundRepository(db.Pool)
+	reconciliationRepo := repository.NewReconciliationRepository(db.Pool)
+	quoteRepo := repository.NewQuoteRepository(db.Pool)
+	taxRepo := repository.NewTaxRepository(db.Pool)
+	customerGroupRepo := repository.NewCustomerGroupRepository(db.Pool)
+	priceChangeRepo := repository.NewPriceChangeRepository(db.Pool)
 
 	// Initialize services
-	pricingService := services.NewPricingService(productRepo, pricingRepo)
-	paymentService := services.NewPaymentService(cfg.PaystackSecretKey, cfg.PaystackPublicKey)
+	pricingService := services.NewPricingService(productRepo, pricingRepo, customerGroupRepo)
+	taxService := services.NewTaxService(taxRepo, productRepo)
+	paymentProviders := []services.PaymentProvider{services.NewPaystackProvider(cfg.PaystackSecretKey, cfg.PaystackPublicKey)}
+	if cfg.FlutterwaveSecretKey != "" {
+		paymentProviders = append(paymentProviders, services.NewFlutterwaveProvider(cfg.FlutterwaveSecretKey, cfg.FlutterwaveWebhookHash))
+	}
+	paymentService := services.NewPaymentService(cfg.PaymentProvider, paymentProviders...)
 	emailService := services.NewEmailService(
-		cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPFromName,
+		cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPFromName, emailOutboxRepo,
+	)
+	emailWorker := services.NewEmailWorker(emailOutboxRepo, emailService, cfg.EmailWorkers, cfg.SMTPRatePerMinute)
+	notificationService := services.NewNotificationService(emailService, userRepo, orderRepo, notificationRepo)
+	orderRepo.OnStatusChange(notificationService.OrderStatusChanged)
+	settlementService := services.NewPaymentSettlementService(paymentRepo, orderRepo, notificationService)
+	refundService := services.NewRefundService(paymentService, paymentRepo, refundRepo, orderRepo)
+	paymentReconciler := services.NewPaymentReconciler(
+		paymentService, paymentRepo, orderRepo, reconciliationRepo, settlementService,
+		time.Duration(cfg.ReconcileIntervalMinutes)*time.Minute,
+		time.Duration(cfg.ReconcileMinAgeMinutes)*time.Minute,
+		time.Duration(cfg.ReconcileExpireAfterHours)*time.Hour,
+	)
+	quoteService := services.NewQuoteService(
+		pricingService, taxService, quoteRepo, orderRepo, cartRepo, shippingConfigRepo,
+		time.Duration(cfg.QuoteValidityDays)*24*time.Hour,
+	)
+	var paymentReminders []time.Duration
+	for _, hours := range cfg.OrderPaymentReminderHours {
+		paymentReminders = append(paymentReminders, time.Duration(hours*float64(time.Hour)))
+	}
+	orderExpiryService := services.NewOrderExpiryService(
+		orderRepo, notificationService,
+		time.Duration(cfg.OrderPaymentWindowHours)*time.Hour,
+		time.Duration(cfg.OrderExpiryCheckMinutes)*time.Minute,
+		paymentReminders,
+	)
+	priceChangeService := services.NewPriceChangeService(
+		priceChangeRepo, productRepo,
+		time.Duration(cfg.PriceChangeCheckMinutes)*time.Minute,
+	)
+	productScheduleService := services.NewProductScheduleService(
+		productRepo,
+		time.Duration(cfg.ProductScheduleCheckMinutes)*time.Minute,
 	)
 
+	// Background workers stop when the server shuts down
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	notificationService.Start(workerCtx)
+	emailWorker.Start(workerCtx)
+	paymentReconciler.Start(workerCtx)
+	orderExpiryService.Start(workerCtx)
+	priceChangeService.Start(workerCtx)
+	productScheduleService.Start(workerCtx)
+
 	// Initialize JWT Manager
 	jwtManager := utils.NewJWTManager(cfg.JWTSecret, cfg.JWTExpiryHours, cfg.JWTRefreshExpiryHours)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(userRepo, orderRepo, jwtManager)
+	authHandler := handlers.NewAuthHandler(userRepo, orderRepo, jwtManager, notificationService)
 	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
-	productHandler := handlers.NewProductHandler(productRepo)
+	productHandler := handlers.NewProductHandler(productRepo, priceChangeRepo, priceChangeService)
 	pricingHandler := handlers.NewPricingHandler(pricingService, pricingRepo)
 	cartHandler := handlers.NewCartHandler(cartRepo, productRepo, pricingService)
-	orderHandler := handlers.NewOrderHandler(orderRepo, cartRepo, productRepo, pricingService, shippingConfigRepo)
+	orderHandler := handlers.NewOrderHandler(orderRepo, cartRepo, productRepo, pricingService, taxService, shippingConfigRepo, notificationService)
 	fileHandler := handlers.NewFileHandler(fileRepo, cfg.UploadDir, cfg.MaxUploadSizeMB*1024*1024)
-	paymentHandler := handlers.NewPaymentHandler(paymentService, paymentRepo, orderRepo, cfg.PaystackSecretKey, cfg.PaystackCallbackURL)
+	paymentHandler := handlers.NewPaymentHandler(paymentService, paymentRepo, orderRepo, webhookEventRepo, settlementService, refundService, cfg.PaystackCallbackURL)
 	adminHandler := handlers.NewAdminHandler(reportRepo, userRepo, orderRepo)
 	announcementHandler := handlers.NewAnnouncementHandler(announcementRepo)
 	heroSlideHandler := handlers.NewHeroSlideHandler(heroSlideRepo)
 	twoFactorHandler := handlers.NewTwoFactorHandler(userRepo)
-	emailHandler := handlers.NewEmailHandler(emailService, userRepo)
+	emailHandler := handlers.NewEmailHandler(emailService, userRepo, emailOutboxRepo)
 	couponHandler := handlers.NewCouponHandler(couponRepo, cartRepo)
 	shippingConfigHandler := handlers.NewShippingConfigHandler(shippingConfigRepo)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo)
+	refundHandler := handlers.NewRefundHandler(refundService, paymentRepo, refundRepo)
+	reconciliationHandler := handlers.NewReconciliationHandler(paymentReconciler, reconciliationRepo)
+	quoteHandler := handlers.NewQuoteHandler(quoteService, quoteRepo, notificationService)
+	taxHandler := handlers.NewTaxHandler(taxRepo, taxService, reportRepo, userRepo)
+	priceChangeHandler := handlers.NewPriceChangeHandler(priceChangeRepo, productRepo)
+	customerGroupHandler := handlers.NewCustomerGroupHandler(customerGroupRepo, userRepo, categoryRepo, productRepo)
 
 	// Auth middleware
 	authMiddleware := middleware.NewAuthMiddleware(jwtManager)
@@ -83,6 +150,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	utils.RegisterValidators()
 
 	// CORS configuration
 	router.Use(cors.New(cors.Config{
@@ -118,17 +186,24 @@ func main() {
 		v1.GET("/products/:slug", productHandler.GetBySlug)
 
 		v1.GET("/pricing/products/:id", pricingHandler.GetPricingRules)
-		v1.POST("/pricing/calculate", pricingHandler.CalculatePrice)
+		v1.POST("/pricing/calculate", authMiddleware.OptionalAuth(), pricingHandler.CalculatePrice)
 
 		// Public shipping config endpoint
 		v1.GET("/shipping-config", shippingConfigHandler.GetShippingConfigPublic)
+		v1.GET("/tax-settings", taxHandler.GetTaxSettingsPublic)
 
 		// Public announcements and hero slides
 		v1.GET("/announcements", announcementHandler.GetActiveAnnouncements)
 		v1.GET("/hero-slides", heroSlideHandler.GetActiveHeroSlides)
 
-		// Webhook (no auth)
+		// Shared quote links
+		v1.GET("/quotes/shared/:token", quoteHandler.GetSharedQuote)
+		v1.GET("/quotes/shared/:token/pdf", quoteHandler.GetSharedQuotePDF)
+
+		// Webhooks (no auth); the bare path is Paystack's original webhook URL
 		v1.POST("/payments/webhook", paymentHandler.Webhook)
+		v1.POST("/payments/webhook/:provider", paymentHandler.Webhook)
+		v1.GET("/payments/providers", paymentHandler.GetProviders)
 
 		// Protected routes
 		protected := v1.Group("")
@@ -142,6 +217,10 @@ func main() {
 			protected.POST("/auth/2fa/enable", twoFactorHandler.VerifyAndEnable)
 			protected.POST("/auth/2fa/disable", twoFactorHandler.Disable)
 
+			// Notification preferences
+			protected.GET("/auth/notification-preferences", notificationHandler.GetPreferences)
+			protected.PUT("/auth/notification-preferences", notificationHandler.UpdatePreferences)
+
 			protected.GET("/cart", cartHandler.GetCart)
 			protected.POST("/cart/items", cartHandler.AddItem)
 			protected.PUT("/cart/items/:id", cartHandler.UpdateItem)
@@ -151,6 +230,12 @@ func main() {
 			protected.GET("/orders", orderHandler.GetOrders)
 			protected.GET("/orders/:id", orderHandler.GetOrder)
 
+			protected.POST("/quotes", quoteHandler.CreateQuote)
+			protected.GET("/quotes", quoteHandler.GetQuotes)
+			protected.GET("/quotes/:id", quoteHandler.GetQuote)
+			protected.GET("/quotes/:id/pdf", quoteHandler.GetQuotePDF)
+			protected.POST("/quotes/:id/convert", quoteHandler.ConvertQuote)
+
 			protected.POST("/files/upload", fileHandler.Upload)
 
 			protected.POST("/payments/initialize", paymentHandler.InitializePayment)
@@ -166,6 +251,7 @@ func main() {
 		{
 			admin.GET("/categories", categoryHandler.GetAll)
 			admin.POST("/categories", categoryHandler.Create)
+			admin.PUT("/categories/reorder", categoryHandler.Reorder)
 			admin.PUT("/categories/:id", categoryHandler.Update)
 			admin.DELETE("/categories/:id", categoryHandler.Delete)
 
@@ -173,16 +259,44 @@ func main() {
 			admin.POST("/products", productHandler.Create)
 			admin.PUT("/products/:id", productHandler.Update)
 			admin.DELETE("/products/:id", productHandler.Delete)
+			admin.POST("/products/:id/restore", productHandler.Restore)
+			admin.PUT("/products/:id/schedule", productHandler.SetSchedule)
 			admin.POST("/products/bulk-update-price", productHandler.BulkUpdatePrice)
+			admin.POST("/products/bulk-update-price/dry-run", productHandler.PreviewBulkUpdatePrice)
+			admin.GET("/products/:id/price-history", priceChangeHandler.GetPriceHistory)
+			admin.GET("/price-changes", priceChangeHandler.GetPriceChanges)
+			admin.GET("/price-changes/:id", priceChangeHandler.GetPriceChange)
+			admin.POST("/price-changes/:id/cancel", priceChangeHandler.CancelPriceChange)
+			admin.POST("/price-changes/:id/rollback", priceChangeHandler.RollbackPriceChange)
 
 			admin.GET("/orders", orderHandler.GetAllOrders)
 			admin.PUT("/orders/:id/status", orderHandler.UpdateOrderStatus)
+			admin.GET("/orders/:id/transitions", orderHandler.GetOrderTransitions)
+
+			admin.GET("/quotes", quoteHandler.GetAllQuotes)
+			admin.GET("/quotes/:id", quoteHandler.GetQuoteAdmin)
+			admin.PUT("/quotes/:id/items/:itemId/price", quoteHandler.OverrideQuotePrice)
+
+			// Payment webhook audit
+			admin.GET("/payments/webhook-events", paymentHandler.GetWebhookEvents)
+			admin.POST("/payments/webhook-events/:id/replay", paymentHandler.ReplayWebhookEvent)
+
+			// Refunds
+			admin.GET("/orders/:id/payments", refundHandler.GetOrderPayments)
+			admin.GET("/payments/:id/refunds", refundHandler.GetRefunds)
+			admin.POST("/payments/:id/refunds", refundHandler.CreateRefund)
+
+			// Payment reconciliation
+			admin.GET("/payments/reconciliation-runs", reconciliationHandler.GetRuns)
+			admin.GET("/payments/reconciliation-runs/:id", reconciliationHandler.GetRun)
+			admin.POST("/payments/reconciliation-runs", reconciliationHandler.RunReconciliation)
 
 			admin.GET("/customers", adminHandler.GetCustomers)
 			admin.GET("/dashboard", adminHandler.GetDashboardStats)
 			admin.GET("/reports/daily", adminHandler.GetDailySalesReport)
 			admin.GET("/reports/weekly", adminHandler.GetWeeklySalesReport)
 			admin.GET("/reports/orders-by-status", adminHandler.GetOrdersByStatusReport)
+			admin.GET("/reports/vat", taxHandler.GetVATReport)
 
 			// Pricing management routes
 			admin.GET("/products/:id/pricing", pricingHandler.GetPricingRules)
@@ -190,6 +304,19 @@ func main() {
 			admin.DELETE("/products/:id/dimensional-pricing", pricingHandler.DeleteDimensionalPricing)
 			admin.POST("/products/:id/pricing-tiers", pricingHandler.SetPricingTiers)
 			admin.DELETE("/products/:id/pricing-tiers", pricingHandler.DeletePricingTiers)
+			admin.GET("/products/:id/add-ons", pricingHandler.GetAddOns)
+			admin.POST("/products/:id/add-ons", pricingHandler.CreateAddOn)
+			admin.PUT("/products/:id/add-ons/:addOnId", pricingHandler.UpdateAddOn)
+			admin.DELETE("/products/:id/add-ons/:addOnId", pricingHandler.DeleteAddOn)
+			admin.GET("/products/:id/pricing-rules", pricingHandler.ListPricingRules)
+			admin.POST("/products/:id/pricing-rules", pricingHandler.CreatePricingRule)
+			admin.PUT("/products/:id/pricing-rules/:ruleId", pricingHandler.UpdatePricingRule)
+			admin.DELETE("/products/:id/pricing-rules/:ruleId", pricingHandler.DeletePricingRule)
+			admin.GET("/products/:id/pricing-formulas", pricingHandler.ListPricingFormulas)
+			admin.POST("/products/:id/pricing-formulas", pricingHandler.CreatePricingFormula)
+			admin.POST("/products/:id/pricing-formulas/dry-run", pricingHandler.DryRunPricingFormulas)
+			admin.PUT("/products/:id/pricing-formulas/:formulaId", pricingHandler.UpdatePricingFormula)
+			admin.DELETE("/products/:id/pricing-formulas/:formulaId", pricingHandler.DeletePricingFormula)
 
 			// Announcement management routes
 			admin.GET("/announcements", announcementHandler.GetAllAnnouncements)
@@ -209,6 +336,10 @@ func main() {
 			admin.GET("/email/status", emailHandler.GetEmailStatus)
 			admin.POST("/email/test", emailHandler.SendTestEmail)
 			admin.POST("/email/broadcast", emailHandler.SendBroadcastEmail)
+			admin.GET("/email/broadcasts", emailHandler.GetBroadcasts)
+			admin.GET("/email/broadcasts/:id", emailHandler.GetBroadcast)
+			admin.POST("/email/broadcasts/:id/resend", emailHandler.ResendBroadcastFailures)
+			admin.GET("/notifications/log", notificationHandler.GetLog)
 
 			// Coupon management routes
 			admin.GET("/coupons", couponHandler.GetAll)
@@ -220,6 +351,34 @@ func main() {
 			// Shipping config management routes
 			admin.GET("/shipping-config", shippingConfigHandler.GetShippingConfig)
 			admin.PUT("/shipping-config", shippingConfigHandler.UpdateShippingConfig)
+
+			// Tax management routes
+			admin.GET("/tax-settings", taxHandler.GetTaxSettings)
+			admin.PUT("/tax-settings", taxHandler.UpdateTaxSettings)
+			admin.GET("/tax-rates", taxHandler.GetTaxRates)
+			admin.POST("/tax-rates", taxHandler.CreateTaxRate)
+			admin.PUT("/tax-rates/:id", taxHandler.UpdateTaxRate)
+			admin.DELETE("/tax-rates/:id", taxHandler.DeleteTaxRate)
+			admin.GET("/customers/:id/tax-profile", taxHandler.GetCustomerTaxProfile)
+			admin.PUT("/customers/:id/tax-profile", taxHandler.UpdateCustomerTaxProfile)
+			admin.DELETE("/customers/:id/tax-profile", taxHandler.DeleteCustomerTaxProfile)
+
+			// Customer groups and their price lists
+			admin.GET("/customer-groups", customerGroupHandler.GetAll)
+			admin.POST("/customer-groups", customerGroupHandler.Create)
+			admin.GET("/customer-groups/:id", customerGroupHandler.GetByID)
+			admin.PUT("/customer-groups/:id", customerGroupHandler.Update)
+			admin.DELETE("/customer-groups/:id", customerGroupHandler.Delete)
+			admin.GET("/customer-groups/:id/members", customerGroupHandler.GetMembers)
+			admin.POST("/customer-groups/:id/members", customerGroupHandler.AddMember)
+			admin.DELETE("/customer-groups/:id/members/:userId", customerGroupHandler.RemoveMember)
+			admin.GET("/customer-groups/:id/prices", customerGroupHandler.GetPriceList)
+			admin.PUT("/customer-groups/:id/category-discounts/:categoryId", customerGroupHandler.SetCategoryDiscount)
+			admin.DELETE("/customer-groups/:id/category-discounts/:categoryId", customerGroupHandler.DeleteCategoryDiscount)
+			admin.PUT("/customer-groups/:id/product-prices/:productId", customerGroupHandler.SetProductPrice)
+			admin.DELETE("/customer-groups/:id/product-prices/:productId", customerGroupHandler.DeleteProductPrice)
+			admin.PUT("/customer-groups/:id/pricing-tiers/:productId", customerGroupHandler.SetPricingTiers)
+			admin.DELETE("/customer-groups/:id/pricing-tiers/:productId", customerGroupHandler.DeletePricingTiers)
 		}
 
 		// Admin-only routes (User Roles and Security features)
@@ -256,5 +415,40 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
+
+	stopWorkers()
+	paymentReconciler.Wait()
+	orderExpiryService.Wait()
+	priceChangeService.Wait()
+	productScheduleService.Wait()
+	notificationService.Wait()
+	emailWorker.Wait()
 	log.Println("Server exited")
 }
+
+// checkSchema reports migrations that have not been applied. In strict mode the
+// server refuses to start until they are; in warn mode it only logs them.
+func checkSchema(db *database.DB, mode string) {
+	if mode == "off" {
+		return
+	}
+
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
+	}
+	pending, err := migrate.New(db.Pool, loaded).Pending(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to check migrations: %v", err)
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	for _, m := range pending {
+		log.Printf("Migration %03d_%s has not been applied", m.Version, m.Name)
+	}
+	if mode == "strict" {
+		log.Fatalf("Database schema is %d migrations behind; run `go run ./cmd/migrate up`", len(pending))
+	}
+}
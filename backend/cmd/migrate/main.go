@@ -2,54 +2,142 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
+	"strconv"
 
 	"github.com/quikprint/backend/config"
-
-	"github.com/jackc/pgx/v5"
+	"github.com/quikprint/backend/internal/database"
+	"github.com/quikprint/backend/internal/migrate"
+	"github.com/quikprint/backend/migrations"
 )
 
+const usage = `Usage: migrate [-dir migrations] <command> [args]
+
+Commands:
+  up            apply all pending migrations
+  down [N]      roll back the N most recent migrations (default 1)
+  status        list migrations and whether they are applied
+  goto V        migrate up or down to version V (0 rolls back everything)
+  create NAME   write new empty up and down files in -dir
+  baseline V    mark migrations up to V as applied without running them, for
+                databases migrated by hand before schema_migrations existed
+`
+
 func main() {
+	dir := flag.String("dir", "migrations", "migrations directory, used by create")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command := args[0]
+
+	// create only touches files, so it works without a database
+	if command == "create" {
+		if len(args) != 2 {
+			log.Fatal("create needs a migration name")
+		}
+		up, down, err := migrate.Create(*dir, args[1])
+		if err != nil {
+			log.Fatalf("Failed to create migration: %v", err)
+		}
+		fmt.Printf("Created %s\nCreated %s\n", up, down)
+		return
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	ctx := context.Background()
-	conn, err := pgx.Connect(ctx, cfg.DatabaseURL)
+	db, err := database.New(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer conn.Close(ctx)
+	defer db.Pool.Close()
 
-	// Get migration file from command line or default to latest
-	migrationFile := "migrations/003_announcements_hero.up.sql"
-	if len(os.Args) > 1 {
-		migrationFile = os.Args[1]
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
+	migrator := migrate.New(db.Pool, loaded)
+	ctx := context.Background()
 
-	// Read migration file
-	absPath, err := filepath.Abs(migrationFile)
-	if err != nil {
-		log.Fatalf("Failed to get absolute path: %v", err)
+	var done []migrate.Migration
+	verb := "Applied"
+	switch command {
+	case "up":
+		done, err = migrator.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				log.Fatalf("Invalid count %q", args[1])
+			}
+		}
+		verb = "Rolled back"
+		done, err = migrator.Down(ctx, n)
+	case "goto":
+		version := versionArg(args)
+		done, err = migrator.Goto(ctx, version)
+	case "baseline":
+		verb = "Marked applied"
+		done, err = migrator.Baseline(ctx, versionArg(args))
+	case "status":
+		printStatus(ctx, migrator)
+		return
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 
-	content, err := os.ReadFile(absPath)
+	for _, m := range done {
+		fmt.Printf("%s %03d_%s\n", verb, m.Version, m.Name)
+	}
+	if errors.Is(err, migrate.ErrLocked) {
+		log.Fatal("Another migration is running; try again when it has finished")
+	}
 	if err != nil {
-		log.Fatalf("Failed to read migration file %s: %v", absPath, err)
+		log.Fatalf("Migration failed: %v", err)
+	}
+	if len(done) == 0 {
+		fmt.Println("Nothing to do")
 	}
+}
 
-	fmt.Printf("Running migration: %s\n", migrationFile)
-	fmt.Println("---")
+func versionArg(args []string) int64 {
+	if len(args) != 2 {
+		log.Fatalf("%s needs a version", args[0])
+	}
+	version, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil || version < 0 {
+		log.Fatalf("Invalid version %q", args[1])
+	}
+	return version
+}
 
-	// Execute migration
-	_, err = conn.Exec(ctx, string(content))
+func printStatus(ctx context.Context, migrator *migrate.Migrator) {
+	statuses, err := migrator.Status(ctx)
 	if err != nil {
-		log.Fatalf("Failed to execute migration: %v", err)
+		log.Fatalf("Failed to read migration status: %v", err)
 	}
 
-	fmt.Println("Migration completed successfully!")
+	pending := 0
+	for _, s := range statuses {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Printf("%03d  %-40s %s\n", s.Version, s.Name, applied)
+	}
+	fmt.Printf("\n%d migrations, %d pending\n", len(statuses), pending)
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/quikprint/backend/config"
+	"github.com/quikprint/backend/internal/database"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
+)
+
+// reconcile runs one payment reconciliation pass and prints its report. The API
+// server runs the same reconciliation on a schedule; this command is for cron
+// deployments with the schedule disabled, or for running a pass by hand.
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	minAge := flag.Duration("min-age", time.Duration(cfg.ReconcileMinAgeMinutes)*time.Minute, "only check payments at least this old")
+	expireAfter := flag.Duration("expire-after", time.Duration(cfg.ReconcileExpireAfterHours)*time.Hour, "expire payments still incomplete after this long")
+	flag.Parse()
+
+	db, err := database.New(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Pool.Close()
+
+	userRepo := repository.NewUserRepository(db.Pool)
+	orderRepo := repository.NewOrderRepository(db.Pool)
+	paymentRepo := repository.NewPaymentRepository(db.Pool)
+	notificationRepo := repository.NewNotificationRepository(db.Pool)
+	emailOutboxRepo := repository.NewEmailOutboxRepository(db.Pool)
+	reconciliationRepo := repository.NewReconciliationRepository(db.Pool)
+
+	paymentProviders := []services.PaymentProvider{services.NewPaystackProvider(cfg.PaystackSecretKey, cfg.PaystackPublicKey)}
+	if cfg.FlutterwaveSecretKey != "" {
+		paymentProviders = append(paymentProviders, services.NewFlutterwaveProvider(cfg.FlutterwaveSecretKey, cfg.FlutterwaveWebhookHash))
+	}
+	paymentService := services.NewPaymentService(cfg.PaymentProvider, paymentProviders...)
+
+	// Emails are queued in the outbox; the API server's email workers deliver them
+	emailService := services.NewEmailService(
+		cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom, cfg.SMTPFromName, emailOutboxRepo,
+	)
+	notificationService := services.NewNotificationService(emailService, userRepo, orderRepo, notificationRepo)
+	orderRepo.OnStatusChange(notificationService.OrderStatusChanged)
+	settlementService := services.NewPaymentSettlementService(paymentRepo, orderRepo, notificationService)
+	reconciler := services.NewPaymentReconciler(
+		paymentService, paymentRepo, orderRepo, reconciliationRepo, settlementService, 0, *minAge, *expireAfter,
+	)
+
+	notifyCtx, stopNotifications := context.WithCancel(context.Background())
+	notificationService.Start(notifyCtx)
+
+	run, err := reconciler.Run(context.Background(), models.ReconciliationTriggerCommand)
+
+	stopNotifications()
+	notificationService.Wait()
+
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	fmt.Printf("Reconciliation run %s\n", run.ID)
+	fmt.Printf("Checked %d: %d settled, %d failed, %d expired, %d still pending, %d mismatched, %d errors\n",
+		run.Checked, run.Settled, run.Failed, run.Expired, run.Pending, run.Mismatches, run.Errors)
+
+	for _, item := range run.Items {
+		if item.Outcome != models.ReconciliationMismatch && item.Outcome != models.ReconciliationError {
+			continue
+		}
+		provided := "-"
+		if item.ProviderAmount != nil {
+			provided = item.ProviderAmount.String()
+		}
+		fmt.Printf("  %-8s %-30s order total %s, %s reports %s %s: %s\n",
+			item.Outcome, item.Reference, item.ExpectedAmount, item.Provider, provided, item.Currency, item.Detail)
+	}
+}
@@ -203,20 +203,20 @@ func getProductsData() []productData {
 			MinQuantity:      100,
 			Options: []models.ProductOption{
 				{ID: "paper", Name: "Paper Stock", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "300gsm", Label: "300gsm Cardstock", PriceModifier: ptrFloat(0)},
-					{Value: "350gsm", Label: "350gsm Premium", PriceModifier: ptrFloat(2000)},
-					{Value: "400gsm", Label: "400gsm Ultra Thick", PriceModifier: ptrFloat(4000)},
+					{Value: "300gsm", Label: "300gsm Cardstock", PriceModifier: ptrNaira(0)},
+					{Value: "350gsm", Label: "350gsm Premium", PriceModifier: ptrNaira(2000)},
+					{Value: "400gsm", Label: "400gsm Ultra Thick", PriceModifier: ptrNaira(4000)},
 				}},
 				{ID: "finish", Name: "Finish", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "matte", Label: "Matte Lamination", PriceModifier: ptrFloat(0)},
-					{Value: "gloss", Label: "Gloss Lamination", PriceModifier: ptrFloat(1500)},
-					{Value: "soft-touch", Label: "Soft Touch Laminate", PriceModifier: ptrFloat(3500)},
+					{Value: "matte", Label: "Matte Lamination", PriceModifier: ptrNaira(0)},
+					{Value: "gloss", Label: "Gloss Lamination", PriceModifier: ptrNaira(1500)},
+					{Value: "soft-touch", Label: "Soft Touch Laminate", PriceModifier: ptrNaira(3500)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "100", Label: "100 cards", PriceModifier: ptrFloat(0)},
-					{Value: "250", Label: "250 cards", PriceModifier: ptrFloat(4000)},
-					{Value: "500", Label: "500 cards", PriceModifier: ptrFloat(7500)},
-					{Value: "1000", Label: "1,000 cards", PriceModifier: ptrFloat(12000)},
+					{Value: "100", Label: "100 cards", PriceModifier: ptrNaira(0)},
+					{Value: "250", Label: "250 cards", PriceModifier: ptrNaira(4000)},
+					{Value: "500", Label: "500 cards", PriceModifier: ptrNaira(7500)},
+					{Value: "1000", Label: "1,000 cards", PriceModifier: ptrNaira(12000)},
 				}},
 			},
 		},
@@ -233,14 +233,14 @@ func getProductsData() []productData {
 			MinQuantity:      50,
 			Options: []models.ProductOption{
 				{ID: "paper", Name: "Paper Stock", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "art-150", Label: "150gsm Art Paper", PriceModifier: ptrFloat(0)},
-					{Value: "art-200", Label: "200gsm Art Paper", PriceModifier: ptrFloat(5000)},
-					{Value: "card-300", Label: "300gsm Cardstock", PriceModifier: ptrFloat(8000)},
+					{Value: "art-150", Label: "150gsm Art Paper", PriceModifier: ptrNaira(0)},
+					{Value: "art-200", Label: "200gsm Art Paper", PriceModifier: ptrNaira(5000)},
+					{Value: "card-300", Label: "300gsm Cardstock", PriceModifier: ptrNaira(8000)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "50", Label: "50 brochures", PriceModifier: ptrFloat(0)},
-					{Value: "100", Label: "100 brochures", PriceModifier: ptrFloat(12000)},
-					{Value: "250", Label: "250 brochures", PriceModifier: ptrFloat(28000)},
+					{Value: "50", Label: "50 brochures", PriceModifier: ptrNaira(0)},
+					{Value: "100", Label: "100 brochures", PriceModifier: ptrNaira(12000)},
+					{Value: "250", Label: "250 brochures", PriceModifier: ptrNaira(28000)},
 				}},
 			},
 		},
@@ -257,15 +257,15 @@ func getProductsData() []productData {
 			MinQuantity:      100,
 			Options: []models.ProductOption{
 				{ID: "size", Name: "Size", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "a5", Label: "A5 (148 x 210mm)", PriceModifier: ptrFloat(0)},
-					{Value: "a4", Label: "A4 (210 x 297mm)", PriceModifier: ptrFloat(5000)},
-					{Value: "dl", Label: "DL (99 x 210mm)", PriceModifier: ptrFloat(-2000)},
+					{Value: "a5", Label: "A5 (148 x 210mm)", PriceModifier: ptrNaira(0)},
+					{Value: "a4", Label: "A4 (210 x 297mm)", PriceModifier: ptrNaira(5000)},
+					{Value: "dl", Label: "DL (99 x 210mm)", PriceModifier: ptrNaira(-2000)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "100", Label: "100 flyers", PriceModifier: ptrFloat(0)},
-					{Value: "250", Label: "250 flyers", PriceModifier: ptrFloat(8000)},
-					{Value: "500", Label: "500 flyers", PriceModifier: ptrFloat(15000)},
-					{Value: "1000", Label: "1,000 flyers", PriceModifier: ptrFloat(25000)},
+					{Value: "100", Label: "100 flyers", PriceModifier: ptrNaira(0)},
+					{Value: "250", Label: "250 flyers", PriceModifier: ptrNaira(8000)},
+					{Value: "500", Label: "500 flyers", PriceModifier: ptrNaira(15000)},
+					{Value: "1000", Label: "1,000 flyers", PriceModifier: ptrNaira(25000)},
 				}},
 			},
 		},
@@ -282,9 +282,9 @@ func getProductsData() []productData {
 			MinQuantity:      1,
 			Options: []models.ProductOption{
 				{ID: "size", Name: "Size", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "small", Label: "Small (60x90cm)", PriceModifier: ptrFloat(0)},
-					{Value: "medium", Label: "Medium (80x120cm)", PriceModifier: ptrFloat(5000)},
-					{Value: "large", Label: "Large (100x150cm)", PriceModifier: ptrFloat(10000)},
+					{Value: "small", Label: "Small (60x90cm)", PriceModifier: ptrNaira(0)},
+					{Value: "medium", Label: "Medium (80x120cm)", PriceModifier: ptrNaira(5000)},
+					{Value: "large", Label: "Large (100x150cm)", PriceModifier: ptrNaira(10000)},
 				}},
 			},
 		},
@@ -301,14 +301,14 @@ func getProductsData() []productData {
 			MinQuantity:      1,
 			Options: []models.ProductOption{
 				{ID: "paper", Name: "Paper Type", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "gloss", Label: "Gloss Art Paper", PriceModifier: ptrFloat(0)},
-					{Value: "matte", Label: "Matte Art Paper", PriceModifier: ptrFloat(1000)},
-					{Value: "photo", Label: "Photo Paper", PriceModifier: ptrFloat(3000)},
+					{Value: "gloss", Label: "Gloss Art Paper", PriceModifier: ptrNaira(0)},
+					{Value: "matte", Label: "Matte Art Paper", PriceModifier: ptrNaira(1000)},
+					{Value: "photo", Label: "Photo Paper", PriceModifier: ptrNaira(3000)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "1", Label: "1 poster", PriceModifier: ptrFloat(0)},
-					{Value: "5", Label: "5 posters", PriceModifier: ptrFloat(25000)},
-					{Value: "10", Label: "10 posters", PriceModifier: ptrFloat(45000)},
+					{Value: "1", Label: "1 poster", PriceModifier: ptrNaira(0)},
+					{Value: "5", Label: "5 posters", PriceModifier: ptrNaira(25000)},
+					{Value: "10", Label: "10 posters", PriceModifier: ptrNaira(45000)},
 				}},
 			},
 		},
@@ -325,13 +325,13 @@ func getProductsData() []productData {
 			MinQuantity:      25,
 			Options: []models.ProductOption{
 				{ID: "sheets", Name: "Number of Sheets", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "7", Label: "7 Sheets (Bi-monthly)", PriceModifier: ptrFloat(0)},
-					{Value: "13", Label: "13 Sheets (Monthly)", PriceModifier: ptrFloat(5000)},
+					{Value: "7", Label: "7 Sheets (Bi-monthly)", PriceModifier: ptrNaira(0)},
+					{Value: "13", Label: "13 Sheets (Monthly)", PriceModifier: ptrNaira(5000)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "25", Label: "25 calendars", PriceModifier: ptrFloat(0)},
-					{Value: "50", Label: "50 calendars", PriceModifier: ptrFloat(35000)},
-					{Value: "100", Label: "100 calendars", PriceModifier: ptrFloat(65000)},
+					{Value: "25", Label: "25 calendars", PriceModifier: ptrNaira(0)},
+					{Value: "50", Label: "50 calendars", PriceModifier: ptrNaira(35000)},
+					{Value: "100", Label: "100 calendars", PriceModifier: ptrNaira(65000)},
 				}},
 			},
 		},
@@ -348,14 +348,14 @@ func getProductsData() []productData {
 			MinQuantity:      12,
 			Options: []models.ProductOption{
 				{ID: "color", Name: "T-Shirt Color", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "white", Label: "White", PriceModifier: ptrFloat(0)},
-					{Value: "black", Label: "Black", PriceModifier: ptrFloat(500)},
-					{Value: "navy", Label: "Navy Blue", PriceModifier: ptrFloat(500)},
+					{Value: "white", Label: "White", PriceModifier: ptrNaira(0)},
+					{Value: "black", Label: "Black", PriceModifier: ptrNaira(500)},
+					{Value: "navy", Label: "Navy Blue", PriceModifier: ptrNaira(500)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "12", Label: "12 shirts", PriceModifier: ptrFloat(0)},
-					{Value: "25", Label: "25 shirts", PriceModifier: ptrFloat(45000)},
-					{Value: "50", Label: "50 shirts", PriceModifier: ptrFloat(95000)},
+					{Value: "12", Label: "12 shirts", PriceModifier: ptrNaira(0)},
+					{Value: "25", Label: "25 shirts", PriceModifier: ptrNaira(45000)},
+					{Value: "50", Label: "50 shirts", PriceModifier: ptrNaira(95000)},
 				}},
 			},
 		},
@@ -372,20 +372,21 @@ func getProductsData() []productData {
 			MinQuantity:      50,
 			Options: []models.ProductOption{
 				{ID: "paper", Name: "Card Stock", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "ivory", Label: "Ivory 300gsm", PriceModifier: ptrFloat(0)},
-					{Value: "pearl", Label: "Pearl 300gsm", PriceModifier: ptrFloat(8000)},
-					{Value: "textured", Label: "Textured 350gsm", PriceModifier: ptrFloat(12000)},
+					{Value: "ivory", Label: "Ivory 300gsm", PriceModifier: ptrNaira(0)},
+					{Value: "pearl", Label: "Pearl 300gsm", PriceModifier: ptrNaira(8000)},
+					{Value: "textured", Label: "Textured 350gsm", PriceModifier: ptrNaira(12000)},
 				}},
 				{ID: "quantity", Name: "Quantity", Type: models.OptionTypeSelect, Options: []models.ProductOptionValue{
-					{Value: "50", Label: "50 invitations", PriceModifier: ptrFloat(0)},
-					{Value: "100", Label: "100 invitations", PriceModifier: ptrFloat(28000)},
-					{Value: "150", Label: "150 invitations", PriceModifier: ptrFloat(52000)},
+					{Value: "50", Label: "50 invitations", PriceModifier: ptrNaira(0)},
+					{Value: "100", Label: "100 invitations", PriceModifier: ptrNaira(28000)},
+					{Value: "150", Label: "150 invitations", PriceModifier: ptrNaira(52000)},
 				}},
 			},
 		},
 	}
 }
 
-func ptrFloat(f float64) *float64 {
-	return &f
+func ptrNaira(naira int64) *models.Money {
+	m := models.Kobo(naira * 100)
+	return &m
 }
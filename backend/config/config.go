@@ -33,6 +33,29 @@ type Config struct {
 	SMTPPassword string
 	SMTPFrom     string
 	SMTPFromName string
+	// Email outbox workers
+	EmailWorkers      int
+	SMTPRatePerMinute int
+	// Payment providers
+	PaymentProvider        string
+	FlutterwaveSecretKey   string
+	FlutterwaveWebhookHash string
+	// Payment reconciliation
+	ReconcileIntervalMinutes  int
+	ReconcileMinAgeMinutes    int
+	ReconcileExpireAfterHours int
+	// Unpaid order expiry
+	OrderPaymentWindowHours   int
+	OrderPaymentReminderHours []float64
+	OrderExpiryCheckMinutes   int
+	// How long quoted prices are held
+	QuoteValidityDays int
+	// How often scheduled price changes are looked for
+	PriceChangeCheckMinutes int
+	// How often products due to be published or archived are looked for
+	ProductScheduleCheckMinutes int
+	// SchemaCheck is what the API does when migrations are pending: off, warn or strict
+	SchemaCheck string
 }
 
 func Load() (*Config, error) {
@@ -43,11 +66,28 @@ func Load() (*Config, error) {
 	jwtRefreshExpiry, _ := strconv.Atoi(getEnv("JWT_REFRESH_EXPIRY_HOURS", "168"))
 	maxUploadSize, _ := strconv.ParseInt(getEnv("MAX_UPLOAD_SIZE_MB", "50"), 10, 64)
 	smtpPort, _ := strconv.Atoi(getEnv("SMTP_PORT", "465"))
+	emailWorkers, _ := strconv.Atoi(getEnv("EMAIL_WORKERS", "2"))
+	smtpRatePerMinute, _ := strconv.Atoi(getEnv("SMTP_RATE_PER_MINUTE", "30"))
+	reconcileInterval, _ := strconv.Atoi(getEnv("RECONCILE_INTERVAL_MINUTES", "15"))
+	reconcileMinAge, _ := strconv.Atoi(getEnv("RECONCILE_MIN_AGE_MINUTES", "30"))
+	reconcileExpireAfter, _ := strconv.Atoi(getEnv("RECONCILE_EXPIRE_AFTER_HOURS", "24"))
+	orderPaymentWindow, _ := strconv.Atoi(getEnv("ORDER_PAYMENT_WINDOW_HOURS", "48"))
+	orderExpiryCheck, _ := strconv.Atoi(getEnv("ORDER_EXPIRY_CHECK_MINUTES", "10"))
+	quoteValidity, _ := strconv.Atoi(getEnv("QUOTE_VALIDITY_DAYS", "14"))
+	priceChangeCheck, _ := strconv.Atoi(getEnv("PRICE_CHANGE_CHECK_MINUTES", "1"))
+	productScheduleCheck, _ := strconv.Atoi(getEnv("PRODUCT_SCHEDULE_CHECK_MINUTES", "1"))
 	shippingFee, _ := strconv.ParseFloat(getEnv("SHIPPING_FEE", "5000"), 64)
 	freeShippingThreshold, _ := strconv.ParseFloat(getEnv("FREE_SHIPPING_THRESHOLD", "50000"), 64)
 
 	corsOrigins := strings.Split(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:5173"), ",")
 
+	var orderPaymentReminders []float64
+	for _, h := range strings.Split(getEnv("ORDER_PAYMENT_REMINDER_HOURS", "24,4"), ",") {
+		if hours, err := strconv.ParseFloat(strings.TrimSpace(h), 64); err == nil {
+			orderPaymentReminders = append(orderPaymentReminders, hours)
+		}
+	}
+
 	return &Config{
 		Port:                  getEnv("PORT", "8080"),
 		Environment:           getEnv("ENVIRONMENT", "development"),
@@ -73,6 +113,25 @@ func Load() (*Config, error) {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		SMTPFrom:     getEnv("SMTP_FROM", "noreply@quikprint.ng"),
 		SMTPFromName: getEnv("SMTP_FROM_NAME", "QuikPrint NG"),
+		// Email outbox workers
+		EmailWorkers:      emailWorkers,
+		SMTPRatePerMinute: smtpRatePerMinute,
+		// Payment providers
+		PaymentProvider:        getEnv("PAYMENT_PROVIDER", "paystack"),
+		FlutterwaveSecretKey:   getEnv("FLUTTERWAVE_SECRET_KEY", ""),
+		FlutterwaveWebhookHash: getEnv("FLUTTERWAVE_WEBHOOK_HASH", ""),
+		// Payment reconciliation
+		ReconcileIntervalMinutes:  reconcileInterval,
+		ReconcileMinAgeMinutes:    reconcileMinAge,
+		ReconcileExpireAfterHours: reconcileExpireAfter,
+		// Unpaid order expiry
+		OrderPaymentWindowHours:     orderPaymentWindow,
+		OrderPaymentReminderHours:   orderPaymentReminders,
+		OrderExpiryCheckMinutes:     orderExpiryCheck,
+		QuoteValidityDays:           quoteValidity,
+		PriceChangeCheckMinutes:     priceChangeCheck,
+		ProductScheduleCheckMinutes: productScheduleCheck,
+		SchemaCheck:                 getEnv("SCHEMA_CHECK", "warn"),
 	}, nil
 }
 
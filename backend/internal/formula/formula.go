@@ -0,0 +1,676 @@
+// Package formula implements the small expression language used for per-product
+// pricing formulas. Expressions are arithmetic over exact decimals with
+// comparisons, boolean logic, string literals, variables and function calls, e.g.
+//
+//	if(config("finish") == "gloss", 1.2, 1) * area * 850 + option("paper")
+//
+// There are no loops or assignments, and expressions are limited in length and
+// nesting, so evaluation always finishes quickly.
+package formula
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxLength is the longest expression accepted
+	MaxLength = 1000
+	// maxDepth limits nesting of parentheses, calls and operators
+	maxDepth = 32
+)
+
+// Value is the result of evaluating an expression or part of one: a *big.Rat,
+// a string or a bool
+type Value interface{}
+
+// Func is a function callable from expressions. Arguments are already evaluated.
+type Func func(args []Value) (Value, error)
+
+// Env holds the variables and functions an expression can use, on top of the
+// built-in functions
+type Env struct {
+	Vars  map[string]Value
+	Funcs map[string]Func
+}
+
+// SyntaxError reports where an expression failed to parse
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos+1, e.Msg)
+}
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses src into an expression
+func Parse(src string) (*Expr, error) {
+	if len(src) > MaxLength {
+		return nil, &SyntaxError{Pos: MaxLength, Msg: fmt.Sprintf("expression is longer than %d characters", MaxLength)}
+	}
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok)}
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Names returns the variables and functions the expression refers to, excluding
+// the built-in functions
+func (e *Expr) Names() (vars, funcs []string) {
+	seenVars := make(map[string]bool)
+	seenFuncs := make(map[string]bool)
+	walk(e.root, func(n node) {
+		switch n := n.(type) {
+		case *identNode:
+			if !seenVars[n.name] {
+				seenVars[n.name] = true
+				vars = append(vars, n.name)
+			}
+		case *callNode:
+			if _, builtin := builtins[n.name]; !builtin && n.name != "if" && !seenFuncs[n.name] {
+				seenFuncs[n.name] = true
+				funcs = append(funcs, n.name)
+			}
+		}
+	})
+	return vars, funcs
+}
+
+// Eval evaluates the expression in env
+func (e *Expr) Eval(env *Env) (Value, error) {
+	return eval(e.root, env)
+}
+
+// EvalNumber evaluates the expression and requires the result to be a number
+func (e *Expr) EvalNumber(env *Env) (*big.Rat, error) {
+	v, err := e.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := v.(*big.Rat)
+	if !ok {
+		return nil, fmt.Errorf("expression is a %s, not a number", typeName(v))
+	}
+	return n, nil
+}
+
+// Number converts a float to a number value through its shortest decimal form, so
+// 0.1 is exactly one tenth
+func Number(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		return new(big.Rat)
+	}
+	return r
+}
+
+// ---- lexer ----
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "+", "-", "*", "/", "!"}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c >= '0' && c <= '9' || c == '.':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: src[start:i], pos: start})
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] >= 'a' && src[i] <= 'z' || src[i] >= 'A' && src[i] <= 'Z' || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: src[start:i], pos: start})
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var sb strings.Builder
+			for {
+				if i >= len(src) {
+					return nil, &SyntaxError{Pos: start, Msg: "unterminated string"}
+				}
+				if src[i] == c {
+					i++
+					break
+				}
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			tokens = append(tokens, token{kind: tokString, text: sb.String(), pos: start})
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, token{kind: tokComma, text: ",", pos: i})
+			i++
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+// ---- parser ----
+
+type node interface{}
+
+type numberNode struct{ value *big.Rat }
+type stringNode struct{ value string }
+type boolNode struct{ value bool }
+type identNode struct {
+	name string
+	pos  int
+}
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+type unaryNode struct {
+	op      string
+	operand node
+}
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// precedence of the binary operators, loosest first
+var precedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5,
+}
+
+type parser struct {
+	tokens []token
+	i      int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *parser) enter(pos int) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &SyntaxError{Pos: pos, Msg: "expression is nested too deeply"}
+	}
+	return nil
+}
+
+// parseExpr parses binary operators binding tighter than minPrec by precedence
+// climbing
+func (p *parser) parseExpr(minPrec int) (node, error) {
+	if err := p.enter(p.peek().pos); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		prec, ok := precedence[tok.text]
+		if tok.kind != tokOp || !ok || prec <= minPrec {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseExpr(prec)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	tok := p.peek()
+	if tok.kind == tokOp && (tok.text == "-" || tok.text == "!") {
+		p.next()
+		if err := p.enter(tok.pos); err != nil {
+			return nil, err
+		}
+		defer func() { p.depth-- }()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: tok.text, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		r, ok := new(big.Rat).SetString(tok.text)
+		if !ok {
+			return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("invalid number %q", tok.text)}
+		}
+		return &numberNode{value: r}, nil
+	case tokString:
+		return &stringNode{value: tok.text}, nil
+	case tokIdent:
+		switch tok.text {
+		case "true":
+			return &boolNode{value: true}, nil
+		case "false":
+			return &boolNode{value: false}, nil
+		}
+		if p.peek().kind != tokLParen {
+			return &identNode{name: tok.text, pos: tok.pos}, nil
+		}
+		p.next()
+		call := &callNode{name: tok.text, pos: tok.pos}
+		if p.peek().kind == tokRParen {
+			p.next()
+			return call, nil
+		}
+		for {
+			arg, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			sep := p.next()
+			if sep.kind == tokRParen {
+				return call, nil
+			}
+			if sep.kind != tokComma {
+				return nil, &SyntaxError{Pos: sep.pos, Msg: fmt.Sprintf("expected \",\" or \")\", found %s", sep)}
+			}
+		}
+	case tokLParen:
+		inner, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &SyntaxError{Pos: closing.pos, Msg: fmt.Sprintf("expected \")\", found %s", closing)}
+		}
+		return inner, nil
+	}
+	return nil, &SyntaxError{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %s", tok)}
+}
+
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *callNode:
+		for _, arg := range n.args {
+			walk(arg, fn)
+		}
+	case *unaryNode:
+		walk(n.operand, fn)
+	case *binaryNode:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	}
+}
+
+// ---- evaluation ----
+
+func eval(n node, env *Env) (Value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return n.value, nil
+	case *stringNode:
+		return n.value, nil
+	case *boolNode:
+		return n.value, nil
+	case *identNode:
+		v, ok := env.Vars[n.name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q", n.name)
+		}
+		return v, nil
+	case *unaryNode:
+		v, err := eval(n.operand, env)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "!" {
+			b, err := asBool(v, "!")
+			if err != nil {
+				return nil, err
+			}
+			return !b, nil
+		}
+		r, err := asNumber(v, "-")
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Rat).Neg(r), nil
+	case *binaryNode:
+		return evalBinary(n, env)
+	case *callNode:
+		return evalCall(n, env)
+	}
+	return nil, fmt.Errorf("invalid expression")
+}
+
+func evalBinary(n *binaryNode, env *Env) (Value, error) {
+	left, err := eval(n.left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || short-circuit
+	if n.op == "&&" || n.op == "||" {
+		l, err := asBool(left, n.op)
+		if err != nil {
+			return nil, err
+		}
+		if l == (n.op == "||") {
+			return l, nil
+		}
+		right, err := eval(n.right, env)
+		if err != nil {
+			return nil, err
+		}
+		return asBool(right, n.op)
+	}
+
+	right, err := eval(n.right, env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "==" || n.op == "!=" {
+		eq, err := equal(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return eq == (n.op == "=="), nil
+	}
+
+	l, err := asNumber(left, n.op)
+	if err != nil {
+		return nil, err
+	}
+	r, err := asNumber(right, n.op)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "+":
+		return new(big.Rat).Add(l, r), nil
+	case "-":
+		return new(big.Rat).Sub(l, r), nil
+	case "*":
+		return new(big.Rat).Mul(l, r), nil
+	case "/":
+		if r.Sign() == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return new(big.Rat).Quo(l, r), nil
+	case "<":
+		return l.Cmp(r) < 0, nil
+	case "<=":
+		return l.Cmp(r) <= 0, nil
+	case ">":
+		return l.Cmp(r) > 0, nil
+	case ">=":
+		return l.Cmp(r) >= 0, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func evalCall(n *callNode, env *Env) (Value, error) {
+	// if evaluates only the branch it takes, so the other may be invalid, e.g.
+	// if(has("width"), config("width"), 0)
+	if n.name == "if" {
+		if len(n.args) != 3 {
+			return nil, fmt.Errorf("if takes 3 arguments, got %d", len(n.args))
+		}
+		c, err := eval(n.args[0], env)
+		if err != nil {
+			return nil, err
+		}
+		cond, err := asBool(c, "if")
+		if err != nil {
+			return nil, err
+		}
+		if cond {
+			return eval(n.args[1], env)
+		}
+		return eval(n.args[2], env)
+	}
+
+	fn, ok := env.Funcs[n.name]
+	if !ok {
+		fn, ok = builtins[n.name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", n.name)
+	}
+	args := make([]Value, len(n.args))
+	for i, arg := range n.args {
+		v, err := eval(arg, env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	v, err := fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return v, nil
+}
+
+func equal(a, b Value) (bool, error) {
+	switch a := a.(type) {
+	case *big.Rat:
+		if b, ok := b.(*big.Rat); ok {
+			return a.Cmp(b) == 0, nil
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return a == b, nil
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			return a == b, nil
+		}
+	}
+	return false, fmt.Errorf("cannot compare %s with %s", typeName(a), typeName(b))
+}
+
+func asNumber(v Value, op string) (*big.Rat, error) {
+	if r, ok := v.(*big.Rat); ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%s needs a number, got %s", op, typeName(v))
+}
+
+func asBool(v Value, op string) (bool, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("%s needs true or false, got %s", op, typeName(v))
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case *big.Rat:
+		return "number"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return "nothing"
+}
+
+// ---- built-in functions ----
+
+var builtins = map[string]Func{
+	"min":   minMax(-1),
+	"max":   minMax(1),
+	"round": round,
+	"ceil":  roundWith(true),
+	"floor": roundWith(false),
+}
+
+func minMax(sign int) Func {
+	return func(args []Value) (Value, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("needs at least one argument")
+		}
+		var best *big.Rat
+		for _, arg := range args {
+			r, err := asNumber(arg, "argument")
+			if err != nil {
+				return nil, err
+			}
+			if best == nil || r.Cmp(best) == sign {
+				best = r
+			}
+		}
+		return best, nil
+	}
+}
+
+// round rounds to the given number of decimal places, default 0, half to even
+func round(args []Value) (Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("takes 1 or 2 arguments, got %d", len(args))
+	}
+	x, err := asNumber(args[0], "argument")
+	if err != nil {
+		return nil, err
+	}
+	places := int64(0)
+	if len(args) == 2 {
+		p, err := asNumber(args[1], "places")
+		if err != nil {
+			return nil, err
+		}
+		if !p.IsInt() || p.Sign() < 0 || p.Num().Int64() > 10 {
+			return nil, fmt.Errorf("places must be a whole number from 0 to 10")
+		}
+		places = p.Num().Int64()
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(places), nil)
+	scaled := new(big.Rat).Mul(x, new(big.Rat).SetInt(scale))
+
+	num, den := scaled.Num(), scaled.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	if c := twice.Cmp(den); c > 0 || c == 0 && q.Bit(0) == 1 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	return new(big.Rat).SetFrac(q, scale), nil
+}
+
+// roundWith returns ceil when up is set and floor otherwise
+func roundWith(up bool) Func {
+	return func(args []Value) (Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("takes 1 argument, got %d", len(args))
+		}
+		x, err := asNumber(args[0], "argument")
+		if err != nil {
+			return nil, err
+		}
+		// Euclidean division keeps the remainder non-negative, which floors for a
+		// positive denominator
+		q, rem := new(big.Int).DivMod(x.Num(), x.Denom(), new(big.Int))
+		if up && rem.Sign() > 0 {
+			q.Add(q, big.NewInt(1))
+		}
+		return new(big.Rat).SetInt(q), nil
+	}
+}
@@ -0,0 +1,256 @@
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func testEnv() *Env {
+	return &Env{
+		Vars: map[string]Value{
+			"area":   big.NewRat(3, 2),
+			"qty":    big.NewRat(100, 1),
+			"base":   big.NewRat(500, 1),
+			"finish": "gloss",
+			"rush":   true,
+		},
+		Funcs: map[string]Func{
+			"option": func(args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("takes 1 argument, got %d", len(args))
+				}
+				return big.NewRat(250, 1), nil
+			},
+		},
+	}
+}
+
+func evalString(t *testing.T, src string) (Value, error) {
+	t.Helper()
+	expr, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return expr.Eval(testEnv())
+}
+
+// show renders a value for comparison, numbers as decimals to 10 places with
+// trailing zeros dropped
+func show(v Value) string {
+	if r, ok := v.(*big.Rat); ok {
+		s := r.FloatString(10)
+		return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+	}
+	return fmt.Sprint(v)
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		// Precedence and associativity
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 - 4 - 3", "3"},
+		{"8 / 4 / 2", "1"},
+		{"2 * 3 + 4 * 5", "26"},
+		{"-2 * 3", "-6"},
+		{"2 - -1", "3"},
+		{"--4", "4"},
+		{"1 + 2 > 2 && 3 < 4", "true"},
+		{"true || false && false", "true"},
+		{"(true || false) && false", "false"},
+		{"!false && true", "true"},
+		{"!(1 < 2)", "false"},
+		{"1 < 2 == true", "true"},
+		{"1 + 1 == 2", "true"},
+
+		// Exact decimals
+		{"0.1 + 0.2 == 0.3", "true"},
+		{"1 / 3 * 3", "1"},
+		{"10 / 4", "2.5"},
+
+		// Variables, strings and functions
+		{"area * 850", "1275"},
+		{"finish == 'gloss'", "true"},
+		{`finish != "matte"`, "true"},
+		{"if(rush, 2, 1) * base", "1000"},
+		{"if(finish == 'matte', 1.2, 1) * area * 850 + option('paper')", "1525"},
+		{"min(3, 1, 2)", "1"},
+		{"max(3, 1, 2)", "3"},
+		{"round(2.5)", "2"},
+		{"round(3.5)", "4"},
+		{"round(-2.5)", "-2"},
+		{"round(1.005, 2)", "1"},
+		{"round(1.015, 2)", "1.02"},
+		{"ceil(1.1)", "2"},
+		{"ceil(-1.1)", "-1"},
+		{"floor(-1.1)", "-2"},
+		{"floor(qty / 3)", "33"},
+
+		// if only evaluates the branch it takes
+		{"if(true, 1, missing)", "1"},
+		{"if(false, 1 / 0, 2)", "2"},
+		{"false && missing", "false"},
+		{"true || 1 / 0 > 0", "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := evalString(t, tt.src)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if show(got) != tt.want {
+				t.Errorf("= %s, want %s", show(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"1 / 0", "division by zero"},
+		{"base / (qty - 100)", "division by zero"},
+		{"width * 2", `unknown variable "width"`},
+		{"lamination(1)", `unknown function "lamination"`},
+		{"1 + 'a'", "+ needs a number, got string"},
+		{"!1", "! needs true or false, got number"},
+		{"1 && true", "&& needs true or false, got number"},
+		{"1 == 'a'", "cannot compare number with string"},
+		{"if(1, 2, 3)", "if needs true or false, got number"},
+
+		// Function arity
+		{"if(true, 1)", "if takes 3 arguments, got 2"},
+		{"if(true, 1, 2, 3)", "if takes 3 arguments, got 4"},
+		{"min()", "min: needs at least one argument"},
+		{"round()", "round: takes 1 or 2 arguments, got 0"},
+		{"round(1, 2, 3)", "round: takes 1 or 2 arguments, got 3"},
+		{"round(1, 11)", "round: places must be a whole number from 0 to 10"},
+		{"round(1, 0.5)", "round: places must be a whole number from 0 to 10"},
+		{"ceil(1, 2)", "ceil: takes 1 argument, got 2"},
+		{"floor()", "floor: takes 1 argument, got 0"},
+		{"option()", "option: takes 1 argument, got 0"},
+		{"max('a')", "max: argument needs a number, got string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			got, err := evalString(t, tt.src)
+			if err == nil {
+				t.Fatalf("= %s, want error %q", show(got), tt.want)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"1 +", 3, "unexpected end of expression"},
+		{"(1 + 2", 6, `expected ")", found end of expression`},
+		{"1 2", 2, `unexpected "2"`},
+		{"min(1 2)", 6, `expected "," or ")", found "2"`},
+		{"1 # 2", 2, `unexpected character '#'`},
+		{"'open", 0, "unterminated string"},
+		{"1..2", 0, `invalid number "1..2"`},
+		{")", 0, `unexpected ")"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("error = %v, want a SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+				t.Errorf("error at %d %q, want at %d %q", syntaxErr.Pos, syntaxErr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("(", n) + "1" + strings.Repeat(")", n)
+	}
+	long := strings.Repeat("1+", MaxLength/2-1) + "1"
+
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"longest allowed", long, ""},
+		{"too long", long + "+1", fmt.Sprintf("position %d: expression is longer than %d characters", MaxLength+1, MaxLength)},
+		// The top level counts as one level of nesting
+		{"deepest parentheses", nested(maxDepth - 1), ""},
+		{"parentheses too deep", nested(maxDepth), "expression is nested too deeply"},
+		{"unary operators too deep", strings.Repeat("-", maxDepth) + "1", "expression is nested too deeply"},
+		{"calls too deep", strings.Repeat("min(", maxDepth) + "1" + strings.Repeat(")", maxDepth), "expression is nested too deeply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.src) > MaxLength && tt.wantErr == "" {
+				t.Fatalf("test expression is %d characters", len(tt.src))
+			}
+			expr, err := Parse(tt.src)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error: %v", err)
+				}
+				if _, err := expr.EvalNumber(testEnv()); err != nil {
+					t.Errorf("eval error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	expr, err := Parse("if(has('w'), config('w'), area) * area + min(qty, round(base)) + has('x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars, funcs := expr.Names()
+	if strings.Join(vars, ",") != "area,qty,base" {
+		t.Errorf("vars = %v, want area, qty, base", vars)
+	}
+	if strings.Join(funcs, ",") != "has,config" {
+		t.Errorf("funcs = %v, want has, config without built-ins", funcs)
+	}
+}
+
+func TestEvalNumberRejectsOtherTypes(t *testing.T) {
+	expr, err := Parse("finish")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expr.EvalNumber(testEnv()); err == nil || err.Error() != "expression is a string, not a number" {
+		t.Errorf("error = %v, want not a number", err)
+	}
+}
+
+func TestNumber(t *testing.T) {
+	if got := Number(0.1); got.Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("Number(0.1) = %s, want exactly 1/10", got.RatString())
+	}
+	if got := Number(1234.5); got.Cmp(big.NewRat(2469, 2)) != 0 {
+		t.Errorf("Number(1234.5) = %s", got.RatString())
+	}
+}
@@ -78,22 +78,22 @@ func (h *AdminHandler) GetCustomers(c *gin.Context) {
 
 	// Create response with order stats for each customer
 	type CustomerWithStats struct {
-		ID          uuid.UUID   `json:"id"`
-		Email       string      `json:"email"`
-		FirstName   string      `json:"firstName"`
-		LastName    string      `json:"lastName"`
-		Phone       string      `json:"phone,omitempty"`
-		Role        string      `json:"role"`
-		CreatedAt   interface{} `json:"createdAt"`
-		TotalOrders int         `json:"totalOrders"`
-		TotalSpent  float64     `json:"totalSpent"`
+		ID          uuid.UUID    `json:"id"`
+		Email       string       `json:"email"`
+		FirstName   string       `json:"firstName"`
+		LastName    string       `json:"lastName"`
+		Phone       string       `json:"phone,omitempty"`
+		Role        string       `json:"role"`
+		CreatedAt   interface{}  `json:"createdAt"`
+		TotalOrders int          `json:"totalOrders"`
+		TotalSpent  models.Money `json:"totalSpent"`
 	}
 
 	var customersWithStats []CustomerWithStats
 	for _, user := range users {
 		stats, _ := h.orderRepo.GetUserOrderStats(ctx, user.ID)
 		totalOrders := 0
-		totalSpent := 0.0
+		var totalSpent models.Money
 		if stats != nil {
 			totalOrders = stats.TotalOrders
 			totalSpent = stats.TotalSpent
@@ -123,7 +123,7 @@ func (h *AdminHandler) GetDashboardStats(c *gin.Context) {
 	ordersByStatus, _ := h.reportRepo.GetOrdersByStatus(ctx)
 
 	var todayOrders int
-	var todaySales float64
+	var todaySales models.Money
 	if len(dailySales) > 0 {
 		todayOrders = dailySales[0].OrderCount
 		todaySales = dailySales[0].TotalSales
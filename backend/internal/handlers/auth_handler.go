@@ -11,6 +11,7 @@ import (
 	"github.com/pquerna/otp/totp"
 	"github.com/quikprint/backend/internal/models"
 	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
 	"github.com/quikprint/backend/internal/utils"
 )
 
@@ -18,10 +19,11 @@ type AuthHandler struct {
 	userRepo   *repository.UserRepository
 	orderRepo  *repository.OrderRepository
 	jwtManager *utils.JWTManager
+	notifier   *services.NotificationService
 }
 
-func NewAuthHandler(userRepo *repository.UserRepository, orderRepo *repository.OrderRepository, jwtManager *utils.JWTManager) *AuthHandler {
-	return &AuthHandler{userRepo: userRepo, orderRepo: orderRepo, jwtManager: jwtManager}
+func NewAuthHandler(userRepo *repository.UserRepository, orderRepo *repository.OrderRepository, jwtManager *utils.JWTManager, notifier *services.NotificationService) *AuthHandler {
+	return &AuthHandler{userRepo: userRepo, orderRepo: orderRepo, jwtManager: jwtManager, notifier: notifier}
 }
 
 func (h *AuthHandler) Register(c *gin.Context) {
@@ -81,6 +83,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		ExpiresAt: expiresAt,
 	})
 
+	h.notifier.UserRegistered(user.ID)
+
 	utils.SuccessResponse(c, 201, models.AuthResponse{
 		User: models.UserProfile{
 			ID:               user.ID,
@@ -325,7 +329,7 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 	// Get user order stats
 	orderStats, _ := h.orderRepo.GetUserOrderStats(ctx, userID)
 	if orderStats == nil {
-		orderStats = &models.UserOrderStats{TotalOrders: 0, TotalSpent: 0}
+		orderStats = &models.UserOrderStats{}
 	}
 
 	utils.SuccessResponse(c, 200, models.UserProfile{
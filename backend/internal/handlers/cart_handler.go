@@ -35,11 +35,11 @@ func (h *CartHandler) GetCart(c *gin.Context) {
 	}
 
 	// Populate product info
-	var subtotal float64
+	var subtotal models.Money
 	for i := range items {
 		product, _ := h.productRepo.GetByID(ctx, items[i].ProductID)
 		items[i].Product = product
-		subtotal += items[i].TotalPrice
+		subtotal = subtotal.Add(items[i].TotalPrice)
 	}
 
 	cart := models.Cart{
@@ -74,8 +74,12 @@ func (h *CartHandler) AddItem(c *gin.Context) {
 		ProductID:     req.ProductID,
 		Configuration: req.Configuration,
 		Quantity:      req.Quantity,
+		UserID:        userID,
 	}
 	breakdown, err := h.pricingService.CalculatePrice(ctx, priceReq)
+	if respondPricingInputError(c, err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, 500, "Failed to calculate price")
 		return
@@ -132,11 +136,21 @@ func (h *CartHandler) UpdateItem(c *gin.Context) {
 		ProductID:     item.ProductID,
 		Configuration: item.Configuration,
 		Quantity:      item.Quantity,
+		UserID:        userID,
+	}
+	breakdown, err := h.pricingService.CalculatePrice(ctx, priceReq)
+	if respondPricingInputError(c, err) {
+		return
 	}
-	breakdown, _ := h.pricingService.CalculatePrice(ctx, priceReq)
-	if breakdown != nil {
-		item.TotalPrice = breakdown.Total
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to calculate price")
+		return
+	}
+	if breakdown == nil {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
 	}
+	item.TotalPrice = breakdown.Total
 
 	if err := h.cartRepo.UpdateItem(ctx, item); err != nil {
 		utils.ErrorResponse(c, 500, "Failed to update cart item")
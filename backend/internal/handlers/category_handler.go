@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -63,11 +65,25 @@ func (h *CategoryHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if req.ParentID != nil {
+		parent, err := h.categoryRepo.GetByID(ctx, *req.ParentID)
+		if err != nil {
+			utils.ErrorResponse(c, 500, "Failed to fetch parent category")
+			return
+		}
+		if parent == nil {
+			utils.ValidationErrorResponse(c, "Parent category not found")
+			return
+		}
+	}
+
 	category := &models.Category{
 		Name:        req.Name,
 		Slug:        req.Slug,
 		Description: req.Description,
 		Image:       req.Image,
+		TaxExempt:   req.TaxExempt,
+		ParentID:    req.ParentID,
 	}
 
 	if err := h.categoryRepo.Create(ctx, category); err != nil {
@@ -111,13 +127,99 @@ func (h *CategoryHandler) Update(c *gin.Context) {
 	if req.Image != nil {
 		category.Image = *req.Image
 	}
+	if req.TaxExempt != nil {
+		category.TaxExempt = *req.TaxExempt
+	}
+	if req.MoveToTop {
+		category.ParentID = nil
+	} else if req.ParentID != nil {
+		parent, err := h.categoryRepo.GetByID(ctx, *req.ParentID)
+		if err != nil {
+			utils.ErrorResponse(c, 500, "Failed to fetch parent category")
+			return
+		}
+		if parent == nil {
+			utils.ValidationErrorResponse(c, "Parent category not found")
+			return
+		}
+		category.ParentID = req.ParentID
+	}
 
 	if err := h.categoryRepo.Update(ctx, category); err != nil {
+		if errors.Is(err, models.ErrCategoryCycle) {
+			utils.ErrorResponse(c, 400, err.Error())
+			return
+		}
+		fmt.Printf("ERROR: Failed to update category %s: %v\n", id, err)
 		utils.ErrorResponse(c, 500, "Failed to update category")
 		return
 	}
 
-	utils.SuccessResponse(c, 200, category)
+	// Reload for the new breadcrumb path after a move
+	updated, err := h.categoryRepo.GetByID(ctx, id)
+	if err != nil || updated == nil {
+		utils.SuccessResponse(c, 200, category)
+		return
+	}
+	utils.SuccessResponse(c, 200, updated)
+}
+
+// Reorder moves categories under a parent, or to the top level, in the order
+// given, for drag-and-drop in the category tree
+func (h *CategoryHandler) Reorder(c *gin.Context) {
+	var req models.ReorderCategoriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	seen := make(map[uuid.UUID]bool, len(req.CategoryIDs))
+	for _, id := range req.CategoryIDs {
+		if seen[id] {
+			utils.ValidationErrorResponse(c, "Category IDs must not repeat")
+			return
+		}
+		seen[id] = true
+	}
+
+	ctx := context.Background()
+
+	if req.ParentID != nil {
+		parent, err := h.categoryRepo.GetByID(ctx, *req.ParentID)
+		if err != nil {
+			utils.ErrorResponse(c, 500, "Failed to fetch parent category")
+			return
+		}
+		if parent == nil {
+			utils.ValidationErrorResponse(c, "Parent category not found")
+			return
+		}
+	}
+
+	found, err := h.categoryRepo.Reorder(ctx, req.ParentID, req.CategoryIDs)
+	if err != nil {
+		if errors.Is(err, models.ErrCategoryCycle) {
+			utils.ErrorResponse(c, 400, err.Error())
+			return
+		}
+		fmt.Printf("ERROR: Failed to reorder categories: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to reorder categories")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "One or more categories not found")
+		return
+	}
+
+	categories, err := h.categoryRepo.GetAll(ctx)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch categories")
+		return
+	}
+	if categories == nil {
+		categories = []models.Category{}
+	}
+	utils.SuccessResponse(c, 200, categories)
 }
 
 func (h *CategoryHandler) Delete(c *gin.Context) {
@@ -127,12 +229,51 @@ func (h *CategoryHandler) Delete(c *gin.Context) {
 		return
 	}
 
+	// Subcategories and products are moved to reassignTo; without it a category
+	// that has any cannot be deleted
+	var reassignTo *uuid.UUID
+	if raw := c.Query("reassignTo"); raw != "" {
+		target, err := uuid.Parse(raw)
+		if err != nil {
+			utils.ValidationErrorResponse(c, "Invalid reassignTo category ID")
+			return
+		}
+		reassignTo = &target
+	}
+
 	ctx := context.Background()
 
-	if err := h.categoryRepo.Delete(ctx, id); err != nil {
+	if reassignTo != nil {
+		target, err := h.categoryRepo.GetByID(ctx, *reassignTo)
+		if err != nil {
+			utils.ErrorResponse(c, 500, "Failed to fetch category")
+			return
+		}
+		if target == nil {
+			utils.ValidationErrorResponse(c, "reassignTo category not found")
+			return
+		}
+	}
+
+	found, err := h.categoryRepo.Delete(ctx, id, reassignTo)
+	if err != nil {
+		var inUse *models.CategoryInUseError
+		if errors.As(err, &inUse) {
+			utils.ErrorResponseWithData(c, 409, inUse.Error(), inUse)
+			return
+		}
+		if errors.Is(err, models.ErrCategoryCycle) {
+			utils.ErrorResponse(c, 400, "Cannot reassign to the category being deleted or one of its subcategories")
+			return
+		}
+		fmt.Printf("ERROR: Failed to delete category %s: %v\n", id, err)
 		utils.ErrorResponse(c, 500, "Failed to delete category")
 		return
 	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Category not found")
+		return
+	}
 
 	utils.SuccessMessageResponse(c, 200, "Category deleted successfully")
 }
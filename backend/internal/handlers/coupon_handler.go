@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"math/big"
 	"strings"
 	"time"
 
@@ -60,6 +61,11 @@ func (h *CouponHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if msg := checkCouponDiscount(req.DiscountType, req.DiscountValue); msg != "" {
+		utils.ValidationErrorResponse(c, msg)
+		return
+	}
+
 	ctx := context.Background()
 
 	// Check if code already exists
@@ -130,6 +136,10 @@ func (h *CouponHandler) Update(c *gin.Context) {
 	if req.DiscountValue != nil {
 		coupon.DiscountValue = *req.DiscountValue
 	}
+	if msg := checkCouponDiscount(coupon.DiscountType, coupon.DiscountValue); msg != "" {
+		utils.ValidationErrorResponse(c, msg)
+		return
+	}
 	if req.MinOrderAmount != nil {
 		coupon.MinOrderAmount = *req.MinOrderAmount
 	}
@@ -182,8 +192,8 @@ func (h *CouponHandler) Apply(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
 	type applyRequest struct {
-		Code        string  `json:"code" binding:"required"`
-		OrderAmount float64 `json:"orderAmount" binding:"required,gt=0"`
+		Code        string       `json:"code" binding:"required"`
+		OrderAmount models.Money `json:"orderAmount" binding:"required,gt=0"`
 	}
 
 	var req applyRequest
@@ -200,31 +210,9 @@ func (h *CouponHandler) Apply(c *gin.Context) {
 		return
 	}
 
-	now := time.Now()
-
-	if !coupon.IsActive {
-		utils.ErrorResponse(c, 400, "Coupon is not active")
-		return
-	}
-
-	if coupon.ValidFrom.After(now) {
-		utils.ErrorResponse(c, 400, "Coupon is not yet valid")
-		return
-	}
-
-	if coupon.ValidUntil != nil && coupon.ValidUntil.Before(now) {
-		utils.ErrorResponse(c, 400, "Coupon has expired")
-		return
-	}
-
-	if req.OrderAmount < coupon.MinOrderAmount {
-		utils.ErrorResponse(c, 400, "Order total does not meet the minimum amount for this coupon")
-		return
-	}
-
-	// Global usage limit
-	if coupon.UsageLimit != nil && coupon.UsedCount >= *coupon.UsageLimit {
-		utils.ErrorResponse(c, 400, "Coupon usage limit has been reached")
+	// Preview only: the coupon is re-validated and redeemed when the order is created
+	if err := coupon.CheckValidity(time.Now(), req.OrderAmount); err != nil {
+		utils.ErrorResponse(c, 400, err.Error())
 		return
 	}
 
@@ -236,27 +224,14 @@ func (h *CouponHandler) Apply(c *gin.Context) {
 			return
 		}
 		if usageCount >= coupon.PerUserLimit {
-			utils.ErrorResponse(c, 400, "You have already used this coupon the maximum allowed times")
+			utils.ErrorResponse(c, 400, models.ErrCouponPerUserLimit.Error())
 			return
 		}
 	}
 
-	// Calculate discount
-	var discount float64
-	switch coupon.DiscountType {
-	case models.DiscountTypeFixed:
-		discount = coupon.DiscountValue
-	case models.DiscountTypePercentage:
-		discount = (req.OrderAmount * coupon.DiscountValue) / 100
-	}
-
-	// Apply maximum discount cap if set
-	if coupon.MaxDiscountAmount != nil && discount > *coupon.MaxDiscountAmount {
-		discount = *coupon.MaxDiscountAmount
-	}
-
-	if discount <= 0 {
-		utils.ErrorResponse(c, 400, "Coupon does not provide a discount for this order amount")
+	discount := coupon.DiscountFor(req.OrderAmount)
+	if !discount.IsPositive() {
+		utils.ErrorResponse(c, 400, models.ErrCouponNoDiscount.Error())
 		return
 	}
 
@@ -268,3 +243,21 @@ func (h *CouponHandler) Apply(c *gin.Context) {
 
 	utils.SuccessResponse(c, 200, response)
 }
+
+// checkCouponDiscount returns why a coupon's discount is invalid, or "" if it is
+// valid: it must be positive, and a percentage at most 100
+func checkCouponDiscount(discountType models.DiscountType, value models.DiscountValue) string {
+	if !value.IsPositive() {
+		return "Discount value must be greater than 0"
+	}
+	switch discountType {
+	case models.DiscountTypeFixed:
+	case models.DiscountTypePercentage:
+		if value.Percent().Cmp(big.NewRat(100, 1)) > 0 {
+			return "A percentage discount cannot be more than 100%"
+		}
+	default:
+		return "Discount type must be percentage or fixed"
+	}
+	return ""
+}
@@ -0,0 +1,473 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type CustomerGroupHandler struct {
+	groupRepo    *repository.CustomerGroupRepository
+	userRepo     *repository.UserRepository
+	categoryRepo *repository.CategoryRepository
+	productRepo  *repository.ProductRepository
+}
+
+func NewCustomerGroupHandler(groupRepo *repository.CustomerGroupRepository, userRepo *repository.UserRepository, categoryRepo *repository.CategoryRepository, productRepo *repository.ProductRepository) *CustomerGroupHandler {
+	return &CustomerGroupHandler{
+		groupRepo:    groupRepo,
+		userRepo:     userRepo,
+		categoryRepo: categoryRepo,
+		productRepo:  productRepo,
+	}
+}
+
+func (h *CustomerGroupHandler) GetAll(c *gin.Context) {
+	groups, err := h.groupRepo.GetAll(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch customer groups")
+		return
+	}
+	if groups == nil {
+		groups = []models.CustomerGroup{}
+	}
+	utils.SuccessResponse(c, 200, groups)
+}
+
+func (h *CustomerGroupHandler) GetByID(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+	utils.SuccessResponse(c, 200, group)
+}
+
+func (h *CustomerGroupHandler) Create(c *gin.Context) {
+	var req models.CustomerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	group := &models.CustomerGroup{Name: strings.TrimSpace(req.Name), Description: strings.TrimSpace(req.Description)}
+
+	existing, err := h.groupRepo.GetByName(ctx, group.Name)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to create customer group")
+		return
+	}
+	if existing != nil {
+		utils.ErrorResponse(c, 409, "A customer group with this name already exists")
+		return
+	}
+
+	if err := h.groupRepo.Create(ctx, group); err != nil {
+		fmt.Printf("ERROR: Failed to create customer group: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to create customer group")
+		return
+	}
+	utils.SuccessResponse(c, 201, group)
+}
+
+func (h *CustomerGroupHandler) Update(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+
+	var req models.CustomerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	group := &models.CustomerGroup{ID: id, Name: strings.TrimSpace(req.Name), Description: strings.TrimSpace(req.Description)}
+
+	existing, err := h.groupRepo.GetByName(ctx, group.Name)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update customer group")
+		return
+	}
+	if existing != nil && existing.ID != id {
+		utils.ErrorResponse(c, 409, "A customer group with this name already exists")
+		return
+	}
+
+	found, err := h.groupRepo.Update(ctx, group)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to update customer group: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to update customer group")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Customer group not found")
+		return
+	}
+
+	group, err = h.groupRepo.GetByID(ctx, id)
+	if err != nil || group == nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch customer group")
+		return
+	}
+	utils.SuccessResponse(c, 200, group)
+}
+
+// Delete deletes a group with its price list. Its members go back to catalogue prices.
+func (h *CustomerGroupHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+
+	found, err := h.groupRepo.Delete(context.Background(), id)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete customer group")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Customer group not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Customer group deleted successfully")
+}
+
+func (h *CustomerGroupHandler) GetMembers(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.groupRepo.GetMembers(context.Background(), group.ID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch group members")
+		return
+	}
+	if members == nil {
+		members = []models.CustomerGroupMember{}
+	}
+	utils.SuccessResponse(c, 200, members)
+}
+
+// AddMember puts a customer in the group. A customer is in at most one group, so
+// this moves them out of any group they were in.
+func (h *CustomerGroupHandler) AddMember(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	var req models.AddCustomerGroupMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.userRepo.GetByID(ctx, req.UserID)
+	if err != nil || user == nil {
+		utils.ErrorResponse(c, 404, "User not found")
+		return
+	}
+
+	adminID := c.MustGet("userID").(uuid.UUID)
+	if err := h.groupRepo.AddMember(ctx, group.ID, user.ID, adminID); err != nil {
+		fmt.Printf("ERROR: Failed to add group member: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to add group member")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Customer added to group")
+}
+
+func (h *CustomerGroupHandler) RemoveMember(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid user ID")
+		return
+	}
+
+	found, err := h.groupRepo.RemoveMember(context.Background(), groupID, userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to remove group member")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Customer is not in this group")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Customer removed from group")
+}
+
+func (h *CustomerGroupHandler) GetPriceList(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+
+	list, err := h.groupRepo.GetPriceList(context.Background(), group.ID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price list")
+		return
+	}
+	utils.SuccessResponse(c, 200, list)
+}
+
+// SetCategoryDiscount gives the group a percentage off every product in a
+// category that it has no fixed price or tiers for
+func (h *CustomerGroupHandler) SetCategoryDiscount(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+	categoryID, err := uuid.Parse(c.Param("categoryId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid category ID")
+		return
+	}
+
+	var req models.GroupCategoryDiscountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	category, err := h.categoryRepo.GetByID(ctx, categoryID)
+	if err != nil || category == nil {
+		utils.ErrorResponse(c, 404, "Category not found")
+		return
+	}
+
+	if err := h.groupRepo.SetCategoryDiscount(ctx, group.ID, categoryID, req.Percent); err != nil {
+		fmt.Printf("ERROR: Failed to set category discount: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to set category discount")
+		return
+	}
+	h.respondPriceList(c, group.ID)
+}
+
+func (h *CustomerGroupHandler) DeleteCategoryDiscount(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+	categoryID, err := uuid.Parse(c.Param("categoryId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid category ID")
+		return
+	}
+
+	found, err := h.groupRepo.DeleteCategoryDiscount(context.Background(), groupID, categoryID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete category discount")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Category discount not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Category discount deleted successfully")
+}
+
+// SetProductPrice gives the group a fixed unit price for a product, in place of
+// its base price and quantity tiers
+func (h *CustomerGroupHandler) SetProductPrice(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var req models.GroupProductPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if !req.Price.IsPositive() {
+		utils.ValidationErrorResponse(c, "price must be greater than zero")
+		return
+	}
+
+	ctx := context.Background()
+	product, err := h.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
+
+	if err := h.groupRepo.SetProductPrice(ctx, group.ID, productID, req.Price); err != nil {
+		fmt.Printf("ERROR: Failed to set group product price: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to set product price")
+		return
+	}
+	h.respondPriceList(c, group.ID)
+}
+
+func (h *CustomerGroupHandler) DeleteProductPrice(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	found, err := h.groupRepo.DeleteProductPrice(context.Background(), groupID, productID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete product price")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Product price not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Product price deleted successfully")
+}
+
+// SetPricingTiers replaces the group's quantity tiers for a product
+func (h *CustomerGroupHandler) SetPricingTiers(c *gin.Context) {
+	group, ok := h.group(c)
+	if !ok {
+		return
+	}
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var reqs []models.CreatePricingTierRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if len(reqs) == 0 {
+		utils.ValidationErrorResponse(c, "At least one tier is required")
+		return
+	}
+	if msg := checkPricingTiers(reqs); msg != "" {
+		utils.ValidationErrorResponse(c, msg)
+		return
+	}
+
+	ctx := context.Background()
+	product, err := h.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
+
+	tiers := make([]models.PricingTier, len(reqs))
+	for i, req := range reqs {
+		tiers[i] = models.PricingTier{MinQty: req.MinQty, MaxQty: req.MaxQty, Price: req.Price}
+	}
+	if err := h.groupRepo.SetPricingTiers(ctx, group.ID, productID, tiers); err != nil {
+		fmt.Printf("ERROR: Failed to set group pricing tiers: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to set pricing tiers")
+		return
+	}
+	utils.SuccessResponse(c, 200, tiers)
+}
+
+func (h *CustomerGroupHandler) DeletePricingTiers(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return
+	}
+	productID, err := uuid.Parse(c.Param("productId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	found, err := h.groupRepo.DeletePricingTiers(context.Background(), groupID, productID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete pricing tiers")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Pricing tiers not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Pricing tiers deleted successfully")
+}
+
+// group loads the group in the URL, responding with an error if there is none
+func (h *CustomerGroupHandler) group(c *gin.Context) (*models.CustomerGroup, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid customer group ID")
+		return nil, false
+	}
+
+	group, err := h.groupRepo.GetByID(context.Background(), id)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch customer group")
+		return nil, false
+	}
+	if group == nil {
+		utils.ErrorResponse(c, 404, "Customer group not found")
+		return nil, false
+	}
+	return group, true
+}
+
+func (h *CustomerGroupHandler) respondPriceList(c *gin.Context, groupID uuid.UUID) {
+	list, err := h.groupRepo.GetPriceList(context.Background(), groupID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price list")
+		return
+	}
+	utils.SuccessResponse(c, 200, list)
+}
+
+// checkPricingTiers returns why a set of quantity tiers is invalid, or "" if it is
+// valid. Each needs a positive price and a range that does not end before it
+// starts, where a maxQty of 0 has no upper limit, and no two ranges may overlap.
+func checkPricingTiers(reqs []models.CreatePricingTierRequest) string {
+	tiers := append([]models.CreatePricingTierRequest(nil), reqs...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinQty < tiers[j].MinQty })
+
+	for i, tier := range tiers {
+		if tier.MinQty < 1 {
+			return "Tier minQty must be at least 1"
+		}
+		if tier.MaxQty != 0 && tier.MaxQty < tier.MinQty {
+			return fmt.Sprintf("Tier from %d ends at %d, before it starts", tier.MinQty, tier.MaxQty)
+		}
+		if !tier.Price.IsPositive() {
+			return fmt.Sprintf("Tier from %d must have a price greater than 0", tier.MinQty)
+		}
+		if i > 0 {
+			prev := tiers[i-1]
+			if prev.MaxQty == 0 || prev.MaxQty >= tier.MinQty {
+				return fmt.Sprintf("Tiers from %d and from %d overlap", prev.MinQty, tier.MinQty)
+			}
+		}
+	}
+	return ""
+}
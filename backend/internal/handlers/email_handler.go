@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/quikprint/backend/internal/models"
 	"github.com/quikprint/backend/internal/repository"
 	"github.com/quikprint/backend/internal/services"
@@ -13,12 +15,14 @@ import (
 type EmailHandler struct {
 	emailService *services.EmailService
 	userRepo     *repository.UserRepository
+	outboxRepo   *repository.EmailOutboxRepository
 }
 
-func NewEmailHandler(emailService *services.EmailService, userRepo *repository.UserRepository) *EmailHandler {
+func NewEmailHandler(emailService *services.EmailService, userRepo *repository.UserRepository, outboxRepo *repository.EmailOutboxRepository) *EmailHandler {
 	return &EmailHandler{
 		emailService: emailService,
 		userRepo:     userRepo,
+		outboxRepo:   outboxRepo,
 	}
 }
 
@@ -42,7 +46,8 @@ func (h *EmailHandler) SendTestEmail(c *gin.Context) {
 		return
 	}
 
-	err := h.emailService.SendEmail(
+	// Deliver directly rather than through the outbox so SMTP errors are reported here
+	err := h.emailService.Deliver(
 		req.Email,
 		"Test Email from QuikPrint",
 		`<html><body>
@@ -62,7 +67,8 @@ func (h *EmailHandler) SendTestEmail(c *gin.Context) {
 	})
 }
 
-// SendBroadcastEmail sends an email to all customers or selected recipients
+// SendBroadcastEmail queues an email to all customers or selected recipients. The
+// response carries the broadcast ID used to follow delivery progress.
 func (h *EmailHandler) SendBroadcastEmail(c *gin.Context) {
 	var req models.BroadcastEmailRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -98,19 +104,85 @@ func (h *EmailHandler) SendBroadcastEmail(c *gin.Context) {
 		return
 	}
 
-	successCount, errors := h.emailService.SendBroadcast(recipients, req.Subject, req.Content)
+	adminID := c.MustGet("userID").(uuid.UUID)
+	broadcast, err := h.emailService.SendBroadcast(recipients, req.Subject, req.Content, &adminID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to queue broadcast: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, 202, gin.H{
+		"message":         fmt.Sprintf("Broadcast queued for %d recipients", broadcast.TotalRecipients),
+		"broadcastId":     broadcast.ID,
+		"totalRecipients": broadcast.TotalRecipients,
+	})
+}
+
+// GetBroadcasts lists recent broadcasts
+func (h *EmailHandler) GetBroadcasts(c *gin.Context) {
+	ctx := context.Background()
+	broadcasts, err := h.outboxRepo.GetBroadcasts(ctx, 50)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch broadcasts")
+		return
+	}
+	if broadcasts == nil {
+		broadcasts = []models.EmailBroadcast{}
+	}
+
+	utils.SuccessResponse(c, 200, broadcasts)
+}
+
+// GetBroadcast returns a broadcast's delivery progress and failed recipients
+func (h *EmailHandler) GetBroadcast(c *gin.Context) {
+	broadcastID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid broadcast ID")
+		return
+	}
+
+	ctx := context.Background()
+	progress, err := h.outboxRepo.GetBroadcastProgress(ctx, broadcastID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch broadcast")
+		return
+	}
+	if progress == nil {
+		utils.ErrorResponse(c, 404, "Broadcast not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, progress)
+}
+
+// ResendBroadcastFailures requeues every dead-lettered message of a broadcast
+func (h *EmailHandler) ResendBroadcastFailures(c *gin.Context) {
+	broadcastID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid broadcast ID")
+		return
+	}
+
+	ctx := context.Background()
+	progress, err := h.outboxRepo.GetBroadcastProgress(ctx, broadcastID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch broadcast")
+		return
+	}
+	if progress == nil {
+		utils.ErrorResponse(c, 404, "Broadcast not found")
+		return
+	}
 
-	var errorMessages []string
-	for _, err := range errors {
-		errorMessages = append(errorMessages, err.Error())
+	requeued, err := h.outboxRepo.RequeueBroadcastFailures(ctx, broadcastID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to requeue failed emails")
+		return
 	}
 
 	utils.SuccessResponse(c, 200, gin.H{
-		"message":      "Broadcast completed",
-		"totalSent":    len(recipients),
-		"successCount": successCount,
-		"failedCount":  len(errors),
-		"errors":       errorMessages,
+		"message":  fmt.Sprintf("Requeued %d failed emails", requeued),
+		"requeued": requeued,
 	})
 }
 
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type NotificationHandler struct {
+	notificationRepo *repository.NotificationRepository
+}
+
+func NewNotificationHandler(notificationRepo *repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{notificationRepo: notificationRepo}
+}
+
+// GetPreferences returns the current user's notification preferences
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	ctx := context.Background()
+	prefs, err := h.notificationRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch notification preferences")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, prefs)
+}
+
+// UpdatePreferences lets the current user opt in or out of order and marketing emails
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req models.UpdateNotificationPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	prefs, err := h.notificationRepo.GetPreferences(ctx, userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch notification preferences")
+		return
+	}
+
+	if req.OrderUpdates != nil {
+		prefs.OrderUpdates = *req.OrderUpdates
+	}
+	if req.Marketing != nil {
+		prefs.Marketing = *req.Marketing
+	}
+
+	if err := h.notificationRepo.SavePreferences(ctx, prefs); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update notification preferences")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, prefs)
+}
+
+// GetLog returns recently dispatched notifications, newest first
+func (h *NotificationHandler) GetLog(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	ctx := context.Background()
+	logs, err := h.notificationRepo.GetLogs(ctx, c.Query("status"), limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch notification log")
+		return
+	}
+	if logs == nil {
+		logs = []models.NotificationLog{}
+	}
+
+	utils.SuccessResponse(c, 200, logs)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -17,7 +19,9 @@ type OrderHandler struct {
 	cartRepo           *repository.CartRepository
 	productRepo        *repository.ProductRepository
 	pricingService     *services.PricingService
+	taxService         *services.TaxService
 	shippingConfigRepo *repository.ShippingConfigRepository
+	notifier           *services.NotificationService
 }
 
 func NewOrderHandler(
@@ -25,14 +29,18 @@ func NewOrderHandler(
 	cartRepo *repository.CartRepository,
 	productRepo *repository.ProductRepository,
 	pricingService *services.PricingService,
+	taxService *services.TaxService,
 	shippingConfigRepo *repository.ShippingConfigRepository,
+	notifier *services.NotificationService,
 ) *OrderHandler {
 	return &OrderHandler{
 		orderRepo:          orderRepo,
 		cartRepo:           cartRepo,
 		productRepo:        productRepo,
 		pricingService:     pricingService,
+		taxService:         taxService,
 		shippingConfigRepo: shippingConfigRepo,
+		notifier:           notifier,
 	}
 }
 
@@ -47,7 +55,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 	ctx := context.Background()
 
-	var subtotal float64
+	var subtotal models.Money
 	var orderItems []models.OrderItem
 
 	// If items are provided in the request, use them; otherwise fall back to the user's cart
@@ -66,29 +74,32 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 				ProductID:     itemReq.ProductID,
 				Configuration: itemReq.Configuration,
 				Quantity:      itemReq.Quantity,
+				UserID:        userID,
 			}
 
-			breakdown, err := h.pricingService.CalculatePrice(ctx, priceReq)
-			if err != nil || breakdown == nil {
+			snapshot, err := h.pricingService.Snapshot(ctx, priceReq)
+			if respondPricingInputError(c, err) {
+				return
+			}
+			if err != nil || snapshot == nil {
 				utils.ErrorResponse(c, 500, "Failed to calculate price for one of the items")
 				return
 			}
 
-			unitPrice := breakdown.Total / float64(itemReq.Quantity)
-			if itemReq.Quantity == 0 {
-				unitPrice = breakdown.Total
-			}
+			total := snapshot.Breakdown.Total
+			unitPrice := total.Div(itemReq.Quantity)
 
-			fmt.Printf("DEBUG: Order item - ProductID=%s, Quantity=%d, UnitPrice=%.2f, TotalPrice=%.2f\n", itemReq.ProductID, itemReq.Quantity, unitPrice, breakdown.Total)
+			fmt.Printf("DEBUG: Order item - ProductID=%s, Quantity=%d, UnitPrice=%s, TotalPrice=%s\n", itemReq.ProductID, itemReq.Quantity, unitPrice, total)
 
 			orderItems = append(orderItems, models.OrderItem{
 				ProductID:     itemReq.ProductID,
 				Quantity:      itemReq.Quantity,
 				Configuration: itemReq.Configuration,
 				UnitPrice:     unitPrice,
-				TotalPrice:    breakdown.Total,
+				TotalPrice:    total,
+				Snapshot:      snapshot,
 			})
-			subtotal += breakdown.Total
+			subtotal = subtotal.Add(total)
 		}
 	} else {
 		// Fallback: use items currently in the user's cart
@@ -99,18 +110,50 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		}
 
 		for _, item := range cartItems {
-			subtotal += item.TotalPrice
+			// Price each item again so the snapshot matches what is charged
+			snapshot, err := h.pricingService.Snapshot(ctx, &models.CalculatePriceRequest{
+				ProductID:     item.ProductID,
+				Configuration: item.Configuration,
+				Quantity:      item.Quantity,
+				UserID:        userID,
+			})
+			if respondPricingInputError(c, err) {
+				return
+			}
+			if err != nil || snapshot == nil {
+				utils.ErrorResponse(c, 500, "Failed to calculate price for one of the items")
+				return
+			}
+
+			total := snapshot.Breakdown.Total
+			subtotal = subtotal.Add(total)
 			orderItems = append(orderItems, models.OrderItem{
 				ProductID:     item.ProductID,
 				Quantity:      item.Quantity,
 				Configuration: item.Configuration,
-				UnitPrice:     item.TotalPrice / float64(item.Quantity),
-				TotalPrice:    item.TotalPrice,
+				UnitPrice:     total.Div(item.Quantity),
+				TotalPrice:    total,
 				UploadedFile:  item.UploadedFile,
+				Snapshot:      snapshot,
 			})
 		}
 	}
 
+	order := &models.Order{
+		UserID:          userID,
+		Status:          models.OrderStatusAwaitingPayment,
+		Items:           orderItems,
+		Subtotal:        subtotal,
+		ShippingAddress: req.ShippingAddress,
+	}
+
+	// Decide how each line is taxed; this can lower the subtotal for exempt customers
+	if err := h.taxService.Prepare(ctx, order); err != nil {
+		fmt.Printf("ERROR: Failed to work out tax: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to calculate tax")
+		return
+	}
+
 	// Fetch shipping configuration from database
 	shippingConfig, err := h.shippingConfigRepo.Get(ctx)
 	if err != nil {
@@ -119,45 +162,16 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	}
 
 	// Calculate shipping using values from database
-	shipping := 0.0
-	if subtotal < shippingConfig.FreeShippingThreshold {
-		shipping = shippingConfig.ShippingFee
-	}
-
-	// Validate discount
-	if req.Discount < 0 {
-		utils.ErrorResponse(c, 400, "Discount cannot be negative")
-		return
-	}
-
-	// Ensure discount doesn't exceed subtotal
-	discount := req.Discount
-	if discount > subtotal {
-		discount = subtotal
-		fmt.Printf("DEBUG: Discount capped from %.2f to %.2f\n", req.Discount, discount)
-	}
-
-	// Calculate total - ensure it's never negative
-	total := subtotal + shipping - discount
-	if total < 0 {
-		total = 0
-	}
+	order.Shipping = shippingConfig.ShippingFor(order.Subtotal)
 
-	fmt.Printf("DEBUG: Order calculation: subtotal=%.2f, shipping=%.2f, discount=%.2f, total=%.2f\n", subtotal, shipping, discount, total)
-
-	order := &models.Order{
-		UserID:          userID,
-		Status:          models.OrderStatusAwaitingPayment,
-		Items:           orderItems,
-		Subtotal:        subtotal,
-		Discount:        discount,
-		Shipping:        shipping,
-		Tax:             0, // VAT removed as requested
-		Total:           total,
-		ShippingAddress: req.ShippingAddress,
-	}
+	fmt.Printf("DEBUG: Order calculation: subtotal=%s, shipping=%s, coupon=%q\n", order.Subtotal, order.Shipping, req.CouponCode)
 
-	if err := h.orderRepo.Create(ctx, order); err != nil {
+	// The repository applies the coupon and works out the tax and total
+	if err := h.orderRepo.Create(ctx, order, strings.TrimSpace(req.CouponCode)); err != nil {
+		if models.IsCouponError(err) {
+			utils.ErrorResponse(c, 400, err.Error())
+			return
+		}
 		fmt.Printf("DEBUG: Order creation error: %v\n", err)
 		utils.ErrorResponse(c, 500, fmt.Sprintf("Failed to create order: %v", err))
 		return
@@ -168,11 +182,7 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 		h.cartRepo.ClearCart(ctx, userID)
 	}
 
-	// Populate product info
-	for i := range order.Items {
-		product, _ := h.productRepo.GetByID(ctx, order.Items[i].ProductID)
-		order.Items[i].Product = product
-	}
+	h.notifier.OrderCreated(order)
 
 	utils.SuccessResponse(c, 201, order)
 }
@@ -214,12 +224,6 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 		return
 	}
 
-	// Populate product info
-	for i := range order.Items {
-		product, _ := h.productRepo.GetByID(ctx, order.Items[i].ProductID)
-		order.Items[i].Product = product
-	}
-
 	utils.SuccessResponse(c, 200, order)
 }
 
@@ -254,9 +258,11 @@ func (h *OrderHandler) GetOrderAdmin(c *gin.Context) {
 		return
 	}
 
+	// Items are described by their snapshots; staff also see the products as
+	// they are now
 	for i := range order.Items {
 		product, _ := h.productRepo.GetByID(ctx, order.Items[i].ProductID)
-		order.Items[i].Product = product
+		order.Items[i].CurrentProduct = product
 	}
 
 	utils.SuccessResponse(c, 200, order)
@@ -274,6 +280,10 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 		utils.ValidationErrorResponse(c, err.Error())
 		return
 	}
+	if !req.Status.IsValid() {
+		utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid order status: %s", req.Status))
+		return
+	}
 
 	ctx := context.Background()
 	order, err := h.orderRepo.GetByID(ctx, orderID)
@@ -285,6 +295,19 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	adminID := c.MustGet("userID").(uuid.UUID)
 
 	if err := h.orderRepo.UpdateStatus(ctx, orderID, req.Status, req.Note, adminID); err != nil {
+		var transitionErr *models.StatusTransitionError
+		if errors.As(err, &transitionErr) {
+			utils.ErrorResponseWithData(c, 409, err.Error(), models.OrderTransitionsResponse{
+				OrderID:            orderID,
+				Status:             transitionErr.From,
+				AllowedTransitions: transitionErr.Allowed,
+			})
+			return
+		}
+		if errors.Is(err, models.ErrOrderNotFound) {
+			utils.ErrorResponse(c, 404, "Order not found")
+			return
+		}
 		utils.ErrorResponse(c, 500, "Failed to update order status")
 		return
 	}
@@ -293,6 +316,28 @@ func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	utils.SuccessResponse(c, 200, order)
 }
 
+// GetOrderTransitions returns the statuses an order can be moved to from its current status
+func (h *OrderHandler) GetOrderTransitions(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid order ID")
+		return
+	}
+
+	ctx := context.Background()
+	order, err := h.orderRepo.GetByID(ctx, orderID)
+	if err != nil || order == nil {
+		utils.ErrorResponse(c, 404, "Order not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, models.OrderTransitionsResponse{
+		OrderID:            order.ID,
+		Status:             order.Status,
+		AllowedTransitions: order.Status.AllowedTransitions(),
+	})
+}
+
 func (h *OrderHandler) AddOrderNote(c *gin.Context) {
 	orderID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -2,12 +2,10 @@ package handlers
 
 import (
 	"context"
-	"crypto/hmac"
-	"crypto/sha512"
-	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,25 +16,32 @@ import (
 )
 
 type PaymentHandler struct {
-	paymentService *services.PaymentService
-	paymentRepo    *repository.PaymentRepository
-	orderRepo      *repository.OrderRepository
-	secretKey      string
-	callbackURL    string
+	paymentService   *services.PaymentService
+	paymentRepo      *repository.PaymentRepository
+	orderRepo        *repository.OrderRepository
+	webhookEventRepo *repository.WebhookEventRepository
+	settlement       *services.PaymentSettlementService
+	refunds          *services.RefundService
+	callbackURL      string
 }
 
 func NewPaymentHandler(
 	paymentService *services.PaymentService,
 	paymentRepo *repository.PaymentRepository,
 	orderRepo *repository.OrderRepository,
-	secretKey, callbackURL string,
+	webhookEventRepo *repository.WebhookEventRepository,
+	settlement *services.PaymentSettlementService,
+	refunds *services.RefundService,
+	callbackURL string,
 ) *PaymentHandler {
 	return &PaymentHandler{
-		paymentService: paymentService,
-		paymentRepo:    paymentRepo,
-		orderRepo:      orderRepo,
-		secretKey:      secretKey,
-		callbackURL:    callbackURL,
+		paymentService:   paymentService,
+		paymentRepo:      paymentRepo,
+		orderRepo:        orderRepo,
+		webhookEventRepo: webhookEventRepo,
+		settlement:       settlement,
+		refunds:          refunds,
+		callbackURL:      callbackURL,
 	}
 }
 
@@ -67,7 +72,7 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 	}
 
 	fmt.Printf("DEBUG: Found order: %+v\n", order)
-	fmt.Printf("DEBUG: Order details: ID=%s, Total=%.2f, Subtotal=%.2f, Shipping=%.2f, Discount=%.2f\n", order.ID, order.Total, order.Subtotal, order.Shipping, order.Discount)
+	fmt.Printf("DEBUG: Order details: ID=%s, Total=%s, Subtotal=%s, Shipping=%s, Discount=%s\n", order.ID, order.Total, order.Subtotal, order.Shipping, order.Discount)
 
 	if order.UserID != userID {
 		fmt.Printf("DEBUG: Access denied - user %s does not own order %s\n", userID, order.ID)
@@ -75,6 +80,17 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 		return
 	}
 
+	// An order whose previous payment attempt failed goes back to pending; move it
+	// back to awaiting payment so the customer can retry.
+	if order.Status == models.OrderStatusPending {
+		if err := h.orderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusAwaitingPayment, "Payment retry initiated", userID); err != nil {
+			fmt.Printf("DEBUG: Failed to move order back to awaiting payment: %v\n", err)
+			utils.ErrorResponse(c, 400, "Order is not awaiting payment")
+			return
+		}
+		order.Status = models.OrderStatusAwaitingPayment
+	}
+
 	if order.Status != models.OrderStatusAwaitingPayment {
 		fmt.Printf("DEBUG: Invalid order status: %s\n", order.Status)
 		utils.ErrorResponse(c, 400, "Order is not awaiting payment")
@@ -92,13 +108,18 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 		return
 	}
 
+	provider, err := h.paymentService.Provider(req.Provider)
+	if err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
 	// Generate payment reference (order number already includes QP- prefix)
 	reference := fmt.Sprintf("%s-%s", order.OrderNumber, uuid.New().String()[:8])
 	fmt.Printf("DEBUG: Generated reference: %s\n", reference)
 
-	// Amount in kobo (multiply by 100) - round to ensure integer
-	amountKobo := int(order.Total * 100)
-	fmt.Printf("DEBUG: Order total: %.2f, Amount in kobo: %d\n", order.Total, amountKobo)
+	amountKobo := order.Total.Minor()
+	fmt.Printf("DEBUG: Order total: %s, Amount in kobo: %d\n", order.Total, amountKobo)
 
 	// Validate amount - Paystack minimum is 50 kobo (0.50 NGN)
 	if amountKobo < 50 {
@@ -107,50 +128,53 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 		return
 	}
 
-	paystackReq := &services.PaystackInitRequest{
-		Email:     userEmail,
-		Amount:    amountKobo,
-		Reference: reference,
-		Callback:  h.callbackURL,
+	initReq := &services.PaymentInitRequest{
+		Email:        userEmail,
+		CustomerName: order.ShippingAddress.Name,
+		AmountKobo:   amountKobo,
+		Currency:     "NGN",
+		Reference:    reference,
+		CallbackURL:  h.callbackURL,
 		Metadata: map[string]string{
 			"order_id":     order.ID.String(),
 			"order_number": order.OrderNumber,
 		},
 	}
 
-	fmt.Printf("DEBUG: Paystack request: %+v\n", paystackReq)
+	fmt.Printf("DEBUG: %s request: %+v\n", provider.Name(), initReq)
 
-	paystackResp, err := h.paymentService.InitializePayment(paystackReq)
+	initResp, err := provider.InitializePayment(ctx, initReq)
 	if err != nil {
-		fmt.Printf("DEBUG: Paystack initialization error: %v\n", err)
+		fmt.Printf("DEBUG: %s initialization error: %v\n", provider.Name(), err)
 		utils.ErrorResponse(c, 500, "Failed to initialize payment: "+err.Error())
 		return
 	}
 
-	fmt.Printf("DEBUG: Paystack response: %+v\n", paystackResp)
+	fmt.Printf("DEBUG: %s response: %+v\n", provider.Name(), initResp)
 
-	// Validate Paystack response has all required fields
-	if paystackResp.Data.AuthorizationURL == "" {
-		fmt.Printf("DEBUG: Paystack response missing authorization URL\n")
+	// Validate the provider response has all required fields
+	if initResp.AuthorizationURL == "" {
+		fmt.Printf("DEBUG: Provider response missing authorization URL\n")
 		utils.ErrorResponse(c, 500, "Payment initialization failed: missing authorization URL")
 		return
 	}
-	if paystackResp.Data.Reference == "" {
-		fmt.Printf("DEBUG: Paystack response missing reference\n")
+	if initResp.Reference == "" {
+		fmt.Printf("DEBUG: Provider response missing reference\n")
 		utils.ErrorResponse(c, 500, "Payment initialization failed: missing reference")
 		return
 	}
 
-	fmt.Printf("DEBUG: Authorization URL: %s\n", paystackResp.Data.AuthorizationURL)
-	fmt.Printf("DEBUG: Reference: %s\n", paystackResp.Data.Reference)
+	fmt.Printf("DEBUG: Authorization URL: %s\n", initResp.AuthorizationURL)
+	fmt.Printf("DEBUG: Reference: %s\n", initResp.Reference)
 
 	// Save payment record
 	payment := &models.Payment{
-		OrderID:     order.ID,
-		PaystackRef: reference,
-		Amount:      order.Total,
-		Currency:    "NGN",
-		Status:      models.PaymentStatusPending,
+		OrderID:           order.ID,
+		Provider:          provider.Name(),
+		ProviderReference: reference,
+		Amount:            order.Total,
+		Currency:          "NGN",
+		Status:            models.PaymentStatusPending,
 	}
 
 	fmt.Printf("DEBUG: Creating payment record: %+v\n", payment)
@@ -164,8 +188,9 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 	fmt.Printf("DEBUG: Payment record created successfully\n")
 
 	response := models.InitializePaymentResponse{
-		AuthorizationURL: paystackResp.Data.AuthorizationURL,
-		AccessCode:       paystackResp.Data.AccessCode,
+		Provider:         provider.Name(),
+		AuthorizationURL: initResp.AuthorizationURL,
+		AccessCode:       initResp.AccessCode,
 		Reference:        reference,
 	}
 
@@ -188,7 +213,7 @@ func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
 	} else {
 		fmt.Printf("DEBUG: Total payments in database: %d\n", len(allPayments))
 		for _, p := range allPayments {
-			fmt.Printf("DEBUG: Payment: ID=%s, Ref=%s, Status=%s\n", p.ID, p.PaystackRef, p.Status)
+			fmt.Printf("DEBUG: Payment: ID=%s, Ref=%s, Status=%s\n", p.ID, p.ProviderReference, p.Status)
 		}
 	}
 
@@ -220,60 +245,78 @@ func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
 		return
 	}
 
-	paystackResp, err := h.paymentService.VerifyPayment(reference)
+	provider, err := h.paymentService.Provider(payment.Provider)
 	if err != nil {
-		fmt.Printf("DEBUG: Paystack verification failed: %v\n", err)
+		fmt.Printf("ERROR: %v (reference %s)\n", err, reference)
 		utils.ErrorResponse(c, 500, "Failed to verify payment")
 		return
 	}
 
-	responseJSON, _ := json.Marshal(paystackResp)
-	fmt.Printf("DEBUG: Paystack response: %s\n", string(responseJSON))
+	verification, err := provider.VerifyPayment(ctx, reference)
+	if err != nil {
+		fmt.Printf("DEBUG: %s verification failed: %v\n", provider.Name(), err)
+		utils.ErrorResponse(c, 500, "Failed to verify payment")
+		return
+	}
 
-	if paystackResp.Data.Status == "success" {
-		fmt.Printf("DEBUG: Payment successful, updating order status to paid\n")
-		// Update order status - this should succeed
-		err = h.orderRepo.UpdateStatus(ctx, payment.OrderID, models.OrderStatusPaid, "Payment confirmed via Paystack", userID)
-		if err != nil {
-			fmt.Printf("DEBUG: Failed to update order status: %v\n", err)
-			utils.ErrorResponse(c, 500, "Failed to update order status")
-			return
-		}
-		fmt.Printf("DEBUG: Order status updated to paid successfully\n")
+	fmt.Printf("DEBUG: %s response: %s\n", provider.Name(), verification.Raw)
 
-		// Update payment status
-		err = h.paymentRepo.UpdateStatus(ctx, reference, models.PaymentStatusSuccess, string(responseJSON))
-		if err != nil {
-			fmt.Printf("DEBUG: Failed to update payment status: %v\n", err)
-			// Don't return error here, order status was already updated
+	switch verification.Status {
+	case services.ChargeSuccess:
+		fmt.Printf("DEBUG: Payment successful, settling payment\n")
+		err = h.settlement.SettleSuccess(ctx, payment, verification.AmountKobo, verification.Currency, verification.Raw, "Payment confirmed via "+provider.Name(), userID)
+		if errors.Is(err, services.ErrPaymentAmountMismatch) {
+			fmt.Printf("ERROR: %v (reference %s)\n", err, reference)
+			utils.ErrorResponse(c, 409, "The amount paid does not match the order total. Our team will review this payment.")
+			return
 		}
-	} else {
-		fmt.Printf("DEBUG: Payment failed, status: %s\n", paystackResp.Data.Status)
-		// Update order back to pending so user can retry
-		err = h.orderRepo.UpdateStatus(ctx, payment.OrderID, models.OrderStatusPending, fmt.Sprintf("Payment verification failed: %s", paystackResp.Data.Status), userID)
 		if err != nil {
-			fmt.Printf("DEBUG: Failed to update order status to pending: %v\n", err)
+			fmt.Printf("DEBUG: Failed to settle payment: %v\n", err)
+			utils.ErrorResponse(c, 500, "Failed to update order status")
+			return
 		}
-
-		// Update payment status to failed
-		err = h.paymentRepo.UpdateStatus(ctx, reference, models.PaymentStatusFailed, string(responseJSON))
+	case services.ChargePending:
+		// Not finished yet; the webhook or a later verification will settle it
+		fmt.Printf("DEBUG: Payment still in progress, status: %s\n", verification.ProviderStatus)
+	default:
+		fmt.Printf("DEBUG: Payment failed, status: %s\n", verification.ProviderStatus)
+		err = h.settlement.SettleFailure(ctx, payment, verification.Raw, fmt.Sprintf("Payment verification failed: %s", verification.ProviderStatus), userID)
 		if err != nil {
-			fmt.Printf("DEBUG: Failed to update payment status to failed: %v\n", err)
+			fmt.Printf("DEBUG: Failed to record payment failure: %v\n", err)
 		}
 	}
 
 	utils.SuccessResponse(c, 200, gin.H{
-		"status":    paystackResp.Data.Status,
+		"status":    verification.Status,
 		"reference": reference,
-		"message":   fmt.Sprintf("Payment %s", paystackResp.Data.Status),
+		"message":   fmt.Sprintf("Payment %s", verification.ProviderStatus),
+	})
+}
+
+// GetProviders lists the payment providers customers can choose at checkout
+func (h *PaymentHandler) GetProviders(c *gin.Context) {
+	utils.SuccessResponse(c, 200, models.PaymentProvidersResponse{
+		Providers: h.paymentService.Providers(),
+		Default:   h.paymentService.DefaultProvider(),
 	})
 }
 
+// Webhook receives provider webhooks. The provider comes from the URL; the bare
+// /payments/webhook path is Paystack's.
 func (h *PaymentHandler) Webhook(c *gin.Context) {
-	fmt.Printf("DEBUG: Webhook received\n")
+	providerName := c.Param("provider")
+	if providerName == "" {
+		providerName = "paystack"
+	}
+	fmt.Printf("DEBUG: Webhook received for provider %s\n", providerName)
+
+	provider, err := h.paymentService.Provider(providerName)
+	if err != nil {
+		fmt.Printf("DEBUG: %v\n", err)
+		c.AbortWithStatus(404)
+		return
+	}
 
-	// Verify signature
-	signature := c.GetHeader("x-paystack-signature")
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
 		fmt.Printf("DEBUG: Failed to read webhook body: %v\n", err)
@@ -281,56 +324,184 @@ func (h *PaymentHandler) Webhook(c *gin.Context) {
 		return
 	}
 
-	fmt.Printf("DEBUG: Webhook signature: %s\n", signature)
 	fmt.Printf("DEBUG: Webhook body: %s\n", string(body))
 
-	mac := hmac.New(sha512.New, []byte(h.secretKey))
-	mac.Write(body)
-	expectedSig := hex.EncodeToString(mac.Sum(nil))
-
-	fmt.Printf("DEBUG: Expected signature: %s\n", expectedSig)
-
-	if signature != expectedSig {
+	if !provider.VerifyWebhook(c.Request.Header, body) {
 		fmt.Printf("DEBUG: Invalid webhook signature\n")
 		c.AbortWithStatus(401)
 		return
 	}
 
-	var payload models.PaystackWebhookPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	parsed, err := provider.ParseWebhook(body)
+	if err != nil {
 		fmt.Printf("DEBUG: Failed to unmarshal webhook payload: %v\n", err)
 		c.AbortWithStatus(400)
 		return
 	}
 
-	fmt.Printf("DEBUG: Webhook event: %s\n", payload.Event)
-	fmt.Printf("DEBUG: Webhook data: %+v\n", payload.Data)
+	fmt.Printf("DEBUG: Webhook event: %s (%s)\n", parsed.Event, parsed.Reference)
 
 	ctx := context.Background()
 
-	if payload.Event == "charge.success" {
-		payment, err := h.paymentRepo.GetByReference(ctx, payload.Data.Reference)
-		if err != nil {
-			fmt.Printf("DEBUG: Error looking up payment in webhook: %v\n", err)
-			c.JSON(200, gin.H{"status": "ok"})
-			return
+	event := &models.PaymentWebhookEvent{
+		Provider:  provider.Name(),
+		EventKey:  parsed.Key,
+		EventType: parsed.Event,
+		Reference: parsed.Reference,
+		Payload:   body,
+	}
+	duplicate, err := h.webhookEventRepo.Record(ctx, event)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to store webhook event %s: %v\n", parsed.Key, err)
+		// Let the provider retry rather than process an event we could not record
+		c.AbortWithStatus(500)
+		return
+	}
+
+	// Redeliveries of events we already handled are acknowledged and ignored. Events
+	// whose earlier processing failed are processed again.
+	if duplicate && (event.Status == models.WebhookEventProcessed || event.Status == models.WebhookEventIgnored) {
+		fmt.Printf("DEBUG: Duplicate webhook event %s ignored\n", event.EventKey)
+		c.JSON(200, gin.H{"status": "ok"})
+		return
+	}
+
+	if err := h.processWebhookEvent(ctx, event, parsed); err != nil && !isPermanentWebhookError(err) {
+		// Transient failure (e.g. database); a non-2xx response makes the provider redeliver
+		c.AbortWithStatus(500)
+		return
+	}
+
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// ReplayWebhookEvent processes a stored webhook event again from its raw payload
+func (h *PaymentHandler) ReplayWebhookEvent(c *gin.Context) {
+	eventID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid webhook event ID")
+		return
+	}
+
+	ctx := context.Background()
+	event, err := h.webhookEventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch webhook event")
+		return
+	}
+	if event == nil {
+		utils.ErrorResponse(c, 404, "Webhook event not found")
+		return
+	}
+
+	provider, err := h.paymentService.Provider(event.Provider)
+	if err != nil {
+		utils.ErrorResponse(c, 422, err.Error())
+		return
+	}
+
+	parsed, err := provider.ParseWebhook(event.Payload)
+	if err != nil {
+		utils.ErrorResponse(c, 422, "Stored payload could not be parsed: "+err.Error())
+		return
+	}
+
+	h.processWebhookEvent(ctx, event, parsed)
+
+	event, err = h.webhookEventRepo.GetByID(ctx, eventID)
+	if err != nil || event == nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch webhook event")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, event)
+}
+
+// GetWebhookEvents lists stored webhook events, newest first
+func (h *PaymentHandler) GetWebhookEvents(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
 		}
-		if payment != nil {
-			fmt.Printf("DEBUG: Processing successful charge for reference: %s\n", payload.Data.Reference)
-			err = h.paymentRepo.UpdateStatus(ctx, payload.Data.Reference, models.PaymentStatusSuccess, string(body))
-			if err != nil {
-				fmt.Printf("DEBUG: Failed to update payment status in webhook: %v\n", err)
-			}
-			// Use system user (uuid.Nil) since this is from webhook
-			err = h.orderRepo.UpdateStatus(ctx, payment.OrderID, models.OrderStatusPaid, "Payment confirmed via webhook", uuid.Nil)
-			if err != nil {
-				fmt.Printf("DEBUG: Failed to update order status in webhook: %v\n", err)
-			}
-			fmt.Printf("DEBUG: Order %s status updated to paid via webhook\n", payment.OrderID)
-		} else {
-			fmt.Printf("DEBUG: Payment not found for reference in webhook: %s\n", payload.Data.Reference)
+	}
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
 		}
 	}
 
-	c.JSON(200, gin.H{"status": "ok"})
+	ctx := context.Background()
+	events, err := h.webhookEventRepo.GetAll(ctx, c.Query("status"), c.Query("reference"), limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch webhook events")
+		return
+	}
+	if events == nil {
+		events = []models.PaymentWebhookEvent{}
+	}
+
+	utils.SuccessResponse(c, 200, events)
+}
+
+// errWebhookIgnored marks an event that needs no action
+var errWebhookIgnored = errors.New("ignored")
+
+// processWebhookEvent applies an event and records the outcome on the stored event
+func (h *PaymentHandler) processWebhookEvent(ctx context.Context, event *models.PaymentWebhookEvent, parsed *services.WebhookEvent) error {
+	err := h.applyWebhookEvent(ctx, event.Provider, parsed, event.Payload)
+
+	status := models.WebhookEventProcessed
+	errMsg := ""
+	switch {
+	case errors.Is(err, errWebhookIgnored):
+		status = models.WebhookEventIgnored
+		errMsg = err.Error()
+		err = nil
+	case err != nil:
+		status = models.WebhookEventFailed
+		errMsg = err.Error()
+		fmt.Printf("ERROR: Webhook event %s failed: %v\n", event.EventKey, err)
+	}
+
+	if markErr := h.webhookEventRepo.MarkResult(ctx, event.ID, status, errMsg); markErr != nil {
+		fmt.Printf("ERROR: Failed to record webhook event result: %v\n", markErr)
+	}
+	return err
+}
+
+func (h *PaymentHandler) applyWebhookEvent(ctx context.Context, providerName string, parsed *services.WebhookEvent, raw []byte) error {
+	switch parsed.Kind {
+	case services.WebhookTransfer:
+		// We never initiate transfers from this account; keep the event for audit only
+		fmt.Printf("DEBUG: Transfer event %s for %s recorded\n", parsed.Event, parsed.Detail)
+		return nil
+	case services.WebhookChargeSuccess, services.WebhookChargeFailed, services.WebhookRefundProcessed, services.WebhookRefundFailed:
+	default:
+		return fmt.Errorf("%w: unhandled event type %s", errWebhookIgnored, parsed.Event)
+	}
+
+	payment, err := h.paymentRepo.GetByReference(ctx, parsed.Reference)
+	if err != nil {
+		return err
+	}
+	if payment == nil || payment.Provider != providerName {
+		return fmt.Errorf("%w: no %s payment with reference %s", errWebhookIgnored, providerName, parsed.Reference)
+	}
+
+	switch parsed.Kind {
+	case services.WebhookChargeSuccess:
+		return h.settlement.SettleSuccess(ctx, payment, parsed.AmountKobo, parsed.Currency, string(raw), "Payment confirmed via webhook", models.SystemActor)
+	case services.WebhookChargeFailed:
+		return h.settlement.SettleFailure(ctx, payment, string(raw), fmt.Sprintf("Payment failed: %s", parsed.Detail), models.SystemActor)
+	default: // refund processed or failed
+		return h.refunds.ReconcileWebhook(ctx, payment, parsed, string(raw))
+	}
+}
+
+// isPermanentWebhookError reports whether redelivering the event cannot help
+func isPermanentWebhookError(err error) bool {
+	return errors.Is(err, services.ErrPaymentAmountMismatch) || errors.Is(err, models.ErrOrderNotFound) ||
+		errors.Is(err, models.ErrPaymentNotRefundable) || errors.Is(err, models.ErrRefundExceedsPayment)
 }
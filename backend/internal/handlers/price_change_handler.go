@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type PriceChangeHandler struct {
+	priceChangeRepo *repository.PriceChangeRepository
+	productRepo     *repository.ProductRepository
+}
+
+func NewPriceChangeHandler(priceChangeRepo *repository.PriceChangeRepository, productRepo *repository.ProductRepository) *PriceChangeHandler {
+	return &PriceChangeHandler{priceChangeRepo: priceChangeRepo, productRepo: productRepo}
+}
+
+// GetPriceChanges lists bulk price changes, optionally only those with a status
+func (h *PriceChangeHandler) GetPriceChanges(c *gin.Context) {
+	status := models.PriceChangeStatus(c.Query("status"))
+	switch status {
+	case "", models.PriceChangeScheduled, models.PriceChangeApplied, models.PriceChangeCancelled, models.PriceChangeRolledBack, models.PriceChangeBlocked:
+	default:
+		utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid price change status: %s", status))
+		return
+	}
+
+	changes, err := h.priceChangeRepo.GetAll(context.Background(), status)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price changes")
+		return
+	}
+	if changes == nil {
+		changes = []models.PriceChange{}
+	}
+	utils.SuccessResponse(c, 200, changes)
+}
+
+// GetPriceChange returns a price change with the price of each product before
+// and after it
+func (h *PriceChangeHandler) GetPriceChange(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid price change ID")
+		return
+	}
+
+	change, err := h.priceChangeRepo.GetByID(context.Background(), id)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price change")
+		return
+	}
+	if change == nil {
+		utils.ErrorResponse(c, 404, "Price change not found")
+		return
+	}
+	utils.SuccessResponse(c, 200, change)
+}
+
+// CancelPriceChange stops a scheduled price change from being applied
+func (h *PriceChangeHandler) CancelPriceChange(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid price change ID")
+		return
+	}
+
+	found, err := h.priceChangeRepo.Cancel(context.Background(), id)
+	if errors.Is(err, models.ErrPriceChangeNotScheduled) {
+		utils.ErrorResponse(c, 409, err.Error())
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to cancel price change")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Price change not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Price change cancelled")
+}
+
+// RollbackPriceChange restores every price an applied price change set, all
+// together. If any of them has changed since, nothing is rolled back and the
+// products are listed.
+func (h *PriceChangeHandler) RollbackPriceChange(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid price change ID")
+		return
+	}
+
+	var req models.RollbackPriceChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	adminID := c.MustGet("userID").(uuid.UUID)
+	ctx := context.Background()
+	rollback, err := h.priceChangeRepo.Rollback(ctx, id, strings.TrimSpace(req.Reason), adminID)
+	var conflictErr *models.PriceRollbackConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		utils.ErrorResponseWithData(c, 409, err.Error(), conflictErr)
+		return
+	case errors.Is(err, models.ErrPriceChangeNotApplied):
+		utils.ErrorResponse(c, 409, err.Error())
+		return
+	case err != nil:
+		fmt.Printf("ERROR: Failed to roll back price change %s: %v\n", id, err)
+		utils.ErrorResponse(c, 500, "Failed to roll back price change")
+		return
+	case rollback == nil:
+		utils.ErrorResponse(c, 404, "Price change not found")
+		return
+	}
+
+	rollback, err = h.priceChangeRepo.GetByID(ctx, rollback.ID)
+	if err != nil || rollback == nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price change")
+		return
+	}
+	utils.SuccessResponse(c, 200, rollback)
+}
+
+// GetPriceHistory lists every change to a product's base price, latest first
+func (h *PriceChangeHandler) GetPriceHistory(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	ctx := context.Background()
+	product, err := h.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
+
+	history, err := h.priceChangeRepo.GetHistory(ctx, productID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price history")
+		return
+	}
+	if history == nil {
+		history = []models.ProductPriceHistory{}
+	}
+	utils.SuccessResponse(c, 200, history)
+}
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -28,8 +30,16 @@ func (h *PricingHandler) CalculatePrice(c *gin.Context) {
 		return
 	}
 
+	// Signed-in customers see their group's prices
+	if userID, ok := c.Get("userID"); ok {
+		req.UserID = userID.(uuid.UUID)
+	}
+
 	ctx := context.Background()
 	breakdown, err := h.pricingService.CalculatePrice(ctx, &req)
+	if respondPricingInputError(c, err) {
+		return
+	}
 	if err != nil {
 		utils.ErrorResponse(c, 500, "Failed to calculate price")
 		return
@@ -83,11 +93,39 @@ func (h *PricingHandler) SetDimensionalPricing(c *gin.Context) {
 		return
 	}
 
+	sizeUnit := req.SizeUnit
+	if sizeUnit == "" {
+		sizeUnit, _ = models.AreaLengthUnit(req.Unit)
+	}
+	if req.MinWidth != nil && req.MaxWidth != nil && *req.MinWidth > *req.MaxWidth {
+		utils.ValidationErrorResponse(c, "minWidth must not be more than maxWidth")
+		return
+	}
+	if req.MinHeight != nil && req.MaxHeight != nil && *req.MinHeight > *req.MaxHeight {
+		utils.ValidationErrorResponse(c, "minHeight must not be more than maxHeight")
+		return
+	}
+	seen := make(map[string]bool)
+	for _, f := range req.Finishings {
+		if seen[f.ID] {
+			utils.ValidationErrorResponse(c, fmt.Sprintf("Duplicate finishing ID %q", f.ID))
+			return
+		}
+		seen[f.ID] = true
+	}
+
 	ctx := context.Background()
 	dp := &models.DimensionalPricing{
 		RatePerUnit: req.RatePerUnit,
 		Unit:        req.Unit,
+		SizeUnit:    sizeUnit,
 		MinCharge:   req.MinCharge,
+		MinWidth:    req.MinWidth,
+		MaxWidth:    req.MaxWidth,
+		MinHeight:   req.MinHeight,
+		MaxHeight:   req.MaxHeight,
+		Bleed:       req.Bleed,
+		Finishings:  req.Finishings,
 	}
 
 	if err := h.pricingRepo.CreateDimensionalPricing(ctx, productID, dp); err != nil {
@@ -153,7 +191,7 @@ func (h *PricingHandler) SetPricingTiers(c *gin.Context) {
 	// Debug logging
 	fmt.Printf("DEBUG: SetPricingTiers called for product %s with %d tiers\n", productID, len(tiers))
 	for i, tier := range tiers {
-		fmt.Printf("DEBUG: Tier %d: minQty=%d, maxQty=%d, price=%f\n", i, tier.MinQty, tier.MaxQty, tier.Price)
+		fmt.Printf("DEBUG: Tier %d: minQty=%d, maxQty=%d, price=%s\n", i, tier.MinQty, tier.MaxQty, tier.Price)
 	}
 
 	ctx := context.Background()
@@ -202,3 +240,436 @@ func (h *PricingHandler) DeletePricingTiers(c *gin.Context) {
 
 	utils.SuccessMessageResponse(c, 200, "Pricing tiers deleted successfully")
 }
+
+// GetAddOns lists a product's add-ons, including disabled ones
+func (h *PricingHandler) GetAddOns(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	ctx := context.Background()
+	addOns, err := h.pricingRepo.GetAllAddOns(ctx, productID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch add-ons")
+		return
+	}
+	if addOns == nil {
+		addOns = []models.AddOn{}
+	}
+
+	utils.SuccessResponse(c, 200, addOns)
+}
+
+func (h *PricingHandler) CreateAddOn(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var req models.AddOnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	addOn := addOnFromRequest(&req)
+	if err := h.pricingRepo.CreateAddOn(ctx, productID, addOn); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to create add-on")
+		return
+	}
+
+	utils.SuccessResponse(c, 201, addOn)
+}
+
+func (h *PricingHandler) UpdateAddOn(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	addOnID, err := uuid.Parse(c.Param("addOnId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid add-on ID")
+		return
+	}
+
+	var req models.AddOnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	addOn := addOnFromRequest(&req)
+	addOn.ID = addOnID
+	addOn.ProductID = productID
+	found, err := h.pricingRepo.UpdateAddOn(ctx, addOn)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update add-on")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Add-on not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, addOn)
+}
+
+func (h *PricingHandler) DeleteAddOn(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	addOnID, err := uuid.Parse(c.Param("addOnId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid add-on ID")
+		return
+	}
+
+	ctx := context.Background()
+	found, err := h.pricingRepo.DeleteAddOn(ctx, productID, addOnID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete add-on")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Add-on not found")
+		return
+	}
+
+	utils.SuccessMessageResponse(c, 200, "Add-on deleted successfully")
+}
+
+func addOnFromRequest(req *models.AddOnRequest) *models.AddOn {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	return &models.AddOn{
+		Name:          req.Name,
+		Type:          req.Type,
+		PriceModifier: req.PriceModifier,
+		Enabled:       enabled,
+	}
+}
+
+// ListPricingRules lists a product's setup, rush and minimum charge rules
+func (h *PricingHandler) ListPricingRules(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	ctx := context.Background()
+	rules, err := h.pricingRepo.GetPricingRules(ctx, productID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch pricing rules")
+		return
+	}
+	if rules == nil {
+		rules = []models.PricingRule{}
+	}
+
+	utils.SuccessResponse(c, 200, rules)
+}
+
+func (h *PricingHandler) CreatePricingRule(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	rule, ok := bindPricingRule(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	if err := h.pricingRepo.CreatePricingRule(ctx, productID, rule); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to create pricing rule")
+		return
+	}
+
+	utils.SuccessResponse(c, 201, rule)
+}
+
+func (h *PricingHandler) UpdatePricingRule(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid pricing rule ID")
+		return
+	}
+
+	rule, ok := bindPricingRule(c)
+	if !ok {
+		return
+	}
+	rule.ID = ruleID
+	rule.ProductID = productID
+
+	ctx := context.Background()
+	found, err := h.pricingRepo.UpdatePricingRule(ctx, rule)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update pricing rule")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Pricing rule not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, rule)
+}
+
+func (h *PricingHandler) DeletePricingRule(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid pricing rule ID")
+		return
+	}
+
+	ctx := context.Background()
+	found, err := h.pricingRepo.DeletePricingRule(ctx, productID, ruleID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete pricing rule")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Pricing rule not found")
+		return
+	}
+
+	utils.SuccessMessageResponse(c, 200, "Pricing rule deleted successfully")
+}
+
+// bindPricingRule reads a pricing rule from the request body, responding with a
+// validation error if it is invalid
+func bindPricingRule(c *gin.Context) (*models.PricingRule, bool) {
+	var req models.PricingRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return nil, false
+	}
+
+	valueType := req.ValueType
+	if valueType == "" {
+		valueType = models.RuleValueFlat
+	}
+	if req.RuleType == models.PricingRuleMinimumCharge && valueType != models.RuleValueFlat {
+		utils.ValidationErrorResponse(c, "A minimum charge must be a flat amount")
+		return nil, false
+	}
+
+	return &models.PricingRule{
+		RuleType:    req.RuleType,
+		ValueType:   valueType,
+		Value:       req.Value,
+		Description: req.Description,
+	}, true
+}
+
+// ListPricingFormulas lists a product's formulas in evaluation order, drafts included
+func (h *PricingHandler) ListPricingFormulas(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	ctx := context.Background()
+	formulas, err := h.pricingRepo.GetPricingFormulas(ctx, productID, true)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch pricing formulas")
+		return
+	}
+	if formulas == nil {
+		formulas = []models.PricingFormula{}
+	}
+
+	utils.SuccessResponse(c, 200, formulas)
+}
+
+func (h *PricingHandler) CreatePricingFormula(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var req models.PricingFormulaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if _, err := services.ParseFormula(req.Expression); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid expression: "+err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	formula := formulaFromRequest(&req)
+	if err := h.pricingRepo.CreatePricingFormula(ctx, productID, formula); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to create pricing formula")
+		return
+	}
+
+	utils.SuccessResponse(c, 201, formula)
+}
+
+func (h *PricingHandler) UpdatePricingFormula(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	formulaID, err := uuid.Parse(c.Param("formulaId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid pricing formula ID")
+		return
+	}
+
+	var req models.PricingFormulaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	if _, err := services.ParseFormula(req.Expression); err != nil {
+		utils.ValidationErrorResponse(c, "Invalid expression: "+err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	formula := formulaFromRequest(&req)
+	formula.ID = formulaID
+	formula.ProductID = productID
+	found, err := h.pricingRepo.UpdatePricingFormula(ctx, formula)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update pricing formula")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Pricing formula not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, formula)
+}
+
+func (h *PricingHandler) DeletePricingFormula(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+	formulaID, err := uuid.Parse(c.Param("formulaId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid pricing formula ID")
+		return
+	}
+
+	ctx := context.Background()
+	found, err := h.pricingRepo.DeletePricingFormula(ctx, productID, formulaID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete pricing formula")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Pricing formula not found")
+		return
+	}
+
+	utils.SuccessMessageResponse(c, 200, "Pricing formula deleted successfully")
+}
+
+// DryRunPricingFormulas prices sample configurations with the formulas in the
+// request, or the product's saved formulas including drafts, without saving
+// anything
+func (h *PricingHandler) DryRunPricingFormulas(c *gin.Context) {
+	productID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var req models.FormulaDryRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	var formulas []models.PricingFormula
+	if req.Formulas != nil {
+		for i := range req.Formulas {
+			if _, err := services.ParseFormula(req.Formulas[i].Expression); err != nil {
+				utils.ValidationErrorResponse(c, fmt.Sprintf("formulas[%d].expression: %v", i, err))
+				return
+			}
+			formula := formulaFromRequest(&req.Formulas[i])
+			formula.ProductID = productID
+			formulas = append(formulas, *formula)
+		}
+		sort.SliceStable(formulas, func(i, j int) bool { return formulas[i].Position < formulas[j].Position })
+	} else {
+		formulas, err = h.pricingRepo.GetPricingFormulas(ctx, productID, true)
+		if err != nil {
+			utils.ErrorResponse(c, 500, "Failed to fetch pricing formulas")
+			return
+		}
+	}
+
+	results, err := h.pricingService.DryRun(ctx, productID, formulas, req.Samples)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to run pricing formulas")
+		return
+	}
+	if results == nil {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, results)
+}
+
+func formulaFromRequest(req *models.PricingFormulaRequest) *models.PricingFormula {
+	return &models.PricingFormula{
+		Position:   req.Position,
+		Label:      req.Label,
+		Expression: req.Expression,
+		Published:  req.Published,
+	}
+}
+
+// respondPricingInputError responds with a 400 when a price calculation failed
+// because of the item configuration, listing the fields at fault, and reports
+// whether it did
+func respondPricingInputError(c *gin.Context, err error) bool {
+	var configErr *models.ConfigurationError
+	if errors.As(err, &configErr) {
+		utils.ErrorResponseWithData(c, 400, "Invalid configuration", configErr)
+		return true
+	}
+	if services.IsPricingInputError(err) {
+		utils.ValidationErrorResponse(c, err.Error())
+		return true
+	}
+	return false
+}
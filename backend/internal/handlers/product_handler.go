@@ -2,37 +2,121 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/quikprint/backend/internal/models"
 	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
 	"github.com/quikprint/backend/internal/utils"
 )
 
 type ProductHandler struct {
-	productRepo *repository.ProductRepository
+	productRepo        *repository.ProductRepository
+	priceChangeRepo    *repository.PriceChangeRepository
+	priceChangeService *services.PriceChangeService
 }
 
-func NewProductHandler(productRepo *repository.ProductRepository) *ProductHandler {
-	return &ProductHandler{productRepo: productRepo}
+func NewProductHandler(productRepo *repository.ProductRepository, priceChangeRepo *repository.PriceChangeRepository, priceChangeService *services.PriceChangeService) *ProductHandler {
+	return &ProductHandler{
+		productRepo:        productRepo,
+		priceChangeRepo:    priceChangeRepo,
+		priceChangeService: priceChangeService,
+	}
 }
 
+// GetAll searches the catalog. It takes a full-text search, categories by slug
+// (repeated or comma separated), a price range, the longest turnaround in days, a sort
+// and a page size, and pages with the cursor from the previous page. Customers
+// only see published products; staff see every product that is not deleted,
+// and can filter by status, including "deleted".
 func (h *ProductHandler) GetAll(c *gin.Context) {
-	categorySlug := c.Query("category")
-	ctx := context.Background()
+	query := models.ProductQuery{
+		Status: models.ProductStatusPublished,
+		Search: strings.TrimSpace(c.Query("search")),
+		Sort:   models.ProductSort(c.Query("sort")),
+		Cursor: c.Query("cursor"),
+		Limit:  24,
+	}
+	if isStaff(c) {
+		switch status := c.Query("status"); {
+		case status == "deleted":
+			query.Status = ""
+			query.Deleted = true
+		case status == "" || models.ProductStatus(status).IsValid():
+			query.Status = models.ProductStatus(status)
+		default:
+			utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid product status: %s", status))
+			return
+		}
+	}
+	if query.Sort != "" && !query.Sort.IsValid() {
+		utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid sort: %s", query.Sort))
+		return
+	}
+	for _, categories := range c.QueryArray("category") {
+		for _, slug := range strings.Split(categories, ",") {
+			if slug = strings.TrimSpace(slug); slug != "" {
+				query.CategorySlugs = append(query.CategorySlugs, slug)
+			}
+		}
+	}
+	var ok bool
+	if query.MinPrice, ok = queryMoney(c, "minPrice"); !ok {
+		return
+	}
+	if query.MaxPrice, ok = queryMoney(c, "maxPrice"); !ok {
+		return
+	}
+	if d := c.Query("maxTurnaroundDays"); d != "" {
+		days, err := strconv.Atoi(d)
+		if err != nil || days <= 0 {
+			utils.ValidationErrorResponse(c, "Invalid maxTurnaroundDays")
+			return
+		}
+		query.MaxTurnaroundDays = days
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			query.Limit = parsed
+		}
+	}
 
-	products, err := h.productRepo.GetAll(ctx, categorySlug)
+	page, err := h.productRepo.Search(context.Background(), &query)
+	if errors.Is(err, models.ErrInvalidCursor) {
+		utils.ValidationErrorResponse(c, "Invalid cursor")
+		return
+	}
 	if err != nil {
+		fmt.Printf("ERROR: Failed to search products: %v\n", err)
 		utils.ErrorResponse(c, 500, "Failed to fetch products")
 		return
 	}
-	if products == nil {
-		products = []models.Product{}
+	if !isStaff(c) {
+		for i := range page.Products {
+			page.Products[i].CostPrice = nil
+		}
 	}
-	utils.SuccessResponse(c, 200, products)
+	utils.SuccessResponse(c, 200, page)
+}
+
+// queryMoney reads an optional amount from the query string, responding with a
+// validation error if it is not one
+func queryMoney(c *gin.Context, param string) (*models.Money, bool) {
+	v := c.Query(param)
+	if v == "" {
+		return nil, true
+	}
+	amount, err := models.ParseMoney(v)
+	if err != nil {
+		utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid %s", param))
+		return nil, false
+	}
+	return &amount, true
 }
 
 func (h *ProductHandler) GetBySlug(c *gin.Context) {
@@ -48,9 +132,24 @@ func (h *ProductHandler) GetBySlug(c *gin.Context) {
 		utils.ErrorResponse(c, 404, "Product not found")
 		return
 	}
+	if !isStaff(c) {
+		if !product.IsAvailable() {
+			utils.ErrorResponse(c, 404, "Product not found")
+			return
+		}
+		product.CostPrice = nil
+	}
 	utils.SuccessResponse(c, 200, product)
 }
 
+// isStaff reports whether the request was authenticated as an admin or manager,
+// the roles the admin routes let through. A customer's role, set by optional
+// auth on a public route, does not count.
+func isStaff(c *gin.Context) bool {
+	role, _ := c.Get("userRole")
+	return role == models.RoleAdmin || role == models.RoleManager
+}
+
 func (h *ProductHandler) Create(c *gin.Context) {
 	var req models.CreateProductRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -78,6 +177,11 @@ func (h *ProductHandler) Create(c *gin.Context) {
 		Features:         req.Features,
 		Turnaround:       req.Turnaround,
 		MinQuantity:      req.MinQuantity,
+		TaxExempt:        req.TaxExempt,
+		CostPrice:        req.CostPrice,
+		Status:           req.Status,
+		PublishAt:        req.PublishAt,
+		UnpublishAt:      req.UnpublishAt,
 	}
 
 	if product.Images == nil {
@@ -92,6 +196,13 @@ func (h *ProductHandler) Create(c *gin.Context) {
 	if product.MinQuantity == 0 {
 		product.MinQuantity = 1
 	}
+	if product.Status == "" {
+		product.Status = models.ProductStatusPublished
+	}
+	if product.PublishAt != nil && product.UnpublishAt != nil && !product.UnpublishAt.After(*product.PublishAt) {
+		utils.ValidationErrorResponse(c, "unpublishAt must be after publishAt")
+		return
+	}
 
 	if err := h.productRepo.Create(ctx, product); err != nil {
 		utils.ErrorResponse(c, 500, "Failed to create product")
@@ -139,9 +250,6 @@ func (h *ProductHandler) Update(c *gin.Context) {
 	if req.ShortDescription != nil {
 		product.ShortDescription = *req.ShortDescription
 	}
-	if req.BasePrice != nil {
-		product.BasePrice = *req.BasePrice
-	}
 	if req.Images != nil {
 		product.Images = req.Images
 	}
@@ -157,12 +265,34 @@ func (h *ProductHandler) Update(c *gin.Context) {
 	if req.MinQuantity != nil {
 		product.MinQuantity = *req.MinQuantity
 	}
+	if req.TaxExempt != nil {
+		product.TaxExempt = *req.TaxExempt
+	}
+	if req.Status != nil {
+		product.Status = *req.Status
+	}
+	if req.CostPrice != nil {
+		product.CostPrice = req.CostPrice
+		if req.CostPrice.IsZero() {
+			product.CostPrice = nil
+		}
+	}
 
 	if err := h.productRepo.Update(ctx, product); err != nil {
 		utils.ErrorResponse(c, 500, "Failed to update product")
 		return
 	}
 
+	// The base price is set separately so the change is recorded in its history
+	if req.BasePrice != nil {
+		adminID := c.MustGet("userID").(uuid.UUID)
+		if err := h.priceChangeRepo.SetPrice(ctx, product.ID, *req.BasePrice, strings.TrimSpace(req.PriceReason), adminID); err != nil {
+			fmt.Printf("ERROR: Failed to update price of product %s: %v\n", product.ID, err)
+			utils.ErrorResponse(c, 500, "Failed to update product price")
+			return
+		}
+	}
+
 	product, _ = h.productRepo.GetByID(ctx, product.ID)
 	utils.SuccessResponse(c, 200, product)
 }
@@ -177,84 +307,132 @@ func (h *ProductHandler) Delete(c *gin.Context) {
 	fmt.Printf("DEBUG: Attempting to delete product with ID: %s\n", id)
 
 	ctx := context.Background()
-	if err := h.productRepo.Delete(ctx, id); err != nil {
+	found, err := h.productRepo.Delete(ctx, id)
+	if err != nil {
 		fmt.Printf("DEBUG: Delete failed with error: %v\n", err)
-		// Check if it's a foreign key constraint error
-		if strings.Contains(strings.ToLower(err.Error()), "violates foreign key constraint") ||
-			strings.Contains(strings.ToLower(err.Error()), "is still referenced") {
-			utils.ErrorResponse(c, 400, "Cannot delete product: it is referenced by existing orders. Consider archiving it instead.")
-			return
-		}
 		utils.ErrorResponse(c, 500, "Failed to delete product")
 		return
 	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
 
 	fmt.Printf("DEBUG: Successfully deleted product with ID: %s\n", id)
 	utils.SuccessMessageResponse(c, 200, "Product deleted successfully")
 }
 
-// BulkUpdatePrice updates prices for multiple products at once
-func (h *ProductHandler) BulkUpdatePrice(c *gin.Context) {
-	var req models.BulkUpdatePriceRequest
+// Restore brings back a deleted product with the status it had
+func (h *ProductHandler) Restore(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	ctx := context.Background()
+	found, err := h.productRepo.Restore(ctx, id)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to restore product")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Deleted product not found")
+		return
+	}
+
+	product, _ := h.productRepo.GetByID(ctx, id)
+	utils.SuccessResponse(c, 200, product)
+}
+
+// SetSchedule replaces when a product is next published and archived
+func (h *ProductHandler) SetSchedule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid product ID")
+		return
+	}
+
+	var req models.ProductScheduleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ValidationErrorResponse(c, err.Error())
 		return
 	}
+	if req.PublishAt != nil && req.UnpublishAt != nil && !req.UnpublishAt.After(*req.PublishAt) {
+		utils.ValidationErrorResponse(c, "unpublishAt must be after publishAt")
+		return
+	}
 
 	ctx := context.Background()
-	var updatedCount, failedCount int
-	var failedIDs []string
-
-	for _, productID := range req.ProductIDs {
-		product, err := h.productRepo.GetByID(ctx, productID)
-		if err != nil || product == nil {
-			failedCount++
-			failedIDs = append(failedIDs, productID.String())
-			continue
-		}
-
-		// Calculate new price based on update type
-		var newPrice float64
-		switch req.UpdateType {
-		case "set":
-			// Set exact price
-			newPrice = req.Value
-		case "increase":
-			// Increase by fixed amount
-			newPrice = product.BasePrice + req.Value
-		case "decrease":
-			// Decrease by fixed amount
-			newPrice = product.BasePrice - req.Value
-			if newPrice < 0 {
-				newPrice = 0
-			}
-		case "percentage":
-			// Adjust by percentage (positive = increase, negative = decrease)
-			newPrice = product.BasePrice * (1 + req.Value/100)
-			if newPrice < 0 {
-				newPrice = 0
-			}
-		default:
-			failedCount++
-			failedIDs = append(failedIDs, productID.String())
-			continue
-		}
+	found, err := h.productRepo.SetSchedule(ctx, id, req.PublishAt, req.UnpublishAt)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to schedule product")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Product not found")
+		return
+	}
 
-		product.BasePrice = newPrice
-		if err := h.productRepo.Update(ctx, product); err != nil {
-			failedCount++
-			failedIDs = append(failedIDs, productID.String())
-			continue
-		}
+	product, _ := h.productRepo.GetByID(ctx, id)
+	utils.SuccessResponse(c, 200, product)
+}
 
-		updatedCount++
+// BulkUpdatePrice updates prices for multiple products at once, as one price
+// change that can be rolled back. With a future effectiveAt the change is
+// scheduled and applied then. If any of the new prices are not allowed nothing
+// changes, and the preview is returned so they can be seen.
+func (h *ProductHandler) BulkUpdatePrice(c *gin.Context) {
+	req, ok := bindBulkUpdatePrice(c)
+	if !ok {
+		return
 	}
 
-	response := models.BulkUpdatePriceResponse{
-		UpdatedCount: updatedCount,
-		FailedCount:  failedCount,
-		FailedIDs:    failedIDs,
+	adminID := c.MustGet("userID").(uuid.UUID)
+	response, err := h.priceChangeService.Submit(context.Background(), req, adminID)
+	var violationErr *models.PriceViolationError
+	if errors.As(err, &violationErr) {
+		utils.ErrorResponseWithData(c, 400, err.Error(), violationErr.Preview)
+		return
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Failed to update prices: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to update prices")
+		return
 	}
 
 	utils.SuccessResponse(c, 200, response)
 }
+
+// PreviewBulkUpdatePrice shows the base and tier prices a bulk update would give
+// each product, and any that would be negative or below cost, without saving
+// anything
+func (h *ProductHandler) PreviewBulkUpdatePrice(c *gin.Context) {
+	req, ok := bindBulkUpdatePrice(c)
+	if !ok {
+		return
+	}
+
+	preview, err := h.priceChangeService.Preview(context.Background(), req)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to preview price update: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to preview price update")
+		return
+	}
+	utils.SuccessResponse(c, 200, preview)
+}
+
+func bindBulkUpdatePrice(c *gin.Context) (*models.BulkUpdatePriceRequest, bool) {
+	var req models.BulkUpdatePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return nil, false
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Search = strings.TrimSpace(req.Search)
+	if !req.HasSelection() {
+		utils.ValidationErrorResponse(c, "Choose products by productIds, categoryId or search")
+		return nil, false
+	}
+	return &req, true
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/quikprint/backend/internal/models"
+)
+
+func TestIsStaff(t *testing.T) {
+	tests := []struct {
+		name string
+		role interface{}
+		want bool
+	}{
+		{"admin", models.RoleAdmin, true},
+		{"manager", models.RoleManager, true},
+		{"customer", models.RoleCustomer, false},
+		{"unknown role", models.UserRole("guest"), false},
+		{"no role", nil, false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if tt.role != nil {
+			c.Set("userRole", tt.role)
+		}
+		if got := isStaff(c); got != tt.want {
+			t.Errorf("%s: isStaff = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type QuoteHandler struct {
+	quoteService *services.QuoteService
+	quoteRepo    *repository.QuoteRepository
+	notifier     *services.NotificationService
+}
+
+func NewQuoteHandler(quoteService *services.QuoteService, quoteRepo *repository.QuoteRepository, notifier *services.NotificationService) *QuoteHandler {
+	return &QuoteHandler{
+		quoteService: quoteService,
+		quoteRepo:    quoteRepo,
+		notifier:     notifier,
+	}
+}
+
+// CreateQuote prices the requested items, or the customer's cart, and saves them as a quote
+func (h *QuoteHandler) CreateQuote(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req models.CreateQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	quote, err := h.quoteService.Create(ctx, userID, &req)
+	if respondPricingInputError(c, err) {
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNothingToQuote):
+			utils.ErrorResponse(c, 400, "Cart is empty")
+		case errors.Is(err, services.ErrQuoteProductNotFound):
+			utils.ErrorResponse(c, 404, "One or more products in the quote were not found")
+		default:
+			fmt.Printf("ERROR: Failed to create quote: %v\n", err)
+			utils.ErrorResponse(c, 500, "Failed to create quote")
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, 201, quote)
+}
+
+func (h *QuoteHandler) GetQuotes(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	ctx := context.Background()
+
+	quotes, err := h.quoteRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch quotes")
+		return
+	}
+	if quotes == nil {
+		quotes = []models.Quote{}
+	}
+
+	utils.SuccessResponse(c, 200, quotes)
+}
+
+func (h *QuoteHandler) GetQuote(c *gin.Context) {
+	quote, ok := h.ownQuote(c)
+	if !ok {
+		return
+	}
+	utils.SuccessResponse(c, 200, quote)
+}
+
+// GetQuotePDF downloads the customer's quote as a PDF
+func (h *QuoteHandler) GetQuotePDF(c *gin.Context) {
+	quote, ok := h.ownQuote(c)
+	if !ok {
+		return
+	}
+	h.sendPDF(c, quote)
+}
+
+// ConvertQuote orders the customer's quote at its quoted prices
+func (h *QuoteHandler) ConvertQuote(c *gin.Context) {
+	var req models.ConvertQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	req.CouponCode = strings.TrimSpace(req.CouponCode)
+
+	quote, ok := h.ownQuote(c)
+	if !ok {
+		return
+	}
+
+	ctx := context.Background()
+	order, err := h.quoteService.Convert(ctx, quote, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrQuoteExpired), errors.Is(err, models.ErrQuoteConverted):
+			utils.ErrorResponse(c, 409, err.Error())
+		case models.IsCouponError(err):
+			utils.ErrorResponse(c, 400, err.Error())
+		default:
+			fmt.Printf("ERROR: Failed to order quote %s: %v\n", quote.QuoteNumber, err)
+			utils.ErrorResponse(c, 500, "Failed to create order")
+		}
+		return
+	}
+
+	h.notifier.OrderCreated(order)
+
+	utils.SuccessResponse(c, 201, order)
+}
+
+// GetSharedQuote returns the quote a share link points to
+func (h *QuoteHandler) GetSharedQuote(c *gin.Context) {
+	quote, ok := h.sharedQuote(c)
+	if !ok {
+		return
+	}
+	utils.SuccessResponse(c, 200, quote)
+}
+
+// GetSharedQuotePDF downloads the quote a share link points to as a PDF
+func (h *QuoteHandler) GetSharedQuotePDF(c *gin.Context) {
+	quote, ok := h.sharedQuote(c)
+	if !ok {
+		return
+	}
+	h.sendPDF(c, quote)
+}
+
+// Admin endpoints
+func (h *QuoteHandler) GetAllQuotes(c *gin.Context) {
+	status := models.QuoteStatus(c.Query("status"))
+	switch status {
+	case "", models.QuoteStatusOpen, models.QuoteStatusExpired, models.QuoteStatusConverted:
+	default:
+		utils.ValidationErrorResponse(c, fmt.Sprintf("Invalid quote status: %s", status))
+		return
+	}
+
+	ctx := context.Background()
+	quotes, err := h.quoteRepo.GetAll(ctx, status)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch quotes")
+		return
+	}
+	if quotes == nil {
+		quotes = []models.Quote{}
+	}
+
+	utils.SuccessResponse(c, 200, quotes)
+}
+
+// GetQuoteAdmin returns a quote with the price overrides made to it
+func (h *QuoteHandler) GetQuoteAdmin(c *gin.Context) {
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid quote ID")
+		return
+	}
+
+	ctx := context.Background()
+	quote, err := h.quoteRepo.GetByID(ctx, quoteID)
+	if err != nil || quote == nil {
+		utils.ErrorResponse(c, 404, "Quote not found")
+		return
+	}
+	if err := h.quoteRepo.LoadOverrides(ctx, quote); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price overrides")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, quote)
+}
+
+// OverrideQuotePrice sets the price of a line on an open quote, recording who
+// changed it and why
+func (h *QuoteHandler) OverrideQuotePrice(c *gin.Context) {
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid quote ID")
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("itemId"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid quote item ID")
+		return
+	}
+
+	var req models.OverrideQuotePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	adminID := c.MustGet("userID").(uuid.UUID)
+	ctx := context.Background()
+
+	found, err := h.quoteRepo.OverridePrice(ctx, quoteID, itemID, req.TotalPrice, strings.TrimSpace(req.Reason), adminID)
+	if err != nil {
+		if errors.Is(err, models.ErrQuoteExpired) || errors.Is(err, models.ErrQuoteConverted) {
+			utils.ErrorResponse(c, 409, err.Error())
+			return
+		}
+		fmt.Printf("ERROR: Failed to override quote price: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to override price")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Quote item not found")
+		return
+	}
+
+	quote, err := h.quoteRepo.GetByID(ctx, quoteID)
+	if err != nil || quote == nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch quote")
+		return
+	}
+	if err := h.quoteRepo.LoadOverrides(ctx, quote); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch price overrides")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, quote)
+}
+
+// ownQuote loads the quote in the URL, responding with an error unless it
+// belongs to the current user
+func (h *QuoteHandler) ownQuote(c *gin.Context) (*models.Quote, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid quote ID")
+		return nil, false
+	}
+
+	quote, err := h.quoteRepo.GetByID(context.Background(), quoteID)
+	if err != nil || quote == nil {
+		utils.ErrorResponse(c, 404, "Quote not found")
+		return nil, false
+	}
+
+	// Verify ownership
+	if quote.UserID != userID {
+		utils.ErrorResponse(c, 403, "Access denied")
+		return nil, false
+	}
+	return quote, true
+}
+
+// sharedQuote loads the quote for the share token in the URL, without the
+// owner's ID or the token itself
+func (h *QuoteHandler) sharedQuote(c *gin.Context) (*models.Quote, bool) {
+	quote, err := h.quoteRepo.GetByShareToken(context.Background(), c.Param("token"))
+	if err != nil || quote == nil {
+		utils.ErrorResponse(c, 404, "Quote not found")
+		return nil, false
+	}
+	quote.UserID = uuid.Nil
+	quote.ShareToken = ""
+	return quote, true
+}
+
+func (h *QuoteHandler) sendPDF(c *gin.Context, quote *models.Quote) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, quote.QuoteNumber))
+	c.Data(200, "application/pdf", h.quoteService.RenderPDF(quote))
+}
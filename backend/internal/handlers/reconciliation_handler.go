@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type ReconciliationHandler struct {
+	reconciler *services.PaymentReconciler
+	reconRepo  *repository.ReconciliationRepository
+}
+
+func NewReconciliationHandler(reconciler *services.PaymentReconciler, reconRepo *repository.ReconciliationRepository) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciler: reconciler,
+		reconRepo:  reconRepo,
+	}
+}
+
+// RunReconciliation reconciles pending payments now and returns the report
+func (h *ReconciliationHandler) RunReconciliation(c *gin.Context) {
+	run, err := h.reconciler.Run(context.Background(), models.ReconciliationTriggerManual)
+	if errors.Is(err, models.ErrReconciliationRunning) {
+		utils.ErrorResponse(c, 409, err.Error())
+		return
+	}
+	if err != nil {
+		fmt.Printf("ERROR: Manual payment reconciliation failed: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to reconcile payments")
+		return
+	}
+	if run.Items == nil {
+		run.Items = []models.ReconciliationItem{}
+	}
+
+	utils.SuccessResponse(c, 200, run)
+}
+
+// GetRuns lists reconciliation runs, newest first
+func (h *ReconciliationHandler) GetRuns(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	ctx := context.Background()
+	runs, err := h.reconRepo.GetRuns(ctx, limit, offset)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch reconciliation runs")
+		return
+	}
+	if runs == nil {
+		runs = []models.ReconciliationRun{}
+	}
+
+	utils.SuccessResponse(c, 200, runs)
+}
+
+// GetRun returns a run's report; ?outcome=mismatch narrows it to one outcome
+func (h *ReconciliationHandler) GetRun(c *gin.Context) {
+	runID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid reconciliation run ID")
+		return
+	}
+
+	ctx := context.Background()
+	run, err := h.reconRepo.GetRun(ctx, runID, c.Query("outcome"))
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch reconciliation run")
+		return
+	}
+	if run == nil {
+		utils.ErrorResponse(c, 404, "Reconciliation run not found")
+		return
+	}
+
+	utils.SuccessResponse(c, 200, run)
+}
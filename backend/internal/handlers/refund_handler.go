@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+type RefundHandler struct {
+	refundService *services.RefundService
+	paymentRepo   *repository.PaymentRepository
+	refundRepo    *repository.RefundRepository
+}
+
+func NewRefundHandler(refundService *services.RefundService, paymentRepo *repository.PaymentRepository, refundRepo *repository.RefundRepository) *RefundHandler {
+	return &RefundHandler{
+		refundService: refundService,
+		paymentRepo:   paymentRepo,
+		refundRepo:    refundRepo,
+	}
+}
+
+// GetOrderPayments lists every payment attempt for an order, so staff can pick the
+// payment to refund
+func (h *RefundHandler) GetOrderPayments(c *gin.Context) {
+	orderID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid order ID")
+		return
+	}
+
+	ctx := context.Background()
+	payments, err := h.paymentRepo.GetAllByOrderID(ctx, orderID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch payments")
+		return
+	}
+	if payments == nil {
+		payments = []models.Payment{}
+	}
+
+	utils.SuccessResponse(c, 200, payments)
+}
+
+// CreateRefund refunds all or part of a payment through its provider
+func (h *RefundHandler) CreateRefund(c *gin.Context) {
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid payment ID")
+		return
+	}
+
+	var req models.CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	payment, err := h.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch payment")
+		return
+	}
+	if payment == nil {
+		utils.ErrorResponse(c, 404, "Payment not found")
+		return
+	}
+
+	userID := c.MustGet("userID").(uuid.UUID)
+	refund, err := h.refundService.Refund(ctx, payment, req.Amount, req.Reason, userID)
+	switch {
+	case errors.Is(err, models.ErrPaymentNotRefundable), errors.Is(err, models.ErrRefundExceedsPayment):
+		utils.ErrorResponse(c, 409, err.Error())
+		return
+	case err != nil:
+		fmt.Printf("ERROR: Refund of payment %s failed: %v\n", paymentID, err)
+		utils.ErrorResponse(c, 500, "Failed to refund payment: "+err.Error())
+		return
+	}
+
+	utils.SuccessResponse(c, 201, refund)
+}
+
+// GetRefunds lists a payment's refunds with the amount refunded so far
+func (h *RefundHandler) GetRefunds(c *gin.Context) {
+	paymentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid payment ID")
+		return
+	}
+
+	ctx := context.Background()
+	payment, err := h.paymentRepo.GetByID(ctx, paymentID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch payment")
+		return
+	}
+	if payment == nil {
+		utils.ErrorResponse(c, 404, "Payment not found")
+		return
+	}
+
+	refunds, err := h.refundRepo.GetByPaymentID(ctx, paymentID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch refunds")
+		return
+	}
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+
+	refunded, err := h.refundRepo.RefundedAmount(ctx, paymentID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch refunds")
+		return
+	}
+
+	var refundable models.Money
+	if payment.Status == models.PaymentStatusSuccess || payment.Status == models.PaymentStatusPartiallyRefunded {
+		refundable = payment.Amount.Sub(refunded)
+	}
+
+	utils.SuccessResponse(c, 200, models.PaymentRefundsResponse{
+		Payment:          payment,
+		Refunds:          refunds,
+		RefundedAmount:   refunded,
+		RefundableAmount: refundable,
+	})
+}
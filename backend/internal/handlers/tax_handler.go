@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+	"github.com/quikprint/backend/internal/services"
+	"github.com/quikprint/backend/internal/utils"
+)
+
+// tinPattern matches a FIRS TIN (12345678-0001) or a JTB TIN (10 digits)
+var tinPattern = regexp.MustCompile(`^(\d{8}-\d{4}|\d{10})$`)
+
+type TaxHandler struct {
+	taxRepo    *repository.TaxRepository
+	taxService *services.TaxService
+	reportRepo *repository.ReportRepository
+	userRepo   *repository.UserRepository
+}
+
+func NewTaxHandler(taxRepo *repository.TaxRepository, taxService *services.TaxService, reportRepo *repository.ReportRepository, userRepo *repository.UserRepository) *TaxHandler {
+	return &TaxHandler{taxRepo: taxRepo, taxService: taxService, reportRepo: reportRepo, userRepo: userRepo}
+}
+
+// GetTaxSettingsPublic tells the storefront whether prices include VAT and the rate in force
+func (h *TaxHandler) GetTaxSettingsPublic(c *gin.Context) {
+	settings, err := h.taxService.PublicSettings(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax settings")
+		return
+	}
+	utils.SuccessResponse(c, 200, settings)
+}
+
+func (h *TaxHandler) GetTaxSettings(c *gin.Context) {
+	settings, err := h.taxRepo.GetSettings(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax settings")
+		return
+	}
+	utils.SuccessResponse(c, 200, settings)
+}
+
+// UpdateTaxSettings sets whether product prices include VAT. It applies to orders
+// placed from now on.
+func (h *TaxHandler) UpdateTaxSettings(c *gin.Context) {
+	var req models.UpdateTaxSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	if err := h.taxRepo.UpdateSettings(ctx, *req.PricesIncludeTax); err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update tax settings")
+		return
+	}
+
+	settings, err := h.taxRepo.GetSettings(ctx)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax settings")
+		return
+	}
+	utils.SuccessResponse(c, 200, settings)
+}
+
+func (h *TaxHandler) GetTaxRates(c *gin.Context) {
+	rates, err := h.taxRepo.GetRates(context.Background())
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax rates")
+		return
+	}
+	if rates == nil {
+		rates = []models.TaxRate{}
+	}
+	utils.SuccessResponse(c, 200, rates)
+}
+
+// CreateTaxRate adds a rate that takes over from the previous one at its effective date
+func (h *TaxHandler) CreateTaxRate(c *gin.Context) {
+	var req models.TaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	existing, err := h.taxRepo.GetRateByEffectiveFrom(ctx, req.EffectiveFrom)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to create tax rate")
+		return
+	}
+	if existing != nil {
+		utils.ErrorResponse(c, 409, "A tax rate already takes effect at that time")
+		return
+	}
+
+	rate := &models.TaxRate{Name: strings.TrimSpace(req.Name), Rate: req.Rate, EffectiveFrom: req.EffectiveFrom}
+	if err := h.taxRepo.CreateRate(ctx, rate); err != nil {
+		fmt.Printf("ERROR: Failed to create tax rate: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to create tax rate")
+		return
+	}
+	utils.SuccessResponse(c, 201, rate)
+}
+
+// UpdateTaxRate replaces a rate. Orders already placed keep the rate they were charged.
+func (h *TaxHandler) UpdateTaxRate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid tax rate ID")
+		return
+	}
+
+	var req models.TaxRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+
+	ctx := context.Background()
+	existing, err := h.taxRepo.GetRateByEffectiveFrom(ctx, req.EffectiveFrom)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to update tax rate")
+		return
+	}
+	if existing != nil && existing.ID != id {
+		utils.ErrorResponse(c, 409, "A tax rate already takes effect at that time")
+		return
+	}
+
+	rate := &models.TaxRate{ID: id, Name: strings.TrimSpace(req.Name), Rate: req.Rate, EffectiveFrom: req.EffectiveFrom}
+	found, err := h.taxRepo.UpdateRate(ctx, rate)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to update tax rate: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to update tax rate")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Tax rate not found")
+		return
+	}
+
+	rate, err = h.taxRepo.GetRateByID(ctx, id)
+	if err != nil || rate == nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax rate")
+		return
+	}
+	utils.SuccessResponse(c, 200, rate)
+}
+
+func (h *TaxHandler) DeleteTaxRate(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid tax rate ID")
+		return
+	}
+
+	found, err := h.taxRepo.DeleteRate(context.Background(), id)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete tax rate")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "Tax rate not found")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Tax rate deleted successfully")
+}
+
+func (h *TaxHandler) GetCustomerTaxProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid user ID")
+		return
+	}
+
+	profile, err := h.taxRepo.GetCustomerProfile(context.Background(), userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to fetch tax profile")
+		return
+	}
+	if profile == nil {
+		utils.ErrorResponse(c, 404, "No tax profile on file")
+		return
+	}
+	utils.SuccessResponse(c, 200, profile)
+}
+
+// UpdateCustomerTaxProfile records a business customer's TIN and whether they are
+// exempt from VAT
+func (h *TaxHandler) UpdateCustomerTaxProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid user ID")
+		return
+	}
+
+	var req models.UpdateCustomerTaxProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ValidationErrorResponse(c, err.Error())
+		return
+	}
+	tin := strings.TrimSpace(req.TIN)
+	if !tinPattern.MatchString(tin) {
+		utils.ValidationErrorResponse(c, "TIN must be 12345678-0001 or 10 digits")
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.userRepo.GetByID(ctx, userID)
+	if err != nil || user == nil {
+		utils.ErrorResponse(c, 404, "User not found")
+		return
+	}
+
+	adminID := c.MustGet("userID").(uuid.UUID)
+	profile := &models.CustomerTaxProfile{
+		UserID:      userID,
+		CompanyName: strings.TrimSpace(req.CompanyName),
+		TIN:         tin,
+		TaxExempt:   req.TaxExempt,
+		UpdatedBy:   &adminID,
+	}
+	if err := h.taxRepo.SaveCustomerProfile(ctx, profile); err != nil {
+		fmt.Printf("ERROR: Failed to save tax profile: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to save tax profile")
+		return
+	}
+	utils.SuccessResponse(c, 200, profile)
+}
+
+func (h *TaxHandler) DeleteCustomerTaxProfile(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ValidationErrorResponse(c, "Invalid user ID")
+		return
+	}
+
+	found, err := h.taxRepo.DeleteCustomerProfile(context.Background(), userID)
+	if err != nil {
+		utils.ErrorResponse(c, 500, "Failed to delete tax profile")
+		return
+	}
+	if !found {
+		utils.ErrorResponse(c, 404, "No tax profile on file")
+		return
+	}
+	utils.SuccessMessageResponse(c, 200, "Tax profile deleted successfully")
+}
+
+// GetVATReport summarises the VAT on paid orders placed between the from and to
+// dates, both included. It defaults to the previous calendar month, the period a
+// monthly VAT return covers.
+func (h *TaxHandler) GetVATReport(c *gin.Context) {
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	from := thisMonth.AddDate(0, -1, 0)
+	to := thisMonth
+
+	if v := c.Query("from"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			utils.ValidationErrorResponse(c, "from must be a date such as 2026-01-31")
+			return
+		}
+		from = d
+	}
+	if v := c.Query("to"); v != "" {
+		d, err := time.ParseInLocation("2006-01-02", v, now.Location())
+		if err != nil {
+			utils.ValidationErrorResponse(c, "to must be a date such as 2026-01-31")
+			return
+		}
+		to = d.AddDate(0, 0, 1)
+	}
+	if !from.Before(to) {
+		utils.ValidationErrorResponse(c, "from must not be after to")
+		return
+	}
+
+	report, err := h.reportRepo.GetVATSummary(context.Background(), from, to)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to build VAT report: %v\n", err)
+		utils.ErrorResponse(c, 500, "Failed to fetch VAT report")
+		return
+	}
+	utils.SuccessResponse(c, 200, report)
+}
@@ -0,0 +1,393 @@
+// Package migrate applies the versioned SQL migrations and records them in the
+// schema_migrations table. Each migration runs in its own transaction together
+// with its tracking row, and an advisory lock keeps two runners from migrating the
+// same database at once.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// lockKey is the advisory lock held while migrations run
+const lockKey = 730842
+
+var (
+	ErrLocked         = errors.New("another migration is already running")
+	ErrUnknownVersion = errors.New("no migration with that version")
+)
+
+var (
+	fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	nonWord  = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// Migration is one schema version with the SQL to apply and revert it
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Load reads the migrations in fsys, ordered by version. Every version needs an up
+// file; a missing down file means the migration cannot be rolled back.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+type Migrator struct {
+	db         *pgxpool.Pool
+	migrations []Migration
+}
+
+func New(db *pgxpool.Pool, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest returns the highest known version, or 0 if there are no migrations
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status lists every migration with when it was applied, if it has been. It does
+// not create the tracking table, so it is safe to call against any database.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			statuses[i].AppliedAt = &at
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations not yet applied, oldest first
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Up applies every pending migration and returns those it applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.Goto(ctx, m.Latest())
+}
+
+// Down rolls back the n most recently applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Goto migrates up or down so that exactly the migrations up to and including
+// version are applied. Version 0 rolls back everything.
+func (m *Migrator) Goto(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && m.find(version) < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Roll back newer migrations first, newest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+			if err := m.revert(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		// Then apply anything missing up to the target, oldest first. Gaps are
+		// filled too, so a migration merged out of order still runs.
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Baseline records the migrations up to and including version as applied without
+// running them. It is for databases that were migrated by hand before the
+// tracking table existed.
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]Migration, error) {
+	if m.find(version) < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var done []Migration
+	err := m.locked(ctx, func(conn *pgxpool.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+			_, err := conn.Exec(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+				migration.Version, migration.Name, time.Now(),
+			)
+			if err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// locked runs fn on a connection holding the migration lock, creating the
+// tracking table first
+func (m *Migrator) locked(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var acquired bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, lockKey).Scan(&acquired); err != nil {
+		return err
+	}
+	if !acquired {
+		return ErrLocked
+	}
+	defer func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			// Closing the connection drops the lock
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// applied returns the applied versions with when they were applied. A database
+// without the tracking table has nothing applied.
+func (m *Migrator) applied(ctx context.Context, db querier) (map[int64]time.Time, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]time.Time)
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := db.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+	}
+	_, err = tx.Exec(ctx,
+		`INSERT INTO schema_migrations (version, name, applied_at) VALUES ($1, $2, $3)`,
+		migration.Version, migration.Name, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (m *Migrator) revert(ctx context.Context, conn *pgxpool.Conn, migration Migration) error {
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, migration.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (m *Migrator) find(version int64) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// Create writes empty up and down files for a new migration numbered after the
+// newest one in dir, and returns their paths
+func Create(dir, name string) (up, down string, err error) {
+	name = strings.Trim(strings.ToLower(nonWord.ReplaceAllString(name, "_")), "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var next int64 = 1
+	if len(migrations) > 0 {
+		next = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := fmt.Sprintf("%03d_%s", next, name)
+	up = filepath.Join(dir, base+".up.sql")
+	down = filepath.Join(dir, base+".down.sql")
+	if err := os.WriteFile(up, []byte(fmt.Sprintf("-- %s\n", base)), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte(fmt.Sprintf("-- Revert %s\n", base)), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
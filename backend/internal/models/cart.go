@@ -13,7 +13,7 @@ type CartItem struct {
 	Product       *Product               `json:"product,omitempty"`
 	Quantity      int                    `json:"quantity"`
 	Configuration map[string]interface{} `json:"configuration"`
-	TotalPrice    float64                `json:"totalPrice"`
+	TotalPrice    Money                  `json:"totalPrice"`
 	UploadedFile  *string                `json:"uploadedFile,omitempty"`
 	CreatedAt     time.Time              `json:"createdAt"`
 	UpdatedAt     time.Time              `json:"updatedAt"`
@@ -32,7 +32,7 @@ type UpdateCartItemRequest struct {
 
 type Cart struct {
 	Items    []CartItem `json:"items"`
-	Subtotal float64    `json:"subtotal"`
+	Subtotal Money      `json:"subtotal"`
 	Count    int        `json:"count"`
 }
 
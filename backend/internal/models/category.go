@@ -1,27 +1,65 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// Category is a node in the category tree. ProductCount counts the published
+// products in it and all of its subcategories.
 type Category struct {
-	ID           uuid.UUID `json:"id"`
-	Name         string    `json:"name"`
-	Slug         string    `json:"slug"`
-	Description  string    `json:"description"`
-	Image        string    `json:"image"`
+	ID           uuid.UUID  `json:"id"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	Description  string     `json:"description"`
+	Image        string     `json:"image"`
+	ParentID     *uuid.UUID `json:"parentId,omitempty"`
+	DisplayOrder int        `json:"displayOrder"`
+	// Depth is how many ancestors the category has; top-level categories are 0
+	Depth        int       `json:"depth"`
 	ProductCount int       `json:"productCount"`
+	TaxExempt    bool      `json:"taxExempt"`
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
+	// Path runs from the top-level category down to this one, for breadcrumbs
+	Path []CategoryCrumb `json:"path,omitempty"`
+	// Children are the direct subcategories, in display order
+	Children []Category `json:"children,omitempty"`
+}
+
+// CategoryCrumb is one step in a category's breadcrumb path
+type CategoryCrumb struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+// ErrCategoryCycle is returned when a category would be moved under itself or
+// one of its own subcategories
+var ErrCategoryCycle = errors.New("a category cannot be moved under itself or one of its subcategories")
+
+// CategoryInUseError is returned when deleting a category that still has
+// subcategories or products, without saying where to move them
+type CategoryInUseError struct {
+	SubcategoryCount int `json:"subcategoryCount"`
+	ProductCount     int `json:"productCount"`
+}
+
+func (e *CategoryInUseError) Error() string {
+	return fmt.Sprintf("category has %d subcategories and %d products; move them first or delete with reassignTo",
+		e.SubcategoryCount, e.ProductCount)
 }
 
 type CreateCategoryRequest struct {
-	Name        string `json:"name" binding:"required"`
-	Slug        string `json:"slug" binding:"required"`
-	Description string `json:"description"`
-	Image       string `json:"image"`
+	Name        string     `json:"name" binding:"required"`
+	Slug        string     `json:"slug" binding:"required"`
+	Description string     `json:"description"`
+	Image       string     `json:"image"`
+	TaxExempt   bool       `json:"taxExempt"`
+	ParentID    *uuid.UUID `json:"parentId"`
 }
 
 type UpdateCategoryRequest struct {
@@ -29,5 +67,17 @@ type UpdateCategoryRequest struct {
 	Slug        *string `json:"slug"`
 	Description *string `json:"description"`
 	Image       *string `json:"image"`
+	TaxExempt   *bool   `json:"taxExempt"`
+	// ParentID moves the category; MoveToTop makes it a top-level category
+	ParentID  *uuid.UUID `json:"parentId"`
+	MoveToTop bool       `json:"moveToTop"`
+}
+
+// ReorderCategoriesRequest puts categories under a parent, or at the top level
+// without one, in the order given. The parent's other subcategories follow them
+// in the order they were in.
+type ReorderCategoriesRequest struct {
+	ParentID    *uuid.UUID  `json:"parentId"`
+	CategoryIDs []uuid.UUID `json:"categoryIds" binding:"required,min=1"`
 }
 
@@ -0,0 +1,337 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Configuration keys the pricing service reads itself. They are accepted on any
+// product; the rest of a configuration must be the product's options. Finishing
+// maps edge finishing IDs to true for all edges or to a list of edges.
+const (
+	ConfigQuantity  = "quantity"
+	ConfigWidth     = "width"
+	ConfigHeight    = "height"
+	ConfigRush      = "rush"
+	ConfigAddOns    = "addOns"
+	ConfigFinishing = "finishing"
+)
+
+// FieldError is a problem with one field of an item configuration
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ConfigurationError lists everything wrong with an item configuration
+type ConfigurationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ConfigurationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+func (e *ConfigurationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// OptionDependency makes an option available only while another option has one of
+// the given values, e.g. a finish that only applies to gloss paper
+type OptionDependency struct {
+	Option string   `json:"option"`
+	Values []string `json:"values"`
+}
+
+// Selected returns the values chosen for a select, radio or checkbox option. A
+// checkbox takes a list of values, or true when it has a single value.
+func (o *ProductOption) Selected(val interface{}) []ProductOptionValue {
+	var chosen []string
+	switch o.Type {
+	case OptionTypeSelect, OptionTypeRadio:
+		if s, ok := optionString(val); ok {
+			chosen = []string{s}
+		}
+	case OptionTypeCheckbox:
+		switch v := val.(type) {
+		case bool:
+			if v && len(o.Options) == 1 {
+				chosen = []string{o.Options[0].Value}
+			}
+		case []interface{}:
+			for _, item := range v {
+				if s, ok := optionString(item); ok {
+					chosen = append(chosen, s)
+				}
+			}
+		}
+	}
+
+	var selected []ProductOptionValue
+	for _, s := range chosen {
+		for _, opt := range o.Options {
+			if opt.Value == s {
+				selected = append(selected, opt)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// ValidateConfiguration checks an item configuration and quantity against the
+// product's options. It returns a *ConfigurationError listing every problem, or
+// nil. A quantity of 0 means the configuration's quantity is used, or 1 if it has
+// none, as that is what the line is priced at.
+func (p *Product) ValidateConfiguration(config map[string]interface{}, quantity int) error {
+	errs := &ConfigurationError{}
+	options := make(map[string]*ProductOption, len(p.Options))
+	for i := range p.Options {
+		options[p.Options[i].ID] = &p.Options[i]
+	}
+
+	for i := range p.Options {
+		option := &p.Options[i]
+		val, set := config[option.ID]
+		set = set && val != nil
+
+		if dep := option.DependsOn; dep != nil && !p.dependencyMet(dep, config, options) {
+			if set {
+				errs.add(option.ID, "is only available when %s is %s", dep.Option, strings.Join(dep.Values, " or "))
+			}
+			continue
+		}
+		if !set {
+			if option.Required {
+				errs.add(option.ID, "is required")
+			}
+			continue
+		}
+		validateOption(errs, option, val)
+	}
+
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		if _, ok := options[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		val := config[key]
+		switch key {
+		case ConfigQuantity:
+			if _, ok := WholeNumber(val); !ok {
+				errs.add(key, "must be a whole number")
+			}
+		case ConfigWidth, ConfigHeight:
+			if n, ok := val.(float64); !ok || n <= 0 {
+				errs.add(key, "must be a positive number")
+			}
+		case ConfigRush:
+			if _, ok := val.(bool); !ok {
+				errs.add(key, "must be true or false")
+			}
+		case ConfigAddOns:
+			if _, ok := val.([]interface{}); !ok {
+				errs.add(key, "must be a list of add-on IDs")
+			}
+		case ConfigFinishing:
+			// The finishings themselves are checked with the dimensional pricing
+			if _, ok := val.(map[string]interface{}); !ok {
+				errs.add(key, "must map finishings to edges")
+			}
+		default:
+			errs.add(key, "is not an option of this product")
+		}
+	}
+
+	if quantity == 0 {
+		quantity = 1
+		if n, ok := WholeNumber(config[ConfigQuantity]); ok {
+			quantity = n
+		}
+	}
+	if quantity < 0 {
+		errs.add(ConfigQuantity, "must not be negative")
+	} else if quantity < p.MinQuantity {
+		errs.add(ConfigQuantity, "must be at least %d", p.MinQuantity)
+	}
+
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// OptionLabels describes the options chosen in a configuration by their labels,
+// in the product's option order, e.g. "Paper Stock: 350gsm Premium"
+func (p *Product) OptionLabels(config map[string]interface{}) []OptionLabel {
+	var labels []OptionLabel
+	for i := range p.Options {
+		option := &p.Options[i]
+		val, ok := config[option.ID]
+		if !ok || val == nil {
+			continue
+		}
+
+		var value string
+		switch option.Type {
+		case OptionTypeSelect, OptionTypeRadio, OptionTypeCheckbox:
+			selected := option.Selected(val)
+			if len(selected) == 0 {
+				continue
+			}
+			names := make([]string, len(selected))
+			for j, opt := range selected {
+				names[j] = opt.Label
+				if names[j] == "" {
+					names[j] = opt.Value
+				}
+			}
+			value = strings.Join(names, ", ")
+		default:
+			s, ok := optionString(val)
+			if !ok {
+				continue
+			}
+			value = s
+			if option.Unit != nil && *option.Unit != "" {
+				value += " " + *option.Unit
+			}
+		}
+		labels = append(labels, OptionLabel{OptionID: option.ID, Name: option.Name, Value: value})
+	}
+	return labels
+}
+
+// dependencyMet reports whether the option an option depends on has one of the
+// required values
+func (p *Product) dependencyMet(dep *OptionDependency, config map[string]interface{}, options map[string]*ProductOption) bool {
+	other, ok := options[dep.Option]
+	if !ok {
+		return false
+	}
+	for _, selected := range other.Selected(config[dep.Option]) {
+		for _, v := range dep.Values {
+			if selected.Value == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func validateOption(errs *ConfigurationError, option *ProductOption, val interface{}) {
+	switch option.Type {
+	case OptionTypeSelect, OptionTypeRadio:
+		if len(option.Selected(val)) == 0 {
+			errs.add(option.ID, "must be one of %s", optionValues(option))
+		}
+	case OptionTypeCheckbox:
+		var count int
+		switch v := val.(type) {
+		case bool:
+			if len(option.Options) != 1 {
+				errs.add(option.ID, "must be a list of values")
+				return
+			}
+			if v {
+				count = 1
+			}
+		case []interface{}:
+			count = len(v)
+		default:
+			errs.add(option.ID, "must be a list of values")
+			return
+		}
+		if len(option.Selected(val)) != count {
+			errs.add(option.ID, "values must be from %s", optionValues(option))
+		} else if option.Required && count == 0 {
+			errs.add(option.ID, "is required")
+		}
+	case OptionTypeQuantity:
+		n, ok := WholeNumber(val)
+		if !ok {
+			errs.add(option.ID, "must be a whole number")
+			return
+		}
+		validateRange(errs, option, float64(n))
+	case OptionTypeDimension:
+		n, ok := val.(float64)
+		if !ok {
+			errs.add(option.ID, "must be a number")
+			return
+		}
+		validateRange(errs, option, n)
+	}
+}
+
+func validateRange(errs *ConfigurationError, option *ProductOption, n float64) {
+	unit := ""
+	if option.Unit != nil && *option.Unit != "" {
+		unit = " " + *option.Unit
+	}
+	switch {
+	case option.Min != nil && n < *option.Min:
+		errs.add(option.ID, "must be at least %s%s", formatNumber(*option.Min), unit)
+	case option.Max != nil && n > *option.Max:
+		errs.add(option.ID, "must be at most %s%s", formatNumber(*option.Max), unit)
+	case option.Step != nil && *option.Step > 0:
+		var from float64
+		if option.Min != nil {
+			from = *option.Min
+		}
+		steps := (n - from) / *option.Step
+		// Allow for binary rounding, e.g. 0.3 in steps of 0.1
+		if math.Abs(steps-math.Round(steps)) > 1e-9 {
+			errs.add(option.ID, "must be in steps of %s%s", formatNumber(*option.Step), unit)
+		}
+	}
+}
+
+func optionValues(option *ProductOption) string {
+	values := make([]string, len(option.Options))
+	for i, opt := range option.Options {
+		values[i] = opt.Value
+	}
+	return strings.Join(values, ", ")
+}
+
+// optionString reads an option value, accepting numbers for values such as "100"
+func optionString(val interface{}) (string, bool) {
+	switch v := val.(type) {
+	case string:
+		return v, true
+	case float64:
+		return formatNumber(v), true
+	}
+	return "", false
+}
+
+// WholeNumber reads a whole number sent as a number or a numeric string
+func WholeNumber(val interface{}) (int, bool) {
+	switch v := val.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), true
+		}
+	case int:
+		return v, true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
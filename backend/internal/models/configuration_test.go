@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateConfigurationQuantity(t *testing.T) {
+	product := &Product{MinQuantity: 50}
+	tests := []struct {
+		name     string
+		config   map[string]interface{}
+		quantity int
+		wantErr  bool
+	}{
+		{"request quantity", map[string]interface{}{}, 100, false},
+		{"request quantity too low", map[string]interface{}{}, 10, true},
+		{"configuration quantity", map[string]interface{}{"quantity": float64(50)}, 0, false},
+		{"configuration quantity too low", map[string]interface{}{"quantity": float64(49)}, 0, true},
+		{"request quantity wins", map[string]interface{}{"quantity": float64(10)}, 100, false},
+		{"no quantity counts as 1", map[string]interface{}{}, 0, true},
+		{"zero in the configuration", map[string]interface{}{"quantity": float64(0)}, 0, true},
+		{"negative", map[string]interface{}{}, -5, true},
+	}
+	for _, tt := range tests {
+		err := product.ValidateConfiguration(tt.config, tt.quantity)
+		var configErr *ConfigurationError
+		if tt.wantErr != errors.As(err, &configErr) {
+			t.Errorf("%s: error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr && (len(configErr.Fields) != 1 || configErr.Fields[0].Field != ConfigQuantity) {
+			t.Errorf("%s: errors = %v, want one for quantity", tt.name, configErr.Fields)
+		}
+	}
+
+	// Products without a minimum take a single unit by default
+	if err := (&Product{MinQuantity: 1}).ValidateConfiguration(map[string]interface{}{}, 0); err != nil {
+		t.Errorf("single unit: %v", err)
+	}
+}
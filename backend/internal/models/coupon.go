@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"math/big"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,14 +15,26 @@ const (
 	DiscountTypeFixed      DiscountType = "fixed"
 )
 
+// DiscountValue is what a coupon takes off: an amount in naira for fixed coupons
+// and a percentage for percentage ones. Both are exact to two decimal places, as
+// stored, and read and write like Money.
+type DiscountValue struct {
+	Money
+}
+
+// Percent returns the value as an exact percentage
+func (v DiscountValue) Percent() *big.Rat {
+	return v.Rat()
+}
+
 type Coupon struct {
 	ID               uuid.UUID    `json:"id"`
 	Code             string       `json:"code"`
 	Description      string       `json:"description"`
 	DiscountType     DiscountType `json:"discountType"`
-	DiscountValue    float64      `json:"discountValue"`
-	MinOrderAmount   float64      `json:"minOrderAmount"`
-	MaxDiscountAmount *float64    `json:"maxDiscountAmount,omitempty"`
+	DiscountValue    DiscountValue `json:"discountValue"`
+	MinOrderAmount   Money        `json:"minOrderAmount"`
+	MaxDiscountAmount *Money      `json:"maxDiscountAmount,omitempty"`
 	UsageLimit       *int         `json:"usageLimit,omitempty"`
 	UsedCount        int          `json:"usedCount"`
 	PerUserLimit     int          `json:"perUserLimit"`
@@ -36,18 +50,87 @@ type CouponUsage struct {
 	CouponID       uuid.UUID `json:"couponId"`
 	UserID         uuid.UUID `json:"userId"`
 	OrderID        *uuid.UUID `json:"orderId,omitempty"`
-	DiscountAmount float64   `json:"discountAmount"`
+	DiscountAmount Money     `json:"discountAmount"`
 	UsedAt         time.Time `json:"usedAt"`
 }
 
+// Coupon validation errors. The messages are shown to customers as-is.
+var (
+	ErrCouponNotFound       = errors.New("Coupon not found")
+	ErrCouponInactive       = errors.New("Coupon is not active")
+	ErrCouponNotYetValid    = errors.New("Coupon is not yet valid")
+	ErrCouponExpired        = errors.New("Coupon has expired")
+	ErrCouponMinOrderAmount = errors.New("Order total does not meet the minimum amount for this coupon")
+	ErrCouponUsageLimit     = errors.New("Coupon usage limit has been reached")
+	ErrCouponPerUserLimit   = errors.New("You have already used this coupon the maximum allowed times")
+	ErrCouponNoDiscount     = errors.New("Coupon does not provide a discount for this order amount")
+)
+
+// IsCouponError reports whether err is one of the coupon validation errors above.
+func IsCouponError(err error) bool {
+	for _, target := range []error{
+		ErrCouponNotFound, ErrCouponInactive, ErrCouponNotYetValid, ErrCouponExpired,
+		ErrCouponMinOrderAmount, ErrCouponUsageLimit, ErrCouponPerUserLimit, ErrCouponNoDiscount,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckValidity verifies that the coupon can be redeemed at the given time against
+// the given order amount. Per-user limits need the usage history and are checked
+// by the caller.
+func (c *Coupon) CheckValidity(now time.Time, orderAmount Money) error {
+	if !c.IsActive {
+		return ErrCouponInactive
+	}
+	if c.ValidFrom.After(now) {
+		return ErrCouponNotYetValid
+	}
+	if c.ValidUntil != nil && c.ValidUntil.Before(now) {
+		return ErrCouponExpired
+	}
+	if orderAmount.LessThan(c.MinOrderAmount) {
+		return ErrCouponMinOrderAmount
+	}
+	if c.UsageLimit != nil && c.UsedCount >= *c.UsageLimit {
+		return ErrCouponUsageLimit
+	}
+	return nil
+}
+
+// DiscountFor returns the discount the coupon gives on the given order amount,
+// capped by MaxDiscountAmount and by the order amount itself. Percentage discounts
+// are rounded half to even to the kobo.
+func (c *Coupon) DiscountFor(orderAmount Money) Money {
+	var discount Money
+	switch c.DiscountType {
+	case DiscountTypeFixed:
+		discount = c.DiscountValue.Money
+	case DiscountTypePercentage:
+		discount = orderAmount.PercentRat(c.DiscountValue.Percent())
+	}
+
+	// Apply maximum discount cap if set
+	if c.MaxDiscountAmount != nil && discount.GreaterThan(*c.MaxDiscountAmount) {
+		discount = *c.MaxDiscountAmount
+	}
+	if discount.GreaterThan(orderAmount) {
+		discount = orderAmount
+	}
+	return discount
+}
+
 // Request/Response types
 type CreateCouponRequest struct {
 	Code              string       `json:"code" binding:"required,min=3,max=50"`
 	Description       string       `json:"description"`
 	DiscountType      DiscountType `json:"discountType" binding:"required,oneof=percentage fixed"`
-	DiscountValue     float64      `json:"discountValue" binding:"required,gt=0"`
-	MinOrderAmount    float64      `json:"minOrderAmount"`
-	MaxDiscountAmount *float64     `json:"maxDiscountAmount"`
+	DiscountValue     DiscountValue `json:"discountValue"`
+	MinOrderAmount    Money        `json:"minOrderAmount"`
+	MaxDiscountAmount *Money       `json:"maxDiscountAmount"`
 	UsageLimit        *int         `json:"usageLimit"`
 	PerUserLimit      int          `json:"perUserLimit"`
 	ValidFrom         *time.Time   `json:"validFrom"`
@@ -58,9 +141,9 @@ type CreateCouponRequest struct {
 type UpdateCouponRequest struct {
 	Description       *string      `json:"description"`
 	DiscountType      *DiscountType `json:"discountType"`
-	DiscountValue     *float64     `json:"discountValue"`
-	MinOrderAmount    *float64     `json:"minOrderAmount"`
-	MaxDiscountAmount *float64     `json:"maxDiscountAmount"`
+	DiscountValue     *DiscountValue `json:"discountValue"`
+	MinOrderAmount    *Money       `json:"minOrderAmount"`
+	MaxDiscountAmount *Money       `json:"maxDiscountAmount"`
 	UsageLimit        *int         `json:"usageLimit"`
 	PerUserLimit      *int         `json:"perUserLimit"`
 	ValidFrom         *time.Time   `json:"validFrom"`
@@ -74,13 +157,13 @@ type ApplyCouponRequest struct {
 
 type ApplyCouponResponse struct {
 	Coupon         *Coupon `json:"coupon"`
-	DiscountAmount float64 `json:"discountAmount"`
+	DiscountAmount Money   `json:"discountAmount"`
 	Message        string  `json:"message"`
 }
 
 type ValidateCouponResult struct {
 	Valid          bool    `json:"valid"`
-	DiscountAmount float64 `json:"discountAmount"`
+	DiscountAmount Money   `json:"discountAmount"`
 	Message        string  `json:"message"`
 	Coupon         *Coupon `json:"coupon,omitempty"`
 }
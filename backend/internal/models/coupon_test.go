@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCouponDiscountFor(t *testing.T) {
+	maxDiscount := Kobo(150000)
+	tests := []struct {
+		name   string
+		coupon Coupon
+		order  Money
+		want   int64
+	}{
+		{"fixed", Coupon{DiscountType: DiscountTypeFixed, DiscountValue: DiscountValue{Kobo(50000)}}, Kobo(200000), 50000},
+		{"fixed above the order", Coupon{DiscountType: DiscountTypeFixed, DiscountValue: DiscountValue{Kobo(50000)}}, Kobo(30000), 30000},
+		{"percentage", Coupon{DiscountType: DiscountTypePercentage, DiscountValue: DiscountValue{Kobo(1000)}}, Kobo(200000), 20000},
+		// 12.5% of 0.99 is 12.375 kobo
+		{"fractional percentage", Coupon{DiscountType: DiscountTypePercentage, DiscountValue: DiscountValue{Kobo(1250)}}, Kobo(99), 12},
+		// 12.5% of 1.00 is a tie at 12.5 kobo
+		{"percentage tie to even", Coupon{DiscountType: DiscountTypePercentage, DiscountValue: DiscountValue{Kobo(1250)}}, Kobo(100), 12},
+		{"capped", Coupon{DiscountType: DiscountTypePercentage, DiscountValue: DiscountValue{Kobo(5000)}, MaxDiscountAmount: &maxDiscount}, Kobo(1000000), 150000},
+	}
+	for _, tt := range tests {
+		if got := tt.coupon.DiscountFor(tt.order); got.Minor() != tt.want {
+			t.Errorf("%s: discount = %d kobo, want %d", tt.name, got.Minor(), tt.want)
+		}
+	}
+}
+
+func TestDiscountValueJSON(t *testing.T) {
+	var req CreateCouponRequest
+	if err := json.Unmarshal([]byte(`{"code": "SAVE", "discountType": "percentage", "discountValue": 12.5}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.DiscountValue.Minor() != 1250 {
+		t.Errorf("discountValue = %d hundredths, want 1250", req.DiscountValue.Minor())
+	}
+
+	out, err := json.Marshal(Coupon{DiscountValue: DiscountValue{Kobo(1250)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back map[string]interface{}
+	json.Unmarshal(out, &back)
+	if back["discountValue"] != 12.5 {
+		t.Errorf("discountValue marshalled as %v, want 12.5", back["discountValue"])
+	}
+}
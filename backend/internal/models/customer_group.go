@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CustomerGroup is a set of customers, such as agencies or schools, who are
+// priced from a negotiated price list instead of the catalogue prices
+type CustomerGroup struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	MemberCount int       `json:"memberCount"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// CustomerGroupMember is a customer in a group
+type CustomerGroupMember struct {
+	UserID    uuid.UUID  `json:"userId"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"firstName"`
+	LastName  string     `json:"lastName"`
+	AddedBy   *uuid.UUID `json:"addedBy,omitempty"`
+	AddedAt   time.Time  `json:"addedAt"`
+}
+
+// CustomerGroupPriceList is everything a group is priced from
+type CustomerGroupPriceList struct {
+	CategoryDiscounts []GroupCategoryDiscount `json:"categoryDiscounts"`
+	ProductPrices     []GroupProductPrice     `json:"productPrices"`
+	PricingTiers      []PricingTier           `json:"pricingTiers"`
+}
+
+// GroupCategoryDiscount takes Percent off the price of every product in a category
+type GroupCategoryDiscount struct {
+	CategoryID   uuid.UUID `json:"categoryId"`
+	CategoryName string    `json:"categoryName"`
+	Percent      float64   `json:"percent"`
+}
+
+// GroupProductPrice is a fixed unit price for a product
+type GroupProductPrice struct {
+	ProductID   uuid.UUID `json:"productId"`
+	ProductName string    `json:"productName"`
+	Price       Money     `json:"price"`
+}
+
+// GroupPricing is how a customer group is priced for one product. A fixed price
+// replaces the product's base price, and group tiers replace its quantity tiers;
+// either one means the category discount does not apply.
+type GroupPricing struct {
+	GroupID          uuid.UUID
+	GroupName        string
+	Price            *Money
+	Tiers            []PricingTier
+	CategoryDiscount float64
+}
+
+// ProductSpecific reports whether the group has its own price for the product
+func (g *GroupPricing) ProductSpecific() bool {
+	return g.Price != nil || len(g.Tiers) > 0
+}
+
+type CustomerGroupRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description"`
+}
+
+type AddCustomerGroupMemberRequest struct {
+	UserID uuid.UUID `json:"userId" binding:"required"`
+}
+
+type GroupCategoryDiscountRequest struct {
+	Percent float64 `json:"percent" binding:"gt=0,lte=100"`
+}
+
+type GroupProductPriceRequest struct {
+	Price Money `json:"price" binding:"required"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Edges of a printed piece that can be finished
+const (
+	EdgeTop    = "top"
+	EdgeBottom = "bottom"
+	EdgeLeft   = "left"
+	EdgeRight  = "right"
+)
+
+// lengthUnits gives each length unit in millimetres, exactly
+var lengthUnits = map[string]*big.Rat{
+	"mm": big.NewRat(1, 1),
+	"cm": big.NewRat(10, 1),
+	"m":  big.NewRat(1000, 1),
+	"in": big.NewRat(254, 10),
+	"ft": big.NewRat(3048, 10),
+}
+
+// lengthAliases maps other spellings used in product options to length units
+var lengthAliases = map[string]string{
+	"millimetre": "mm", "millimetres": "mm", "millimeter": "mm", "millimeters": "mm",
+	"centimetre": "cm", "centimetres": "cm", "centimeter": "cm", "centimeters": "cm",
+	"metre": "m", "metres": "m", "meter": "m", "meters": "m",
+	"inch": "in", "inches": "in",
+	"foot": "ft", "feet": "ft",
+}
+
+// LengthUnit returns the canonical name of a length unit such as "cm" or "inches",
+// and whether it is one
+func LengthUnit(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := lengthAliases[name]; ok {
+		name = alias
+	}
+	_, ok := lengthUnits[name]
+	return name, ok
+}
+
+// AreaLengthUnit returns the length unit an area unit is the square of, e.g. "ft"
+// for "sqft", and whether areaUnit is an area unit
+func AreaLengthUnit(areaUnit string) (string, bool) {
+	unit := strings.TrimPrefix(strings.ToLower(areaUnit), "sq")
+	_, ok := lengthUnits[unit]
+	return unit, ok && unit != areaUnit
+}
+
+// ConvertLength converts a length between units. Both must be canonical length
+// units.
+func ConvertLength(value float64, from, to string) float64 {
+	if from == to {
+		return value
+	}
+	r, ok := new(big.Rat).SetString(formatNumber(value))
+	if !ok {
+		return value
+	}
+	r.Mul(r, lengthUnits[from])
+	r.Quo(r, lengthUnits[to])
+	f, _ := r.Float64()
+	return f
+}
+
+// EdgeFinishing is a finish applied along the edges of a large-format piece, such
+// as hemming or grommets. Customers choose it with the edges to finish under
+// "finishing" in the configuration. Without Spacing it costs Rate per size unit of
+// finished edge. With Spacing it places one piece, such as a grommet, at each
+// corner and at most Spacing apart along each edge, and costs Rate per piece.
+type EdgeFinishing struct {
+	ID      string  `json:"id" binding:"required"`
+	Name    string  `json:"name" binding:"required"`
+	Rate    float64 `json:"rate" binding:"gte=0"`
+	Spacing float64 `json:"spacing,omitempty" binding:"gte=0"`
+}
+
+// Price returns the cost of finishing the given edges of a width by height piece
+func (f *EdgeFinishing) Price(width, height float64, edges map[string]bool) Money {
+	lengths := map[string]float64{EdgeTop: width, EdgeBottom: width, EdgeLeft: height, EdgeRight: height}
+	if f.Spacing <= 0 {
+		var length float64
+		for edge := range edges {
+			length += lengths[edge]
+		}
+		return AmountAtRate(f.Rate, length)
+	}
+
+	pieces := 0
+	for edge := range edges {
+		// Allow for binary rounding so an exact multiple does not gain a piece
+		pieces += int(math.Ceil(lengths[edge]/f.Spacing-1e-9)) + 1
+	}
+	// Adjacent finished edges share the piece at their corner
+	for _, corner := range [][2]string{{EdgeTop, EdgeLeft}, {EdgeTop, EdgeRight}, {EdgeBottom, EdgeLeft}, {EdgeBottom, EdgeRight}} {
+		if edges[corner[0]] && edges[corner[1]] {
+			pieces--
+		}
+	}
+	return MoneyFromFloat(f.Rate).Times(pieces)
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertLength(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{30.48, "cm", "ft", 1},
+		{91.44, "cm", "ft", 3},
+		{150, "cm", "ft", 4.921259842519685},
+		{10, "in", "m", 0.254},
+		{39.37, "in", "m", 0.999998},
+		{1, "ft", "in", 12},
+		{2.5, "m", "mm", 2500},
+		{7, "cm", "cm", 7},
+	}
+	for _, tt := range tests {
+		if got := ConvertLength(tt.value, tt.from, tt.to); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("ConvertLength(%v, %s, %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConvertLengthRoundTrip(t *testing.T) {
+	units := []string{"mm", "cm", "m", "in", "ft"}
+	for _, value := range []float64{0.1, 1, 33.3, 120.75, 2400} {
+		for _, from := range units {
+			for _, to := range units {
+				back := ConvertLength(ConvertLength(value, from, to), to, from)
+				if math.Abs(back-value) > 1e-9*value {
+					t.Errorf("%v %s to %s and back = %v", value, from, to, back)
+				}
+			}
+		}
+	}
+}
+
+func TestLengthUnits(t *testing.T) {
+	lengths := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"cm", "cm", true},
+		{" Inches ", "in", true},
+		{"feet", "ft", true},
+		{"Metres", "m", true},
+		{"yards", "yards", false},
+	}
+	for _, tt := range lengths {
+		if got, ok := LengthUnit(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("LengthUnit(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	areas := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"sqft", "ft", true},
+		{"sqm", "m", true},
+		{"SQIN", "in", true},
+		{"ft", "ft", false},
+		{"sqyd", "yd", false},
+	}
+	for _, tt := range areas {
+		if got, ok := AreaLengthUnit(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("AreaLengthUnit(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEdgeFinishingPrice(t *testing.T) {
+	all := map[string]bool{EdgeTop: true, EdgeBottom: true, EdgeLeft: true, EdgeRight: true}
+	hem := &EdgeFinishing{ID: "hem", Name: "Hemming", Rate: 500}
+	grommets := &EdgeFinishing{ID: "grommets", Name: "Grommets", Rate: 50, Spacing: 1}
+	wideGrommets := &EdgeFinishing{ID: "grommets", Name: "Grommets", Rate: 50, Spacing: 2}
+	fineGrommets := &EdgeFinishing{ID: "grommets", Name: "Grommets", Rate: 50, Spacing: 0.1}
+
+	tests := []struct {
+		name          string
+		finishing     *EdgeFinishing
+		width, height float64
+		edges         map[string]bool
+		want          int64
+	}{
+		// 10 ft of edge at ₦500 a foot
+		{"by length, all edges", hem, 3, 2, all, 500000},
+		{"by length, top and bottom", hem, 3, 2, map[string]bool{EdgeTop: true, EdgeBottom: true}, 300000},
+		// 4 + 4 + 3 + 3 grommets, less the 4 shared at the corners
+		{"spaced, all edges", grommets, 3, 2, all, 10 * 5000},
+		{"spaced, one edge", grommets, 3, 2, map[string]bool{EdgeTop: true}, 4 * 5000},
+		{"spaced, adjacent edges share a corner", grommets, 3, 2, map[string]bool{EdgeTop: true, EdgeLeft: true}, 6 * 5000},
+		{"spaced, opposite edges share none", grommets, 3, 2, map[string]bool{EdgeLeft: true, EdgeRight: true}, 6 * 5000},
+		// 3 ft at 2 ft spacing needs 3 grommets, 2 ft needs 2
+		{"spaced, not a multiple of the spacing", wideGrommets, 3, 2, all, 6 * 5000},
+		// 1.1 / 0.1 is 11.000000000000002 in binary
+		{"spaced, exact multiple in decimal", fineGrommets, 1.1, 2, map[string]bool{EdgeTop: true}, 12 * 5000},
+		{"no edges", grommets, 3, 2, map[string]bool{}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.finishing.Price(tt.width, tt.height, tt.edges); got.Minor() != tt.want {
+			t.Errorf("%s: price = %d kobo, want %d", tt.name, got.Minor(), tt.want)
+		}
+	}
+}
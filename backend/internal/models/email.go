@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EmailOutboxStatus string
+
+const (
+	EmailOutboxPending EmailOutboxStatus = "pending"
+	EmailOutboxSending EmailOutboxStatus = "sending"
+	EmailOutboxSent    EmailOutboxStatus = "sent"
+	EmailOutboxDead    EmailOutboxStatus = "dead"
+)
+
+// EmailOutboxMessage is a queued outbound email. Messages that keep failing are
+// retried with backoff until MaxAttempts, then moved to the dead status.
+type EmailOutboxMessage struct {
+	ID            uuid.UUID         `json:"id"`
+	BroadcastID   *uuid.UUID        `json:"broadcastId,omitempty"`
+	Recipient     string            `json:"recipient"`
+	Subject       string            `json:"subject"`
+	HTMLBody      string            `json:"-"`
+	SMTPAccount   string            `json:"smtpAccount"`
+	Status        EmailOutboxStatus `json:"status"`
+	Attempts      int               `json:"attempts"`
+	MaxAttempts   int               `json:"maxAttempts"`
+	NextAttemptAt time.Time         `json:"nextAttemptAt"`
+	LastError     *string           `json:"lastError,omitempty"`
+	SentAt        *time.Time        `json:"sentAt,omitempty"`
+	CreatedAt     time.Time         `json:"createdAt"`
+}
+
+type EmailBroadcast struct {
+	ID              uuid.UUID  `json:"id"`
+	Subject         string     `json:"subject"`
+	Content         string     `json:"content,omitempty"`
+	TotalRecipients int        `json:"totalRecipients"`
+	CreatedBy       *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+// EmailBroadcastProgress summarises delivery of a broadcast
+type EmailBroadcastProgress struct {
+	EmailBroadcast
+	Pending  int                  `json:"pending"`
+	Sending  int                  `json:"sending"`
+	Sent     int                  `json:"sent"`
+	Failed   int                  `json:"failed"`
+	Complete bool                 `json:"complete"`
+	Failures []EmailOutboxMessage `json:"failures"`
+}
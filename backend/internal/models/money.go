@@ -0,0 +1,276 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// DefaultCurrency is the currency of amounts that do not carry their own
+const DefaultCurrency = "NGN"
+
+// Money is an amount in the minor unit of its currency (kobo for NGN). Amounts
+// that need rounding, such as percentages and shares of a total, are rounded half
+// to even (banker's rounding) so that rounding errors do not drift one way.
+//
+// Money is stored in DECIMAL columns and written to JSON as a number in major
+// units, e.g. 1500.5 for ₦1,500.50. The zero value is zero in DefaultCurrency.
+type Money struct {
+	minor    int64
+	currency string
+}
+
+// Kobo returns an amount of NGN in kobo
+func Kobo(minor int64) Money {
+	return Money{minor: minor}
+}
+
+// NewMoney returns an amount in the minor unit of the given currency
+func NewMoney(minor int64, currency string) Money {
+	if currency == DefaultCurrency {
+		currency = ""
+	}
+	return Money{minor: minor, currency: currency}
+}
+
+// MoneyFromFloat converts an amount in major units, such as a value read from a
+// product's JSON configuration, rounding half to even to the nearest minor unit
+func MoneyFromFloat(amount float64) Money {
+	return Money{minor: roundHalfEven(ratFromFloat(amount), 100)}
+}
+
+// AmountAtRate prices quantity units at rate major units each, e.g. an area at a
+// rate per square foot, rounding the result rather than the rate
+func AmountAtRate(rate, quantity float64) Money {
+	r := ratFromFloat(rate)
+	r.Mul(r, ratFromFloat(quantity))
+	return Money{minor: roundHalfEven(r, 100)}
+}
+
+// MoneyFromRat converts an exact amount in major units, such as the result of a
+// pricing formula, rounding half to even to the nearest minor unit
+func MoneyFromRat(amount *big.Rat) Money {
+	return Money{minor: roundHalfEven(amount, 100)}
+}
+
+// ParseMoney parses a decimal amount in major units, e.g. "1500.50"
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return Money{minor: roundHalfEven(r, 100)}, nil
+}
+
+// Minor returns the amount in minor units
+func (m Money) Minor() int64 {
+	return m.minor
+}
+
+// Currency returns the ISO 4217 currency code
+func (m Money) Currency() string {
+	if m.currency == "" {
+		return DefaultCurrency
+	}
+	return m.currency
+}
+
+// Float64 returns the amount in major units. It is meant for display and for
+// provider APIs that take decimal amounts, not for arithmetic.
+func (m Money) Float64() float64 {
+	return float64(m.minor) / 100
+}
+
+// Rat returns the amount in major units as an exact fraction
+func (m Money) Rat() *big.Rat {
+	return big.NewRat(m.minor, 100)
+}
+
+func (m Money) IsZero() bool     { return m.minor == 0 }
+func (m Money) IsPositive() bool { return m.minor > 0 }
+func (m Money) IsNegative() bool { return m.minor < 0 }
+
+// Add returns m + o. Mixing currencies is a programming error and panics.
+func (m Money) Add(o Money) Money {
+	return Money{minor: m.minor + o.minor, currency: sameCurrency(m, o)}
+}
+
+// Sub returns m - o
+func (m Money) Sub(o Money) Money {
+	return Money{minor: m.minor - o.minor, currency: sameCurrency(m, o)}
+}
+
+// Times multiplies by a whole number, e.g. a unit price by a quantity
+func (m Money) Times(n int) Money {
+	return Money{minor: m.minor * int64(n), currency: m.currency}
+}
+
+// Div divides by a whole number, e.g. a line total by its quantity, rounding half to even
+func (m Money) Div(n int) Money {
+	if n == 0 {
+		return m
+	}
+	return Money{minor: roundHalfEven(big.NewRat(m.minor, int64(n)), 1), currency: m.currency}
+}
+
+// Mul multiplies by a factor, rounding half to even
+func (m Money) Mul(factor float64) Money {
+	r := ratFromFloat(factor)
+	r.Mul(r, new(big.Rat).SetInt64(m.minor))
+	return Money{minor: roundHalfEven(r, 1), currency: m.currency}
+}
+
+// Percent returns percent per cent of m, rounding half to even
+func (m Money) Percent(percent float64) Money {
+	return m.PercentRat(ratFromFloat(percent))
+}
+
+// PercentRat is Percent for an exact percentage
+func (m Money) PercentRat(percent *big.Rat) Money {
+	r := new(big.Rat).Mul(percent, big.NewRat(m.minor, 100))
+	return Money{minor: roundHalfEven(r, 1), currency: m.currency}
+}
+
+// Cmp compares m and o, returning -1, 0 or +1
+func (m Money) Cmp(o Money) int {
+	sameCurrency(m, o)
+	switch {
+	case m.minor < o.minor:
+		return -1
+	case m.minor > o.minor:
+		return 1
+	}
+	return 0
+}
+
+func (m Money) LessThan(o Money) bool    { return m.Cmp(o) < 0 }
+func (m Money) GreaterThan(o Money) bool { return m.Cmp(o) > 0 }
+
+// String formats the amount in major units with two decimals, e.g. "1500.50"
+func (m Money) String() string {
+	minor := m.minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, minor/100, minor%100)
+}
+
+// MarshalJSON writes the amount as a number in major units
+func (m Money) MarshalJSON() ([]byte, error) {
+	s := m.String()
+	// Trim trailing zeros so whole amounts stay integers, as they were as float64
+	s = trimZeros(s)
+	return []byte(s), nil
+}
+
+// UnmarshalJSON reads a number in major units. Quoted numbers are accepted too.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ScanNumeric implements pgtype.NumericScanner so DECIMAL columns scan into Money.
+// NULL scans as zero; use *Money for nullable columns.
+func (m *Money) ScanNumeric(n pgtype.Numeric) error {
+	if !n.Valid {
+		*m = Money{}
+		return nil
+	}
+	if n.NaN || n.InfinityModifier != pgtype.Finite {
+		return fmt.Errorf("cannot scan %v into Money", n)
+	}
+
+	r := new(big.Rat)
+	if n.Int != nil {
+		r.SetInt(n.Int)
+	}
+	exp := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(n.Exp))), nil)
+	if n.Exp < 0 {
+		r.Quo(r, new(big.Rat).SetInt(exp))
+	} else {
+		r.Mul(r, new(big.Rat).SetInt(exp))
+	}
+	*m = Money{minor: roundHalfEven(r, 100)}
+	return nil
+}
+
+// NumericValue implements pgtype.NumericValuer so Money is written to DECIMAL columns
+func (m Money) NumericValue() (pgtype.Numeric, error) {
+	return pgtype.Numeric{Int: big.NewInt(m.minor), Exp: -2, Valid: true}, nil
+}
+
+// sameCurrency returns the currency shared by a and b, treating an unset currency
+// as matching any
+func sameCurrency(a, b Money) string {
+	if a.currency == "" {
+		return b.currency
+	}
+	if b.currency != "" && b.currency != a.currency {
+		panic(fmt.Sprintf("money: mixing %s and %s", a.currency, b.currency))
+	}
+	return a.currency
+}
+
+// ratFromFloat converts f through its shortest decimal form, so 0.1 is exactly one
+// tenth rather than the nearest binary fraction
+func ratFromFloat(f float64) *big.Rat {
+	r, ok := new(big.Rat).SetString(strconv.FormatFloat(f, 'f', -1, 64))
+	if !ok {
+		// NaN or infinity
+		return new(big.Rat)
+	}
+	return r
+}
+
+// roundHalfEven returns r*scale rounded to the nearest integer, ties to even
+func roundHalfEven(r *big.Rat, scale int64) int64 {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt64(scale))
+	num, den := scaled.Num(), scaled.Denom()
+
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Compare twice the remainder with the denominator to decide the rounding
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(den) {
+	case 1:
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	case 0:
+		if q.Bit(0) == 1 {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return q.Int64()
+}
+
+func trimZeros(s string) string {
+	for s[len(s)-1] == '0' {
+		s = s[:len(s)-1]
+	}
+	if s[len(s)-1] == '.' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+func abs32(n int32) int32 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
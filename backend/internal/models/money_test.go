@@ -0,0 +1,274 @@
+package models
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestRoundHalfEven(t *testing.T) {
+	tests := []struct {
+		num, den int64
+		scale    int64
+		want     int64
+	}{
+		// Ties go to the even neighbour
+		{1, 2, 1, 0},
+		{3, 2, 1, 2},
+		{5, 2, 1, 2},
+		{7, 2, 1, 4},
+		{-1, 2, 1, 0},
+		{-3, 2, 1, -2},
+		{-5, 2, 1, -2},
+		// Anything else to the nearest
+		{1, 3, 1, 0},
+		{2, 3, 1, 1},
+		{-2, 3, 1, -1},
+		{51, 100, 1, 1},
+		{49, 100, 1, 0},
+		// Scaled to kobo: 0.125 and 0.135 naira
+		{125, 1000, 100, 12},
+		{135, 1000, 100, 14},
+		{1005, 1000, 100, 100},
+		{-125, 1000, 100, -12},
+	}
+	for _, tt := range tests {
+		got := roundHalfEven(big.NewRat(tt.num, tt.den), tt.scale)
+		if got != tt.want {
+			t.Errorf("roundHalfEven(%d/%d, %d) = %d, want %d", tt.num, tt.den, tt.scale, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"1500.50", 150050},
+		{"0.01", 1},
+		{"0.005", 0},
+		{"0.015", 2},
+		{"0.025", 2},
+		{"-12.345", -1234},
+		{"1e3", 100000},
+		{"99999999.99", 9999999999},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got.Minor() != tt.want {
+			t.Errorf("ParseMoney(%q) = %d kobo, want %d", tt.in, got.Minor(), tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "1,500", "₦100", "1.2.3"} {
+		if _, err := ParseMoney(bad); err == nil {
+			t.Errorf("ParseMoney(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestMoneyArithmeticRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Money
+		want int64
+	}{
+		{"float 0.1 + 0.2 is exact", MoneyFromFloat(0.1).Add(MoneyFromFloat(0.2)), 30},
+		{"7.5% of 1.50 rounds to even", Kobo(150).Percent(7.5), 11},
+		{"12.5% of 1.00 ties to even", Kobo(100).Percent(12.5), 12},
+		{"exact percent", Kobo(1000).PercentRat(big.NewRat(25, 2)), 125},
+		{"1.00 / 3", Kobo(100).Div(3), 33},
+		{"0.05 / 2 ties to even", Kobo(5).Div(2), 2},
+		{"0.07 / 2 ties to even", Kobo(7).Div(2), 4},
+		{"rate times quantity", AmountAtRate(0.35, 3), 105},
+		{"1.5 x 0.15", Kobo(15).Mul(1.5), 22},
+	}
+	for _, tt := range tests {
+		if tt.got.Minor() != tt.want {
+			t.Errorf("%s: got %d kobo, want %d", tt.name, tt.got.Minor(), tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	marshal := []struct {
+		m    Money
+		want string
+	}{
+		{Kobo(0), "0"},
+		{Kobo(150000), "1500"},
+		{Kobo(150050), "1500.5"},
+		{Kobo(150055), "1500.55"},
+		{Kobo(1), "0.01"},
+		{Kobo(-250), "-2.5"},
+	}
+	for _, tt := range marshal {
+		got, err := json.Marshal(tt.m)
+		if err != nil {
+			t.Fatalf("Marshal(%d kobo): %v", tt.m.Minor(), err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%d kobo) = %s, want %s", tt.m.Minor(), got, tt.want)
+		}
+	}
+
+	unmarshal := []struct {
+		in   string
+		want int64
+	}{
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{`"1500.50"`, 150050},
+		{"0.1", 10},
+		{"0.125", 12},
+		{"-2.5", -250},
+	}
+	for _, tt := range unmarshal {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.in), &m); err != nil {
+			t.Errorf("Unmarshal(%s) error: %v", tt.in, err)
+			continue
+		}
+		if m.Minor() != tt.want {
+			t.Errorf("Unmarshal(%s) = %d kobo, want %d", tt.in, m.Minor(), tt.want)
+		}
+	}
+
+	// null leaves the value alone, so nullable fields use *Money
+	m := Kobo(500)
+	if err := json.Unmarshal([]byte("null"), &m); err != nil || m.Minor() != 500 {
+		t.Errorf("Unmarshal(null) = %d kobo, %v; want unchanged", m.Minor(), err)
+	}
+	var ptr *Money
+	if err := json.Unmarshal([]byte("null"), &ptr); err != nil || ptr != nil {
+		t.Errorf("Unmarshal(null) into *Money = %v, %v; want nil", ptr, err)
+	}
+	for _, bad := range []string{`"abc"`, "true", "{}"} {
+		if err := json.Unmarshal([]byte(bad), &m); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", bad)
+		}
+	}
+
+	// Inside a struct, as handlers see it
+	var req struct {
+		Price Money  `json:"price"`
+		Cost  *Money `json:"cost"`
+	}
+	if err := json.Unmarshal([]byte(`{"price": 19.99, "cost": 7.5}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Price.Minor() != 1999 || req.Cost == nil || req.Cost.Minor() != 750 {
+		t.Errorf("struct = %d, %v; want 1999 and 750 kobo", req.Price.Minor(), req.Cost)
+	}
+}
+
+func TestMoneyScanNumeric(t *testing.T) {
+	tests := []struct {
+		name string
+		n    pgtype.Numeric
+		want int64
+	}{
+		{"DECIMAL(10,2)", pgtype.Numeric{Int: big.NewInt(150050), Exp: -2, Valid: true}, 150050},
+		{"fewer places", pgtype.Numeric{Int: big.NewInt(15005), Exp: -1, Valid: true}, 150050},
+		{"positive exponent", pgtype.Numeric{Int: big.NewInt(15), Exp: 2, Valid: true}, 150000},
+		{"more places, tie to even", pgtype.Numeric{Int: big.NewInt(1125), Exp: -3, Valid: true}, 112},
+		{"more places, round up", pgtype.Numeric{Int: big.NewInt(1126), Exp: -3, Valid: true}, 113},
+		{"negative", pgtype.Numeric{Int: big.NewInt(-250), Exp: -2, Valid: true}, -250},
+		{"nil int is zero", pgtype.Numeric{Valid: true}, 0},
+		{"NULL is zero", pgtype.Numeric{}, 0},
+	}
+	for _, tt := range tests {
+		m := Kobo(999)
+		if err := m.ScanNumeric(tt.n); err != nil {
+			t.Errorf("%s: error: %v", tt.name, err)
+			continue
+		}
+		if m.Minor() != tt.want {
+			t.Errorf("%s: scanned %d kobo, want %d", tt.name, m.Minor(), tt.want)
+		}
+	}
+
+	var m Money
+	if err := m.ScanNumeric(pgtype.Numeric{NaN: true, Valid: true}); err == nil {
+		t.Error("scanning NaN succeeded, want an error")
+	}
+	if err := m.ScanNumeric(pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}); err == nil {
+		t.Error("scanning infinity succeeded, want an error")
+	}
+}
+
+func TestMoneyNumericValueRoundTrips(t *testing.T) {
+	for _, minor := range []int64{0, 1, 150050, -250} {
+		n, err := Kobo(minor).NumericValue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back Money
+		if err := back.ScanNumeric(n); err != nil {
+			t.Fatal(err)
+		}
+		if back.Minor() != minor {
+			t.Errorf("%d kobo came back as %d", minor, back.Minor())
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := map[int64]string{0: "0.00", 5: "0.05", 150050: "1500.50", -5: "-0.05", -150000: "-1500.00"}
+	for minor, want := range tests {
+		if got := Kobo(minor).String(); got != want {
+			t.Errorf("Kobo(%d).String() = %q, want %q", minor, got, want)
+		}
+	}
+}
+
+func TestMoneyCurrency(t *testing.T) {
+	if got := Kobo(100).Currency(); got != DefaultCurrency {
+		t.Errorf("Kobo currency = %s, want %s", got, DefaultCurrency)
+	}
+	if got := (Money{}).Currency(); got != "NGN" {
+		t.Errorf("zero value currency = %s, want NGN", got)
+	}
+	if NewMoney(100, "NGN") != Kobo(100) {
+		t.Error("NewMoney in NGN differs from Kobo")
+	}
+
+	usd := NewMoney(1050, "USD")
+	sum := usd.Add(NewMoney(50, "USD"))
+	if sum.Currency() != "USD" || sum.Minor() != 1100 {
+		t.Errorf("USD sum = %d %s, want 1100 USD", sum.Minor(), sum.Currency())
+	}
+	// Amounts without a currency of their own, such as zero, take the other's
+	if got := (Money{}).Add(usd); got.Currency() != "USD" {
+		t.Errorf("zero + USD is %s, want USD", got.Currency())
+	}
+	for name, got := range map[string]Money{
+		"Times":      usd.Times(3),
+		"Div":        usd.Div(2),
+		"Mul":        usd.Mul(1.5),
+		"Percent":    usd.Percent(10),
+		"LineTax":    LineTax(usd, 7.5, true),
+		"Sub from 0": Money{}.Sub(usd),
+	} {
+		if got.Currency() != "USD" {
+			t.Errorf("%s lost the currency: %s", name, got.Currency())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding GBP to USD did not panic")
+		}
+	}()
+	NewMoney(100, "GBP").Add(usd)
+}
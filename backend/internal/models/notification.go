@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type NotificationEventType string
+
+const (
+	EventOrderCreated       NotificationEventType = "order.created"
+	EventOrderStatusChanged NotificationEventType = "order.status_changed"
+	EventPaymentSucceeded   NotificationEventType = "payment.succeeded"
+	EventUserRegistered     NotificationEventType = "user.registered"
+	EventPaymentReminder    NotificationEventType = "order.payment_reminder"
+)
+
+// NotificationEvent is something that happened which a customer may need to hear about
+type NotificationEvent struct {
+	Type       NotificationEventType
+	UserID     uuid.UUID
+	OrderID    uuid.UUID
+	FromStatus OrderStatus
+	Status     OrderStatus
+	Note       string
+	// DueAt is when an unpaid order will be cancelled, for payment reminders
+	DueAt      time.Time
+	OccurredAt time.Time
+}
+
+type NotificationStatus string
+
+const (
+	NotificationQueued  NotificationStatus = "queued"
+	NotificationSent    NotificationStatus = "sent"
+	NotificationFailed  NotificationStatus = "failed"
+	NotificationSkipped NotificationStatus = "skipped"
+)
+
+// NotificationPreferences holds a customer's opt-outs. Account emails such as the
+// welcome email are always sent.
+type NotificationPreferences struct {
+	UserID       uuid.UUID `json:"userId"`
+	OrderUpdates bool      `json:"orderUpdates"`
+	Marketing    bool      `json:"marketing"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+type UpdateNotificationPreferencesRequest struct {
+	OrderUpdates *bool `json:"orderUpdates"`
+	Marketing    *bool `json:"marketing"`
+}
+
+type NotificationLog struct {
+	ID        uuid.UUID             `json:"id"`
+	EventType NotificationEventType `json:"eventType"`
+	UserID    *uuid.UUID            `json:"userId,omitempty"`
+	OrderID   *uuid.UUID            `json:"orderId,omitempty"`
+	Channel   string                `json:"channel"`
+	Recipient string                `json:"recipient"`
+	Status    NotificationStatus    `json:"status"`
+	Error     *string               `json:"error,omitempty"`
+	CreatedAt time.Time             `json:"createdAt"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,6 +22,68 @@ const (
 	OrderStatusCancelled       OrderStatus = "cancelled"
 )
 
+// orderStatusTransitions is the order lifecycle graph: for each status, the statuses
+// an order may move to next. An order can be cancelled until it has been shipped;
+// delivered and cancelled orders are final. A failed payment sends the order back
+// to pending so the customer can retry.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:         {OrderStatusAwaitingPayment, OrderStatusPaid, OrderStatusCancelled},
+	OrderStatusAwaitingPayment: {OrderStatusPaid, OrderStatusPending, OrderStatusCancelled},
+	OrderStatusPaid:            {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing:      {OrderStatusPrinting, OrderStatusCancelled},
+	OrderStatusPrinting:        {OrderStatusReady, OrderStatusCancelled},
+	OrderStatusReady:           {OrderStatusShipped, OrderStatusDelivered, OrderStatusCancelled},
+	OrderStatusShipped:         {OrderStatusDelivered},
+	OrderStatusDelivered:       {},
+	OrderStatusCancelled:       {},
+}
+
+// IsValid reports whether s is one of the known order statuses.
+func (s OrderStatus) IsValid() bool {
+	_, ok := orderStatusTransitions[s]
+	return ok
+}
+
+// AllowedTransitions returns the statuses an order in status s may move to.
+func (s OrderStatus) AllowedTransitions() []OrderStatus {
+	next := orderStatusTransitions[s]
+	allowed := make([]OrderStatus, len(next))
+	copy(allowed, next)
+	return allowed
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnpaid reports whether an order in status s is still waiting to be paid for.
+func (s OrderStatus) IsUnpaid() bool {
+	return s == OrderStatusPending || s == OrderStatusAwaitingPayment
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// SystemActor is passed as the acting user for changes made by the system itself,
+// such as payment webhooks and background jobs. It is recorded as a NULL created_by.
+var SystemActor = uuid.Nil
+
+// StatusTransitionError is returned when an order cannot move to the requested status.
+type StatusTransitionError struct {
+	From    OrderStatus
+	To      OrderStatus
+	Allowed []OrderStatus
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
 type ShippingAddress struct {
 	Name    string `json:"name"`
 	Street  string `json:"street"`
@@ -29,32 +93,74 @@ type ShippingAddress struct {
 	Country string `json:"country"`
 }
 
+// OrderItem is one line of an order. Snapshot records the product and pricing as
+// they were when the order was placed; Product is the live product, if it still
+// exists, and is only for display.
 type OrderItem struct {
 	ID            uuid.UUID              `json:"id"`
 	OrderID       uuid.UUID              `json:"orderId"`
 	ProductID     uuid.UUID              `json:"productId"`
-	Product       *Product               `json:"product,omitempty"`
 	Quantity      int                    `json:"quantity"`
 	Configuration map[string]interface{} `json:"configuration"`
-	UnitPrice     float64                `json:"unitPrice"`
-	TotalPrice    float64                `json:"totalPrice"`
+	UnitPrice     Money                  `json:"unitPrice"`
+	TotalPrice    Money                  `json:"totalPrice"`
 	UploadedFile  *string                `json:"uploadedFile,omitempty"`
+	Snapshot      *OrderItemSnapshot     `json:"snapshot"`
+	TaxRate       float64                `json:"taxRate"`
+	TaxExemption  TaxExemption           `json:"taxExemption,omitempty"`
+	Tax           Money                  `json:"tax"`
+	NetAmount     Money                  `json:"netAmount"`
+	// CurrentProduct is the product as it is now, not as it was ordered, and is
+	// only filled in for staff. Snapshot describes the item as ordered.
+	CurrentProduct *Product `json:"currentProduct,omitempty"`
+}
+
+// OrderItemSnapshot freezes an order line at the time of the order: the product's
+// name and slug, the chosen options by label, the full price breakdown, and the
+// definitions of the pricing rules and formulas that priced it, each with its ID
+// and updatedAt so the version used can be traced. Items ordered
+// before snapshots were kept only have the product name and slug. Items ordered
+// from a quote carry its number and the snapshot taken when it was quoted,
+// repriced by any staff override.
+type OrderItemSnapshot struct {
+	ProductName string           `json:"productName"`
+	ProductSlug string           `json:"productSlug"`
+	Options     []OptionLabel    `json:"options,omitempty"`
+	Breakdown   *PriceBreakdown  `json:"breakdown,omitempty"`
+	Rules       []PricingRule    `json:"rules,omitempty"`
+	Formulas    []PricingFormula `json:"formulas,omitempty"`
+	PricedAt    *time.Time       `json:"pricedAt,omitempty"`
+	QuoteNumber string           `json:"quoteNumber,omitempty"`
+	// Override is the last staff change to the price of the quote line, which
+	// the breakdown's total and subtotal show
+	Override *QuotePriceOverride `json:"override,omitempty"`
+}
+
+// OptionLabel is an option chosen for an order line, as shown to the customer
+type OptionLabel struct {
+	OptionID string `json:"optionId"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
 }
 
 type Order struct {
-	ID              uuid.UUID       `json:"id"`
-	OrderNumber     string          `json:"order_number"`
-	UserID          uuid.UUID       `json:"user_id"`
-	Status          OrderStatus     `json:"status"`
-	Items           []OrderItem     `json:"items"`
-	Subtotal        float64         `json:"subtotal"`
-	Discount        float64         `json:"discount"`
-	Shipping        float64         `json:"shipping"`
-	Tax             float64         `json:"tax"`
-	Total           float64         `json:"total"`
-	ShippingAddress ShippingAddress `json:"shippingAddress"`
-	CreatedAt       time.Time       `json:"createdAt"`
-	UpdatedAt       time.Time       `json:"updatedAt"`
+	ID               uuid.UUID       `json:"id"`
+	OrderNumber      string          `json:"order_number"`
+	UserID           uuid.UUID       `json:"user_id"`
+	Status           OrderStatus     `json:"status"`
+	Items            []OrderItem     `json:"items"`
+	Subtotal         Money           `json:"subtotal"`
+	Discount         Money           `json:"discount"`
+	Shipping         Money           `json:"shipping"`
+	Tax              Money           `json:"tax"`
+	Total            Money           `json:"total"`
+	PricesIncludeTax bool            `json:"pricesIncludeTax"`
+	CustomerTIN      string          `json:"customerTin,omitempty"`
+	CouponID         *uuid.UUID      `json:"couponId,omitempty"`
+	CouponCode       *string         `json:"couponCode,omitempty"`
+	ShippingAddress  ShippingAddress `json:"shippingAddress"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	UpdatedAt        time.Time       `json:"updatedAt"`
 }
 
 type OrderStatusHistory struct {
@@ -74,10 +180,12 @@ type OrderNote struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+// CreateOrderRequest is the checkout payload. Discounts are never taken from the
+// client: the coupon code is re-validated and redeemed server-side.
 type CreateOrderRequest struct {
 	ShippingAddress ShippingAddress          `json:"shippingAddress" binding:"required"`
 	Items           []CreateOrderItemRequest `json:"items"`
-	Discount        float64                  `json:"discount"`
+	CouponCode      string                   `json:"couponCode"`
 }
 
 // CreateOrderItemRequest represents an item sent from the client when creating an order
@@ -92,6 +200,13 @@ type UpdateOrderStatusRequest struct {
 	Note   string      `json:"note"`
 }
 
+// OrderTransitionsResponse lists the statuses an order can be moved to next
+type OrderTransitionsResponse struct {
+	OrderID            uuid.UUID     `json:"orderId"`
+	Status             OrderStatus   `json:"status"`
+	AllowedTransitions []OrderStatus `json:"allowedTransitions"`
+}
+
 type AddOrderNoteRequest struct {
 	Note string `json:"note" binding:"required"`
 }
@@ -107,6 +222,6 @@ type AdminOrderResponse struct {
 
 // UserOrderStats holds aggregated order data for a user
 type UserOrderStats struct {
-	TotalOrders int     `json:"totalOrders"`
-	TotalSpent  float64 `json:"totalSpent"`
+	TotalOrders int   `json:"totalOrders"`
+	TotalSpent  Money `json:"totalSpent"`
 }
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,42 +14,70 @@ const (
 	PaymentStatusSuccess  PaymentStatus = "success"
 	PaymentStatusFailed   PaymentStatus = "failed"
 	PaymentStatusRefunded PaymentStatus = "refunded"
+	// PaymentStatusPartiallyRefunded is a successful payment with some, but not
+	// all, of its amount refunded
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	// PaymentStatusExpired is a payment the customer never completed, closed by
+	// the reconciler
+	PaymentStatusExpired PaymentStatus = "expired"
 )
 
 type Payment struct {
-	ID               uuid.UUID     `json:"id"`
-	OrderID          uuid.UUID     `json:"orderId"`
-	PaystackRef      string        `json:"paystackRef"`
-	Amount           float64       `json:"amount"`
-	Currency         string        `json:"currency"`
-	Status           PaymentStatus `json:"status"`
-	PaystackResponse string        `json:"-"`
-	CreatedAt        time.Time     `json:"createdAt"`
-	UpdatedAt        time.Time     `json:"updatedAt"`
+	ID                uuid.UUID     `json:"id"`
+	OrderID           uuid.UUID     `json:"orderId"`
+	Provider          string        `json:"provider"`
+	ProviderReference string        `json:"providerReference"`
+	Amount            Money         `json:"amount"`
+	Currency          string        `json:"currency"`
+	Status            PaymentStatus `json:"status"`
+	ProviderResponse  string        `json:"-"`
+	CreatedAt         time.Time     `json:"createdAt"`
+	UpdatedAt         time.Time     `json:"updatedAt"`
 }
 
 type InitializePaymentRequest struct {
 	OrderID uuid.UUID `json:"orderId" binding:"required"`
+	// Provider optionally picks the payment gateway; the configured default is used otherwise
+	Provider string `json:"provider"`
 }
 
 type InitializePaymentResponse struct {
+	Provider         string `json:"provider"`
 	AuthorizationURL string `json:"authorization_url"`
 	AccessCode       string `json:"access_code"`
 	Reference        string `json:"reference"`
 }
 
+// PaymentProvidersResponse lists the gateways a customer can pay with
+type PaymentProvidersResponse struct {
+	Providers []string `json:"providers"`
+	Default   string   `json:"default"`
+}
+
 type VerifyPaymentRequest struct {
 	Reference string `json:"reference" binding:"required"`
 }
 
-type PaystackWebhookPayload struct {
-	Event string `json:"event"`
-	Data  struct {
-		Reference string `json:"reference"`
-		Amount    int    `json:"amount"`
-		Currency  string `json:"currency"`
-		Status    string `json:"status"`
-		Channel   string `json:"channel"`
-		PaidAt    string `json:"paid_at"`
-	} `json:"data"`
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived  WebhookEventStatus = "received"
+	WebhookEventProcessed WebhookEventStatus = "processed"
+	WebhookEventIgnored   WebhookEventStatus = "ignored"
+	WebhookEventFailed    WebhookEventStatus = "failed"
+)
+
+// PaymentWebhookEvent is a stored webhook delivery
+type PaymentWebhookEvent struct {
+	ID          uuid.UUID          `json:"id"`
+	Provider    string             `json:"provider"`
+	EventKey    string             `json:"eventKey"`
+	EventType   string             `json:"eventType"`
+	Reference   string             `json:"reference"`
+	Payload     json.RawMessage    `json:"payload"`
+	Status      WebhookEventStatus `json:"status"`
+	Error       *string            `json:"error,omitempty"`
+	Deliveries  int                `json:"deliveries"`
+	ReceivedAt  time.Time          `json:"receivedAt"`
+	ProcessedAt *time.Time         `json:"processedAt,omitempty"`
 }
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PriceChangeStatus is where a price change is in its lifecycle
+type PriceChangeStatus string
+
+const (
+	PriceChangeScheduled  PriceChangeStatus = "scheduled"
+	PriceChangeApplied    PriceChangeStatus = "applied"
+	PriceChangeCancelled  PriceChangeStatus = "cancelled"
+	PriceChangeRolledBack PriceChangeStatus = "rolled_back"
+	// PriceChangeBlocked is a scheduled change that was not applied because
+	// the prices it would have set by then were not allowed
+	PriceChangeBlocked PriceChangeStatus = "blocked"
+)
+
+// PriceChangeRollback is the update type of a price change that undid another
+const PriceChangeRollback = "rollback"
+
+var (
+	ErrPriceChangeNotScheduled = errors.New("price change is no longer scheduled")
+	ErrPriceChangeNotApplied   = errors.New("only an applied price change can be rolled back")
+)
+
+// PriceViolation is a problem with a price a bulk update would set
+type PriceViolation string
+
+const (
+	PriceViolationNegative  PriceViolation = "negative_price"
+	PriceViolationBelowCost PriceViolation = "below_cost"
+)
+
+// PriceViolations lists what is wrong with a price for a product that costs cost
+// to make, which may be unknown
+func PriceViolations(price Money, cost *Money) []PriceViolation {
+	var violations []PriceViolation
+	if price.IsNegative() {
+		violations = append(violations, PriceViolationNegative)
+	}
+	if cost != nil && price.Cmp(*cost) < 0 {
+		violations = append(violations, PriceViolationBelowCost)
+	}
+	return violations
+}
+
+// PriceViolationError is returned when a bulk update is refused because of the
+// prices it would set. Preview shows each product's prices and violations.
+type PriceViolationError struct {
+	Preview *BulkPricePreview
+}
+
+func (e *PriceViolationError) Error() string {
+	return fmt.Sprintf("%d product(s) would have invalid prices", e.Preview.ViolationCount)
+}
+
+// PriceRollbackConflictError is returned when a price change cannot be rolled
+// back because some of its products' prices have changed since
+type PriceRollbackConflictError struct {
+	ProductIDs []uuid.UUID `json:"productIds"`
+}
+
+func (e *PriceRollbackConflictError) Error() string {
+	return fmt.Sprintf("%d product price(s) have changed since this price change was applied", len(e.ProductIDs))
+}
+
+// PriceChange is one bulk update of product base prices. It is applied to all
+// of its products together, at EffectiveAt, and can be rolled back as a unit.
+type PriceChange struct {
+	ID           uuid.UUID         `json:"id"`
+	UpdateType   string            `json:"updateType"`
+	Value        float64           `json:"value"`
+	Reason       string            `json:"reason,omitempty"`
+	Status       PriceChangeStatus `json:"status"`
+	EffectiveAt  time.Time         `json:"effectiveAt"`
+	AppliedAt    *time.Time        `json:"appliedAt,omitempty"`
+	RollbackOf   *uuid.UUID        `json:"rollbackOf,omitempty"`
+	RolledBackBy *uuid.UUID        `json:"rolledBackBy,omitempty"`
+	RolledBackAt *time.Time        `json:"rolledBackAt,omitempty"`
+	CreatedBy    *uuid.UUID        `json:"createdBy,omitempty"`
+	ProductIDs   []uuid.UUID       `json:"productIds"`
+	// AllowBelowCost lets the change set prices below cost when it is applied
+	AllowBelowCost bool `json:"allowBelowCost"`
+	// History is the price of each product before and after the change
+	History []ProductPriceHistory `json:"history,omitempty"`
+	// Tiers are the quantity tier prices the change set
+	Tiers     []PriceChangeTier `json:"tiers,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// Adjust returns what the change would set a price of old to, which may be
+// negative
+func (c *PriceChange) Adjust(old Money) Money {
+	switch c.UpdateType {
+	case "set":
+		return MoneyFromFloat(c.Value)
+	case "increase":
+		return old.Add(MoneyFromFloat(c.Value))
+	case "decrease":
+		return old.Sub(MoneyFromFloat(c.Value))
+	case "percentage":
+		// Positive values increase the price, negative values decrease it
+		return old.Add(old.Percent(c.Value))
+	}
+	return old
+}
+
+// NewPrice returns what the change sets a price of old to. Prices never go
+// below zero.
+func (c *PriceChange) NewPrice(old Money) Money {
+	price := c.Adjust(old)
+	if price.IsNegative() {
+		return Money{}
+	}
+	return price
+}
+
+// AdjustsTiers reports whether the change also applies to products' quantity
+// tier prices. Setting an exact base price leaves the tiers as they are.
+func (c *PriceChange) AdjustsTiers() bool {
+	switch c.UpdateType {
+	case "increase", "decrease", "percentage":
+		return true
+	}
+	return false
+}
+
+// PriceChangeTier records one quantity tier price a price change set
+type PriceChangeTier struct {
+	TierID    uuid.UUID `json:"tierId"`
+	ProductID uuid.UUID `json:"productId"`
+	OldPrice  Money     `json:"oldPrice"`
+	NewPrice  Money     `json:"newPrice"`
+}
+
+// BulkPricePreview shows what a bulk price update would do without applying it.
+// FailedIDs are requested products that do not exist or do not match the rest
+// of the selection.
+type BulkPricePreview struct {
+	Products       []BulkPricePreviewProduct `json:"products"`
+	ProductCount   int                       `json:"productCount"`
+	ViolationCount int                       `json:"violationCount"`
+	FailedIDs      []string                  `json:"failedIds,omitempty"`
+}
+
+// Blocks reports whether a preview's violations stop the update going ahead.
+// Prices below cost are allowed if allowBelowCost is set; negative prices never are.
+func (p *BulkPricePreview) Blocks(allowBelowCost bool) bool {
+	for _, product := range p.Products {
+		violations := product.Violations
+		for _, tier := range product.Tiers {
+			violations = append(violations, tier.Violations...)
+		}
+		for _, v := range violations {
+			if v == PriceViolationNegative || !allowBelowCost {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BulkPricePreviewProduct is one product's prices before and after a bulk
+// update. New prices are shown as computed, so may be negative.
+type BulkPricePreviewProduct struct {
+	ProductID  uuid.UUID              `json:"productId"`
+	Name       string                 `json:"name"`
+	CostPrice  *Money                 `json:"costPrice,omitempty"`
+	OldPrice   Money                  `json:"oldPrice"`
+	NewPrice   Money                  `json:"newPrice"`
+	Tiers      []BulkPricePreviewTier `json:"tiers,omitempty"`
+	Violations []PriceViolation       `json:"violations,omitempty"`
+}
+
+// Violated reports whether the product's base price or any of its tier prices
+// has a violation
+func (p *BulkPricePreviewProduct) Violated() bool {
+	violated := len(p.Violations) > 0
+	for _, tier := range p.Tiers {
+		violated = violated || len(tier.Violations) > 0
+	}
+	return violated
+}
+
+// BulkPricePreviewTier is one quantity tier's price before and after a bulk update
+type BulkPricePreviewTier struct {
+	TierID     uuid.UUID        `json:"tierId"`
+	MinQty     int              `json:"minQty"`
+	MaxQty     int              `json:"maxQty"`
+	OldPrice   Money            `json:"oldPrice"`
+	NewPrice   Money            `json:"newPrice"`
+	Violations []PriceViolation `json:"violations,omitempty"`
+}
+
+// ProductPriceHistory records one change to a product's base price. ChangedBy
+// is nil for changes made by the system.
+type ProductPriceHistory struct {
+	ID            uuid.UUID  `json:"id"`
+	ProductID     uuid.UUID  `json:"productId"`
+	ProductName   string     `json:"productName"`
+	PriceChangeID *uuid.UUID `json:"priceChangeId,omitempty"`
+	OldPrice      Money      `json:"oldPrice"`
+	NewPrice      Money      `json:"newPrice"`
+	Reason        string     `json:"reason,omitempty"`
+	ChangedBy     *uuid.UUID `json:"changedBy,omitempty"`
+	ChangedByName string     `json:"changedByName,omitempty"`
+	ChangedAt     time.Time  `json:"changedAt"`
+}
+
+// RollbackPriceChangeRequest gives the reason a price change is being undone
+type RollbackPriceChangeRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPriceViolations(t *testing.T) {
+	cost := Kobo(50000)
+	tests := []struct {
+		name  string
+		price Money
+		cost  *Money
+		want  []PriceViolation
+	}{
+		{"above cost", Kobo(60000), &cost, nil},
+		{"at cost", Kobo(50000), &cost, nil},
+		{"below cost", Kobo(49999), &cost, []PriceViolation{PriceViolationBelowCost}},
+		{"negative", Kobo(-100), &cost, []PriceViolation{PriceViolationNegative, PriceViolationBelowCost}},
+		{"negative with no cost", Kobo(-100), nil, []PriceViolation{PriceViolationNegative}},
+		{"zero with no cost", Money{}, nil, nil},
+	}
+	for _, tt := range tests {
+		if got := PriceViolations(tt.price, tt.cost); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: violations = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBulkPricePreviewBlocks(t *testing.T) {
+	preview := func(base, tier []PriceViolation) *BulkPricePreview {
+		return &BulkPricePreview{Products: []BulkPricePreviewProduct{
+			{Violations: nil},
+			{Violations: base, Tiers: []BulkPricePreviewTier{{Violations: tier}}},
+		}}
+	}
+	belowCost := []PriceViolation{PriceViolationBelowCost}
+	negative := []PriceViolation{PriceViolationNegative}
+
+	tests := []struct {
+		name           string
+		preview        *BulkPricePreview
+		allowBelowCost bool
+		want           bool
+	}{
+		{"no violations", preview(nil, nil), false, false},
+		{"below cost", preview(belowCost, nil), false, true},
+		{"below cost allowed", preview(belowCost, nil), true, false},
+		{"tier below cost", preview(nil, belowCost), false, true},
+		{"tier below cost allowed", preview(nil, belowCost), true, false},
+		{"negative", preview(negative, nil), true, true},
+		{"negative tier", preview(nil, negative), true, true},
+	}
+	for _, tt := range tests {
+		if got := tt.preview.Blocks(tt.allowBelowCost); got != tt.want {
+			t.Errorf("%s: Blocks(%v) = %v, want %v", tt.name, tt.allowBelowCost, got, tt.want)
+		}
+		violated := tt.preview.Products[1].Violated()
+		if violated != (tt.name != "no violations") {
+			t.Errorf("%s: Violated() = %v", tt.name, violated)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"time"
+
 	"github.com/google/uuid"
 )
 
@@ -9,75 +12,224 @@ type PricingTier struct {
 	ProductID uuid.UUID `json:"productId"`
 	MinQty    int       `json:"minQty"`
 	MaxQty    int       `json:"maxQty"`
-	Price     float64   `json:"price"`
+	Price     Money     `json:"price"`
 }
 
+// DimensionalPricing prices a product by its printed area at RatePerUnit naira per
+// Unit of area. Size limits, bleed and edge finishing are in SizeUnit. Bleed is
+// added to every edge and charged for, but not finished.
 type DimensionalPricing struct {
-	ID          uuid.UUID `json:"id"`
-	ProductID   uuid.UUID `json:"productId"`
-	RatePerUnit float64   `json:"ratePerUnit"`
-	Unit        string    `json:"unit"` // e.g., "sqft", "sqin"
-	MinCharge   float64   `json:"minCharge"`
+	ID          uuid.UUID       `json:"id"`
+	ProductID   uuid.UUID       `json:"productId"`
+	RatePerUnit float64         `json:"ratePerUnit"`
+	Unit        string          `json:"unit"`     // sqmm, sqcm, sqm, sqin, sqft
+	SizeUnit    string          `json:"sizeUnit"` // mm, cm, m, in, ft
+	MinCharge   Money           `json:"minCharge"`
+	MinWidth    *float64        `json:"minWidth,omitempty"`
+	MaxWidth    *float64        `json:"maxWidth,omitempty"`
+	MinHeight   *float64        `json:"minHeight,omitempty"`
+	MaxHeight   *float64        `json:"maxHeight,omitempty"`
+	Bleed       float64         `json:"bleed"`
+	Finishings  []EdgeFinishing `json:"finishings"`
 }
 
+// Add-on types. A flat add-on adds PriceModifier naira to each unit; a percentage
+// add-on adds PriceModifier per cent of the unit subtotal.
+const (
+	AddOnTypeFlat       = "flat"
+	AddOnTypePercentage = "percentage"
+)
+
+// ErrPricingFormula is returned when a product's pricing formula cannot be
+// evaluated for a configuration, e.g. because a value it reads is missing
+var ErrPricingFormula = errors.New("pricing formula failed")
+
+// AddOn is an optional extra such as lamination or rounded corners, selected by
+// listing its ID under "addOns" in the item configuration
 type AddOn struct {
-	ID            uuid.UUID `json:"id"`
-	ProductID     uuid.UUID `json:"productId"`
-	Name          string    `json:"name"`
-	Type          string    `json:"type"` // flat, percentage
-	PriceModifier float64   `json:"priceModifier"`
-	Enabled       bool      `json:"enabled"`
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"productId"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"` // flat, percentage
+	// PriceModifier is naira for flat add-ons and a percentage otherwise, so it
+	// stays a float
+	PriceModifier float64 `json:"priceModifier"`
+	Enabled       bool    `json:"enabled"`
 }
 
+// Pricing rule types. Setup and rush fees are charged once per order line, not per
+// unit, and a minimum charge raises the line total to at least its value.
+const (
+	PricingRuleMinimumCharge = "minimum_charge"
+	PricingRuleSetupFee      = "setup_fee"
+	PricingRuleRushFee       = "rush_fee"
+)
+
+// Pricing rule value types. A percentage rule charges that share of the line's
+// items, including add-ons. Minimum charges are always flat.
+const (
+	RuleValueFlat       = "flat"
+	RuleValuePercentage = "percentage"
+)
+
 type PricingRule struct {
-	ID          uuid.UUID `json:"id"`
-	ProductID   uuid.UUID `json:"productId"`
-	RuleType    string    `json:"ruleType"` // minimum_charge, setup_fee, rush_fee
-	Value       float64   `json:"value"`
-	Description string    `json:"description"`
+	ID        uuid.UUID `json:"id"`
+	ProductID uuid.UUID `json:"productId"`
+	RuleType  string    `json:"ruleType"`  // minimum_charge, setup_fee, rush_fee
+	ValueType string    `json:"valueType"` // flat, percentage
+	// Value is naira for flat rules and a percentage otherwise, so it stays a float
+	Value       float64 `json:"value"`
+	Description string  `json:"description"`
+	// CreatedAt and UpdatedAt version the rule; order snapshots keep both
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// PricingRuleRequest creates or replaces a pricing rule. ValueType defaults to flat.
+type PricingRuleRequest struct {
+	RuleType    string  `json:"ruleType" binding:"required,oneof=minimum_charge setup_fee rush_fee"`
+	ValueType   string  `json:"valueType" binding:"omitempty,oneof=flat percentage"`
+	Value       float64 `json:"value" binding:"gt=0"`
+	Description string  `json:"description" binding:"max=255"`
+}
+
+// AppliedRule is a pricing rule as charged on a line, labelled for display
+type AppliedRule struct {
+	RuleID   uuid.UUID `json:"ruleId"`
+	RuleType string    `json:"ruleType"`
+	Label    string    `json:"label"`
+	Amount   Money     `json:"amount"`
+}
+
+// PricingFormula is one step of a product's pricing formula, written in the
+// expression language of the formula package. Published formulas replace the
+// base price, quantity tiers and dimensional pricing: they are evaluated in
+// Position order and each result is added to the line subtotal.
+type PricingFormula struct {
+	ID         uuid.UUID `json:"id"`
+	ProductID  uuid.UUID `json:"productId"`
+	Position   int       `json:"position"`
+	Label      string    `json:"label"`
+	Expression string    `json:"expression"`
+	Published  bool      `json:"published"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+type PricingFormulaRequest struct {
+	Position   int    `json:"position"`
+	Label      string `json:"label" binding:"required,max=100"`
+	Expression string `json:"expression" binding:"required,max=1000"`
+	Published  bool   `json:"published"`
+}
+
+// AppliedFormula is a formula's contribution to a line, in evaluation order
+type AppliedFormula struct {
+	FormulaID uuid.UUID `json:"formulaId"`
+	Label     string    `json:"label"`
+	Amount    Money     `json:"amount"`
+}
+
+// FormulaDryRunRequest prices sample configurations without saving anything.
+// Without Formulas the product's saved formulas are used, drafts included.
+type FormulaDryRunRequest struct {
+	Formulas []PricingFormulaRequest `json:"formulas" binding:"dive"`
+	Samples  []FormulaSample         `json:"samples" binding:"required,min=1,max=50,dive"`
+}
+
+type FormulaSample struct {
+	Configuration map[string]interface{} `json:"configuration" binding:"required"`
+	Quantity      int                    `json:"quantity" binding:"gte=0"`
+}
+
+// FormulaDryRunResult is the price of one sample, or why it could not be priced
+type FormulaDryRunResult struct {
+	FormulaSample
+	Breakdown *PriceBreakdown `json:"breakdown,omitempty"`
+	Error     string          `json:"error,omitempty"`
 }
 
 type CalculatePriceRequest struct {
 	ProductID     uuid.UUID              `json:"productId" binding:"required"`
 	Configuration map[string]interface{} `json:"configuration" binding:"required"`
 	Quantity      int                    `json:"quantity"`
+	// UserID prices the item for a signed-in customer, from their group's price
+	// list if they are in one
+	UserID uuid.UUID `json:"-"`
 }
 
+// PriceBreakdown itemises the price of an order line. Option modifiers, edge
+// finishing and add-ons are per unit. Formula contributions and setup and rush fees are for the whole
+// line, and MinimumCharge is what the minimum charge added to bring the line up
+// to it. Rules lists each pricing rule that was charged. CustomerGroup names the
+// group whose price list was used, and GroupDiscount is its category discount on
+// the line.
 type PriceBreakdown struct {
-	BasePrice       float64            `json:"basePrice"`
-	OptionModifiers map[string]float64 `json:"optionModifiers"`
-	DimensionalCost float64            `json:"dimensionalCost,omitempty"`
-	QuantityPrice   float64            `json:"quantityPrice,omitempty"`
-	AddOns          map[string]float64 `json:"addOns,omitempty"`
-	SetupFee        float64            `json:"setupFee,omitempty"`
-	RushFee         float64            `json:"rushFee,omitempty"`
-	Subtotal        float64            `json:"subtotal"`
-	Total           float64            `json:"total"`
+	BasePrice       Money            `json:"basePrice"`
+	OptionModifiers map[string]Money `json:"optionModifiers"`
+	DimensionalCost Money            `json:"dimensionalCost"`
+	Finishing       map[string]Money `json:"finishing,omitempty"`
+	QuantityPrice   Money            `json:"quantityPrice"`
+	Formula         []AppliedFormula `json:"formula,omitempty"`
+	AddOns          map[string]Money `json:"addOns,omitempty"`
+	SetupFee        Money            `json:"setupFee"`
+	RushFee         Money            `json:"rushFee"`
+	MinimumCharge   Money            `json:"minimumCharge"`
+	Rules           []AppliedRule    `json:"rules,omitempty"`
+	CustomerGroup   string           `json:"customerGroup,omitempty"`
+	GroupDiscount   Money            `json:"groupDiscount"`
+	Subtotal        Money            `json:"subtotal"`
+	Total           Money            `json:"total"`
 }
 
 type CreatePricingTierRequest struct {
-	MinQty int     `json:"minQty" binding:"required"`
-	MaxQty int     `json:"maxQty"`
-	Price  float64 `json:"price" binding:"required"`
+	MinQty int   `json:"minQty" binding:"required"`
+	MaxQty int   `json:"maxQty"`
+	Price  Money `json:"price" binding:"required"`
 }
 
+// AddOnRequest creates or replaces an add-on. Enabled defaults to true.
+type AddOnRequest struct {
+	Name          string  `json:"name" binding:"required,max=100"`
+	Type          string  `json:"type" binding:"required,oneof=flat percentage"`
+	PriceModifier float64 `json:"priceModifier" binding:"gte=0"`
+	Enabled       *bool   `json:"enabled"`
+}
+
+// CreateDimensionalPricingRequest sets a product's dimensional pricing. SizeUnit
+// defaults to the length unit of Unit, e.g. ft for sqft.
 type CreateDimensionalPricingRequest struct {
-	RatePerUnit float64 `json:"ratePerUnit" binding:"required"`
-	Unit        string  `json:"unit" binding:"required"`
-	MinCharge   float64 `json:"minCharge"`
+	RatePerUnit float64         `json:"ratePerUnit" binding:"required"`
+	Unit        string          `json:"unit" binding:"required,oneof=sqmm sqcm sqm sqin sqft"`
+	SizeUnit    string          `json:"sizeUnit" binding:"omitempty,oneof=mm cm m in ft"`
+	MinCharge   Money           `json:"minCharge"`
+	MinWidth    *float64        `json:"minWidth" binding:"omitempty,gt=0"`
+	MaxWidth    *float64        `json:"maxWidth" binding:"omitempty,gt=0"`
+	MinHeight   *float64        `json:"minHeight" binding:"omitempty,gt=0"`
+	MaxHeight   *float64        `json:"maxHeight" binding:"omitempty,gt=0"`
+	Bleed       float64         `json:"bleed" binding:"gte=0"`
+	Finishings  []EdgeFinishing `json:"finishings" binding:"dive"`
 }
 
 // ShippingConfig holds the global shipping configuration
 type ShippingConfig struct {
 	ID                    uuid.UUID `json:"id"`
-	ShippingFee           float64   `json:"shippingFee"`
-	FreeShippingThreshold float64   `json:"freeShippingThreshold"`
+	ShippingFee           Money     `json:"shippingFee"`
+	FreeShippingThreshold Money     `json:"freeShippingThreshold"`
 	CreatedAt             string    `json:"createdAt"`
 	UpdatedAt             string    `json:"updatedAt"`
 }
 
+// ShippingFor returns the shipping charged on an order with the given subtotal
+func (c *ShippingConfig) ShippingFor(subtotal Money) Money {
+	if subtotal.LessThan(c.FreeShippingThreshold) {
+		return c.ShippingFee
+	}
+	return Money{}
+}
+
 // UpdateShippingConfigRequest represents a request to update shipping config
 type UpdateShippingConfigRequest struct {
-	ShippingFee           float64 `json:"shippingFee" binding:"required,min=0"`
-	FreeShippingThreshold float64 `json:"freeShippingThreshold" binding:"required,min=0"`
+	ShippingFee           Money `json:"shippingFee" binding:"required,min=0"`
+	FreeShippingThreshold Money `json:"freeShippingThreshold" binding:"required,min=0"`
 }
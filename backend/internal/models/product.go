@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,40 +18,137 @@ const (
 )
 
 type ProductOptionValue struct {
-	Value         string   `json:"value"`
-	Label         string   `json:"label"`
-	PriceModifier *float64 `json:"priceModifier,omitempty"`
+	Value         string `json:"value"`
+	Label         string `json:"label"`
+	PriceModifier *Money `json:"priceModifier,omitempty"`
 }
 
+// ProductOption is one configurable choice on a product. Select and radio options
+// take one of Options, checkboxes a list of them, and quantity and dimension
+// options a number within Min and Max, in steps of Step counted from Min. A
+// quantity option with PricePerUnit adds that much per unit chosen.
 type ProductOption struct {
-	ID      string               `json:"id"`
-	Name    string               `json:"name"`
-	Type    OptionType           `json:"type"`
-	Options []ProductOptionValue `json:"options,omitempty"`
-	Min     *float64             `json:"min,omitempty"`
-	Max     *float64             `json:"max,omitempty"`
-	Step    *float64             `json:"step,omitempty"`
-	Unit    *string              `json:"unit,omitempty"`
+	ID           string               `json:"id"`
+	Name         string               `json:"name"`
+	Type         OptionType           `json:"type"`
+	Options      []ProductOptionValue `json:"options,omitempty"`
+	Min          *float64             `json:"min,omitempty"`
+	Max          *float64             `json:"max,omitempty"`
+	Step         *float64             `json:"step,omitempty"`
+	Unit         *string              `json:"unit,omitempty"`
+	PricePerUnit *Money               `json:"pricePerUnit,omitempty"`
+	Required     bool                 `json:"required,omitempty"`
+	DependsOn    *OptionDependency    `json:"dependsOn,omitempty"`
 }
 
+// ProductStatus is where a product is in its lifecycle. Only published products
+// are shown to customers and can be bought.
+type ProductStatus string
+
+const (
+	ProductStatusDraft     ProductStatus = "draft"
+	ProductStatusPublished ProductStatus = "published"
+	ProductStatusArchived  ProductStatus = "archived"
+)
+
+// IsValid checks if the status is one a product can have
+func (s ProductStatus) IsValid() bool {
+	switch s {
+	case ProductStatusDraft, ProductStatusPublished, ProductStatusArchived:
+		return true
+	}
+	return false
+}
+
+// ErrProductUnavailable is returned when pricing a product that is not on sale
+var ErrProductUnavailable = errors.New("product is not available")
+
 type Product struct {
-	ID               uuid.UUID       `json:"id"`
-	Name             string          `json:"name"`
-	Slug             string          `json:"slug"`
-	CategoryID       uuid.UUID       `json:"categoryId"`
-	Category         string          `json:"category"`
-	CategorySlug     string          `json:"categorySlug"`
-	Description      string          `json:"description"`
-	ShortDescription string          `json:"shortDescription"`
-	BasePrice        float64         `json:"basePrice"`
-	Images           []string        `json:"images"`
-	Options          []ProductOption `json:"options"`
-	Features         []string        `json:"features"`
-	Turnaround       string          `json:"turnaround"`
-	MinQuantity      int             `json:"minQuantity"`
-	PricingTiers     []PricingTier   `json:"pricingTiers,omitempty"`
-	CreatedAt        time.Time       `json:"createdAt"`
-	UpdatedAt        time.Time       `json:"updatedAt"`
+	ID                uuid.UUID       `json:"id"`
+	Name              string          `json:"name"`
+	Slug              string          `json:"slug"`
+	CategoryID        uuid.UUID       `json:"categoryId"`
+	Category          string          `json:"category"`
+	CategorySlug      string          `json:"categorySlug"`
+	Description       string          `json:"description"`
+	ShortDescription  string          `json:"shortDescription"`
+	BasePrice         Money           `json:"basePrice"`
+	Images            []string        `json:"images"`
+	Options           []ProductOption `json:"options"`
+	Features          []string        `json:"features"`
+	Turnaround        string          `json:"turnaround"`
+	MinQuantity       int             `json:"minQuantity"`
+	PricingTiers      []PricingTier   `json:"pricingTiers,omitempty"`
+	TaxExempt         bool            `json:"taxExempt"`
+	CategoryTaxExempt bool            `json:"categoryTaxExempt"`
+	// CostPrice is what a unit costs to make. It is only shown to staff.
+	CostPrice *Money        `json:"costPrice,omitempty"`
+	Status    ProductStatus `json:"status"`
+	// PublishAt and UnpublishAt are when the product is next published or
+	// archived. Each is cleared once it has happened.
+	PublishAt   *time.Time `json:"publishAt,omitempty"`
+	UnpublishAt *time.Time `json:"unpublishAt,omitempty"`
+	DeletedAt   *time.Time `json:"deletedAt,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// IsAvailable reports whether customers can see and buy the product
+func (p *Product) IsAvailable() bool {
+	return p.Status == ProductStatusPublished && p.DeletedAt == nil
+}
+
+// ProductSort orders a product search
+type ProductSort string
+
+const (
+	// ProductSortRelevance puts the best matches for the search first. Without a
+	// search it is the same as ProductSortName.
+	ProductSortRelevance  ProductSort = "relevance"
+	ProductSortName       ProductSort = "name"
+	ProductSortPriceAsc   ProductSort = "price_asc"
+	ProductSortPriceDesc  ProductSort = "price_desc"
+	ProductSortNewest     ProductSort = "newest"
+	ProductSortPopularity ProductSort = "popularity"
+)
+
+// IsValid checks if the sort is one the search supports
+func (s ProductSort) IsValid() bool {
+	switch s {
+	case ProductSortRelevance, ProductSortName, ProductSortPriceAsc, ProductSortPriceDesc,
+		ProductSortNewest, ProductSortPopularity:
+		return true
+	}
+	return false
+}
+
+// ErrInvalidCursor is returned for a page cursor that is malformed or was issued
+// for a different sort
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ProductQuery searches and filters the catalog. Products must match every
+// filter that is set. Deleted products are only found, and then only they,
+// when Deleted is set. Cursor is the NextCursor of the previous page, and must
+// be used with the same query.
+type ProductQuery struct {
+	Status            ProductStatus
+	Deleted           bool
+	Search            string
+	CategorySlugs     []string
+	MinPrice          *Money
+	MaxPrice          *Money
+	MaxTurnaroundDays int
+	Sort              ProductSort
+	Cursor            string
+	Limit             int
+}
+
+// ProductPage is one page of a product search. Total counts every match, and
+// NextCursor is empty on the last page.
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	Total      int       `json:"total"`
+	NextCursor string    `json:"nextCursor,omitempty"`
 }
 
 type CreateProductRequest struct {
@@ -59,12 +157,18 @@ type CreateProductRequest struct {
 	CategoryID       uuid.UUID       `json:"categoryId" binding:"required"`
 	Description      string          `json:"description"`
 	ShortDescription string          `json:"shortDescription"`
-	BasePrice        float64         `json:"basePrice" binding:"required"`
+	BasePrice        Money           `json:"basePrice" binding:"required"`
 	Images           []string        `json:"images"`
 	Options          []ProductOption `json:"options"`
 	Features         []string        `json:"features"`
 	Turnaround       string          `json:"turnaround"`
 	MinQuantity      int             `json:"minQuantity"`
+	TaxExempt        bool            `json:"taxExempt"`
+	CostPrice        *Money          `json:"costPrice"`
+	// Status defaults to published
+	Status      ProductStatus `json:"status" binding:"omitempty,oneof=draft published archived"`
+	PublishAt   *time.Time    `json:"publishAt"`
+	UnpublishAt *time.Time    `json:"unpublishAt"`
 }
 
 type UpdateProductRequest struct {
@@ -73,24 +177,58 @@ type UpdateProductRequest struct {
 	CategoryID       *uuid.UUID       `json:"categoryId"`
 	Description      *string          `json:"description"`
 	ShortDescription *string          `json:"shortDescription"`
-	BasePrice        *float64         `json:"basePrice"`
+	BasePrice        *Money           `json:"basePrice"`
 	Images           []string         `json:"images"`
 	Options          *[]ProductOption `json:"options"`
 	Features         []string         `json:"features"`
 	Turnaround       *string          `json:"turnaround"`
 	MinQuantity      *int             `json:"minQuantity"`
+	TaxExempt        *bool            `json:"taxExempt"`
+	// CostPrice of zero clears the cost
+	CostPrice *Money `json:"costPrice"`
+	// PriceReason is recorded in the price history when BasePrice changes
+	PriceReason string         `json:"priceReason" binding:"max=500"`
+	Status      *ProductStatus `json:"status" binding:"omitempty,oneof=draft published archived"`
 }
 
-// BulkUpdatePriceRequest for updating prices of multiple products at once
+// ProductScheduleRequest replaces when a product is next published and archived.
+// A missing time clears it.
+type ProductScheduleRequest struct {
+	PublishAt   *time.Time `json:"publishAt"`
+	UnpublishAt *time.Time `json:"unpublishAt"`
+}
+
+// BulkUpdatePriceRequest for updating prices of multiple products at once. Value is
+// an amount in naira, or a percentage for the percentage update type. With a
+// future EffectiveAt the change is scheduled instead of applied straight away.
+//
+// Products are chosen by ID, category and name search; at least one must be
+// given, and a product must match all of those that are. Updates that would take
+// a price below zero are refused, as are those below the product's cost price
+// unless AllowBelowCost is set.
 type BulkUpdatePriceRequest struct {
-	ProductIDs []uuid.UUID `json:"productIds" binding:"required,min=1"`
-	UpdateType string      `json:"updateType" binding:"required,oneof=set increase decrease percentage"`
-	Value      float64     `json:"value" binding:"required"`
+	ProductIDs     []uuid.UUID `json:"productIds"`
+	CategoryID     *uuid.UUID  `json:"categoryId"`
+	Search         string      `json:"search" binding:"max=200"`
+	UpdateType     string      `json:"updateType" binding:"required,oneof=set increase decrease percentage"`
+	Value          float64     `json:"value" binding:"required"`
+	EffectiveAt    *time.Time  `json:"effectiveAt"`
+	Reason         string      `json:"reason" binding:"max=500"`
+	AllowBelowCost bool        `json:"allowBelowCost"`
+}
+
+// HasSelection reports whether the request says which products to update
+func (r *BulkUpdatePriceRequest) HasSelection() bool {
+	return len(r.ProductIDs) > 0 || r.CategoryID != nil || r.Search != ""
 }
 
-// BulkUpdatePriceResponse returns the results of bulk price update
+// BulkUpdatePriceResponse returns the results of bulk price update. A scheduled
+// change counts its products in ScheduledCount until it is applied.
 type BulkUpdatePriceResponse struct {
-	UpdatedCount int      `json:"updatedCount"`
-	FailedCount  int      `json:"failedCount"`
-	FailedIDs    []string `json:"failedIds,omitempty"`
+	PriceChangeID  *uuid.UUID        `json:"priceChangeId,omitempty"`
+	Status         PriceChangeStatus `json:"status,omitempty"`
+	UpdatedCount   int               `json:"updatedCount"`
+	ScheduledCount int               `json:"scheduledCount,omitempty"`
+	FailedCount    int               `json:"failedCount"`
+	FailedIDs      []string          `json:"failedIds,omitempty"`
 }
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQuoteExpired   = errors.New("quote has expired")
+	ErrQuoteConverted = errors.New("quote has already been ordered")
+)
+
+// Quote statuses. Only open and converted are stored; an open quote past its
+// expiry is reported as expired.
+type QuoteStatus string
+
+const (
+	QuoteStatusOpen      QuoteStatus = "open"
+	QuoteStatusExpired   QuoteStatus = "expired"
+	QuoteStatusConverted QuoteStatus = "converted"
+)
+
+// Quote is a priced list of items that holds its prices until ExpiresAt. Anyone
+// with the share token can view it; only its owner can order it.
+type Quote struct {
+	ID          uuid.UUID   `json:"id"`
+	QuoteNumber string      `json:"quoteNumber"`
+	UserID      uuid.UUID   `json:"userId"`
+	Status      QuoteStatus `json:"status"`
+	Items       []QuoteItem `json:"items"`
+	Subtotal    Money       `json:"subtotal"`
+	Notes       string      `json:"notes,omitempty"`
+	ShareToken  string      `json:"shareToken,omitempty"`
+	ExpiresAt   time.Time   `json:"expiresAt"`
+	OrderID     *uuid.UUID  `json:"orderId,omitempty"`
+	CreatedAt   time.Time   `json:"createdAt"`
+	UpdatedAt   time.Time   `json:"updatedAt"`
+}
+
+// Convertible reports why the quote cannot be ordered at now, or nil if it can
+func (q *Quote) Convertible(now time.Time) error {
+	if q.Status == QuoteStatusConverted {
+		return ErrQuoteConverted
+	}
+	if !now.Before(q.ExpiresAt) {
+		return ErrQuoteExpired
+	}
+	return nil
+}
+
+// QuoteItem is one line of a quote, frozen like an order item. Overrides lists
+// the staff price changes made to it, oldest first, and is only loaded for staff.
+type QuoteItem struct {
+	ID            uuid.UUID              `json:"id"`
+	QuoteID       uuid.UUID              `json:"quoteId"`
+	ProductID     uuid.UUID              `json:"productId"`
+	Quantity      int                    `json:"quantity"`
+	Configuration map[string]interface{} `json:"configuration"`
+	UnitPrice     Money                  `json:"unitPrice"`
+	TotalPrice    Money                  `json:"totalPrice"`
+	Snapshot      *OrderItemSnapshot     `json:"snapshot"`
+	Overrides     []QuotePriceOverride   `json:"overrides,omitempty"`
+}
+
+// QuotePriceOverride records a staff change to the price of a quote line
+type QuotePriceOverride struct {
+	ID            uuid.UUID `json:"id"`
+	QuoteItemID   uuid.UUID `json:"quoteItemId"`
+	PreviousPrice Money     `json:"previousPrice"`
+	Price         Money     `json:"price"`
+	Reason        string    `json:"reason"`
+	CreatedBy     uuid.UUID `json:"createdBy"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// WithOverride returns a copy of the snapshot for a line whose price staff have
+// overridden. The breakdown's total, and its subtotal when it has one, become
+// the new price, and the override is recorded with it.
+func (s *OrderItemSnapshot) WithOverride(override QuotePriceOverride) *OrderItemSnapshot {
+	snapshot := *s
+	if s.Breakdown != nil {
+		breakdown := *s.Breakdown
+		breakdown.Total = override.Price
+		if !breakdown.Subtotal.IsZero() {
+			breakdown.Subtotal = override.Price
+		}
+		snapshot.Breakdown = &breakdown
+	}
+	snapshot.Override = &override
+	return &snapshot
+}
+
+// CreateQuoteRequest quotes the given items, or the customer's cart when there
+// are none
+type CreateQuoteRequest struct {
+	Items []CreateOrderItemRequest `json:"items" binding:"dive"`
+	Notes string                   `json:"notes" binding:"max=2000"`
+}
+
+// ConvertQuoteRequest orders a quote at its quoted prices
+type ConvertQuoteRequest struct {
+	ShippingAddress ShippingAddress `json:"shippingAddress" binding:"required"`
+	CouponCode      string          `json:"couponCode"`
+}
+
+// OverrideQuotePriceRequest sets the total price of a quote line
+type OverrideQuotePriceRequest struct {
+	TotalPrice Money  `json:"totalPrice" binding:"gte=0"`
+	Reason     string `json:"reason" binding:"required,max=500"`
+}
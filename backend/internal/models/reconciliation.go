@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrReconciliationRunning is returned when another reconciliation run holds the lock
+var ErrReconciliationRunning = errors.New("a reconciliation run is already in progress")
+
+const (
+	ReconciliationTriggerScheduled = "scheduled"
+	ReconciliationTriggerManual    = "manual"
+	ReconciliationTriggerCommand   = "command"
+)
+
+type ReconciliationOutcome string
+
+const (
+	// ReconciliationSettled: the provider reported success and the order was marked paid
+	ReconciliationSettled ReconciliationOutcome = "settled"
+	// ReconciliationFailed: the provider reported the charge failed or was abandoned
+	ReconciliationFailed ReconciliationOutcome = "failed"
+	// ReconciliationExpired: the payment was never completed and its reference was closed
+	ReconciliationExpired ReconciliationOutcome = "expired"
+	// ReconciliationPending: the charge is still in progress at the provider
+	ReconciliationPending ReconciliationOutcome = "pending"
+	// ReconciliationMismatch: the provider's amount or currency differs from the order
+	ReconciliationMismatch ReconciliationOutcome = "mismatch"
+	// ReconciliationError: the payment could not be checked this time
+	ReconciliationError ReconciliationOutcome = "error"
+)
+
+type ReconciliationRun struct {
+	ID         uuid.UUID            `json:"id"`
+	Trigger    string               `json:"trigger"`
+	Checked    int                  `json:"checked"`
+	Settled    int                  `json:"settled"`
+	Failed     int                  `json:"failed"`
+	Expired    int                  `json:"expired"`
+	Pending    int                  `json:"pending"`
+	Mismatches int                  `json:"mismatches"`
+	Errors     int                  `json:"errors"`
+	StartedAt  time.Time            `json:"startedAt"`
+	FinishedAt *time.Time           `json:"finishedAt,omitempty"`
+	Items      []ReconciliationItem `json:"items,omitempty"`
+}
+
+// Count adds an item's outcome to the run totals
+func (r *ReconciliationRun) Count(outcome ReconciliationOutcome) {
+	r.Checked++
+	switch outcome {
+	case ReconciliationSettled:
+		r.Settled++
+	case ReconciliationFailed:
+		r.Failed++
+	case ReconciliationExpired:
+		r.Expired++
+	case ReconciliationPending:
+		r.Pending++
+	case ReconciliationMismatch:
+		r.Mismatches++
+	case ReconciliationError:
+		r.Errors++
+	}
+}
+
+type ReconciliationItem struct {
+	ID             uuid.UUID             `json:"id"`
+	RunID          uuid.UUID             `json:"runId"`
+	PaymentID      uuid.UUID             `json:"paymentId"`
+	OrderID        uuid.UUID             `json:"orderId"`
+	Provider       string                `json:"provider"`
+	Reference      string                `json:"reference"`
+	Outcome        ReconciliationOutcome `json:"outcome"`
+	ProviderStatus string                `json:"providerStatus"`
+	// ExpectedAmount is the order total; ProviderAmount is what the provider reports
+	ExpectedAmount Money     `json:"expectedAmount"`
+	ProviderAmount *Money    `json:"providerAmount,omitempty"`
+	Currency       string    `json:"currency"`
+	Detail         string    `json:"detail"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPaymentNotRefundable = errors.New("only successful payments can be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left to refund")
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending   RefundStatus = "pending"
+	RefundStatusProcessed RefundStatus = "processed"
+	RefundStatusFailed    RefundStatus = "failed"
+)
+
+type Refund struct {
+	ID               uuid.UUID    `json:"id"`
+	PaymentID        uuid.UUID    `json:"paymentId"`
+	OrderID          uuid.UUID    `json:"orderId"`
+	Provider         string       `json:"provider"`
+	ProviderRefundID string       `json:"providerRefundId"`
+	Amount           Money        `json:"amount"`
+	Currency         string       `json:"currency"`
+	Reason           string       `json:"reason"`
+	Status           RefundStatus `json:"status"`
+	ProviderResponse string       `json:"-"`
+	Error            *string      `json:"error,omitempty"`
+	// CreatedBy is nil for refunds found through a provider webhook
+	CreatedBy   *uuid.UUID `json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	ProcessedAt *time.Time `json:"processedAt,omitempty"`
+}
+
+type CreateRefundRequest struct {
+	// Amount in naira; zero or omitted refunds whatever has not been refunded yet
+	Amount Money  `json:"amount" binding:"gte=0"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+type PaymentRefundsResponse struct {
+	Payment          *Payment `json:"payment"`
+	Refunds          []Refund `json:"refunds"`
+	RefundedAmount   Money    `json:"refundedAmount"`
+	RefundableAmount Money    `json:"refundableAmount"`
+}
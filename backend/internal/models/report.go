@@ -5,20 +5,22 @@ import "time"
 type DailySalesReport struct {
 	Date        time.Time `json:"date"`
 	OrderCount  int       `json:"orderCount"`
-	TotalSales  float64   `json:"totalSales"`
-	AvgOrderVal float64   `json:"avgOrderValue"`
+	TotalSales  Money     `json:"totalSales"`
+	AvgOrderVal Money     `json:"avgOrderValue"`
+	Refunded    Money     `json:"refunded"`
 }
 
 type WeeklySalesReport struct {
 	WeekStart   time.Time `json:"weekStart"`
 	WeekEnd     time.Time `json:"weekEnd"`
 	OrderCount  int       `json:"orderCount"`
-	TotalSales  float64   `json:"totalSales"`
-	AvgOrderVal float64   `json:"avgOrderValue"`
+	TotalSales  Money     `json:"totalSales"`
+	AvgOrderVal Money     `json:"avgOrderValue"`
+	Refunded    Money     `json:"refunded"`
 }
 
 type OrdersByStatusReport struct {
-	Status     string  `json:"status"`
-	OrderCount int     `json:"orderCount"`
-	TotalValue float64 `json:"totalAmount"`
+	Status     string `json:"status"`
+	OrderCount int    `json:"orderCount"`
+	TotalValue Money  `json:"totalAmount"`
 }
@@ -0,0 +1,184 @@
+package models
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaxRate is a VAT rate, in per cent, in force from EffectiveFrom until the next
+// rate takes over
+type TaxRate struct {
+	ID            uuid.UUID `json:"id"`
+	Name          string    `json:"name"`
+	Rate          float64   `json:"rate"`
+	EffectiveFrom time.Time `json:"effectiveFrom"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TaxSettings holds the global tax configuration. When PricesIncludeTax is set,
+// product prices already include VAT and the tax on an order is the part of its
+// lines that is tax; otherwise VAT is added on top.
+type TaxSettings struct {
+	ID               uuid.UUID `json:"id"`
+	PricesIncludeTax bool      `json:"pricesIncludeTax"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// PublicTaxSettings tells the storefront how to show prices
+type PublicTaxSettings struct {
+	PricesIncludeTax bool     `json:"pricesIncludeTax"`
+	Rate             *TaxRate `json:"rate"`
+}
+
+// CustomerTaxProfile holds a business customer's tax details. Only staff can set
+// TaxExempt, and only with a TIN on file.
+type CustomerTaxProfile struct {
+	UserID      uuid.UUID  `json:"userId"`
+	CompanyName string     `json:"companyName,omitempty"`
+	TIN         string     `json:"tin"`
+	TaxExempt   bool       `json:"taxExempt"`
+	UpdatedBy   *uuid.UUID `json:"updatedBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// TaxExemption says why no tax was charged on an order line
+type TaxExemption string
+
+const (
+	TaxExemptProduct  TaxExemption = "product"
+	TaxExemptCategory TaxExemption = "category"
+	TaxExemptCustomer TaxExemption = "customer"
+)
+
+// ApplyTax works out the discount share, tax and net amount of each line from
+// its tax rate, then the order's tax and total. The discount is shared between
+// lines in proportion to their totals, so tax is charged on what the customer
+// pays. Shipping is not taxed.
+func (o *Order) ApplyTax() {
+	shares := shareDiscount(o.Discount, o.Items)
+	o.Tax = Money{}
+	for i := range o.Items {
+		item := &o.Items[i]
+		base := item.TotalPrice.Sub(shares[i])
+		item.Tax = LineTax(base, item.TaxRate, o.PricesIncludeTax)
+		item.NetAmount = base
+		if o.PricesIncludeTax {
+			item.NetAmount = base.Sub(item.Tax)
+		}
+		o.Tax = o.Tax.Add(item.Tax)
+	}
+
+	o.Total = o.Subtotal.Add(o.Shipping).Sub(o.Discount)
+	if !o.PricesIncludeTax {
+		o.Total = o.Total.Add(o.Tax)
+	}
+	if o.Total.IsNegative() {
+		o.Total = Money{}
+	}
+}
+
+// LineTax returns the tax on an amount at rate per cent. When the amount
+// includes tax it is the part of it that is tax; otherwise it is the tax to add.
+// Both are rounded half to even.
+func LineTax(amount Money, rate float64, inclusive bool) Money {
+	if rate == 0 {
+		return Money{}
+	}
+	if !inclusive {
+		return amount.Percent(rate)
+	}
+	r := ratFromFloat(rate)
+	tax := new(big.Rat).Mul(big.NewRat(amount.minor, 1), r)
+	tax.Quo(tax, r.Add(r, big.NewRat(100, 1)))
+	return Money{minor: roundHalfEven(tax, 1), currency: amount.currency}
+}
+
+// RemoveIncludedTax reduces a line whose price includes tax at rate per cent to
+// its price before tax, for a customer who does not pay the tax. The snapshot
+// shows the line as charged, so its breakdown's total and subtotal are reduced
+// too; the breakdown may be shared with a quote, so it is copied rather than
+// changed.
+func (item *OrderItem) RemoveIncludedTax(rate float64) {
+	withoutTax := func(m Money) Money {
+		return m.Sub(LineTax(m, rate, true))
+	}
+	item.TotalPrice = withoutTax(item.TotalPrice)
+	item.UnitPrice = item.TotalPrice.Div(item.Quantity)
+	if item.Snapshot != nil && item.Snapshot.Breakdown != nil {
+		breakdown := *item.Snapshot.Breakdown
+		breakdown.Total = withoutTax(breakdown.Total)
+		if !breakdown.Subtotal.IsZero() {
+			breakdown.Subtotal = withoutTax(breakdown.Subtotal)
+		}
+		item.Snapshot.Breakdown = &breakdown
+	}
+}
+
+// shareDiscount splits a discount between items in proportion to their totals,
+// giving the rounding difference to the last item so the shares add up exactly
+func shareDiscount(discount Money, items []OrderItem) []Money {
+	shares := make([]Money, len(items))
+	var total Money
+	for _, item := range items {
+		total = total.Add(item.TotalPrice)
+	}
+	if discount.IsZero() || !total.IsPositive() {
+		return shares
+	}
+
+	var shared Money
+	for i, item := range items {
+		if i == len(items)-1 {
+			shares[i] = discount.Sub(shared)
+			break
+		}
+		share := new(big.Int).Mul(big.NewInt(discount.minor), big.NewInt(item.TotalPrice.minor))
+		shares[i] = Money{minor: roundHalfEven(new(big.Rat).SetFrac(share, big.NewInt(total.minor)), 1), currency: discount.currency}
+		shared = shared.Add(shares[i])
+	}
+	return shares
+}
+
+// VATReport summarises the VAT charged on paid orders placed in [From, To), for
+// filing. Lines are grouped by rate and, for lines with no tax, the exemption.
+type VATReport struct {
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	OrderCount int             `json:"orderCount"`
+	Lines      []VATReportLine `json:"lines"`
+	NetAmount  Money           `json:"netAmount"`
+	Tax        Money           `json:"tax"`
+}
+
+// VATReportLine totals the order lines charged at one rate, or exempt for one reason
+type VATReportLine struct {
+	TaxRate   float64      `json:"taxRate"`
+	Exemption TaxExemption `json:"exemption,omitempty"`
+	ItemCount int          `json:"itemCount"`
+	NetAmount Money        `json:"netAmount"`
+	Tax       Money        `json:"tax"`
+}
+
+// TaxRateRequest creates or replaces a tax rate
+type TaxRateRequest struct {
+	Name          string    `json:"name" binding:"required,max=100"`
+	Rate          float64   `json:"rate" binding:"gte=0,lt=100"`
+	EffectiveFrom time.Time `json:"effectiveFrom" binding:"required"`
+}
+
+// UpdateTaxSettingsRequest changes whether prices include tax
+type UpdateTaxSettingsRequest struct {
+	PricesIncludeTax *bool `json:"pricesIncludeTax" binding:"required"`
+}
+
+// UpdateCustomerTaxProfileRequest sets a customer's tax details
+type UpdateCustomerTaxProfileRequest struct {
+	CompanyName string `json:"companyName" binding:"max=255"`
+	TIN         string `json:"tin" binding:"required,max=20"`
+	TaxExempt   bool   `json:"taxExempt"`
+}
@@ -0,0 +1,202 @@
+package models
+
+import "testing"
+
+func TestLineTax(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    int64
+		rate      float64
+		inclusive bool
+		want      int64
+	}{
+		{"exclusive", 1000000, 7.5, false, 75000},
+		{"inclusive", 1075000, 7.5, true, 75000},
+		// 1000 × 7.5 / 107.5 is 69.77 kobo
+		{"inclusive rounded", 1000, 7.5, true, 70},
+		{"exclusive tie to even down", 1, 50, false, 0},
+		{"exclusive tie to even up", 3, 50, false, 2},
+		{"inclusive tie to even down", 1, 100, true, 0},
+		{"inclusive tie to even up", 3, 100, true, 2},
+		{"zero rate", 1000000, 0, false, 0},
+		{"zero rate inclusive", 1000000, 0, true, 0},
+	}
+	for _, tt := range tests {
+		if got := LineTax(Kobo(tt.amount), tt.rate, tt.inclusive); got.Minor() != tt.want {
+			t.Errorf("%s: LineTax(%d, %v, %v) = %d, want %d", tt.name, tt.amount, tt.rate, tt.inclusive, got.Minor(), tt.want)
+		}
+	}
+}
+
+func TestShareDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		discount int64
+		totals   []int64
+		want     []int64
+	}{
+		{"no discount", 0, []int64{1000, 2000}, []int64{0, 0}},
+		{"proportional", 100, []int64{300, 100}, []int64{75, 25}},
+		{"remainder to the last line", 100, []int64{100, 100, 100}, []int64{33, 33, 34}},
+		// 333 × 500 / 1000 is 166.5, rounded to even
+		{"tie to even", 500, []int64{333, 333, 334}, []int64{166, 166, 168}},
+		{"free line", 100, []int64{0, 1000}, []int64{0, 100}},
+		{"nothing to share against", 100, []int64{0, 0}, []int64{0, 0}},
+	}
+	for _, tt := range tests {
+		items := make([]OrderItem, len(tt.totals))
+		for i, total := range tt.totals {
+			items[i].TotalPrice = Kobo(total)
+		}
+		shares := shareDiscount(Kobo(tt.discount), items)
+		var sum int64
+		for i, share := range shares {
+			if share.Minor() != tt.want[i] {
+				t.Errorf("%s: share %d = %d, want %d", tt.name, i, share.Minor(), tt.want[i])
+			}
+			sum += share.Minor()
+		}
+		if sum != tt.discount && tt.name != "nothing to share against" {
+			t.Errorf("%s: shares add up to %d, want %d", tt.name, sum, tt.discount)
+		}
+	}
+}
+
+func TestOrderApplyTax(t *testing.T) {
+	type line struct {
+		total     int64
+		rate      float64
+		exemption TaxExemption
+	}
+	type want struct {
+		tax, net int64
+	}
+	tests := []struct {
+		name      string
+		inclusive bool
+		lines     []line
+		shipping  int64
+		discount  int64
+		wantLines []want
+		wantTax   int64
+		wantTotal int64
+	}{
+		{
+			name:      "exclusive",
+			lines:     []line{{1000000, 7.5, ""}},
+			shipping:  200000,
+			wantLines: []want{{75000, 1000000}},
+			wantTax:   75000,
+			wantTotal: 1275000,
+		},
+		{
+			name:      "inclusive",
+			inclusive: true,
+			lines:     []line{{1075000, 7.5, ""}},
+			shipping:  200000,
+			wantLines: []want{{75000, 1000000}},
+			wantTax:   75000,
+			wantTotal: 1275000,
+		},
+		{
+			// Shares of 33, 33 and 34 kobo leave bases of 999.67, 999.67 and 999.66
+			name:      "exclusive discount with a remainder",
+			lines:     []line{{100000, 7.5, ""}, {100000, 7.5, ""}, {100000, 7.5, ""}},
+			discount:  100,
+			wantLines: []want{{7498, 99967}, {7498, 99967}, {7497, 99966}},
+			wantTax:   22493,
+			wantTotal: 322393,
+		},
+		{
+			// Shares of 667 and 333 kobo
+			name:      "inclusive discount",
+			inclusive: true,
+			lines:     []line{{107500, 7.5, ""}, {53750, 7.5, ""}},
+			discount:  1000,
+			wantLines: []want{{7453, 99380}, {3727, 49690}},
+			wantTax:   11180,
+			wantTotal: 160250,
+		},
+		{
+			name:      "zero-rate and exempt lines",
+			lines:     []line{{100000, 7.5, ""}, {50000, 0, TaxExemptProduct}, {20000, 0, TaxExemptCustomer}},
+			wantLines: []want{{7500, 100000}, {0, 50000}, {0, 20000}},
+			wantTax:   7500,
+			wantTotal: 177500,
+		},
+		{
+			name:      "inclusive exempt line",
+			inclusive: true,
+			lines:     []line{{107500, 7.5, ""}, {50000, 0, TaxExemptCategory}},
+			wantLines: []want{{7500, 100000}, {0, 50000}},
+			wantTax:   7500,
+			wantTotal: 157500,
+		},
+		{
+			name:      "total clamped at zero",
+			lines:     []line{{50000, 0, ""}},
+			discount:  60000,
+			wantLines: []want{{0, -10000}},
+			wantTax:   0,
+			wantTotal: 0,
+		},
+	}
+	for _, tt := range tests {
+		order := &Order{
+			PricesIncludeTax: tt.inclusive,
+			Shipping:         Kobo(tt.shipping),
+			Discount:         Kobo(tt.discount),
+		}
+		for _, l := range tt.lines {
+			order.Items = append(order.Items, OrderItem{TotalPrice: Kobo(l.total), TaxRate: l.rate, TaxExemption: l.exemption})
+			order.Subtotal = order.Subtotal.Add(Kobo(l.total))
+		}
+
+		order.ApplyTax()
+
+		for i, w := range tt.wantLines {
+			item := order.Items[i]
+			if item.Tax.Minor() != w.tax || item.NetAmount.Minor() != w.net {
+				t.Errorf("%s: line %d tax %d, net %d; want %d, %d", tt.name, i, item.Tax.Minor(), item.NetAmount.Minor(), w.tax, w.net)
+			}
+		}
+		if order.Tax.Minor() != tt.wantTax || order.Total.Minor() != tt.wantTotal {
+			t.Errorf("%s: order tax %d, total %d; want %d, %d", tt.name, order.Tax.Minor(), order.Total.Minor(), tt.wantTax, tt.wantTotal)
+		}
+	}
+}
+
+func TestRemoveIncludedTax(t *testing.T) {
+	quoted := &PriceBreakdown{Subtotal: Kobo(860000), Total: Kobo(1075000)}
+	item := OrderItem{
+		Quantity:   100,
+		UnitPrice:  Kobo(10750),
+		TotalPrice: Kobo(1075000),
+		Snapshot:   &OrderItemSnapshot{Breakdown: quoted},
+	}
+
+	item.RemoveIncludedTax(7.5)
+
+	if item.TotalPrice.Minor() != 1000000 || item.UnitPrice.Minor() != 10000 {
+		t.Errorf("line = %s (%s each), want 10000.00 (100.00 each)", item.TotalPrice, item.UnitPrice)
+	}
+	breakdown := item.Snapshot.Breakdown
+	if breakdown.Total.Minor() != 1000000 || breakdown.Subtotal.Minor() != 800000 {
+		t.Errorf("breakdown = %s subtotal, %s total; want 8000.00, 10000.00", breakdown.Subtotal, breakdown.Total)
+	}
+	if quoted.Total.Minor() != 1075000 || quoted.Subtotal.Minor() != 860000 {
+		t.Error("the breakdown the line shared was changed")
+	}
+
+	// Breakdowns from before subtotals were kept, and lines without a snapshot
+	old := OrderItem{Quantity: 1, TotalPrice: Kobo(1075), Snapshot: &OrderItemSnapshot{Breakdown: &PriceBreakdown{Total: Kobo(1075)}}}
+	old.RemoveIncludedTax(7.5)
+	if old.Snapshot.Breakdown.Subtotal.Minor() != 0 || old.Snapshot.Breakdown.Total.Minor() != 1000 {
+		t.Errorf("old breakdown = %s subtotal, %s total; want 0.00, 10.00", old.Snapshot.Breakdown.Subtotal, old.Snapshot.Breakdown.Total)
+	}
+	bare := OrderItem{Quantity: 2, TotalPrice: Kobo(2150)}
+	bare.RemoveIncludedTax(7.5)
+	if bare.TotalPrice.Minor() != 2000 || bare.UnitPrice.Minor() != 1000 {
+		t.Errorf("line without a snapshot = %s (%s each), want 20.00 (10.00 each)", bare.TotalPrice, bare.UnitPrice)
+	}
+}
@@ -61,7 +61,7 @@ type UserProfile struct {
 	TwoFactorEnabled bool      `json:"twoFactorEnabled"`
 	CreatedAt        time.Time `json:"createdAt"`
 	TotalOrders      int       `json:"totalOrders,omitempty"`
-	TotalSpent       float64   `json:"totalSpent,omitempty"`
+	TotalSpent       Money     `json:"totalSpent"`
 }
 
 type RegisterRequest struct {
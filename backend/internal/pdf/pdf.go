@@ -0,0 +1,192 @@
+// Package pdf writes simple text documents as PDF: A4 pages of text and rules in
+// the standard Helvetica fonts, which every PDF reader has, so nothing is embedded.
+// Text is encoded as WinAnsi; other characters are replaced with "?".
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size and margins, in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+	Margin     = 50.0
+)
+
+// Character widths of Helvetica and Helvetica-Bold for ' ' to '~', in thousandths
+// of the font size
+var (
+	regularWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	boldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// Document is a PDF being written top to bottom. Text is drawn on the current
+// line, whose baseline moves down with Down; a new page starts when it would go
+// past the bottom margin.
+type Document struct {
+	pages []*bytes.Buffer
+	y     float64
+}
+
+// New starts a document with one empty page
+func New() *Document {
+	d := &Document{}
+	d.newPage()
+	return d
+}
+
+func (d *Document) newPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+	d.y = PageHeight - Margin
+}
+
+func (d *Document) page() *bytes.Buffer {
+	return d.pages[len(d.pages)-1]
+}
+
+// Down moves the current line down by height points
+func (d *Document) Down(height float64) {
+	d.y -= height
+	if d.y < Margin {
+		d.newPage()
+		d.y -= height
+	}
+}
+
+// Text draws text with its left edge at x on the current line
+func (d *Document) Text(x float64, text string, size float64, bold bool) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.page(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, d.y, escape(text))
+}
+
+// TextRight draws text with its right edge at x on the current line
+func (d *Document) TextRight(x float64, text string, size float64, bold bool) {
+	d.Text(x-Width(text, size, bold), text, size, bold)
+}
+
+// Rule draws a horizontal line from x1 to x2 just below the current line
+func (d *Document) Rule(x1, x2 float64) {
+	fmt.Fprintf(d.page(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, d.y-4, x2, d.y-4)
+}
+
+// Width returns the width of text in points
+func Width(text string, size float64, bold bool) float64 {
+	widths := &regularWidths
+	if bold {
+		widths = &boldWidths
+	}
+	var total int
+	for _, b := range encode(text) {
+		if b >= ' ' && b <= '~' {
+			total += widths[b-' ']
+		} else {
+			total += widths['?'-' ']
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap breaks text into lines no wider than width, breaking between words
+func Wrap(text string, size float64, bold bool, width float64) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		next := word
+		if line != "" {
+			next = line + " " + word
+		}
+		if line != "" && Width(next, size, bold) > width {
+			lines = append(lines, line)
+			next = word
+		}
+		line = next
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Bytes returns the finished PDF
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n")
+	// Objects 1 to 4 are the catalog, page tree and fonts; each page is then a
+	// page object followed by its content stream
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 6+2*i,
+		))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// encode converts text to WinAnsi, which matches Latin-1 for the characters used
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x100 && (r >= ' ' && r <= '~' || r >= 0xA0):
+			out = append(out, byte(r))
+		case r == '–' || r == '—':
+			out = append(out, '-')
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+func escape(text string) string {
+	var b strings.Builder
+	for _, c := range encode(text) {
+		if c == '(' || c == ')' || c == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -19,106 +19,357 @@ func NewCategoryRepository(db *pgxpool.Pool) *CategoryRepository {
 	return &CategoryRepository{db: db}
 }
 
+const categoryColumns = `c.id, c.name, c.slug, c.description, c.image, c.parent_id, c.display_order, c.tax_exempt,
+	c.created_at, c.updated_at`
+
+// categoryProductCount counts the published products in category c and all of
+// its subcategories
+const categoryProductCount = `(
+	WITH RECURSIVE subtree AS (
+		SELECT c.id
+		UNION
+		SELECT k.id FROM categories k JOIN subtree s ON k.parent_id = s.id
+	)
+	SELECT COUNT(*) FROM products p
+	WHERE p.category_id IN (SELECT id FROM subtree) AND p.status = 'published' AND p.deleted_at IS NULL
+)`
+
+// categorySubtree selects the IDs of the categories matching a condition, to be
+// filled in with fmt.Sprintf, and all of their subcategories
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE %s
+		UNION
+		SELECT k.id FROM categories k JOIN subtree s ON k.parent_id = s.id
+	)
+	SELECT id FROM subtree`
+
+func scanCategory(row pgx.Row) (*models.Category, error) {
+	var cat models.Category
+	err := row.Scan(
+		&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.Image, &cat.ParentID, &cat.DisplayOrder, &cat.TaxExempt,
+		&cat.CreatedAt, &cat.UpdatedAt, &cat.Depth, &cat.ProductCount,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cat, nil
+}
+
+func scanCategories(rows pgx.Rows) ([]models.Category, error) {
+	defer rows.Close()
+	var categories []models.Category
+	for rows.Next() {
+		cat, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, *cat)
+	}
+	return categories, rows.Err()
+}
+
+// Create adds a category after its siblings
 func (r *CategoryRepository) Create(ctx context.Context, category *models.Category) error {
 	query := `
-		INSERT INTO categories (id, name, slug, description, image, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO categories (id, name, slug, description, image, tax_exempt, parent_id, display_order, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7,
+			(SELECT COALESCE(MAX(display_order) + 1, 0) FROM categories WHERE parent_id IS NOT DISTINCT FROM $7),
+			$8, $9)
+		RETURNING display_order
 	`
 	category.ID = uuid.New()
 	category.CreatedAt = time.Now()
 	category.UpdatedAt = time.Now()
 
-	_, err := r.db.Exec(ctx, query,
+	return r.db.QueryRow(ctx, query,
 		category.ID, category.Name, category.Slug, category.Description,
-		category.Image, category.CreatedAt, category.UpdatedAt,
-	)
-	return err
+		category.Image, category.TaxExempt, category.ParentID, category.CreatedAt, category.UpdatedAt,
+	).Scan(&category.DisplayOrder)
 }
 
+// GetAll lists every category in tree order: each followed by its subcategories,
+// siblings in display order
 func (r *CategoryRepository) GetAll(ctx context.Context) ([]models.Category, error) {
 	query := `
-		SELECT c.id, c.name, c.slug, c.description, c.image, c.created_at, c.updated_at,
-			   COALESCE(COUNT(p.id), 0) as product_count
-		FROM categories c
-		LEFT JOIN products p ON p.category_id = c.id
-		GROUP BY c.id
-		ORDER BY c.name
+		WITH RECURSIVE tree AS (
+			SELECT id, 0 AS depth, ARRAY[(LPAD(display_order::text, 10, '0') || name)::text] AS sort_path
+			FROM categories
+			WHERE parent_id IS NULL
+			UNION ALL
+			SELECT k.id, t.depth + 1, t.sort_path || (LPAD(k.display_order::text, 10, '0') || k.name)::text
+			FROM categories k
+			JOIN tree t ON k.parent_id = t.id
+		)
+		SELECT ` + categoryColumns + `, t.depth, ` + categoryProductCount + `
+		FROM tree t
+		JOIN categories c ON c.id = t.id
+		ORDER BY t.sort_path
 	`
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanCategories(rows)
+}
 
-	var categories []models.Category
-	for rows.Next() {
-		var cat models.Category
-		if err := rows.Scan(
-			&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.Image,
-			&cat.CreatedAt, &cat.UpdatedAt, &cat.ProductCount,
-		); err != nil {
-			return nil, err
-		}
-		categories = append(categories, cat)
+// GetByID returns a category with its breadcrumb path, or nil if there is none
+func (r *CategoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + `, 0, ` + categoryProductCount + ` FROM categories c WHERE c.id = $1`
+	cat, err := scanCategory(r.db.QueryRow(ctx, query, id))
+	if err != nil || cat == nil {
+		return nil, err
+	}
+	if err := r.loadPath(ctx, cat); err != nil {
+		return nil, err
 	}
-	return categories, nil
+	return cat, nil
 }
 
-func (r *CategoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Category, error) {
-	query := `
-		SELECT c.id, c.name, c.slug, c.description, c.image, c.created_at, c.updated_at,
-			   COALESCE(COUNT(p.id), 0) as product_count
+// GetBySlug returns a category with its breadcrumb path and subcategories, or
+// nil if there is none
+func (r *CategoryRepository) GetBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + `, 0, ` + categoryProductCount + ` FROM categories c WHERE c.slug = $1`
+	cat, err := scanCategory(r.db.QueryRow(ctx, query, slug))
+	if err != nil || cat == nil {
+		return nil, err
+	}
+	if err := r.loadPath(ctx, cat); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT `+categoryColumns+`, $2::int, `+categoryProductCount+`
 		FROM categories c
-		LEFT JOIN products p ON p.category_id = c.id
-		WHERE c.id = $1
-		GROUP BY c.id
-	`
-	var cat models.Category
-	err := r.db.QueryRow(ctx, query, id).Scan(
-		&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.Image,
-		&cat.CreatedAt, &cat.UpdatedAt, &cat.ProductCount,
+		WHERE c.parent_id = $1
+		ORDER BY c.display_order, c.name`,
+		cat.ID, cat.Depth+1,
 	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
+	if err != nil {
+		return nil, err
+	}
+	if cat.Children, err = scanCategories(rows); err != nil {
+		return nil, err
 	}
-	return &cat, err
+	return cat, nil
 }
 
-func (r *CategoryRepository) GetBySlug(ctx context.Context, slug string) (*models.Category, error) {
-	query := `
-		SELECT c.id, c.name, c.slug, c.description, c.image, c.created_at, c.updated_at,
-			   COALESCE(COUNT(p.id), 0) as product_count
-		FROM categories c
-		LEFT JOIN products p ON p.category_id = c.id
-		WHERE c.slug = $1
-		GROUP BY c.id
-	`
-	var cat models.Category
-	err := r.db.QueryRow(ctx, query, slug).Scan(
-		&cat.ID, &cat.Name, &cat.Slug, &cat.Description, &cat.Image,
-		&cat.CreatedAt, &cat.UpdatedAt, &cat.ProductCount,
+// loadPath sets a category's breadcrumb path, from the top level down, and its depth
+func (r *CategoryRepository) loadPath(ctx context.Context, cat *models.Category) error {
+	rows, err := r.db.Query(ctx, `
+		WITH RECURSIVE path AS (
+			SELECT id, name, slug, parent_id, 0 AS level FROM categories WHERE id = $1
+			UNION ALL
+			SELECT k.id, k.name, k.slug, k.parent_id, p.level + 1
+			FROM categories k
+			JOIN path p ON k.id = p.parent_id
+		)
+		SELECT id, name, slug FROM path ORDER BY level DESC`,
+		cat.ID,
 	)
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cat.Path = nil
+	for rows.Next() {
+		var crumb models.CategoryCrumb
+		if err := rows.Scan(&crumb.ID, &crumb.Name, &crumb.Slug); err != nil {
+			return err
+		}
+		cat.Path = append(cat.Path, crumb)
 	}
-	return &cat, err
+	cat.Depth = len(cat.Path) - 1
+	return rows.Err()
 }
 
+// Update saves a category. Moving it to another parent puts it after its new
+// siblings, and fails with ErrCategoryCycle if the parent is the category itself
+// or one of its subcategories.
 func (r *CategoryRepository) Update(ctx context.Context, category *models.Category) error {
-	query := `
-		UPDATE categories SET name = $2, slug = $3, description = $4, image = $5, updated_at = $6
-		WHERE id = $1
-	`
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var parentID *uuid.UUID
+	if err := tx.QueryRow(ctx, `SELECT parent_id FROM categories WHERE id = $1`, category.ID).Scan(&parentID); err != nil {
+		return err
+	}
+	if !sameCategory(parentID, category.ParentID) {
+		if err := lockCategories(ctx, tx); err != nil {
+			return err
+		}
+		if err := checkCategoryCycle(ctx, tx, category.ID, category.ParentID); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(ctx, `
+			SELECT COALESCE(MAX(display_order) + 1, 0) FROM categories WHERE parent_id IS NOT DISTINCT FROM $1`,
+			category.ParentID,
+		).Scan(&category.DisplayOrder); err != nil {
+			return err
+		}
+	}
+
 	category.UpdatedAt = time.Now()
-	_, err := r.db.Exec(ctx, query,
+	if _, err := tx.Exec(ctx, `
+		UPDATE categories SET name = $2, slug = $3, description = $4, image = $5, tax_exempt = $6,
+			parent_id = $7, display_order = $8, updated_at = $9
+		WHERE id = $1`,
 		category.ID, category.Name, category.Slug, category.Description,
-		category.Image, category.UpdatedAt,
-	)
-	return err
+		category.Image, category.TaxExempt, category.ParentID, category.DisplayOrder, category.UpdatedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Reorder puts the categories under parentID, or at the top level if it is nil,
+// in the order given. The parent's other subcategories follow them, in the order
+// they were in. It returns false, changing nothing, if any category does not
+// exist, and ErrCategoryCycle if one would be put under itself.
+func (r *CategoryRepository) Reorder(ctx context.Context, parentID *uuid.UUID, ids []uuid.UUID) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCategories(ctx, tx); err != nil {
+		return false, err
+	}
+	for i, id := range ids {
+		if err := checkCategoryCycle(ctx, tx, id, parentID); err != nil {
+			return false, err
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE categories SET parent_id = $2, display_order = $3, updated_at = NOW() WHERE id = $1`,
+			id, parentID, i,
+		)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE categories c SET display_order = o.position, updated_at = NOW()
+		FROM (
+			SELECT id, $3 + ROW_NUMBER() OVER (ORDER BY display_order, name) - 1 AS position
+			FROM categories
+			WHERE parent_id IS NOT DISTINCT FROM $1 AND id <> ALL($2)
+		) o
+		WHERE c.id = o.id`,
+		parentID, ids, len(ids),
+	); err != nil {
+		return false, err
+	}
+	return true, tx.Commit(ctx)
 }
 
-func (r *CategoryRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `DELETE FROM categories WHERE id = $1`, id)
+// Delete removes a category. One with subcategories or products, including
+// deleted products, fails with a CategoryInUseError unless reassignTo is given,
+// in which case they are moved there first. It returns false if there is no
+// such category.
+func (r *CategoryRepository) Delete(ctx context.Context, id uuid.UUID, reassignTo *uuid.UUID) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockCategories(ctx, tx); err != nil {
+		return false, err
+	}
+	var exists bool
+	inUse := &models.CategoryInUseError{}
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1),
+		       (SELECT COUNT(*) FROM categories WHERE parent_id = $1),
+		       (SELECT COUNT(*) FROM products WHERE category_id = $1)`,
+		id,
+	).Scan(&exists, &inUse.SubcategoryCount, &inUse.ProductCount); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+
+	if inUse.SubcategoryCount > 0 || inUse.ProductCount > 0 {
+		if reassignTo == nil {
+			return true, inUse
+		}
+		// Subcategories cannot be moved under the category being deleted, or
+		// anything below it
+		if err := checkCategoryCycle(ctx, tx, id, reassignTo); err != nil {
+			return true, err
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE categories c SET parent_id = $2, display_order = o.position, updated_at = NOW()
+			FROM (
+				SELECT id, (SELECT COALESCE(MAX(display_order) + 1, 0) FROM categories WHERE parent_id = $2)
+				           + ROW_NUMBER() OVER (ORDER BY display_order, name) - 1 AS position
+				FROM categories
+				WHERE parent_id = $1
+			) o
+			WHERE c.id = o.id`,
+			id, *reassignTo,
+		); err != nil {
+			return true, err
+		}
+		if _, err := tx.Exec(ctx, `UPDATE products SET category_id = $2, updated_at = NOW() WHERE category_id = $1`, id, *reassignTo); err != nil {
+			return true, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM categories WHERE id = $1`, id); err != nil {
+		return true, err
+	}
+	return true, tx.Commit(ctx)
+}
+
+// lockCategories stops the tree changing under a transaction that moves
+// categories, so two moves cannot make a cycle between them
+func lockCategories(ctx context.Context, tx pgx.Tx) error {
+	_, err := tx.Exec(ctx, `LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`)
 	return err
 }
 
+// checkCategoryCycle returns ErrCategoryCycle if parentID is the category id or
+// one of its subcategories
+func checkCategoryCycle(ctx context.Context, tx pgx.Tx, id uuid.UUID, parentID *uuid.UUID) error {
+	if parentID == nil {
+		return nil
+	}
+	var cycle bool
+	if err := tx.QueryRow(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT k.id, k.parent_id FROM categories k JOIN ancestors a ON k.id = a.parent_id
+		)
+		SELECT EXISTS(SELECT 1 FROM ancestors WHERE id = $2)`,
+		*parentID, id,
+	).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return models.ErrCategoryCycle
+	}
+	return nil
+}
+
+func sameCategory(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
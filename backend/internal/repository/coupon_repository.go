@@ -2,10 +2,12 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/quikprint/backend/internal/models"
 )
@@ -157,3 +159,84 @@ func (r *CouponRepository) RecordUsage(ctx context.Context, usage *models.Coupon
 	)
 	return err
 }
+
+// redeemCoupon locks the coupon row, re-validates it against the order subtotal and
+// the user's usage history, and returns the coupon with the discount it grants.
+// It must run inside the order transaction: the row lock serialises concurrent
+// redemptions of the same coupon until the transaction ends.
+func redeemCoupon(ctx context.Context, tx pgx.Tx, code string, userID uuid.UUID, subtotal models.Money) (*models.Coupon, models.Money, error) {
+	query := `
+		SELECT id, code, description, discount_type, discount_value, min_order_amount, 
+			max_discount_amount, usage_limit, used_count, per_user_limit, valid_from, valid_until, 
+			is_active, created_at, updated_at
+		FROM coupons WHERE UPPER(code) = UPPER($1)
+		FOR UPDATE
+	`
+	var coupon models.Coupon
+	err := tx.QueryRow(ctx, query, strings.TrimSpace(code)).Scan(
+		&coupon.ID, &coupon.Code, &coupon.Description, &coupon.DiscountType,
+		&coupon.DiscountValue, &coupon.MinOrderAmount, &coupon.MaxDiscountAmount,
+		&coupon.UsageLimit, &coupon.UsedCount, &coupon.PerUserLimit, &coupon.ValidFrom,
+		&coupon.ValidUntil, &coupon.IsActive, &coupon.CreatedAt, &coupon.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, models.Money{}, models.ErrCouponNotFound
+	}
+	if err != nil {
+		return nil, models.Money{}, err
+	}
+
+	if err := coupon.CheckValidity(time.Now(), subtotal); err != nil {
+		return nil, models.Money{}, err
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var usageCount int
+		err := tx.QueryRow(ctx,
+			`SELECT COUNT(*) FROM coupon_usage WHERE coupon_id = $1 AND user_id = $2`,
+			coupon.ID, userID,
+		).Scan(&usageCount)
+		if err != nil {
+			return nil, models.Money{}, err
+		}
+		if usageCount >= coupon.PerUserLimit {
+			return nil, models.Money{}, models.ErrCouponPerUserLimit
+		}
+	}
+
+	discount := coupon.DiscountFor(subtotal)
+	if !discount.IsPositive() {
+		return nil, models.Money{}, models.ErrCouponNoDiscount
+	}
+	return &coupon, discount, nil
+}
+
+// releaseCouponRedemption undoes an order's coupon redemption in the caller's
+// transaction, so a coupon reserved by an order that was never paid can be used again.
+func releaseCouponRedemption(ctx context.Context, tx pgx.Tx, orderID uuid.UUID) error {
+	_, err := tx.Exec(ctx, `
+		WITH released AS (
+			DELETE FROM coupon_usage WHERE order_id = $1 RETURNING coupon_id
+		)
+		UPDATE coupons SET used_count = GREATEST(used_count - 1, 0), updated_at = $2
+		WHERE id IN (SELECT coupon_id FROM released)
+	`, orderID, time.Now())
+	return err
+}
+
+// recordCouponRedemption stores the usage row for an order and bumps the coupon's
+// used_count in the caller's transaction.
+func recordCouponRedemption(ctx context.Context, tx pgx.Tx, usage *models.CouponUsage) error {
+	usage.ID = uuid.New()
+	usage.UsedAt = time.Now()
+	_, err := tx.Exec(ctx, `
+		INSERT INTO coupon_usage (id, coupon_id, user_id, order_id, discount_amount, used_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, usage.ID, usage.CouponID, usage.UserID, usage.OrderID, usage.DiscountAmount, usage.UsedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE coupons SET used_count = used_count + 1, updated_at = $2 WHERE id = $1`, usage.CouponID, usage.UsedAt)
+	return err
+}
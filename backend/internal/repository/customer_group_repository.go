@@ -0,0 +1,358 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type CustomerGroupRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCustomerGroupRepository(db *pgxpool.Pool) *CustomerGroupRepository {
+	return &CustomerGroupRepository{db: db}
+}
+
+const customerGroupSelect = `
+	SELECT g.id, g.name, COALESCE(g.description, ''),
+	       (SELECT COUNT(*) FROM customer_group_members m WHERE m.group_id = g.id),
+	       g.created_at, g.updated_at
+	FROM customer_groups g`
+
+func (r *CustomerGroupRepository) GetAll(ctx context.Context) ([]models.CustomerGroup, error) {
+	rows, err := r.db.Query(ctx, customerGroupSelect+` ORDER BY g.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.CustomerGroup
+	for rows.Next() {
+		var g models.CustomerGroup
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.MemberCount, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GetByID returns a group, or nil if there is none
+func (r *CustomerGroupRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.CustomerGroup, error) {
+	var g models.CustomerGroup
+	err := r.db.QueryRow(ctx, customerGroupSelect+` WHERE g.id = $1`, id).
+		Scan(&g.ID, &g.Name, &g.Description, &g.MemberCount, &g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// GetByName returns the group with a name, or nil if there is none
+func (r *CustomerGroupRepository) GetByName(ctx context.Context, name string) (*models.CustomerGroup, error) {
+	var g models.CustomerGroup
+	err := r.db.QueryRow(ctx, customerGroupSelect+` WHERE LOWER(g.name) = LOWER($1)`, name).
+		Scan(&g.ID, &g.Name, &g.Description, &g.MemberCount, &g.CreatedAt, &g.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (r *CustomerGroupRepository) Create(ctx context.Context, g *models.CustomerGroup) error {
+	g.ID = uuid.New()
+	g.CreatedAt = time.Now()
+	g.UpdatedAt = g.CreatedAt
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customer_groups (id, name, description, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $4)`,
+		g.ID, g.Name, g.Description, g.CreatedAt,
+	)
+	return err
+}
+
+// Update renames a group. It returns false if there is no such group.
+func (r *CustomerGroupRepository) Update(ctx context.Context, g *models.CustomerGroup) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE customer_groups SET name = $2, description = NULLIF($3, ''), updated_at = NOW()
+		WHERE id = $1`,
+		g.ID, g.Name, g.Description,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// Delete deletes a group with its members and price list. It returns false if
+// there is no such group.
+func (r *CustomerGroupRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_groups WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *CustomerGroupRepository) GetMembers(ctx context.Context, groupID uuid.UUID) ([]models.CustomerGroupMember, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT u.id, u.email, u.first_name, u.last_name, m.added_by, m.created_at
+		FROM customer_group_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.group_id = $1
+		ORDER BY u.last_name, u.first_name`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []models.CustomerGroupMember
+	for rows.Next() {
+		var m models.CustomerGroupMember
+		if err := rows.Scan(&m.UserID, &m.Email, &m.FirstName, &m.LastName, &m.AddedBy, &m.AddedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// AddMember puts a customer in a group, taking them out of any other group
+func (r *CustomerGroupRepository) AddMember(ctx context.Context, groupID, userID, addedBy uuid.UUID) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customer_group_members (user_id, group_id, added_by, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET group_id = EXCLUDED.group_id, added_by = EXCLUDED.added_by, created_at = NOW()`,
+		userID, groupID, addedBy,
+	)
+	return err
+}
+
+// RemoveMember takes a customer out of a group. It returns false if they were
+// not in it.
+func (r *CustomerGroupRepository) RemoveMember(ctx context.Context, groupID, userID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_group_members WHERE group_id = $1 AND user_id = $2`, groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetPriceList returns a group's category discounts, fixed prices and tiers
+func (r *CustomerGroupRepository) GetPriceList(ctx context.Context, groupID uuid.UUID) (*models.CustomerGroupPriceList, error) {
+	list := &models.CustomerGroupPriceList{
+		CategoryDiscounts: []models.GroupCategoryDiscount{},
+		ProductPrices:     []models.GroupProductPrice{},
+		PricingTiers:      []models.PricingTier{},
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT d.category_id, c.name, d.percent
+		FROM customer_group_category_discounts d
+		JOIN categories c ON c.id = d.category_id
+		WHERE d.group_id = $1
+		ORDER BY c.name`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d models.GroupCategoryDiscount
+		if err := rows.Scan(&d.CategoryID, &d.CategoryName, &d.Percent); err != nil {
+			return nil, err
+		}
+		list.CategoryDiscounts = append(list.CategoryDiscounts, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT pp.product_id, p.name, pp.price
+		FROM customer_group_product_prices pp
+		JOIN products p ON p.id = pp.product_id
+		WHERE pp.group_id = $1
+		ORDER BY p.name`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p models.GroupProductPrice
+		if err := rows.Scan(&p.ProductID, &p.ProductName, &p.Price); err != nil {
+			return nil, err
+		}
+		list.ProductPrices = append(list.ProductPrices, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT id, product_id, min_qty, max_qty, price
+		FROM customer_group_pricing_tiers
+		WHERE group_id = $1
+		ORDER BY product_id, min_qty`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.PricingTier
+		if err := rows.Scan(&t.ID, &t.ProductID, &t.MinQty, &t.MaxQty, &t.Price); err != nil {
+			return nil, err
+		}
+		list.PricingTiers = append(list.PricingTiers, t)
+	}
+	return list, rows.Err()
+}
+
+// SetCategoryDiscount creates or replaces a group's discount on a category
+func (r *CustomerGroupRepository) SetCategoryDiscount(ctx context.Context, groupID, categoryID uuid.UUID, percent float64) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customer_group_category_discounts (group_id, category_id, percent)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (group_id, category_id) DO UPDATE SET percent = EXCLUDED.percent`,
+		groupID, categoryID, percent,
+	)
+	return err
+}
+
+// DeleteCategoryDiscount returns false if the group had no discount on the category
+func (r *CustomerGroupRepository) DeleteCategoryDiscount(ctx context.Context, groupID, categoryID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_group_category_discounts WHERE group_id = $1 AND category_id = $2`, groupID, categoryID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetProductPrice creates or replaces a group's fixed price for a product
+func (r *CustomerGroupRepository) SetProductPrice(ctx context.Context, groupID, productID uuid.UUID, price models.Money) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO customer_group_product_prices (group_id, product_id, price)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (group_id, product_id) DO UPDATE SET price = EXCLUDED.price`,
+		groupID, productID, price,
+	)
+	return err
+}
+
+// DeleteProductPrice returns false if the group had no fixed price for the product
+func (r *CustomerGroupRepository) DeleteProductPrice(ctx context.Context, groupID, productID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_group_product_prices WHERE group_id = $1 AND product_id = $2`, groupID, productID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetPricingTiers replaces a group's quantity tiers for a product
+func (r *CustomerGroupRepository) SetPricingTiers(ctx context.Context, groupID, productID uuid.UUID, tiers []models.PricingTier) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM customer_group_pricing_tiers WHERE group_id = $1 AND product_id = $2`, groupID, productID); err != nil {
+		return err
+	}
+	for i := range tiers {
+		tiers[i].ID = uuid.New()
+		tiers[i].ProductID = productID
+		_, err := tx.Exec(ctx, `
+			INSERT INTO customer_group_pricing_tiers (id, group_id, product_id, min_qty, max_qty, price)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			tiers[i].ID, groupID, productID, tiers[i].MinQty, tiers[i].MaxQty, tiers[i].Price,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// DeletePricingTiers returns false if the group had no tiers for the product
+func (r *CustomerGroupRepository) DeletePricingTiers(ctx context.Context, groupID, productID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_group_pricing_tiers WHERE group_id = $1 AND product_id = $2`, groupID, productID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetPricing returns how a customer's group is priced for a product in a
+// category, or nil if the customer is not in a group. The category discount is
+// the group's discount on the category, or failing that on the nearest category
+// above it that has one.
+func (r *CustomerGroupRepository) GetPricing(ctx context.Context, userID, productID, categoryID uuid.UUID) (*models.GroupPricing, error) {
+	var p models.GroupPricing
+	err := r.db.QueryRow(ctx, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id, 0 AS distance FROM categories WHERE id = $3
+			UNION ALL
+			SELECT k.id, k.parent_id, a.distance + 1 FROM categories k JOIN ancestors a ON k.id = a.parent_id
+		)
+		SELECT g.id, g.name,
+		       (SELECT price FROM customer_group_product_prices WHERE group_id = g.id AND product_id = $2),
+		       COALESCE((
+		           SELECT d.percent
+		           FROM ancestors a
+		           JOIN customer_group_category_discounts d ON d.category_id = a.id
+		           WHERE d.group_id = g.id
+		           ORDER BY a.distance
+		           LIMIT 1
+		       ), 0)
+		FROM customer_group_members m
+		JOIN customer_groups g ON g.id = m.group_id
+		WHERE m.user_id = $1`,
+		userID, productID, categoryID,
+	).Scan(&p.GroupID, &p.GroupName, &p.Price, &p.CategoryDiscount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT id, product_id, min_qty, max_qty, price
+		FROM customer_group_pricing_tiers
+		WHERE group_id = $1 AND product_id = $2
+		ORDER BY min_qty`,
+		p.GroupID, productID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t models.PricingTier
+		if err := rows.Scan(&t.ID, &t.ProductID, &t.MinQty, &t.MaxQty, &t.Price); err != nil {
+			return nil, err
+		}
+		p.Tiers = append(p.Tiers, t)
+	}
+	return &p, rows.Err()
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type EmailOutboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEmailOutboxRepository(db *pgxpool.Pool) *EmailOutboxRepository {
+	return &EmailOutboxRepository{db: db}
+}
+
+const outboxColumns = `id, broadcast_id, recipient, subject, html_body, smtp_account, status, attempts,
+	max_attempts, next_attempt_at, last_error, sent_at, created_at`
+
+func scanOutboxMessage(row pgx.Row) (*models.EmailOutboxMessage, error) {
+	var m models.EmailOutboxMessage
+	err := row.Scan(
+		&m.ID, &m.BroadcastID, &m.Recipient, &m.Subject, &m.HTMLBody, &m.SMTPAccount, &m.Status, &m.Attempts,
+		&m.MaxAttempts, &m.NextAttemptAt, &m.LastError, &m.SentAt, &m.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *EmailOutboxRepository) Enqueue(ctx context.Context, msg *models.EmailOutboxMessage) error {
+	query := `
+		INSERT INTO email_outbox (id, recipient, subject, html_body, smtp_account, status, max_attempts, next_attempt_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
+	`
+	msg.ID = uuid.New()
+	msg.Status = models.EmailOutboxPending
+	msg.CreatedAt = time.Now()
+	msg.NextAttemptAt = msg.CreatedAt
+
+	_, err := r.db.Exec(ctx, query,
+		msg.ID, msg.Recipient, msg.Subject, msg.HTMLBody, msg.SMTPAccount, msg.Status, msg.MaxAttempts,
+		msg.NextAttemptAt, msg.CreatedAt,
+	)
+	return err
+}
+
+// EnqueueBroadcast records the broadcast and queues one message per recipient in a
+// single transaction, so a broadcast is either fully queued or not at all
+func (r *EmailOutboxRepository) EnqueueBroadcast(ctx context.Context, broadcast *models.EmailBroadcast, recipients []string, htmlBody, smtpAccount string, maxAttempts int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	broadcast.ID = uuid.New()
+	broadcast.TotalRecipients = len(recipients)
+	broadcast.CreatedAt = time.Now()
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO email_broadcasts (id, subject, content, total_recipients, created_by, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		broadcast.ID, broadcast.Subject, broadcast.Content, broadcast.TotalRecipients, broadcast.CreatedBy, broadcast.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, 0, len(recipients))
+	for _, to := range recipients {
+		rows = append(rows, []interface{}{
+			uuid.New(), broadcast.ID, to, broadcast.Subject, htmlBody, smtpAccount,
+			string(models.EmailOutboxPending), maxAttempts, broadcast.CreatedAt, broadcast.CreatedAt, broadcast.CreatedAt,
+		})
+	}
+
+	_, err = tx.CopyFrom(ctx,
+		pgx.Identifier{"email_outbox"},
+		[]string{"id", "broadcast_id", "recipient", "subject", "html_body", "smtp_account", "status", "max_attempts", "next_attempt_at", "created_at", "updated_at"},
+		pgx.CopyFromRows(rows),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ClaimDue locks up to limit messages that are due for delivery and marks them as
+// sending. SKIP LOCKED lets several workers (and API instances) drain the queue
+// without handing out the same message twice.
+func (r *EmailOutboxRepository) ClaimDue(ctx context.Context, limit int) ([]models.EmailOutboxMessage, error) {
+	query := `
+		UPDATE email_outbox SET status = 'sending', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []models.EmailOutboxMessage
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, rows.Err()
+}
+
+func (r *EmailOutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE email_outbox SET status = 'sent', sent_at = NOW(), last_error = NULL, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// MarkFailed records a failed attempt. The message is retried at nextAttempt, or
+// dead-lettered when nextAttempt is nil.
+func (r *EmailOutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, nextAttempt *time.Time) error {
+	if nextAttempt == nil {
+		query := `
+			UPDATE email_outbox SET status = 'dead', last_error = $2, locked_at = NULL, updated_at = NOW()
+			WHERE id = $1
+		`
+		_, err := r.db.Exec(ctx, query, id, errMsg)
+		return err
+	}
+
+	query := `
+		UPDATE email_outbox SET status = 'pending', last_error = $2, next_attempt_at = $3, locked_at = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.Exec(ctx, query, id, errMsg, *nextAttempt)
+	return err
+}
+
+// Release returns a claimed message to the queue without counting the attempt
+func (r *EmailOutboxRepository) Release(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE email_outbox SET status = 'pending', attempts = GREATEST(attempts - 1, 0), locked_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = 'sending'
+	`
+	_, err := r.db.Exec(ctx, query, id)
+	return err
+}
+
+// Touch renews a claim just before sending, so a message that waited on the rate
+// limiter isn't taken for stale. It returns false if the claim was released in the
+// meantime; attempts identifies the claim, since claiming again increments it.
+func (r *EmailOutboxRepository) Touch(ctx context.Context, id uuid.UUID, attempts int) (bool, error) {
+	query := `
+		UPDATE email_outbox SET locked_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'sending' AND attempts = $2
+	`
+	tag, err := r.db.Exec(ctx, query, id, attempts)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseStale puts messages claimed by a worker that died mid-send back in the queue
+func (r *EmailOutboxRepository) ReleaseStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+		UPDATE email_outbox SET status = 'pending', locked_at = NULL, updated_at = NOW()
+		WHERE status = 'sending' AND locked_at < $1
+	`
+	tag, err := r.db.Exec(ctx, query, time.Now().Add(-olderThan))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *EmailOutboxRepository) GetBroadcasts(ctx context.Context, limit int) ([]models.EmailBroadcast, error) {
+	query := `
+		SELECT id, subject, total_recipients, created_by, created_at
+		FROM email_broadcasts ORDER BY created_at DESC LIMIT $1
+	`
+	rows, err := r.db.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var broadcasts []models.EmailBroadcast
+	for rows.Next() {
+		var b models.EmailBroadcast
+		if err := rows.Scan(&b.ID, &b.Subject, &b.TotalRecipients, &b.CreatedBy, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, nil
+}
+
+// GetBroadcastProgress returns delivery counts for a broadcast along with its
+// dead-lettered messages
+func (r *EmailOutboxRepository) GetBroadcastProgress(ctx context.Context, id uuid.UUID) (*models.EmailBroadcastProgress, error) {
+	var p models.EmailBroadcastProgress
+	err := r.db.QueryRow(ctx,
+		`SELECT id, subject, content, total_recipients, created_by, created_at FROM email_broadcasts WHERE id = $1`, id,
+	).Scan(&p.ID, &p.Subject, &p.Content, &p.TotalRecipients, &p.CreatedBy, &p.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.db.QueryRow(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'pending'),
+			COUNT(*) FILTER (WHERE status = 'sending'),
+			COUNT(*) FILTER (WHERE status = 'sent'),
+			COUNT(*) FILTER (WHERE status = 'dead')
+		FROM email_outbox WHERE broadcast_id = $1
+	`, id).Scan(&p.Pending, &p.Sending, &p.Sent, &p.Failed)
+	if err != nil {
+		return nil, err
+	}
+	p.Complete = p.Pending == 0 && p.Sending == 0
+
+	rows, err := r.db.Query(ctx,
+		`SELECT `+outboxColumns+` FROM email_outbox WHERE broadcast_id = $1 AND status = 'dead' ORDER BY recipient`, id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	p.Failures = []models.EmailOutboxMessage{}
+	for rows.Next() {
+		m, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		p.Failures = append(p.Failures, *m)
+	}
+	return &p, rows.Err()
+}
+
+// RequeueBroadcastFailures gives every dead-lettered message of a broadcast a fresh
+// set of attempts and returns how many were requeued
+func (r *EmailOutboxRepository) RequeueBroadcastFailures(ctx context.Context, broadcastID uuid.UUID) (int64, error) {
+	query := `
+		UPDATE email_outbox SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+		WHERE broadcast_id = $1 AND status = 'dead'
+	`
+	tag, err := r.db.Exec(ctx, query, broadcastID)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/migrate"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/migrations"
+)
+
+// testDB connects to TEST_DATABASE_URL, migrates it and empties the outbox. The
+// tests are skipped without it; point it at a throwaway database, as the tables
+// are truncated.
+func testDB(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	loaded, err := migrate.Load(migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := migrate.New(db, loaded).Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if _, err := db.Exec(ctx, `TRUNCATE email_outbox, email_broadcasts`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	return db
+}
+
+func enqueueN(t *testing.T, repo *EmailOutboxRepository, n int) []uuid.UUID {
+	t.Helper()
+	var ids []uuid.UUID
+	for i := 0; i < n; i++ {
+		msg := &models.EmailOutboxMessage{
+			Recipient:   "customer@example.com",
+			Subject:     "Hello",
+			HTMLBody:    "<p>Hi</p>",
+			SMTPAccount: "shop",
+			MaxAttempts: 3,
+		}
+		if err := repo.Enqueue(context.Background(), msg); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		ids = append(ids, msg.ID)
+	}
+	return ids
+}
+
+func TestClaimDueSkipsLockedMessages(t *testing.T) {
+	db := testDB(t)
+	repo := NewEmailOutboxRepository(db)
+	ctx := context.Background()
+	ids := enqueueN(t, repo, 5)
+
+	// Another worker is part way through claiming two of them
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `SELECT id FROM email_outbox WHERE id = ANY($1) FOR UPDATE`, ids[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	claimCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	claimed, err := repo.ClaimDue(claimCtx, 10)
+	if err != nil {
+		t.Fatalf("ClaimDue blocked on locked rows: %v", err)
+	}
+	if len(claimed) != 3 {
+		t.Fatalf("claimed %d messages, want the 3 unlocked ones", len(claimed))
+	}
+	for _, m := range claimed {
+		if m.ID == ids[0] || m.ID == ids[1] {
+			t.Errorf("claimed locked message %s", m.ID)
+		}
+		if m.Status != models.EmailOutboxSending || m.Attempts != 1 {
+			t.Errorf("claimed message = %s/%d, want sending/1", m.Status, m.Attempts)
+		}
+	}
+
+	tx.Rollback(ctx)
+	claimed, err = repo.ClaimDue(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 2 {
+		t.Errorf("claimed %d messages once unlocked, want 2", len(claimed))
+	}
+}
+
+func TestClaimDueHandsOutEachMessageOnce(t *testing.T) {
+	db := testDB(t)
+	repo := NewEmailOutboxRepository(db)
+	ctx := context.Background()
+	enqueueN(t, repo, 40)
+
+	var mu sync.Mutex
+	seen := map[uuid.UUID]int{}
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				claimed, err := repo.ClaimDue(ctx, 3)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(claimed) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, m := range claimed {
+					seen[m.ID]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 40 {
+		t.Errorf("claimed %d distinct messages, want 40", len(seen))
+	}
+	for id, n := range seen {
+		if n != 1 {
+			t.Errorf("message %s claimed %d times", id, n)
+		}
+	}
+}
+
+func TestClaimDueWaitsForNextAttempt(t *testing.T) {
+	db := testDB(t)
+	repo := NewEmailOutboxRepository(db)
+	ctx := context.Background()
+	ids := enqueueN(t, repo, 1)
+
+	claimed, _ := repo.ClaimDue(ctx, 1)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d messages, want 1", len(claimed))
+	}
+	later := time.Now().Add(time.Hour)
+	if err := repo.MarkFailed(ctx, ids[0], "550 mailbox unavailable", &later); err != nil {
+		t.Fatal(err)
+	}
+	if claimed, _ := repo.ClaimDue(ctx, 1); len(claimed) != 0 {
+		t.Errorf("claimed a message before its next attempt")
+	}
+}
+
+func TestTouchFailsAfterClaimIsReleased(t *testing.T) {
+	db := testDB(t)
+	repo := NewEmailOutboxRepository(db)
+	ctx := context.Background()
+	enqueueN(t, repo, 1)
+
+	claimed, _ := repo.ClaimDue(ctx, 1)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d messages, want 1", len(claimed))
+	}
+	msg := claimed[0]
+	if held, err := repo.Touch(ctx, msg.ID, msg.Attempts); err != nil || !held {
+		t.Fatalf("Touch = %v, %v; want the claim held", held, err)
+	}
+
+	if n, err := repo.ReleaseStale(ctx, -time.Minute); err != nil || n != 1 {
+		t.Fatalf("ReleaseStale = %d, %v; want 1 released", n, err)
+	}
+	if held, _ := repo.Touch(ctx, msg.ID, msg.Attempts); held {
+		t.Error("Touch held a released claim")
+	}
+
+	// Claimed again by someone else
+	repo.ClaimDue(ctx, 1)
+	if held, _ := repo.Touch(ctx, msg.ID, msg.Attempts); held {
+		t.Error("Touch held a claim that was taken over")
+	}
+}
+
+func TestRequeueBroadcastFailures(t *testing.T) {
+	db := testDB(t)
+	repo := NewEmailOutboxRepository(db)
+	ctx := context.Background()
+
+	broadcast := &models.EmailBroadcast{Subject: "Sale", Content: "Everything half price"}
+	recipients := []string{"a@example.com", "b@example.com", "c@example.com"}
+	if err := repo.EnqueueBroadcast(ctx, broadcast, recipients, "<p>Sale</p>", "shop", 2); err != nil {
+		t.Fatal(err)
+	}
+	// A dead message outside the broadcast is left alone
+	other := enqueueN(t, repo, 1)[0]
+
+	claimed, err := repo.ClaimDue(ctx, 10)
+	if err != nil || len(claimed) != 4 {
+		t.Fatalf("ClaimDue = %d, %v; want 4", len(claimed), err)
+	}
+	var sentID uuid.UUID
+	for _, m := range claimed {
+		if m.Recipient == "a@example.com" {
+			sentID = m.ID
+			repo.MarkSent(ctx, m.ID)
+			continue
+		}
+		repo.MarkFailed(ctx, m.ID, "550 mailbox unavailable", nil)
+	}
+
+	n, err := repo.RequeueBroadcastFailures(ctx, broadcast.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("requeued %d messages, want 2", n)
+	}
+
+	progress, err := repo.GetBroadcastProgress(ctx, broadcast.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Pending != 2 || progress.Sent != 1 || progress.Failed != 0 {
+		t.Errorf("progress = %d pending, %d sent, %d failed; want 2, 1, 0", progress.Pending, progress.Sent, progress.Failed)
+	}
+
+	var attempts int
+	var status string
+	db.QueryRow(ctx, `SELECT MAX(attempts) FROM email_outbox WHERE broadcast_id = $1 AND status = 'pending'`, broadcast.ID).Scan(&attempts)
+	if attempts != 0 {
+		t.Errorf("requeued messages have %d attempts, want a fresh start", attempts)
+	}
+	db.QueryRow(ctx, `SELECT status FROM email_outbox WHERE id = $1`, sentID).Scan(&status)
+	if status != string(models.EmailOutboxSent) {
+		t.Errorf("sent message is %s after requeue", status)
+	}
+	db.QueryRow(ctx, `SELECT status FROM email_outbox WHERE id = $1`, other).Scan(&status)
+	if status != string(models.EmailOutboxDead) {
+		t.Errorf("message outside the broadcast is %s, want dead", status)
+	}
+}
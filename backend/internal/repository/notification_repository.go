@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type NotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// GetPreferences returns a user's notification preferences, falling back to the
+// defaults when the user has never changed them
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	query := `
+		SELECT user_id, order_updates, marketing, updated_at
+		FROM notification_preferences WHERE user_id = $1
+	`
+	var prefs models.NotificationPreferences
+	err := r.db.QueryRow(ctx, query, userID).Scan(&prefs.UserID, &prefs.OrderUpdates, &prefs.Marketing, &prefs.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.NotificationPreferences{
+			UserID:       userID,
+			OrderUpdates: true,
+			Marketing:    true,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (r *NotificationRepository) SavePreferences(ctx context.Context, prefs *models.NotificationPreferences) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, order_updates, marketing, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET order_updates = EXCLUDED.order_updates, marketing = EXCLUDED.marketing, updated_at = EXCLUDED.updated_at
+	`
+	prefs.UpdatedAt = time.Now()
+	_, err := r.db.Exec(ctx, query, prefs.UserID, prefs.OrderUpdates, prefs.Marketing, prefs.UpdatedAt)
+	return err
+}
+
+func (r *NotificationRepository) CreateLog(ctx context.Context, entry *models.NotificationLog) error {
+	query := `
+		INSERT INTO notification_log (id, event_type, user_id, order_id, channel, recipient, status, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	entry.ID = uuid.New()
+	entry.CreatedAt = time.Now()
+	if entry.Channel == "" {
+		entry.Channel = "email"
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		entry.ID, entry.EventType, entry.UserID, entry.OrderID, entry.Channel,
+		entry.Recipient, entry.Status, entry.Error, entry.CreatedAt,
+	)
+	return err
+}
+
+// GetLogs returns the most recent notification log entries, optionally filtered by status
+func (r *NotificationRepository) GetLogs(ctx context.Context, status string, limit, offset int) ([]models.NotificationLog, error) {
+	query := `
+		SELECT id, event_type, user_id, order_id, channel, COALESCE(recipient, ''), status, error, created_at
+		FROM notification_log
+	`
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" WHERE status = $%d", len(args))
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []models.NotificationLog
+	for rows.Next() {
+		var l models.NotificationLog
+		if err := rows.Scan(
+			&l.ID, &l.EventType, &l.UserID, &l.OrderID, &l.Channel, &l.Recipient,
+			&l.Status, &l.Error, &l.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
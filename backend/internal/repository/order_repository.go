@@ -14,42 +14,68 @@ import (
 	"github.com/quikprint/backend/internal/models"
 )
 
+// StatusChangeHook is called after an order status change has been committed
+type StatusChangeHook func(orderID uuid.UUID, from, to models.OrderStatus, note string)
+
 type OrderRepository struct {
-	db *pgxpool.Pool
+	db          *pgxpool.Pool
+	statusHooks []StatusChangeHook
 }
 
 func NewOrderRepository(db *pgxpool.Pool) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-func (r *OrderRepository) Create(ctx context.Context, order *models.Order) error {
+// OnStatusChange registers a hook that runs after every successful UpdateStatus.
+// Hooks run on the caller's goroutine and must not block.
+func (r *OrderRepository) OnStatusChange(hook StatusChangeHook) {
+	r.statusHooks = append(r.statusHooks, hook)
+}
+
+// Create inserts the order, its items and the initial status history entry. When
+// couponCode is non-empty the coupon is re-validated against the order subtotal and
+// redeemed in the same transaction; the order discount and total are set from it.
+func (r *OrderRepository) Create(ctx context.Context, order *models.Order, couponCode string) error {
+	return r.create(ctx, order, couponCode, nil)
+}
+
+// CreateFromQuote creates an order like Create and marks the quote it was made
+// from as ordered in the same transaction. It fails with models.ErrQuoteConverted
+// or models.ErrQuoteExpired if the quote can no longer be ordered.
+func (r *OrderRepository) CreateFromQuote(ctx context.Context, order *models.Order, couponCode string, quoteID uuid.UUID) error {
+	return r.create(ctx, order, couponCode, func(tx pgx.Tx) error {
+		return markQuoteConverted(ctx, tx, quoteID, order.ID)
+	})
+}
+
+// create inserts an order, running beforeCommit, if set, last in its transaction
+func (r *OrderRepository) create(ctx context.Context, order *models.Order, couponCode string, beforeCommit func(tx pgx.Tx) error) error {
 	order.ID = uuid.New()
 	order.CreatedAt = time.Now()
 	order.UpdatedAt = time.Now()
 
-	// Try to create order with discount column first
-	err := r.createOrderWithSchema(ctx, order, true)
-	if err != nil {
-		// Check if this is a discount column not found error, if so try without discount column
-		if contains(err.Error(), "discount") {
-			fmt.Printf("DEBUG: Discount column not found, retrying without discount\n")
-			err = r.createOrderWithSchema(ctx, order, false)
-		}
-		if err != nil {
-			fmt.Printf("DEBUG: Order creation failed: %v\n", err)
-			return err
-		}
-	}
-	return nil
-}
-
-func (r *OrderRepository) createOrderWithSchema(ctx context.Context, order *models.Order, includeDiscount bool) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
+	// Redeem the coupon before anything is written so the discount is part of the
+	// order row. The coupon stays locked until this transaction ends.
+	var coupon *models.Coupon
+	if couponCode != "" {
+		var discount models.Money
+		coupon, discount, err = redeemCoupon(ctx, tx, couponCode, order.UserID, order.Subtotal)
+		if err != nil {
+			return err
+		}
+		order.Discount = discount
+		order.CouponID = &coupon.ID
+		order.CouponCode = &coupon.Code
+	}
+	// Tax is charged on the lines after the discount, so it is worked out here
+	order.ApplyTax()
+
 	// Generate order number
 	var orderNumber string
 	err = tx.QueryRow(ctx, `SELECT generate_order_number()`).Scan(&orderNumber)
@@ -58,54 +84,42 @@ func (r *OrderRepository) createOrderWithSchema(ctx context.Context, order *mode
 	}
 	order.OrderNumber = orderNumber
 
-	var orderQuery string
-	var queryArgs []interface{}
-
-	if includeDiscount {
-		orderQuery = `
-			INSERT INTO orders (id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
-				shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
-				created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-		`
-		queryArgs = []interface{}{
-			order.ID, order.OrderNumber, order.UserID, order.Status, order.Subtotal, order.Discount, order.Shipping, order.Tax, order.Total,
-			order.ShippingAddress.Name, order.ShippingAddress.Street, order.ShippingAddress.City,
-			order.ShippingAddress.State, order.ShippingAddress.Zip, order.ShippingAddress.Country,
-			order.CreatedAt, order.UpdatedAt,
-		}
-	} else {
-		orderQuery = `
-			INSERT INTO orders (id, order_number, user_id, status, subtotal, shipping, tax, total,
-				shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
-				created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
-		`
-		queryArgs = []interface{}{
-			order.ID, order.OrderNumber, order.UserID, order.Status, order.Subtotal, order.Shipping, order.Tax, order.Total,
-			order.ShippingAddress.Name, order.ShippingAddress.Street, order.ShippingAddress.City,
-			order.ShippingAddress.State, order.ShippingAddress.Zip, order.ShippingAddress.Country,
-			order.CreatedAt, order.UpdatedAt,
-		}
-	}
-
-	_, err = tx.Exec(ctx, orderQuery, queryArgs...)
+	orderQuery := `
+		INSERT INTO orders (id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
+			created_at, updated_at, coupon_id, coupon_code, discount_amount, prices_include_tax, customer_tin)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $6, $20, NULLIF($21, ''))
+	`
+	_, err = tx.Exec(ctx, orderQuery,
+		order.ID, order.OrderNumber, order.UserID, order.Status, order.Subtotal, order.Discount, order.Shipping, order.Tax, order.Total,
+		order.ShippingAddress.Name, order.ShippingAddress.Street, order.ShippingAddress.City,
+		order.ShippingAddress.State, order.ShippingAddress.Zip, order.ShippingAddress.Country,
+		order.CreatedAt, order.UpdatedAt, order.CouponID, order.CouponCode, order.PricesIncludeTax, order.CustomerTIN,
+	)
 	if err != nil {
 		return err
 	}
 
 	// Insert order items
 	itemQuery := `
-		INSERT INTO order_items (id, order_id, product_id, quantity, configuration, unit_price, total_price, uploaded_file)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO order_items (id, order_id, product_id, quantity, configuration, unit_price, total_price, uploaded_file,
+			product_name, product_slug, snapshot, tax_rate, tax_exemption, tax, net_amount)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14, $15)
 	`
 	for i := range order.Items {
-		order.Items[i].ID = uuid.New()
-		order.Items[i].OrderID = order.ID
-		configJSON, _ := json.Marshal(order.Items[i].Configuration)
+		item := &order.Items[i]
+		if item.Snapshot == nil {
+			return fmt.Errorf("order item for product %s has no snapshot", item.ProductID)
+		}
+		item.ID = uuid.New()
+		item.OrderID = order.ID
+		configJSON, _ := json.Marshal(item.Configuration)
+		snapshotJSON, _ := json.Marshal(item.Snapshot)
 		_, err = tx.Exec(ctx, itemQuery,
-			order.Items[i].ID, order.ID, order.Items[i].ProductID, order.Items[i].Quantity,
-			configJSON, order.Items[i].UnitPrice, order.Items[i].TotalPrice, order.Items[i].UploadedFile,
+			item.ID, order.ID, item.ProductID, item.Quantity,
+			configJSON, item.UnitPrice, item.TotalPrice, item.UploadedFile,
+			item.Snapshot.ProductName, item.Snapshot.ProductSlug, snapshotJSON,
+			item.TaxRate, item.TaxExemption, item.Tax, item.NetAmount,
 		)
 		if err != nil {
 			return err
@@ -122,45 +136,38 @@ func (r *OrderRepository) createOrderWithSchema(ctx context.Context, order *mode
 		return err
 	}
 
-	return tx.Commit(ctx)
-}
-
-func contains(s, substr string) bool {
-	return len(s) > 0 && len(substr) > 0 && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || findSubstring(s, substr)))
-}
+	if coupon != nil {
+		usage := &models.CouponUsage{
+			CouponID:       coupon.ID,
+			UserID:         order.UserID,
+			OrderID:        &order.ID,
+			DiscountAmount: order.Discount,
+		}
+		if err := recordCouponRedemption(ctx, tx, usage); err != nil {
+			return err
+		}
+	}
 
-func findSubstring(s, substr string) bool {
-	for i := 0; i < len(s)-len(substr)+1; i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+	if beforeCommit != nil {
+		if err := beforeCommit(tx); err != nil {
+			return err
 		}
 	}
-	return false
+
+	return tx.Commit(ctx)
 }
 
 func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Order, error) {
-	// Try with discount column first
 	query := `
 		SELECT id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			prices_include_tax, COALESCE(customer_tin, ''),
 			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
 			created_at, updated_at
 		FROM orders WHERE id = $1
 	`
 	order, err := r.scanOrder(r.db.QueryRow(ctx, query, id))
-	if err != nil {
-		// If discount column error, try without discount
-		if contains(err.Error(), "discount") {
-			queryFallback := `
-				SELECT id, order_number, user_id, status, subtotal, 0::decimal, shipping, tax, total,
-					shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
-					created_at, updated_at
-				FROM orders WHERE id = $1
-			`
-			order, err = r.scanOrder(r.db.QueryRow(ctx, queryFallback, id))
-		}
-		if err != nil || order == nil {
-			return order, err
-		}
+	if err != nil || order == nil {
+		return order, err
 	}
 
 	items, err := r.getOrderItems(ctx, order.ID)
@@ -174,25 +181,14 @@ func (r *OrderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Or
 func (r *OrderRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Order, error) {
 	query := `
 		SELECT id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			prices_include_tax, COALESCE(customer_tin, ''),
 			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
 			created_at, updated_at
 		FROM orders WHERE user_id = $1 ORDER BY created_at DESC
 	`
 	rows, err := r.db.Query(ctx, query, userID)
 	if err != nil {
-		// Try without discount
-		if contains(err.Error(), "discount") {
-			queryFallback := `
-				SELECT id, order_number, user_id, status, subtotal, 0::decimal, shipping, tax, total,
-					shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
-					created_at, updated_at
-				FROM orders WHERE user_id = $1 ORDER BY created_at DESC
-			`
-			rows, err = r.db.Query(ctx, queryFallback, userID)
-		}
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -219,6 +215,7 @@ func (r *OrderRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]
 func (r *OrderRepository) GetAll(ctx context.Context, status string) ([]models.Order, error) {
 	query := `
 		SELECT id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			prices_include_tax, COALESCE(customer_tin, ''),
 			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
 			created_at, updated_at
 		FROM orders
@@ -232,25 +229,7 @@ func (r *OrderRepository) GetAll(ctx context.Context, status string) ([]models.O
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		// Try without discount
-		if contains(err.Error(), "discount") {
-			query = `
-				SELECT id, order_number, user_id, status, subtotal, 0::decimal, shipping, tax, total,
-					shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
-					created_at, updated_at
-				FROM orders
-			`
-			args = []interface{}{}
-			if status != "" {
-				query += " WHERE status = $1"
-				args = append(args, status)
-			}
-			query += " ORDER BY created_at DESC"
-			rows, err = r.db.Query(ctx, query, args...)
-		}
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -278,6 +257,7 @@ func (r *OrderRepository) GetAll(ctx context.Context, status string) ([]models.O
 func (r *OrderRepository) GetAllAdmin(ctx context.Context, status string) ([]models.AdminOrderResponse, error) {
 	query := `
 		SELECT o.id, o.order_number, o.user_id, o.status, o.subtotal, o.discount, o.shipping, o.tax, o.total,
+			o.prices_include_tax, COALESCE(o.customer_tin, ''),
 			o.shipping_name, o.shipping_street, o.shipping_city, o.shipping_state, o.shipping_zip, o.shipping_country,
 			o.created_at, o.updated_at,
 			u.email as user_email, u.first_name, u.last_name
@@ -293,27 +273,7 @@ func (r *OrderRepository) GetAllAdmin(ctx context.Context, status string) ([]mod
 
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		// Try without discount
-		if contains(err.Error(), "discount") {
-			query = `
-				SELECT o.id, o.order_number, o.user_id, o.status, o.subtotal, 0::decimal, o.shipping, o.tax, o.total,
-					o.shipping_name, o.shipping_street, o.shipping_city, o.shipping_state, o.shipping_zip, o.shipping_country,
-					o.created_at, o.updated_at,
-					u.email as user_email, u.first_name, u.last_name
-				FROM orders o
-				JOIN users u ON o.user_id = u.id
-			`
-			args = []interface{}{}
-			if status != "" {
-				query += " WHERE o.status = $1"
-				args = append(args, status)
-			}
-			query += " ORDER BY o.created_at DESC"
-			rows, err = r.db.Query(ctx, query, args...)
-		}
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -323,6 +283,7 @@ func (r *OrderRepository) GetAllAdmin(ctx context.Context, status string) ([]mod
 		var firstName, lastName sql.NullString
 		if err := rows.Scan(
 			&o.ID, &o.OrderNumber, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.Shipping, &o.Tax, &o.Total,
+			&o.PricesIncludeTax, &o.CustomerTIN,
 			&o.ShippingAddress.Name, &o.ShippingAddress.Street, &o.ShippingAddress.City,
 			&o.ShippingAddress.State, &o.ShippingAddress.Zip, &o.ShippingAddress.Country,
 			&o.CreatedAt, &o.UpdatedAt,
@@ -355,6 +316,9 @@ func (r *OrderRepository) GetUserOrderStats(ctx context.Context, userID uuid.UUI
 	return &stats, nil
 }
 
+// UpdateStatus moves an order to a new status and records it in the status history.
+// The order lifecycle is enforced here for every caller: if the transition is not
+// allowed from the order's current status a *models.StatusTransitionError is returned.
 func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID uuid.UUID, status models.OrderStatus, note string, userID uuid.UUID) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -362,20 +326,57 @@ func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID uuid.UUID, s
 	}
 	defer tx.Rollback(ctx)
 
+	// Lock the order so concurrent updates (admin, verify, webhook) see each other
+	var current models.OrderStatus
+	err = tx.QueryRow(ctx, `SELECT status FROM orders WHERE id = $1 FOR UPDATE`, orderID).Scan(&current)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.ErrOrderNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if !current.CanTransitionTo(status) {
+		return &models.StatusTransitionError{
+			From:    current,
+			To:      status,
+			Allowed: current.AllowedTransitions(),
+		}
+	}
+
 	_, err = tx.Exec(ctx, `UPDATE orders SET status = $2, updated_at = $3 WHERE id = $1`, orderID, status, time.Now())
 	if err != nil {
 		return err
 	}
 
+	// An order cancelled before payment gives back the coupon use it reserved
+	if status == models.OrderStatusCancelled && current.IsUnpaid() {
+		if err := releaseCouponRedemption(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+
+	var createdBy *uuid.UUID
+	if userID != models.SystemActor {
+		createdBy = &userID
+	}
+
 	_, err = tx.Exec(ctx,
 		`INSERT INTO order_status_history (id, order_id, status, note, created_by, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
-		uuid.New(), orderID, status, note, userID, time.Now(),
+		uuid.New(), orderID, status, note, createdBy, time.Now(),
 	)
 	if err != nil {
 		return err
 	}
 
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	for _, hook := range r.statusHooks {
+		hook(orderID, current, status, note)
+	}
+	return nil
 }
 
 func (r *OrderRepository) AddNote(ctx context.Context, orderID uuid.UUID, note string, userID uuid.UUID) error {
@@ -405,7 +406,8 @@ func (r *OrderRepository) GetNotes(ctx context.Context, orderID uuid.UUID) ([]mo
 
 func (r *OrderRepository) getOrderItems(ctx context.Context, orderID uuid.UUID) ([]models.OrderItem, error) {
 	query := `
-		SELECT id, order_id, product_id, quantity, configuration, unit_price, total_price, uploaded_file
+		SELECT id, order_id, product_id, quantity, configuration, unit_price, total_price, uploaded_file,
+			product_name, product_slug, snapshot, tax_rate, COALESCE(tax_exemption, ''), tax, net_amount
 		FROM order_items WHERE order_id = $1
 	`
 	rows, err := r.db.Query(ctx, query, orderID)
@@ -417,14 +419,23 @@ func (r *OrderRepository) getOrderItems(ctx context.Context, orderID uuid.UUID)
 	var items []models.OrderItem
 	for rows.Next() {
 		var item models.OrderItem
-		var configJSON []byte
+		var configJSON, snapshotJSON []byte
+		var productName, productSlug string
 		if err := rows.Scan(
 			&item.ID, &item.OrderID, &item.ProductID, &item.Quantity,
 			&configJSON, &item.UnitPrice, &item.TotalPrice, &item.UploadedFile,
+			&productName, &productSlug, &snapshotJSON,
+			&item.TaxRate, &item.TaxExemption, &item.Tax, &item.NetAmount,
 		); err != nil {
 			return nil, err
 		}
 		json.Unmarshal(configJSON, &item.Configuration)
+		item.Snapshot = &models.OrderItemSnapshot{}
+		if snapshotJSON != nil {
+			json.Unmarshal(snapshotJSON, item.Snapshot)
+		}
+		item.Snapshot.ProductName = productName
+		item.Snapshot.ProductSlug = productSlug
 		items = append(items, item)
 	}
 	return items, nil
@@ -434,6 +445,7 @@ func (r *OrderRepository) scanOrder(row pgx.Row) (*models.Order, error) {
 	var o models.Order
 	err := row.Scan(
 		&o.ID, &o.OrderNumber, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.Shipping, &o.Tax, &o.Total,
+		&o.PricesIncludeTax, &o.CustomerTIN,
 		&o.ShippingAddress.Name, &o.ShippingAddress.Street, &o.ShippingAddress.City,
 		&o.ShippingAddress.State, &o.ShippingAddress.Zip, &o.ShippingAddress.Country,
 		&o.CreatedAt, &o.UpdatedAt,
@@ -450,6 +462,7 @@ func (r *OrderRepository) scanOrders(rows pgx.Rows) ([]models.Order, error) {
 		var o models.Order
 		if err := rows.Scan(
 			&o.ID, &o.OrderNumber, &o.UserID, &o.Status, &o.Subtotal, &o.Discount, &o.Shipping, &o.Tax, &o.Total,
+			&o.PricesIncludeTax, &o.CustomerTIN,
 			&o.ShippingAddress.Name, &o.ShippingAddress.Street, &o.ShippingAddress.City,
 			&o.ShippingAddress.State, &o.ShippingAddress.Zip, &o.ShippingAddress.Country,
 			&o.CreatedAt, &o.UpdatedAt,
@@ -462,7 +475,8 @@ func (r *OrderRepository) scanOrders(rows pgx.Rows) ([]models.Order, error) {
 }
 
 func (r *OrderRepository) GetStatusHistory(ctx context.Context, orderID uuid.UUID) ([]models.OrderStatusHistory, error) {
-	query := `SELECT id, order_id, status, COALESCE(note, ''), created_by, created_at FROM order_status_history WHERE order_id = $1 ORDER BY created_at DESC`
+	// System changes have no created_by; they are reported as models.SystemActor
+	query := `SELECT id, order_id, status, COALESCE(note, ''), COALESCE(created_by, '00000000-0000-0000-0000-000000000000'), created_at FROM order_status_history WHERE order_id = $1 ORDER BY created_at DESC`
 	rows, err := r.db.Query(ctx, query, orderID)
 	if err != nil {
 		return nil, err
@@ -483,6 +497,7 @@ func (r *OrderRepository) GetStatusHistory(ctx context.Context, orderID uuid.UUI
 func (r *OrderRepository) GetByOrderNumber(ctx context.Context, orderNumber string) (*models.Order, error) {
 	query := `
 		SELECT id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			prices_include_tax, COALESCE(customer_tin, ''),
 			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
 			created_at, updated_at
 		FROM orders WHERE order_number = $1
@@ -499,3 +514,55 @@ func (r *OrderRepository) GetByOrderNumber(ctx context.Context, orderNumber stri
 	order.Items = items
 	return order, nil
 }
+
+// unpaidOrdersQuery selects unpaid orders created in [$1, $2). Orders with a payment
+// still pending at the provider are left out; the payment reconciler settles or
+// expires those first.
+const unpaidOrdersQuery = `
+		SELECT id, order_number, user_id, status, subtotal, discount, shipping, tax, total,
+			prices_include_tax, COALESCE(customer_tin, ''),
+			shipping_name, shipping_street, shipping_city, shipping_state, shipping_zip, shipping_country,
+			created_at, updated_at
+		FROM orders o
+		WHERE o.status IN ('awaiting_payment', 'pending')
+		AND o.created_at >= $1 AND o.created_at < $2
+		AND NOT EXISTS (SELECT 1 FROM payments p WHERE p.order_id = o.id AND p.status = 'pending')`
+
+// GetUnpaidCreatedBefore returns up to limit unpaid orders created before the given
+// time, oldest first, without their items
+func (r *OrderRepository) GetUnpaidCreatedBefore(ctx context.Context, before time.Time, limit int) ([]models.Order, error) {
+	query := unpaidOrdersQuery + ` ORDER BY o.created_at LIMIT $3`
+	rows, err := r.db.Query(ctx, query, time.Time{}, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return r.scanOrders(rows)
+}
+
+// GetUnpaidDueReminder returns up to limit unpaid orders created in [from, to) that
+// have not yet had the reminder sent minutesBefore their payment deadline
+func (r *OrderRepository) GetUnpaidDueReminder(ctx context.Context, from, to time.Time, minutesBefore, limit int) ([]models.Order, error) {
+	query := unpaidOrdersQuery + `
+		AND NOT EXISTS (SELECT 1 FROM order_payment_reminders pr WHERE pr.order_id = o.id AND pr.minutes_before = $3)
+		ORDER BY o.created_at LIMIT $4`
+	rows, err := r.db.Query(ctx, query, from, to, minutesBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return r.scanOrders(rows)
+}
+
+// RecordPaymentReminder marks a payment reminder as sent. It returns false if it
+// had already been recorded, e.g. by another instance.
+func (r *OrderRepository) RecordPaymentReminder(ctx context.Context, orderID uuid.UUID, minutesBefore int) (bool, error) {
+	tag, err := r.db.Exec(ctx,
+		`INSERT INTO order_payment_reminders (order_id, minutes_before, sent_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		orderID, minutesBefore, time.Now(),
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
@@ -22,75 +22,165 @@ func NewPaymentRepository(db *pgxpool.Pool) *PaymentRepository {
 
 func (r *PaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
 	query := `
-		INSERT INTO payments (id, order_id, paystack_ref, amount, currency, status, paystack_response, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO payments (id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 	`
 	payment.ID = uuid.New()
 	payment.CreatedAt = time.Now()
 	payment.UpdatedAt = time.Now()
 
-	// Handle empty paystack_response - use NULL instead of empty string for JSONB field
-	var paystackResponse interface{} = nil
-	if payment.PaystackResponse != "" {
-		paystackResponse = payment.PaystackResponse
+	// Handle empty provider_response - use NULL instead of empty string for JSONB field
+	var providerResponse interface{} = nil
+	if payment.ProviderResponse != "" {
+		providerResponse = payment.ProviderResponse
 	}
 
 	_, err := r.db.Exec(ctx, query,
-		payment.ID, payment.OrderID, payment.PaystackRef, payment.Amount, payment.Currency,
-		payment.Status, paystackResponse, payment.CreatedAt, payment.UpdatedAt,
+		payment.ID, payment.OrderID, payment.Provider, payment.ProviderReference, payment.Amount, payment.Currency,
+		payment.Status, providerResponse, payment.CreatedAt, payment.UpdatedAt,
 	)
 	return err
 }
 
 func (r *PaymentRepository) GetByReference(ctx context.Context, ref string) (*models.Payment, error) {
 	query := `
-		SELECT id, order_id, paystack_ref, amount, currency, status, paystack_response, created_at, updated_at
-		FROM payments WHERE paystack_ref = $1
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
+		FROM payments WHERE provider_reference = $1
 	`
 	var p models.Payment
-	var paystackResponse sql.NullString
+	var providerResponse sql.NullString
 	err := r.db.QueryRow(ctx, query, ref).Scan(
-		&p.ID, &p.OrderID, &p.PaystackRef, &p.Amount, &p.Currency,
-		&p.Status, &paystackResponse, &p.CreatedAt, &p.UpdatedAt,
+		&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+		&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
-	if paystackResponse.Valid {
-		p.PaystackResponse = paystackResponse.String
+	if providerResponse.Valid {
+		p.ProviderResponse = providerResponse.String
+	}
+	return &p, err
+}
+
+func (r *PaymentRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Payment, error) {
+	query := `
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
+		FROM payments WHERE id = $1
+	`
+	var p models.Payment
+	var providerResponse sql.NullString
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+		&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if providerResponse.Valid {
+		p.ProviderResponse = providerResponse.String
 	}
 	return &p, err
 }
 
 func (r *PaymentRepository) GetByOrderID(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
 	query := `
-		SELECT id, order_id, paystack_ref, amount, currency, status, paystack_response, created_at, updated_at
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
 		FROM payments WHERE order_id = $1 ORDER BY created_at DESC LIMIT 1
 	`
 	var p models.Payment
-	var paystackResponse sql.NullString
+	var providerResponse sql.NullString
 	err := r.db.QueryRow(ctx, query, orderID).Scan(
-		&p.ID, &p.OrderID, &p.PaystackRef, &p.Amount, &p.Currency,
-		&p.Status, &paystackResponse, &p.CreatedAt, &p.UpdatedAt,
+		&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+		&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
-	if paystackResponse.Valid {
-		p.PaystackResponse = paystackResponse.String
+	if providerResponse.Valid {
+		p.ProviderResponse = providerResponse.String
 	}
 	return &p, err
 }
 
+// GetAllByOrderID returns every payment attempt for an order, newest first
+func (r *PaymentRepository) GetAllByOrderID(ctx context.Context, orderID uuid.UUID) ([]models.Payment, error) {
+	query := `
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
+		FROM payments WHERE order_id = $1 ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		var p models.Payment
+		var providerResponse sql.NullString
+		err := rows.Scan(
+			&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+			&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if providerResponse.Valid {
+			p.ProviderResponse = providerResponse.String
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
+// GetPendingCreatedBefore returns up to limit pending payments created before the
+// given time, oldest first
+func (r *PaymentRepository) GetPendingCreatedBefore(ctx context.Context, before time.Time, limit int) ([]models.Payment, error) {
+	query := `
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
+		FROM payments WHERE status = 'pending' AND created_at < $1 ORDER BY created_at LIMIT $2
+	`
+	rows, err := r.db.Query(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		var p models.Payment
+		var providerResponse sql.NullString
+		err := rows.Scan(
+			&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+			&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if providerResponse.Valid {
+			p.ProviderResponse = providerResponse.String
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
+
 func (r *PaymentRepository) UpdateStatus(ctx context.Context, ref string, status models.PaymentStatus, response string) error {
-	query := `UPDATE payments SET status = $2, paystack_response = $3, updated_at = $4 WHERE paystack_ref = $1`
+	query := `UPDATE payments SET status = $2, provider_response = $3, updated_at = $4 WHERE provider_reference = $1`
 	_, err := r.db.Exec(ctx, query, ref, status, response, time.Now())
 	return err
 }
 
+// SetStatus changes a payment's status without replacing the stored provider response
+func (r *PaymentRepository) SetStatus(ctx context.Context, ref string, status models.PaymentStatus) error {
+	query := `UPDATE payments SET status = $2, updated_at = $3 WHERE provider_reference = $1`
+	_, err := r.db.Exec(ctx, query, ref, status, time.Now())
+	return err
+}
+
 func (r *PaymentRepository) GetAllPayments(ctx context.Context) ([]models.Payment, error) {
 	query := `
-		SELECT id, order_id, paystack_ref, amount, currency, status, paystack_response, created_at, updated_at
+		SELECT id, order_id, provider, provider_reference, amount, currency, status, provider_response, created_at, updated_at
 		FROM payments
 		ORDER BY created_at DESC
 	`
@@ -103,18 +193,18 @@ func (r *PaymentRepository) GetAllPayments(ctx context.Context) ([]models.Paymen
 	var payments []models.Payment
 	for rows.Next() {
 		var p models.Payment
-		var paystackResponse sql.NullString
+		var providerResponse sql.NullString
 		err := rows.Scan(
-			&p.ID, &p.OrderID, &p.PaystackRef, &p.Amount, &p.Currency,
-			&p.Status, &paystackResponse, &p.CreatedAt, &p.UpdatedAt,
+			&p.ID, &p.OrderID, &p.Provider, &p.ProviderReference, &p.Amount, &p.Currency,
+			&p.Status, &providerResponse, &p.CreatedAt, &p.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
-		// Handle NULL paystack_response
-		if paystackResponse.Valid {
-			p.PaystackResponse = paystackResponse.String
+		// Handle NULL provider_response
+		if providerResponse.Valid {
+			p.ProviderResponse = providerResponse.String
 		}
 
 		payments = append(payments, p)
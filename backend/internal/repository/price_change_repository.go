@@ -0,0 +1,574 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type PriceChangeRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPriceChangeRepository(db *pgxpool.Pool) *PriceChangeRepository {
+	return &PriceChangeRepository{db: db}
+}
+
+const priceChangeColumns = `id, update_type, value, COALESCE(reason, ''), allow_below_cost, status, effective_at, applied_at,
+	rollback_of, rolled_back_by, rolled_back_at, created_by, created_at, updated_at`
+
+func scanPriceChange(row pgx.Row) (*models.PriceChange, error) {
+	var pc models.PriceChange
+	err := row.Scan(&pc.ID, &pc.UpdateType, &pc.Value, &pc.Reason, &pc.AllowBelowCost, &pc.Status, &pc.EffectiveAt, &pc.AppliedAt,
+		&pc.RollbackOf, &pc.RolledBackBy, &pc.RolledBackAt, &pc.CreatedBy, &pc.CreatedAt, &pc.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pc, nil
+}
+
+// Create saves a scheduled price change for its products
+func (r *PriceChangeRepository) Create(ctx context.Context, pc *models.PriceChange) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	pc.ID = uuid.New()
+	pc.Status = models.PriceChangeScheduled
+	pc.CreatedAt = time.Now()
+	pc.UpdatedAt = pc.CreatedAt
+	_, err = tx.Exec(ctx, `
+		INSERT INTO price_changes (id, update_type, value, reason, allow_below_cost, status, effective_at, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $9)`,
+		pc.ID, pc.UpdateType, pc.Value, pc.Reason, pc.AllowBelowCost, pc.Status, pc.EffectiveAt, pc.CreatedBy, pc.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	for _, productID := range pc.ProductIDs {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO price_change_products (price_change_id, product_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`,
+			pc.ID, productID,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// GetAll lists price changes, latest first, optionally only those with a status
+func (r *PriceChangeRepository) GetAll(ctx context.Context, status models.PriceChangeStatus) ([]models.PriceChange, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+priceChangeColumns+`,
+		       ARRAY(SELECT product_id FROM price_change_products WHERE price_change_id = price_changes.id)
+		FROM price_changes
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY effective_at DESC`,
+		string(status),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.PriceChange
+	for rows.Next() {
+		var pc models.PriceChange
+		if err := rows.Scan(&pc.ID, &pc.UpdateType, &pc.Value, &pc.Reason, &pc.Status, &pc.EffectiveAt, &pc.AppliedAt,
+			&pc.RollbackOf, &pc.RolledBackBy, &pc.RolledBackAt, &pc.CreatedBy, &pc.CreatedAt, &pc.UpdatedAt,
+			&pc.ProductIDs); err != nil {
+			return nil, err
+		}
+		changes = append(changes, pc)
+	}
+	return changes, rows.Err()
+}
+
+// GetByID returns a price change with its products and the prices it changed,
+// or nil if there is none
+func (r *PriceChangeRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PriceChange, error) {
+	pc, err := scanPriceChange(r.db.QueryRow(ctx, `SELECT `+priceChangeColumns+` FROM price_changes WHERE id = $1`, id))
+	if err != nil || pc == nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `SELECT product_id FROM price_change_products WHERE price_change_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pc.ProductIDs = []uuid.UUID{}
+	for rows.Next() {
+		var productID uuid.UUID
+		if err := rows.Scan(&productID); err != nil {
+			return nil, err
+		}
+		pc.ProductIDs = append(pc.ProductIDs, productID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pc.History, err = r.queryHistory(ctx, `h.price_change_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	pc.Tiers, err = scanPriceChangeTiers(r.db.Query(ctx, priceChangeTiersQuery, id))
+	if err != nil {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// GetDue returns the IDs of scheduled price changes whose time has come, oldest first
+func (r *PriceChangeRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM price_changes
+		WHERE status = 'scheduled' AND effective_at <= $1
+		ORDER BY effective_at LIMIT $2`,
+		now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Apply sets the new price of every product in a scheduled price change in one
+// transaction, recording each in the price history as changed by whoever made
+// the change. Changes that adjust prices rather than set them adjust the
+// products' quantity tier prices too. Products deleted since it was made are
+// skipped. The new prices are checked against the products' prices and cost
+// prices at that time: if any are negative, or below cost when the change does
+// not allow it, nothing is changed, the change is marked blocked and a
+// PriceViolationError shows why. It returns the number of products updated, or
+// ErrPriceChangeNotScheduled.
+func (r *PriceChangeRepository) Apply(ctx context.Context, id uuid.UUID) (int, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	pc, err := scanPriceChange(tx.QueryRow(ctx, `SELECT `+priceChangeColumns+` FROM price_changes WHERE id = $1 FOR UPDATE`, id))
+	if err != nil {
+		return 0, err
+	}
+	if pc == nil || pc.Status != models.PriceChangeScheduled {
+		return 0, models.ErrPriceChangeNotScheduled
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT p.id, p.name, p.base_price, p.cost_price
+		FROM price_change_products pcp
+		JOIN products p ON p.id = pcp.product_id
+		WHERE pcp.price_change_id = $1
+		ORDER BY p.id
+		FOR UPDATE OF p`,
+		id,
+	)
+	if err != nil {
+		return 0, err
+	}
+	preview := &models.BulkPricePreview{}
+	for rows.Next() {
+		var p models.BulkPricePreviewProduct
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.OldPrice, &p.CostPrice); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		p.NewPrice = pc.Adjust(p.OldPrice)
+		p.Violations = models.PriceViolations(p.NewPrice, p.CostPrice)
+		preview.Products = append(preview.Products, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if pc.AdjustsTiers() {
+		rows, err := tx.Query(ctx, `
+			SELECT t.id, t.product_id, t.min_qty, t.max_qty, t.price
+			FROM pricing_tiers t
+			JOIN price_change_products pcp ON pcp.product_id = t.product_id
+			WHERE pcp.price_change_id = $1
+			ORDER BY t.product_id, t.min_qty
+			FOR UPDATE OF t`,
+			id,
+		)
+		if err != nil {
+			return 0, err
+		}
+		index := make(map[uuid.UUID]int, len(preview.Products))
+		for i, p := range preview.Products {
+			index[p.ProductID] = i
+		}
+		for rows.Next() {
+			var t models.BulkPricePreviewTier
+			var productID uuid.UUID
+			if err := rows.Scan(&t.TierID, &productID, &t.MinQty, &t.MaxQty, &t.OldPrice); err != nil {
+				rows.Close()
+				return 0, err
+			}
+			p := &preview.Products[index[productID]]
+			t.NewPrice = pc.Adjust(t.OldPrice)
+			t.Violations = models.PriceViolations(t.NewPrice, p.CostPrice)
+			p.Tiers = append(p.Tiers, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	if preview.Blocks(pc.AllowBelowCost) {
+		for _, p := range preview.Products {
+			if p.Violated() {
+				preview.ViolationCount++
+			}
+		}
+		preview.ProductCount = len(preview.Products)
+		if _, err := tx.Exec(ctx, `
+			UPDATE price_changes SET status = 'blocked', updated_at = NOW() WHERE id = $1`,
+			id,
+		); err != nil {
+			return 0, err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return 0, err
+		}
+		return 0, &models.PriceViolationError{Preview: preview}
+	}
+
+	for _, p := range preview.Products {
+		if err := setPrice(ctx, tx, p.ProductID, p.OldPrice, pc.NewPrice(p.OldPrice), &pc.ID, pc.Reason, pc.CreatedBy); err != nil {
+			return 0, err
+		}
+		for _, t := range p.Tiers {
+			if err := setTierPrice(ctx, tx, pc.ID, t.TierID, p.ProductID, t.OldPrice, pc.NewPrice(t.OldPrice)); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE price_changes SET status = 'applied', applied_at = NOW(), updated_at = NOW() WHERE id = $1`,
+		id,
+	); err != nil {
+		return 0, err
+	}
+	return len(preview.Products), tx.Commit(ctx)
+}
+
+// Cancel stops a scheduled price change from being applied. It returns false if
+// there is no such change, or ErrPriceChangeNotScheduled.
+func (r *PriceChangeRepository) Cancel(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE price_changes SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1 AND status = 'scheduled'`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	var exists bool
+	if err := r.db.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM price_changes WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+	return true, models.ErrPriceChangeNotScheduled
+}
+
+// Rollback restores every base and tier price an applied price change set, in one
+// transaction, and returns the price change that did so. It fails with a
+// PriceRollbackConflictError, changing nothing, if any of the prices have changed
+// since; and with ErrPriceChangeNotApplied unless the change is applied. It
+// returns nil if there is no such change.
+func (r *PriceChangeRepository) Rollback(ctx context.Context, id uuid.UUID, reason string, actor uuid.UUID) (*models.PriceChange, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	pc, err := scanPriceChange(tx.QueryRow(ctx, `SELECT `+priceChangeColumns+` FROM price_changes WHERE id = $1 FOR UPDATE`, id))
+	if err != nil || pc == nil {
+		return nil, err
+	}
+	if pc.Status != models.PriceChangeApplied {
+		return nil, models.ErrPriceChangeNotApplied
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT h.product_id, h.old_price, h.new_price, p.base_price
+		FROM product_price_history h
+		JOIN products p ON p.id = h.product_id
+		WHERE h.price_change_id = $1
+		ORDER BY h.product_id
+		FOR UPDATE OF p`,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type restore struct {
+		productID uuid.UUID
+		oldPrice  models.Money
+		newPrice  models.Money
+		current   models.Money
+	}
+	var restores []restore
+	for rows.Next() {
+		var rs restore
+		if err := rows.Scan(&rs.productID, &rs.oldPrice, &rs.newPrice, &rs.current); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		restores = append(restores, rs)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tiers, err := scanPriceChangeTiers(tx.Query(ctx, priceChangeTiersQuery, id))
+	if err != nil {
+		return nil, err
+	}
+	current, err := lockTiers(ctx, tx, `
+		SELECT t.id, t.product_id, t.price
+		FROM pricing_tiers t
+		JOIN price_change_tiers pct ON pct.tier_id = t.id
+		WHERE pct.price_change_id = $1
+		ORDER BY t.id
+		FOR UPDATE OF t`, id)
+	if err != nil {
+		return nil, err
+	}
+	currentTiers := make(map[uuid.UUID]models.Money, len(current))
+	for _, t := range current {
+		currentTiers[t.TierID] = t.OldPrice
+	}
+
+	conflict := &models.PriceRollbackConflictError{}
+	conflicted := make(map[uuid.UUID]bool)
+	for _, rs := range restores {
+		if rs.current.Cmp(rs.newPrice) != 0 && !conflicted[rs.productID] {
+			conflicted[rs.productID] = true
+			conflict.ProductIDs = append(conflict.ProductIDs, rs.productID)
+		}
+	}
+	// A tier that has been replaced or repriced since cannot be restored
+	for _, t := range tiers {
+		price, ok := currentTiers[t.TierID]
+		if (!ok || price.Cmp(t.NewPrice) != 0) && !conflicted[t.ProductID] {
+			conflicted[t.ProductID] = true
+			conflict.ProductIDs = append(conflict.ProductIDs, t.ProductID)
+		}
+	}
+	if len(conflict.ProductIDs) > 0 {
+		return nil, conflict
+	}
+
+	now := time.Now()
+	rollback := &models.PriceChange{
+		ID:          uuid.New(),
+		UpdateType:  models.PriceChangeRollback,
+		Reason:      reason,
+		Status:      models.PriceChangeApplied,
+		EffectiveAt: now,
+		AppliedAt:   &now,
+		RollbackOf:  &pc.ID,
+		CreatedBy:   &actor,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO price_changes (id, update_type, reason, status, effective_at, applied_at, rollback_of, created_by, created_at, updated_at)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $5, $6, $7, $5, $5)`,
+		rollback.ID, rollback.UpdateType, rollback.Reason, rollback.Status, now, pc.ID, actor,
+	); err != nil {
+		return nil, err
+	}
+	for _, rs := range restores {
+		if _, err := tx.Exec(ctx, `INSERT INTO price_change_products (price_change_id, product_id) VALUES ($1, $2)`, rollback.ID, rs.productID); err != nil {
+			return nil, err
+		}
+		if err := setPrice(ctx, tx, rs.productID, rs.current, rs.oldPrice, &rollback.ID, reason, &actor); err != nil {
+			return nil, err
+		}
+		rollback.ProductIDs = append(rollback.ProductIDs, rs.productID)
+	}
+	for _, t := range tiers {
+		if err := setTierPrice(ctx, tx, rollback.ID, t.TierID, t.ProductID, t.NewPrice, t.OldPrice); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(ctx, `
+		UPDATE price_changes SET status = 'rolled_back', rolled_back_by = $2, rolled_back_at = $3, updated_at = $3
+		WHERE id = $1`,
+		pc.ID, actor, now,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rollback, nil
+}
+
+// SetPrice changes one product's base price outside a bulk update, recording the
+// change in the price history. Nothing is recorded if the price is unchanged.
+func (r *PriceChangeRepository) SetPrice(ctx context.Context, productID uuid.UUID, price models.Money, reason string, actor uuid.UUID) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var old models.Money
+	if err := tx.QueryRow(ctx, `SELECT base_price FROM products WHERE id = $1 FOR UPDATE`, productID).Scan(&old); err != nil {
+		return err
+	}
+	if old.Cmp(price) == 0 {
+		return nil
+	}
+	if err := setPrice(ctx, tx, productID, old, price, nil, reason, &actor); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// GetHistory lists the changes to a product's base price, latest first
+func (r *PriceChangeRepository) GetHistory(ctx context.Context, productID uuid.UUID) ([]models.ProductPriceHistory, error) {
+	return r.queryHistory(ctx, `h.product_id = $1`, productID)
+}
+
+func (r *PriceChangeRepository) queryHistory(ctx context.Context, where string, arg uuid.UUID) ([]models.ProductPriceHistory, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT h.id, h.product_id, p.name, h.price_change_id, h.old_price, h.new_price, COALESCE(h.reason, ''),
+		       h.changed_by, COALESCE(u.first_name || ' ' || u.last_name, ''), h.changed_at
+		FROM product_price_history h
+		JOIN products p ON p.id = h.product_id
+		LEFT JOIN users u ON u.id = h.changed_by
+		WHERE `+where+`
+		ORDER BY h.changed_at DESC, p.name`,
+		arg,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []models.ProductPriceHistory
+	for rows.Next() {
+		var h models.ProductPriceHistory
+		if err := rows.Scan(&h.ID, &h.ProductID, &h.ProductName, &h.PriceChangeID, &h.OldPrice, &h.NewPrice, &h.Reason,
+			&h.ChangedBy, &h.ChangedByName, &h.ChangedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+// setPrice writes a product's base price and its price history entry
+func setPrice(ctx context.Context, tx pgx.Tx, productID uuid.UUID, old, price models.Money, priceChangeID *uuid.UUID, reason string, actor *uuid.UUID) error {
+	if _, err := tx.Exec(ctx, `UPDATE products SET base_price = $2, updated_at = NOW() WHERE id = $1`, productID, price); err != nil {
+		return err
+	}
+	if actor != nil && *actor == models.SystemActor {
+		actor = nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO product_price_history (id, product_id, price_change_id, old_price, new_price, reason, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NOW())`,
+		uuid.New(), productID, priceChangeID, old, price, reason, actor,
+	)
+	return err
+}
+
+// setTierPrice writes a quantity tier's price and records it against a price change
+func setTierPrice(ctx context.Context, tx pgx.Tx, priceChangeID, tierID, productID uuid.UUID, old, price models.Money) error {
+	if _, err := tx.Exec(ctx, `UPDATE pricing_tiers SET price = $2 WHERE id = $1`, tierID, price); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO price_change_tiers (price_change_id, tier_id, product_id, old_price, new_price)
+		VALUES ($1, $2, $3, $4, $5)`,
+		priceChangeID, tierID, productID, old, price,
+	)
+	return err
+}
+
+// lockTiers runs a query selecting tier id, product id and price, returning each
+// tier's current price as its OldPrice
+func lockTiers(ctx context.Context, tx pgx.Tx, query string, priceChangeID uuid.UUID) ([]models.PriceChangeTier, error) {
+	rows, err := tx.Query(ctx, query, priceChangeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiers []models.PriceChangeTier
+	for rows.Next() {
+		var t models.PriceChangeTier
+		if err := rows.Scan(&t.TierID, &t.ProductID, &t.OldPrice); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
+
+// priceChangeTiersQuery selects the tier prices a price change set
+const priceChangeTiersQuery = `
+	SELECT tier_id, product_id, old_price, new_price
+	FROM price_change_tiers
+	WHERE price_change_id = $1
+	ORDER BY product_id, old_price`
+
+func scanPriceChangeTiers(rows pgx.Rows, err error) ([]models.PriceChangeTier, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tiers []models.PriceChangeTier
+	for rows.Next() {
+		var t models.PriceChangeTier
+		if err := rows.Scan(&t.TierID, &t.ProductID, &t.OldPrice, &t.NewPrice); err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, rows.Err()
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+// createPricedProduct adds a product with a base price and one quantity tier
+func createPricedProduct(t *testing.T, db *pgxpool.Pool, basePrice, tierPrice models.Money) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+
+	categoryID, productID := uuid.New(), uuid.New()
+	if _, err := db.Exec(ctx, `INSERT INTO categories (id, name, slug) VALUES ($1, 'Flyers', $2)`,
+		categoryID, "flyers-"+categoryID.String()); err != nil {
+		t.Fatalf("create category: %v", err)
+	}
+	if _, err := db.Exec(ctx, `
+		INSERT INTO products (id, name, slug, category_id, base_price) VALUES ($1, 'A5 Flyer', $2, $3, $4)`,
+		productID, "a5-flyer-"+productID.String(), categoryID, basePrice); err != nil {
+		t.Fatalf("create product: %v", err)
+	}
+	if _, err := db.Exec(ctx, `
+		INSERT INTO pricing_tiers (product_id, min_qty, max_qty, price) VALUES ($1, 100, 0, $2)`,
+		productID, tierPrice); err != nil {
+		t.Fatalf("create tier: %v", err)
+	}
+	return productID
+}
+
+func scheduleDecrease(t *testing.T, repo *PriceChangeRepository, productID uuid.UUID, value float64, allowBelowCost bool) *models.PriceChange {
+	t.Helper()
+	pc := &models.PriceChange{
+		UpdateType:     "decrease",
+		Value:          value,
+		EffectiveAt:    time.Now(),
+		ProductIDs:     []uuid.UUID{productID},
+		AllowBelowCost: allowBelowCost,
+	}
+	if err := repo.Create(context.Background(), pc); err != nil {
+		t.Fatalf("create price change: %v", err)
+	}
+	return pc
+}
+
+func basePrice(t *testing.T, db *pgxpool.Pool, productID uuid.UUID) models.Money {
+	t.Helper()
+	var price models.Money
+	if err := db.QueryRow(context.Background(), `SELECT base_price FROM products WHERE id = $1`, productID).Scan(&price); err != nil {
+		t.Fatal(err)
+	}
+	return price
+}
+
+func TestApplyBlocksPricesBelowCostAtApplyTime(t *testing.T) {
+	db := testDB(t)
+	repo := NewPriceChangeRepository(db)
+	ctx := context.Background()
+	productID := createPricedProduct(t, db, models.Kobo(100000), models.Kobo(90000))
+	pc := scheduleDecrease(t, repo, productID, 300, false)
+
+	// The cost price goes up after the change was scheduled
+	if _, err := db.Exec(ctx, `UPDATE products SET cost_price = 800 WHERE id = $1`, productID); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.Apply(ctx, pc.ID)
+	var violationErr *models.PriceViolationError
+	if !errors.As(err, &violationErr) {
+		t.Fatalf("Apply = %d, %v; want a PriceViolationError", updated, err)
+	}
+	preview := violationErr.Preview
+	if preview.ViolationCount != 1 || len(preview.Products) != 1 || len(preview.Products[0].Tiers) != 1 {
+		t.Fatalf("preview = %+v, want one product with one tier", preview)
+	}
+	product := preview.Products[0]
+	if product.NewPrice.Minor() != 70000 || product.Tiers[0].NewPrice.Minor() != 60000 {
+		t.Errorf("new prices = %s and %s, want 700.00 and 600.00", product.NewPrice, product.Tiers[0].NewPrice)
+	}
+
+	if price := basePrice(t, db, productID); price.Minor() != 100000 {
+		t.Errorf("base price = %s, want it unchanged at 1000.00", price)
+	}
+	got, err := repo.GetByID(ctx, pc.ID)
+	if err != nil || got.Status != models.PriceChangeBlocked {
+		t.Errorf("status = %v, %v; want blocked", got, err)
+	}
+	if _, err := repo.Apply(ctx, pc.ID); !errors.Is(err, models.ErrPriceChangeNotScheduled) {
+		t.Errorf("applying a blocked change again = %v, want ErrPriceChangeNotScheduled", err)
+	}
+}
+
+func TestApplyAllowsBelowCostWhenSubmittedSo(t *testing.T) {
+	db := testDB(t)
+	repo := NewPriceChangeRepository(db)
+	ctx := context.Background()
+	productID := createPricedProduct(t, db, models.Kobo(100000), models.Kobo(90000))
+	pc := scheduleDecrease(t, repo, productID, 300, true)
+	if _, err := db.Exec(ctx, `UPDATE products SET cost_price = 800 WHERE id = $1`, productID); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.Apply(ctx, pc.ID)
+	if err != nil || updated != 1 {
+		t.Fatalf("Apply = %d, %v; want 1 product updated", updated, err)
+	}
+	if price := basePrice(t, db, productID); price.Minor() != 70000 {
+		t.Errorf("base price = %s, want 700.00", price)
+	}
+}
+
+func TestApplyBlocksNegativePricesEvenBelowCostAllowed(t *testing.T) {
+	db := testDB(t)
+	repo := NewPriceChangeRepository(db)
+	ctx := context.Background()
+	productID := createPricedProduct(t, db, models.Kobo(100000), models.Kobo(90000))
+	pc := scheduleDecrease(t, repo, productID, 500, true)
+
+	// The tier is repriced after the change was scheduled
+	if _, err := db.Exec(ctx, `UPDATE pricing_tiers SET price = 400 WHERE product_id = $1`, productID); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := repo.Apply(ctx, pc.ID)
+	var violationErr *models.PriceViolationError
+	if !errors.As(err, &violationErr) {
+		t.Fatalf("Apply = %v, want a PriceViolationError", err)
+	}
+	if v := violationErr.Preview.Products[0].Tiers[0].Violations; len(v) != 1 || v[0] != models.PriceViolationNegative {
+		t.Errorf("tier violations = %v, want negative_price", v)
+	}
+	if price := basePrice(t, db, productID); price.Minor() != 100000 {
+		t.Errorf("base price = %s, want it unchanged at 1000.00", price)
+	}
+}
@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/quikprint/backend/internal/models"
 )
@@ -36,17 +39,37 @@ func (r *PricingRepository) GetPricingTiers(ctx context.Context, productID uuid.
 }
 
 func (r *PricingRepository) GetDimensionalPricing(ctx context.Context, productID uuid.UUID) (*models.DimensionalPricing, error) {
-	query := `SELECT id, product_id, rate_per_unit, unit, min_charge FROM dimensional_pricing WHERE product_id = $1`
+	query := `
+		SELECT id, product_id, rate_per_unit, unit, size_unit, min_charge,
+		       min_width, max_width, min_height, max_height, bleed, finishings
+		FROM dimensional_pricing WHERE product_id = $1`
 	var dp models.DimensionalPricing
-	err := r.db.QueryRow(ctx, query, productID).Scan(&dp.ID, &dp.ProductID, &dp.RatePerUnit, &dp.Unit, &dp.MinCharge)
-	if err != nil {
+	var finishingsJSON []byte
+	err := r.db.QueryRow(ctx, query, productID).Scan(
+		&dp.ID, &dp.ProductID, &dp.RatePerUnit, &dp.Unit, &dp.SizeUnit, &dp.MinCharge,
+		&dp.MinWidth, &dp.MaxWidth, &dp.MinHeight, &dp.MaxHeight, &dp.Bleed, &finishingsJSON,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
+	if err != nil {
+		return nil, err
+	}
+	json.Unmarshal(finishingsJSON, &dp.Finishings)
 	return &dp, nil
 }
 
+// GetAddOns returns a product's enabled add-ons
 func (r *PricingRepository) GetAddOns(ctx context.Context, productID uuid.UUID) ([]models.AddOn, error) {
-	query := `SELECT id, product_id, name, type, price_modifier, enabled FROM add_ons WHERE product_id = $1 AND enabled = true`
+	return r.queryAddOns(ctx, `SELECT id, product_id, name, type, price_modifier, enabled FROM add_ons WHERE product_id = $1 AND enabled = true ORDER BY name`, productID)
+}
+
+// GetAllAddOns returns a product's add-ons including disabled ones
+func (r *PricingRepository) GetAllAddOns(ctx context.Context, productID uuid.UUID) ([]models.AddOn, error) {
+	return r.queryAddOns(ctx, `SELECT id, product_id, name, type, price_modifier, enabled FROM add_ons WHERE product_id = $1 ORDER BY name`, productID)
+}
+
+func (r *PricingRepository) queryAddOns(ctx context.Context, query string, productID uuid.UUID) ([]models.AddOn, error) {
 	rows, err := r.db.Query(ctx, query, productID)
 	if err != nil {
 		return nil, err
@@ -61,11 +84,40 @@ func (r *PricingRepository) GetAddOns(ctx context.Context, productID uuid.UUID)
 		}
 		addOns = append(addOns, a)
 	}
-	return addOns, nil
+	return addOns, rows.Err()
+}
+
+func (r *PricingRepository) CreateAddOn(ctx context.Context, productID uuid.UUID, addOn *models.AddOn) error {
+	query := `INSERT INTO add_ons (id, product_id, name, type, price_modifier, enabled) VALUES ($1, $2, $3, $4, $5, $6)`
+	addOn.ID = uuid.New()
+	addOn.ProductID = productID
+	_, err := r.db.Exec(ctx, query, addOn.ID, addOn.ProductID, addOn.Name, addOn.Type, addOn.PriceModifier, addOn.Enabled)
+	return err
+}
+
+// UpdateAddOn replaces an add-on's fields and reports whether it exists on the product
+func (r *PricingRepository) UpdateAddOn(ctx context.Context, addOn *models.AddOn) (bool, error) {
+	query := `UPDATE add_ons SET name = $3, type = $4, price_modifier = $5, enabled = $6 WHERE id = $1 AND product_id = $2`
+	tag, err := r.db.Exec(ctx, query, addOn.ID, addOn.ProductID, addOn.Name, addOn.Type, addOn.PriceModifier, addOn.Enabled)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// DeleteAddOn removes an add-on and reports whether it existed on the product
+func (r *PricingRepository) DeleteAddOn(ctx context.Context, productID, addOnID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM add_ons WHERE id = $1 AND product_id = $2`, addOnID, productID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
 }
 
 func (r *PricingRepository) GetPricingRules(ctx context.Context, productID uuid.UUID) ([]models.PricingRule, error) {
-	query := `SELECT id, product_id, rule_type, value, description FROM pricing_rules WHERE product_id = $1`
+	query := `
+		SELECT id, product_id, rule_type, value_type, value, COALESCE(description, ''), created_at, updated_at
+		FROM pricing_rules WHERE product_id = $1 ORDER BY rule_type, id`
 	rows, err := r.db.Query(ctx, query, productID)
 	if err != nil {
 		return nil, err
@@ -75,12 +127,50 @@ func (r *PricingRepository) GetPricingRules(ctx context.Context, productID uuid.
 	var rules []models.PricingRule
 	for rows.Next() {
 		var pr models.PricingRule
-		if err := rows.Scan(&pr.ID, &pr.ProductID, &pr.RuleType, &pr.Value, &pr.Description); err != nil {
+		if err := rows.Scan(&pr.ID, &pr.ProductID, &pr.RuleType, &pr.ValueType, &pr.Value, &pr.Description, &pr.CreatedAt, &pr.UpdatedAt); err != nil {
 			return nil, err
 		}
 		rules = append(rules, pr)
 	}
-	return rules, nil
+	return rules, rows.Err()
+}
+
+func (r *PricingRepository) CreatePricingRule(ctx context.Context, productID uuid.UUID, rule *models.PricingRule) error {
+	query := `
+		INSERT INTO pricing_rules (id, product_id, rule_type, value_type, value, description)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`
+	rule.ID = uuid.New()
+	rule.ProductID = productID
+	return r.db.QueryRow(ctx, query, rule.ID, rule.ProductID, rule.RuleType, rule.ValueType, rule.Value, rule.Description).
+		Scan(&rule.CreatedAt, &rule.UpdatedAt)
+}
+
+// UpdatePricingRule replaces a rule's fields and reports whether it exists on the product
+func (r *PricingRepository) UpdatePricingRule(ctx context.Context, rule *models.PricingRule) (bool, error) {
+	query := `
+		UPDATE pricing_rules
+		SET rule_type = $3, value_type = $4, value = $5, description = $6, updated_at = NOW()
+		WHERE id = $1 AND product_id = $2
+		RETURNING created_at, updated_at`
+	err := r.db.QueryRow(ctx, query, rule.ID, rule.ProductID, rule.RuleType, rule.ValueType, rule.Value, rule.Description).
+		Scan(&rule.CreatedAt, &rule.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeletePricingRule removes a rule and reports whether it existed on the product
+func (r *PricingRepository) DeletePricingRule(ctx context.Context, productID, ruleID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM pricing_rules WHERE id = $1 AND product_id = $2`, ruleID, productID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
 }
 
 func (r *PricingRepository) CreatePricingTier(ctx context.Context, productID uuid.UUID, tier *models.PricingTier) error {
@@ -95,10 +185,18 @@ func (r *PricingRepository) CreateDimensionalPricing(ctx context.Context, produc
 	// Delete existing first
 	r.db.Exec(ctx, `DELETE FROM dimensional_pricing WHERE product_id = $1`, productID)
 
-	query := `INSERT INTO dimensional_pricing (id, product_id, rate_per_unit, unit, min_charge) VALUES ($1, $2, $3, $4, $5)`
+	query := `
+		INSERT INTO dimensional_pricing (id, product_id, rate_per_unit, unit, size_unit, min_charge,
+			min_width, max_width, min_height, max_height, bleed, finishings)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
 	dp.ID = uuid.New()
 	dp.ProductID = productID
-	_, err := r.db.Exec(ctx, query, dp.ID, dp.ProductID, dp.RatePerUnit, dp.Unit, dp.MinCharge)
+	if dp.Finishings == nil {
+		dp.Finishings = []models.EdgeFinishing{}
+	}
+	finishingsJSON, _ := json.Marshal(dp.Finishings)
+	_, err := r.db.Exec(ctx, query, dp.ID, dp.ProductID, dp.RatePerUnit, dp.Unit, dp.SizeUnit, dp.MinCharge,
+		dp.MinWidth, dp.MaxWidth, dp.MinHeight, dp.MaxHeight, dp.Bleed, finishingsJSON)
 	return err
 }
 
@@ -111,3 +209,66 @@ func (r *PricingRepository) DeleteDimensionalPricing(ctx context.Context, produc
 	_, err := r.db.Exec(ctx, `DELETE FROM dimensional_pricing WHERE product_id = $1`, productID)
 	return err
 }
+
+// GetPricingFormulas returns a product's formulas in evaluation order, only the
+// published ones unless drafts is set
+func (r *PricingRepository) GetPricingFormulas(ctx context.Context, productID uuid.UUID, drafts bool) ([]models.PricingFormula, error) {
+	query := `
+		SELECT id, product_id, position, label, expression, published, created_at, updated_at
+		FROM pricing_formulas
+		WHERE product_id = $1 AND (published OR $2)
+		ORDER BY position, created_at`
+	rows, err := r.db.Query(ctx, query, productID, drafts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var formulas []models.PricingFormula
+	for rows.Next() {
+		var f models.PricingFormula
+		if err := rows.Scan(&f.ID, &f.ProductID, &f.Position, &f.Label, &f.Expression, &f.Published, &f.CreatedAt, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		formulas = append(formulas, f)
+	}
+	return formulas, rows.Err()
+}
+
+func (r *PricingRepository) CreatePricingFormula(ctx context.Context, productID uuid.UUID, formula *models.PricingFormula) error {
+	query := `
+		INSERT INTO pricing_formulas (id, product_id, position, label, expression, published)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`
+	formula.ID = uuid.New()
+	formula.ProductID = productID
+	return r.db.QueryRow(ctx, query, formula.ID, formula.ProductID, formula.Position, formula.Label, formula.Expression, formula.Published).
+		Scan(&formula.CreatedAt, &formula.UpdatedAt)
+}
+
+// UpdatePricingFormula replaces a formula's fields and reports whether it exists on the product
+func (r *PricingRepository) UpdatePricingFormula(ctx context.Context, formula *models.PricingFormula) (bool, error) {
+	query := `
+		UPDATE pricing_formulas
+		SET position = $3, label = $4, expression = $5, published = $6, updated_at = NOW()
+		WHERE id = $1 AND product_id = $2
+		RETURNING created_at, updated_at`
+	err := r.db.QueryRow(ctx, query, formula.ID, formula.ProductID, formula.Position, formula.Label, formula.Expression, formula.Published).
+		Scan(&formula.CreatedAt, &formula.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeletePricingFormula removes a formula and reports whether it existed on the product
+func (r *PricingRepository) DeletePricingFormula(ctx context.Context, productID, formulaID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM pricing_formulas WHERE id = $1 AND product_id = $2`, formulaID, productID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
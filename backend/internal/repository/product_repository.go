@@ -2,9 +2,13 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -21,11 +25,27 @@ func NewProductRepository(db *pgxpool.Pool, pricingRepo *PricingRepository) *Pro
 	return &ProductRepository{db: db, pricingRepo: pricingRepo}
 }
 
+const productColumns = `p.id, p.name, p.slug, p.category_id, c.name, c.slug, p.description,
+	p.short_description, p.base_price, p.images, p.options, p.features,
+	p.turnaround, p.min_quantity, p.tax_exempt, ` + categoryTaxExempt + `, p.cost_price,
+	p.status, p.publish_at, p.unpublish_at, p.deleted_at, p.created_at, p.updated_at`
+
+// categoryTaxExempt is whether category c, or any category above it, is tax exempt
+const categoryTaxExempt = `(
+	WITH RECURSIVE ancestors AS (
+		SELECT c.id, c.parent_id, c.tax_exempt
+		UNION
+		SELECT k.id, k.parent_id, k.tax_exempt FROM categories k JOIN ancestors a ON k.id = a.parent_id
+	)
+	SELECT bool_or(tax_exempt) FROM ancestors
+)`
+
 func (r *ProductRepository) Create(ctx context.Context, product *models.Product) error {
 	query := `
 		INSERT INTO products (id, name, slug, category_id, description, short_description, 
-			base_price, images, options, features, turnaround, min_quantity, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			base_price, images, options, features, turnaround, min_quantity, tax_exempt, cost_price,
+			status, publish_at, unpublish_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
 	`
 	product.ID = uuid.New()
 	product.CreatedAt = time.Now()
@@ -38,40 +58,176 @@ func (r *ProductRepository) Create(ctx context.Context, product *models.Product)
 	_, err := r.db.Exec(ctx, query,
 		product.ID, product.Name, product.Slug, product.CategoryID, product.Description,
 		product.ShortDescription, product.BasePrice, imagesJSON, optionsJSON, featuresJSON,
-		product.Turnaround, product.MinQuantity, product.CreatedAt, product.UpdatedAt,
+		product.Turnaround, product.MinQuantity, product.TaxExempt, product.CostPrice,
+		product.Status, product.PublishAt, product.UnpublishAt, product.CreatedAt, product.UpdatedAt,
 	)
 	return err
 }
 
-func (r *ProductRepository) GetAll(ctx context.Context, categorySlug string) ([]models.Product, error) {
-	query := `
-		SELECT p.id, p.name, p.slug, p.category_id, c.name, c.slug, p.description, 
-			   p.short_description, p.base_price, p.images, p.options, p.features, 
-			   p.turnaround, p.min_quantity, p.created_at, p.updated_at
+// productSortKeys gives, for each sort, the expression products are ordered by,
+// the type its cursor value is cast back to, and whether it is descending. Ties
+// are broken by ID in the same direction, so pages can be keyed on both.
+var productSortKeys = map[models.ProductSort]struct {
+	expr string
+	typ  string
+	desc bool
+}{
+	models.ProductSortRelevance:  {"ROUND(ts_rank(p.search_vector, to_tsquery('english', $1))::numeric, 6)", "numeric", true},
+	models.ProductSortName:       {"p.name", "text", false},
+	models.ProductSortPriceAsc:   {"p.base_price", "numeric", false},
+	models.ProductSortPriceDesc:  {"p.base_price", "numeric", true},
+	models.ProductSortNewest:     {"p.created_at", "timestamptz", true},
+	models.ProductSortPopularity: {"COALESCE(s.sold, 0)", "numeric", true},
+}
+
+// productCursor is where a page of a product search ends: the sort key, as
+// Postgres prints it, and the ID of its last product
+type productCursor struct {
+	Sort models.ProductSort `json:"s"`
+	Key  string             `json:"k"`
+	ID   uuid.UUID          `json:"id"`
+}
+
+func encodeProductCursor(c productCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProductCursor(s string, sort models.ProductSort) (*productCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, models.ErrInvalidCursor
+	}
+	var c productCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.ID == uuid.Nil {
+		return nil, models.ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// searchQuery turns what someone typed into a tsquery matching products with
+// every word, or words starting with it, so partly typed words still match.
+// It is empty if nothing searchable was typed.
+func searchQuery(search string) string {
+	var terms []string
+	for _, word := range strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		terms = append(terms, strings.ToLower(word)+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// Search returns a page of the products matching q, in its sort order, and how
+// many match in all. It fails with ErrInvalidCursor if q's cursor cannot be used.
+func (r *ProductRepository) Search(ctx context.Context, q *models.ProductQuery) (*models.ProductPage, error) {
+	var args []interface{}
+	var where []string
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	sort := q.Sort
+	if sort == "" {
+		sort = models.ProductSortRelevance
+	}
+	// The search is always $1, which the relevance sort refers to
+	if tsquery := searchQuery(q.Search); tsquery != "" {
+		where = append(where, "p.search_vector @@ to_tsquery('english', "+arg(tsquery)+")")
+	} else if sort == models.ProductSortRelevance {
+		sort = models.ProductSortName
+	}
+	if q.Deleted {
+		where = append(where, "p.deleted_at IS NOT NULL")
+	} else {
+		where = append(where, "p.deleted_at IS NULL")
+	}
+	if q.Status != "" {
+		where = append(where, "p.status = "+arg(string(q.Status)))
+	}
+	if len(q.CategorySlugs) > 0 {
+		where = append(where, "p.category_id IN ("+fmt.Sprintf(categorySubtree, "slug = ANY("+arg(q.CategorySlugs)+")")+")")
+	}
+	if q.MinPrice != nil {
+		where = append(where, "p.base_price >= "+arg(*q.MinPrice))
+	}
+	if q.MaxPrice != nil {
+		where = append(where, "p.base_price <= "+arg(*q.MaxPrice))
+	}
+	if q.MaxTurnaroundDays > 0 {
+		where = append(where, "p.turnaround_days <= "+arg(q.MaxTurnaroundDays))
+	}
+
+	from := `
 		FROM products p
-		JOIN categories c ON c.id = p.category_id
-	`
-	args := []interface{}{}
-	if categorySlug != "" {
-		query += " WHERE c.slug = $1"
-		args = append(args, categorySlug)
+		JOIN categories c ON c.id = p.category_id`
+	conditions := " WHERE " + strings.Join(where, " AND ")
+
+	page := &models.ProductPage{Products: []models.Product{}}
+	if err := r.db.QueryRow(ctx, `SELECT COUNT(*)`+from+conditions, args...).Scan(&page.Total); err != nil {
+		return nil, err
 	}
-	query += " ORDER BY p.name"
 
+	key := productSortKeys[sort]
+	if sort == models.ProductSortPopularity {
+		// Units sold on orders that have been paid for
+		from += `
+		LEFT JOIN (
+			SELECT oi.product_id, SUM(oi.quantity) AS sold
+			FROM order_items oi
+			JOIN orders o ON o.id = oi.order_id
+			WHERE o.status NOT IN ('pending', 'awaiting_payment', 'cancelled')
+			GROUP BY oi.product_id
+		) s ON s.product_id = p.id`
+	}
+	if q.Cursor != "" {
+		cursor, err := decodeProductCursor(q.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if key.desc {
+			op = "<"
+		}
+		where = append(where, fmt.Sprintf("(%s, p.id) %s (%s::%s, %s)", key.expr, op, arg(cursor.Key), key.typ, arg(cursor.ID)))
+		conditions = " WHERE " + strings.Join(where, " AND ")
+	}
+	direction := "ASC"
+	if key.desc {
+		direction = "DESC"
+	}
+
+	query := `
+		SELECT ` + productColumns + `,
+			   (` + key.expr + `)::text` + from + conditions + `
+		ORDER BY ` + key.expr + ` ` + direction + `, p.id ` + direction + `
+		LIMIT ` + arg(q.Limit+1)
 	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	return r.scanProducts(rows)
+	var lastKey string
+	for rows.Next() {
+		if len(page.Products) == q.Limit {
+			last := page.Products[len(page.Products)-1]
+			page.NextCursor = encodeProductCursor(productCursor{Sort: sort, Key: lastKey, ID: last.ID})
+			break
+		}
+		product, err := r.scanProduct(rows, &lastKey)
+		if err != nil {
+			return nil, err
+		}
+		page.Products = append(page.Products, *product)
+	}
+	return page, rows.Err()
 }
 
 func (r *ProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.slug, p.category_id, c.name, c.slug, p.description, 
-			   p.short_description, p.base_price, p.images, p.options, p.features, 
-			   p.turnaround, p.min_quantity, p.created_at, p.updated_at
+		SELECT ` + productColumns + `
 		FROM products p
 		JOIN categories c ON c.id = p.category_id
 		WHERE p.id = $1
@@ -92,9 +248,7 @@ func (r *ProductRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.
 
 func (r *ProductRepository) GetBySlug(ctx context.Context, slug string) (*models.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.slug, p.category_id, c.name, c.slug, p.description, 
-			   p.short_description, p.base_price, p.images, p.options, p.features, 
-			   p.turnaround, p.min_quantity, p.created_at, p.updated_at
+		SELECT ` + productColumns + `
 		FROM products p
 		JOIN categories c ON c.id = p.category_id
 		WHERE p.slug = $1
@@ -113,11 +267,50 @@ func (r *ProductRepository) GetBySlug(ctx context.Context, slug string) (*models
 	return product, nil
 }
 
+// Select returns the products matching all of the given IDs, category and name
+// search that are set, with their pricing tiers. Deleted products are left out.
+func (r *ProductRepository) Select(ctx context.Context, ids []uuid.UUID, categoryID *uuid.UUID, search string) ([]models.Product, error) {
+	query := `
+		SELECT ` + productColumns + `
+		FROM products p
+		JOIN categories c ON c.id = p.category_id
+		WHERE p.deleted_at IS NULL
+		  AND (cardinality($1::uuid[]) = 0 OR p.id = ANY($1))
+		  AND ($2::uuid IS NULL OR p.category_id IN (` + fmt.Sprintf(categorySubtree, "id = $2") + `))
+		  AND ($3 = '' OR p.name ILIKE '%' || $3 || '%')
+		ORDER BY p.name
+	`
+	if ids == nil {
+		ids = []uuid.UUID{}
+	}
+	rows, err := r.db.Query(ctx, query, ids, categoryID, search)
+	if err != nil {
+		return nil, err
+	}
+	products, err := r.scanProducts(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range products {
+		tiers, err := r.pricingRepo.GetPricingTiers(ctx, products[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		products[i].PricingTiers = tiers
+	}
+	return products, nil
+}
+
+// Update saves a product's details. The base price is left as it is: it is only
+// changed through PriceChangeRepository, which records each change in the price
+// history and cannot be undone by an edit made from an older copy of the product.
 func (r *ProductRepository) Update(ctx context.Context, product *models.Product) error {
 	query := `
 		UPDATE products SET name = $2, slug = $3, category_id = $4, description = $5,
-			short_description = $6, base_price = $7, images = $8, options = $9, features = $10,
-			turnaround = $11, min_quantity = $12, updated_at = $13
+			short_description = $6, images = $7, options = $8, features = $9,
+			turnaround = $10, min_quantity = $11, tax_exempt = $12, cost_price = $13, status = $14, updated_at = $15
 		WHERE id = $1
 	`
 	product.UpdatedAt = time.Now()
@@ -127,26 +320,95 @@ func (r *ProductRepository) Update(ctx context.Context, product *models.Product)
 
 	_, err := r.db.Exec(ctx, query,
 		product.ID, product.Name, product.Slug, product.CategoryID, product.Description,
-		product.ShortDescription, product.BasePrice, imagesJSON, optionsJSON, featuresJSON,
-		product.Turnaround, product.MinQuantity, product.UpdatedAt,
+		product.ShortDescription, imagesJSON, optionsJSON, featuresJSON,
+		product.Turnaround, product.MinQuantity, product.TaxExempt, product.CostPrice, product.Status, product.UpdatedAt,
 	)
 	return err
 }
 
-func (r *ProductRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.Exec(ctx, `DELETE FROM products WHERE id = $1`, id)
-	return err
+// Delete hides a product from customers and from staff listings. It is kept so
+// orders for it still resolve, and can be restored. It returns false if there is
+// no such product, or it is already deleted.
+func (r *ProductRepository) Delete(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE products SET deleted_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
 }
 
-func (r *ProductRepository) scanProduct(row pgx.Row) (*models.Product, error) {
+// Restore undoes Delete, leaving the product with the status it had. It returns
+// false if there is no such deleted product.
+func (r *ProductRepository) Restore(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE products SET deleted_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL`,
+		id,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetSchedule replaces when a product is next published and archived. It returns
+// false if there is no such product, or it is deleted.
+func (r *ProductRepository) SetSchedule(ctx context.Context, id uuid.UUID, publishAt, unpublishAt *time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE products SET publish_at = $2, unpublish_at = $3, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL`,
+		id, publishAt, unpublishAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// PublishDue publishes the products whose publish time has come, and returns
+// how many there were
+func (r *ProductRepository) PublishDue(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE products SET status = 'published', publish_at = NULL, updated_at = NOW()
+		WHERE publish_at <= $1 AND deleted_at IS NULL`,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// UnpublishDue archives the products whose unpublish time has come, and returns
+// how many there were
+func (r *ProductRepository) UnpublishDue(ctx context.Context, now time.Time) (int64, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE products SET status = 'archived', unpublish_at = NULL, updated_at = NOW()
+		WHERE unpublish_at <= $1 AND deleted_at IS NULL`,
+		now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// scanProduct scans a product row, and any extra columns after it into extra
+func (r *ProductRepository) scanProduct(row pgx.Row, extra ...interface{}) (*models.Product, error) {
 	var p models.Product
 	var imagesJSON, optionsJSON, featuresJSON []byte
 
-	err := row.Scan(
+	dest := []interface{}{
 		&p.ID, &p.Name, &p.Slug, &p.CategoryID, &p.Category, &p.CategorySlug,
 		&p.Description, &p.ShortDescription, &p.BasePrice, &imagesJSON, &optionsJSON,
-		&featuresJSON, &p.Turnaround, &p.MinQuantity, &p.CreatedAt, &p.UpdatedAt,
-	)
+		&featuresJSON, &p.Turnaround, &p.MinQuantity, &p.TaxExempt, &p.CategoryTaxExempt, &p.CostPrice,
+		&p.Status, &p.PublishAt, &p.UnpublishAt, &p.DeletedAt, &p.CreatedAt, &p.UpdatedAt,
+	}
+	err := row.Scan(append(dest, extra...)...)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
@@ -169,7 +431,8 @@ func (r *ProductRepository) scanProducts(rows pgx.Rows) ([]models.Product, error
 		if err := rows.Scan(
 			&p.ID, &p.Name, &p.Slug, &p.CategoryID, &p.Category, &p.CategorySlug,
 			&p.Description, &p.ShortDescription, &p.BasePrice, &imagesJSON, &optionsJSON,
-			&featuresJSON, &p.Turnaround, &p.MinQuantity, &p.CreatedAt, &p.UpdatedAt,
+			&featuresJSON, &p.Turnaround, &p.MinQuantity, &p.TaxExempt, &p.CategoryTaxExempt, &p.CostPrice,
+			&p.Status, &p.PublishAt, &p.UnpublishAt, &p.DeletedAt, &p.CreatedAt, &p.UpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quikprint/backend/internal/models"
+)
+
+func TestUpdateLeavesBasePriceAlone(t *testing.T) {
+	db := testDB(t)
+	products := NewProductRepository(db, NewPricingRepository(db))
+	prices := NewPriceChangeRepository(db)
+	ctx := context.Background()
+	adminID := createUser(t, db, "admin")
+	productID := createPricedProduct(t, db, models.Kobo(100000), models.Kobo(90000))
+
+	// An edit loaded before the price changed is saved after it
+	stale, err := products.GetByID(ctx, productID)
+	if err != nil || stale == nil {
+		t.Fatalf("GetByID = %v, %v", stale, err)
+	}
+	if err := prices.SetPrice(ctx, productID, models.Kobo(120000), "New paper costs", adminID); err != nil {
+		t.Fatal(err)
+	}
+	stale.Name = "A5 Flyer (Gloss)"
+	if err := products.Update(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := products.GetByID(ctx, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "A5 Flyer (Gloss)" {
+		t.Errorf("name = %q, want the edit saved", got.Name)
+	}
+	if got.BasePrice.Cmp(models.Kobo(120000)) != 0 {
+		t.Errorf("base price = %s, want 1200.00 from the price change", got.BasePrice)
+	}
+	history, err := prices.GetHistory(ctx, productID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].NewPrice.Cmp(got.BasePrice) != 0 {
+		t.Errorf("history = %+v, want the one recorded change to 1200.00", history)
+	}
+}
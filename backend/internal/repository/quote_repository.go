@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type QuoteRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewQuoteRepository(db *pgxpool.Pool) *QuoteRepository {
+	return &QuoteRepository{db: db}
+}
+
+const quoteColumns = `id, quote_number, user_id, status, subtotal, COALESCE(notes, ''), share_token, expires_at, order_id,
+	created_at, updated_at`
+
+// scanQuote reads a quote, reporting an open quote past its expiry as expired
+func scanQuote(row pgx.Row) (*models.Quote, error) {
+	var q models.Quote
+	err := row.Scan(
+		&q.ID, &q.QuoteNumber, &q.UserID, &q.Status, &q.Subtotal, &q.Notes, &q.ShareToken, &q.ExpiresAt, &q.OrderID,
+		&q.CreatedAt, &q.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if q.Status == models.QuoteStatusOpen && !time.Now().Before(q.ExpiresAt) {
+		q.Status = models.QuoteStatusExpired
+	}
+	return &q, nil
+}
+
+// Create inserts the quote and its items, numbering the quote
+func (r *QuoteRepository) Create(ctx context.Context, quote *models.Quote) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	quote.ID = uuid.New()
+	quote.Status = models.QuoteStatusOpen
+	quote.CreatedAt = time.Now()
+	quote.UpdatedAt = quote.CreatedAt
+	if err := tx.QueryRow(ctx, `SELECT generate_quote_number()`).Scan(&quote.QuoteNumber); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO quotes (id, quote_number, user_id, status, subtotal, notes, share_token, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, $9, $9)`,
+		quote.ID, quote.QuoteNumber, quote.UserID, quote.Status, quote.Subtotal, quote.Notes, quote.ShareToken,
+		quote.ExpiresAt, quote.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := range quote.Items {
+		item := &quote.Items[i]
+		item.ID = uuid.New()
+		item.QuoteID = quote.ID
+		configJSON, _ := json.Marshal(item.Configuration)
+		snapshotJSON, _ := json.Marshal(item.Snapshot)
+		_, err = tx.Exec(ctx, `
+			INSERT INTO quote_items (id, quote_id, position, product_id, quantity, configuration, unit_price, total_price, snapshot)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			item.ID, quote.ID, i, item.ProductID, item.Quantity, configJSON, item.UnitPrice, item.TotalPrice, snapshotJSON,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// GetByID returns a quote with its items, or nil if there is none
+func (r *QuoteRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Quote, error) {
+	return r.getOne(ctx, `SELECT `+quoteColumns+` FROM quotes WHERE id = $1`, id)
+}
+
+// GetByShareToken returns the quote a share link points to, or nil if there is none
+func (r *QuoteRepository) GetByShareToken(ctx context.Context, token string) (*models.Quote, error) {
+	return r.getOne(ctx, `SELECT `+quoteColumns+` FROM quotes WHERE share_token = $1`, token)
+}
+
+func (r *QuoteRepository) getOne(ctx context.Context, query string, arg interface{}) (*models.Quote, error) {
+	quote, err := scanQuote(r.db.QueryRow(ctx, query, arg))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := r.getItems(ctx, quote.ID)
+	if err != nil {
+		return nil, err
+	}
+	quote.Items = items
+	return quote, nil
+}
+
+// GetByUserID lists a customer's quotes with their items, newest first
+func (r *QuoteRepository) GetByUserID(ctx context.Context, userID uuid.UUID) ([]models.Quote, error) {
+	return r.list(ctx, `SELECT `+quoteColumns+` FROM quotes WHERE user_id = $1 ORDER BY created_at DESC`, userID)
+}
+
+// GetAll lists every quote with its items, newest first, optionally only those
+// with the given status
+func (r *QuoteRepository) GetAll(ctx context.Context, status models.QuoteStatus) ([]models.Quote, error) {
+	query := `SELECT ` + quoteColumns + ` FROM quotes`
+	switch status {
+	case models.QuoteStatusOpen:
+		query += ` WHERE status = 'open' AND expires_at > NOW()`
+	case models.QuoteStatusExpired:
+		query += ` WHERE status = 'open' AND expires_at <= NOW()`
+	case models.QuoteStatusConverted:
+		query += ` WHERE status = 'converted'`
+	}
+	return r.list(ctx, query+` ORDER BY created_at DESC`)
+}
+
+func (r *QuoteRepository) list(ctx context.Context, query string, args ...interface{}) ([]models.Quote, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []models.Quote
+	for rows.Next() {
+		quote, err := scanQuote(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, *quote)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range quotes {
+		items, err := r.getItems(ctx, quotes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		quotes[i].Items = items
+	}
+	return quotes, nil
+}
+
+func (r *QuoteRepository) getItems(ctx context.Context, quoteID uuid.UUID) ([]models.QuoteItem, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, quote_id, product_id, quantity, configuration, unit_price, total_price, snapshot
+		FROM quote_items WHERE quote_id = $1 ORDER BY position`,
+		quoteID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.QuoteItem{}
+	for rows.Next() {
+		var item models.QuoteItem
+		var configJSON, snapshotJSON []byte
+		if err := rows.Scan(
+			&item.ID, &item.QuoteID, &item.ProductID, &item.Quantity,
+			&configJSON, &item.UnitPrice, &item.TotalPrice, &snapshotJSON,
+		); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(configJSON, &item.Configuration)
+		item.Snapshot = &models.OrderItemSnapshot{}
+		json.Unmarshal(snapshotJSON, item.Snapshot)
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// LoadOverrides fills in the price overrides of each of the quote's items
+func (r *QuoteRepository) LoadOverrides(ctx context.Context, quote *models.Quote) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT o.id, o.quote_item_id, o.previous_price, o.price, o.reason, o.created_by, o.created_at
+		FROM quote_price_overrides o
+		JOIN quote_items i ON i.id = o.quote_item_id
+		WHERE i.quote_id = $1
+		ORDER BY o.created_at`,
+		quote.ID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	byItem := make(map[uuid.UUID][]models.QuotePriceOverride)
+	for rows.Next() {
+		var o models.QuotePriceOverride
+		if err := rows.Scan(&o.ID, &o.QuoteItemID, &o.PreviousPrice, &o.Price, &o.Reason, &o.CreatedBy, &o.CreatedAt); err != nil {
+			return err
+		}
+		byItem[o.QuoteItemID] = append(byItem[o.QuoteItemID], o)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i := range quote.Items {
+		quote.Items[i].Overrides = byItem[quote.Items[i].ID]
+	}
+	return nil
+}
+
+// OverridePrice sets the total price of a line on an open quote, records the
+// change, reprices the line's snapshot to match and updates the quote subtotal. The quote row is locked so the change
+// cannot race with the quote being ordered. It returns false if the quote has no
+// such item.
+func (r *QuoteRepository) OverridePrice(ctx context.Context, quoteID, itemID uuid.UUID, price models.Money, reason string, actorID uuid.UUID) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	quote, err := scanQuote(tx.QueryRow(ctx, `SELECT `+quoteColumns+` FROM quotes WHERE id = $1 FOR UPDATE`, quoteID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := quote.Convertible(time.Now()); err != nil {
+		return false, err
+	}
+
+	var previous models.Money
+	var quantity int
+	var snapshotJSON []byte
+	err = tx.QueryRow(ctx,
+		`SELECT total_price, quantity, snapshot FROM quote_items WHERE id = $1 AND quote_id = $2`,
+		itemID, quoteID,
+	).Scan(&previous, &quantity, &snapshotJSON)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	override := models.QuotePriceOverride{
+		ID:            uuid.New(),
+		QuoteItemID:   itemID,
+		PreviousPrice: previous,
+		Price:         price,
+		Reason:        reason,
+		CreatedBy:     actorID,
+		CreatedAt:     time.Now(),
+	}
+	snapshot := &models.OrderItemSnapshot{}
+	if err := json.Unmarshal(snapshotJSON, snapshot); err != nil {
+		return false, err
+	}
+	snapshotJSON, err = json.Marshal(snapshot.WithOverride(override))
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE quote_items SET total_price = $1, unit_price = $2, snapshot = $3 WHERE id = $4`,
+		price, price.Div(quantity), snapshotJSON, itemID,
+	)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE quotes SET subtotal = (SELECT SUM(total_price) FROM quote_items WHERE quote_id = $1), updated_at = NOW()
+		WHERE id = $1`,
+		quoteID,
+	)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO quote_price_overrides (id, quote_item_id, previous_price, price, reason, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		override.ID, override.QuoteItemID, override.PreviousPrice, override.Price, override.Reason, override.CreatedBy, override.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// markQuoteConverted records that a quote was ordered, as part of the order's
+// transaction. It fails if the quote has been ordered or has expired meanwhile.
+func markQuoteConverted(ctx context.Context, tx pgx.Tx, quoteID, orderID uuid.UUID) error {
+	var status models.QuoteStatus
+	var expiresAt time.Time
+	err := tx.QueryRow(ctx, `SELECT status, expires_at FROM quotes WHERE id = $1 FOR UPDATE`, quoteID).Scan(&status, &expiresAt)
+	if err != nil {
+		return err
+	}
+	quote := models.Quote{Status: status, ExpiresAt: expiresAt}
+	if err := quote.Convertible(time.Now()); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx,
+		`UPDATE quotes SET status = 'converted', order_id = $1, updated_at = NOW() WHERE id = $2`,
+		orderID, quoteID,
+	)
+	return err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+func createUser(t *testing.T, db *pgxpool.Pool, role string) uuid.UUID {
+	t.Helper()
+	id := uuid.New()
+	if _, err := db.Exec(context.Background(), `
+		INSERT INTO users (id, email, password_hash, first_name, last_name, role)
+		VALUES ($1, $2, 'x', 'Ada', 'Obi', $3)`,
+		id, id.String()+"@example.com", role); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return id
+}
+
+func TestOverridePriceRepricesSnapshot(t *testing.T) {
+	db := testDB(t)
+	repo := NewQuoteRepository(db)
+	ctx := context.Background()
+	customerID, adminID := createUser(t, db, "customer"), createUser(t, db, "admin")
+	productID := createPricedProduct(t, db, models.Kobo(10000), models.Kobo(9500))
+
+	quote := &models.Quote{
+		UserID:     customerID,
+		Subtotal:   models.Kobo(1050000),
+		ShareToken: uuid.NewString(),
+		ExpiresAt:  time.Now().Add(time.Hour),
+		Items: []models.QuoteItem{{
+			ProductID:  productID,
+			Quantity:   100,
+			UnitPrice:  models.Kobo(10500),
+			TotalPrice: models.Kobo(1050000),
+			Snapshot: &models.OrderItemSnapshot{
+				ProductName: "A5 Flyer",
+				Breakdown:   &models.PriceBreakdown{Subtotal: models.Kobo(1000000), Total: models.Kobo(1050000)},
+			},
+		}},
+	}
+	if err := repo.Create(ctx, quote); err != nil {
+		t.Fatalf("create quote: %v", err)
+	}
+	itemID := quote.Items[0].ID
+
+	found, err := repo.OverridePrice(ctx, quote.ID, itemID, models.Kobo(900000), "Repeat customer", adminID)
+	if err != nil || !found {
+		t.Fatalf("OverridePrice = %v, %v", found, err)
+	}
+
+	got, err := repo.GetByID(ctx, quote.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetByID = %v, %v", got, err)
+	}
+	item := got.Items[0]
+	if item.TotalPrice.Cmp(models.Kobo(900000)) != 0 || got.Subtotal.Cmp(models.Kobo(900000)) != 0 {
+		t.Errorf("item %s, quote subtotal %s; want both 9000.00", item.TotalPrice, got.Subtotal)
+	}
+	breakdown := item.Snapshot.Breakdown
+	if breakdown.Total.Cmp(models.Kobo(900000)) != 0 || breakdown.Subtotal.Cmp(models.Kobo(900000)) != 0 {
+		t.Errorf("snapshot breakdown = %s subtotal, %s total; want both 9000.00", breakdown.Subtotal, breakdown.Total)
+	}
+	override := item.Snapshot.Override
+	if override == nil || override.PreviousPrice.Cmp(models.Kobo(1050000)) != 0 || override.CreatedBy != adminID {
+		t.Errorf("snapshot override = %+v, want the previous price and actor", override)
+	}
+
+	if err := repo.LoadOverrides(ctx, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items[0].Overrides) != 1 || got.Items[0].Overrides[0].ID != override.ID {
+		t.Errorf("overrides = %+v, want the one recorded in the snapshot", got.Items[0].Overrides)
+	}
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+// reconciliationLockKey is the advisory lock that keeps reconciliation runs from
+// overlapping across API instances and the reconcile command
+const reconciliationLockKey = 730841
+
+type ReconciliationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReconciliationRepository(db *pgxpool.Pool) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+// TryLock takes the reconciliation lock if it is free. The lock belongs to a
+// dedicated connection, which release unlocks and returns to the pool.
+func (r *ReconciliationRepository) TryLock(ctx context.Context) (release func(), acquired bool, err error) {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, reconciliationLockKey).Scan(&acquired); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	return func() {
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, reconciliationLockKey); err != nil {
+			// Closing the connection drops the lock
+			conn.Conn().Close(context.Background())
+		}
+		conn.Release()
+	}, true, nil
+}
+
+func (r *ReconciliationRepository) CreateRun(ctx context.Context, trigger string) (*models.ReconciliationRun, error) {
+	run := &models.ReconciliationRun{
+		ID:        uuid.New(),
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+	}
+	_, err := r.db.Exec(ctx,
+		`INSERT INTO reconciliation_runs (id, trigger, started_at) VALUES ($1, $2, $3)`,
+		run.ID, run.Trigger, run.StartedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// FinishRun stores the run's totals and marks it finished
+func (r *ReconciliationRepository) FinishRun(ctx context.Context, run *models.ReconciliationRun) error {
+	now := time.Now()
+	run.FinishedAt = &now
+	query := `
+		UPDATE reconciliation_runs SET
+			checked = $2, settled = $3, failed = $4, expired = $5, pending = $6, mismatches = $7, errors = $8,
+			finished_at = $9
+		WHERE id = $1`
+	_, err := r.db.Exec(ctx, query,
+		run.ID, run.Checked, run.Settled, run.Failed, run.Expired, run.Pending, run.Mismatches, run.Errors, run.FinishedAt,
+	)
+	return err
+}
+
+func (r *ReconciliationRepository) AddItem(ctx context.Context, item *models.ReconciliationItem) error {
+	item.ID = uuid.New()
+	item.CreatedAt = time.Now()
+	query := `
+		INSERT INTO reconciliation_items (id, run_id, payment_id, order_id, provider, reference, outcome, provider_status,
+			expected_amount, provider_amount, currency, detail, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+	_, err := r.db.Exec(ctx, query,
+		item.ID, item.RunID, item.PaymentID, item.OrderID, item.Provider, item.Reference, item.Outcome, item.ProviderStatus,
+		item.ExpectedAmount, item.ProviderAmount, item.Currency, item.Detail, item.CreatedAt,
+	)
+	return err
+}
+
+const reconciliationRunColumns = `id, trigger, checked, settled, failed, expired, pending, mismatches, errors, started_at, finished_at`
+
+func scanReconciliationRun(row pgx.Row) (*models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	err := row.Scan(
+		&run.ID, &run.Trigger, &run.Checked, &run.Settled, &run.Failed, &run.Expired, &run.Pending, &run.Mismatches,
+		&run.Errors, &run.StartedAt, &run.FinishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetRuns returns runs newest first, without their items
+func (r *ReconciliationRepository) GetRuns(ctx context.Context, limit, offset int) ([]models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM reconciliation_runs ORDER BY started_at DESC LIMIT $1 OFFSET $2`
+	rows, err := r.db.Query(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.ReconciliationRun
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *run)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun returns a run with its items, optionally only those with the given outcome
+func (r *ReconciliationRepository) GetRun(ctx context.Context, id uuid.UUID, outcome string) (*models.ReconciliationRun, error) {
+	query := `SELECT ` + reconciliationRunColumns + ` FROM reconciliation_runs WHERE id = $1`
+	run, err := scanReconciliationRun(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	itemsQuery := `
+		SELECT id, run_id, payment_id, order_id, provider, reference, outcome, COALESCE(provider_status, ''),
+			expected_amount, provider_amount, COALESCE(currency, ''), COALESCE(detail, ''), created_at
+		FROM reconciliation_items
+		WHERE run_id = $1 AND ($2 = '' OR outcome = $2)
+		ORDER BY created_at`
+	rows, err := r.db.Query(ctx, itemsQuery, id, outcome)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	run.Items = []models.ReconciliationItem{}
+	for rows.Next() {
+		var item models.ReconciliationItem
+		err := rows.Scan(
+			&item.ID, &item.RunID, &item.PaymentID, &item.OrderID, &item.Provider, &item.Reference, &item.Outcome,
+			&item.ProviderStatus, &item.ExpectedAmount, &item.ProviderAmount, &item.Currency, &item.Detail, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		run.Items = append(run.Items, item)
+	}
+	return run, rows.Err()
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type RefundRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRefundRepository(db *pgxpool.Pool) *RefundRepository {
+	return &RefundRepository{db: db}
+}
+
+const refundColumns = `id, payment_id, order_id, provider, COALESCE(provider_refund_id, ''), amount, currency, reason, status,
+	COALESCE(provider_response::text, ''), error, created_by, created_at, updated_at, processed_at`
+
+func scanRefund(row pgx.Row) (*models.Refund, error) {
+	var rf models.Refund
+	err := row.Scan(
+		&rf.ID, &rf.PaymentID, &rf.OrderID, &rf.Provider, &rf.ProviderRefundID, &rf.Amount, &rf.Currency, &rf.Reason, &rf.Status,
+		&rf.ProviderResponse, &rf.Error, &rf.CreatedBy, &rf.CreatedAt, &rf.UpdatedAt, &rf.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rf, nil
+}
+
+// Reserve records a new refund against a payment. The payment row is locked while
+// the amount already refunded is totalled, so concurrent refunds cannot exceed the
+// payment. A zero amount refunds whatever is left. Failed refunds do not count.
+func (r *RefundRepository) Reserve(ctx context.Context, refund *models.Refund) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var paid models.Money
+	var status models.PaymentStatus
+	err = tx.QueryRow(ctx, `SELECT amount, status FROM payments WHERE id = $1 FOR UPDATE`, refund.PaymentID).Scan(&paid, &status)
+	if err != nil {
+		return err
+	}
+	if status != models.PaymentStatusSuccess && status != models.PaymentStatusPartiallyRefunded {
+		return models.ErrPaymentNotRefundable
+	}
+
+	var refunded models.Money
+	err = tx.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status <> 'failed'`,
+		refund.PaymentID,
+	).Scan(&refunded)
+	if err != nil {
+		return err
+	}
+
+	remaining := paid.Sub(refunded)
+	if refund.Amount.IsZero() {
+		refund.Amount = remaining
+	}
+	if !remaining.IsPositive() || refund.Amount.GreaterThan(remaining) {
+		return models.ErrRefundExceedsPayment
+	}
+
+	refund.ID = uuid.New()
+	refund.CreatedAt = time.Now()
+	refund.UpdatedAt = refund.CreatedAt
+	if refund.Status == "" {
+		refund.Status = models.RefundStatusPending
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO refunds (id, payment_id, order_id, provider, amount, currency, reason, status, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		refund.ID, refund.PaymentID, refund.OrderID, refund.Provider, refund.Amount, refund.Currency, refund.Reason,
+		refund.Status, refund.CreatedBy, refund.CreatedAt, refund.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SetResult records the provider's answer for a refund. providerRefundID and
+// response are left unchanged when empty.
+func (r *RefundRepository) SetResult(ctx context.Context, id uuid.UUID, status models.RefundStatus, providerRefundID, response, errMsg string) error {
+	var refundID, resp, errVal *string
+	if providerRefundID != "" {
+		refundID = &providerRefundID
+	}
+	if response != "" {
+		resp = &response
+	}
+	if errMsg != "" {
+		errVal = &errMsg
+	}
+
+	var processedAt *time.Time
+	if status == models.RefundStatusProcessed {
+		now := time.Now()
+		processedAt = &now
+	}
+
+	query := `
+		UPDATE refunds SET
+			status = $2,
+			provider_refund_id = COALESCE($3, provider_refund_id),
+			provider_response = COALESCE($4::jsonb, provider_response),
+			error = $5,
+			processed_at = COALESCE(processed_at, $6),
+			updated_at = $7
+		WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, status, refundID, resp, errVal, processedAt, time.Now())
+	return err
+}
+
+func (r *RefundRepository) GetByProviderRefundID(ctx context.Context, provider, providerRefundID string) (*models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE provider = $1 AND provider_refund_id = $2`
+	refund, err := scanRefund(r.db.QueryRow(ctx, query, provider, providerRefundID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return refund, err
+}
+
+// ClaimUnmatched attaches a provider refund ID to the oldest pending refund of the
+// same amount that has none yet. This covers a webhook arriving before the refund
+// API call that created it has returned.
+func (r *RefundRepository) ClaimUnmatched(ctx context.Context, paymentID uuid.UUID, amount models.Money, providerRefundID string) (*models.Refund, error) {
+	query := `
+		UPDATE refunds SET provider_refund_id = $3, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM refunds
+			WHERE payment_id = $1 AND provider_refund_id IS NULL AND status = 'pending' AND amount = $2
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + refundColumns
+	refund, err := scanRefund(r.db.QueryRow(ctx, query, paymentID, amount, providerRefundID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return refund, err
+}
+
+func (r *RefundRepository) GetByPaymentID(ctx context.Context, paymentID uuid.UUID) ([]models.Refund, error) {
+	query := `SELECT ` + refundColumns + ` FROM refunds WHERE payment_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(ctx, query, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []models.Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, *refund)
+	}
+	return refunds, rows.Err()
+}
+
+// RefundedAmount totals the refunds of a payment that have not failed
+func (r *RefundRepository) RefundedAmount(ctx context.Context, paymentID uuid.UUID) (models.Money, error) {
+	var refunded models.Money
+	err := r.db.QueryRow(ctx,
+		`SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE payment_id = $1 AND status <> 'failed'`,
+		paymentID,
+	).Scan(&refunded)
+	return refunded, err
+}
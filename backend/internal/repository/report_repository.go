@@ -16,17 +16,28 @@ func NewReportRepository(db *pgxpool.Pool) *ReportRepository {
 	return &ReportRepository{db: db}
 }
 
+// refundedByOrder totals the refunds of each order that have not failed; sales
+// reports are net of them
+const refundedByOrder = `
+		LEFT JOIN (
+			SELECT order_id, SUM(amount) as refunded
+			FROM refunds
+			WHERE status <> 'failed'
+			GROUP BY order_id
+		) r ON r.order_id = o.id`
+
 func (r *ReportRepository) GetDailySales(ctx context.Context, days int) ([]models.DailySalesReport, error) {
 	query := `
 		SELECT 
-			DATE(created_at) as date,
+			DATE(o.created_at) as date,
 			COUNT(*) as order_count,
-			COALESCE(SUM(total), 0) as total_sales,
-			COALESCE(AVG(total), 0) as avg_order_value
-		FROM orders
-		WHERE created_at >= CURRENT_DATE - $1::interval
-		AND status NOT IN ('cancelled')
-		GROUP BY DATE(created_at)
+			COALESCE(SUM(o.total - COALESCE(r.refunded, 0)), 0) as total_sales,
+			COALESCE(AVG(o.total - COALESCE(r.refunded, 0)), 0) as avg_order_value,
+			COALESCE(SUM(r.refunded), 0) as refunded
+		FROM orders o` + refundedByOrder + `
+		WHERE o.created_at >= CURRENT_DATE - $1::interval
+		AND o.status NOT IN ('cancelled')
+		GROUP BY DATE(o.created_at)
 		ORDER BY date DESC
 	`
 	rows, err := r.db.Query(ctx, query, time.Duration(days)*24*time.Hour)
@@ -38,7 +49,7 @@ func (r *ReportRepository) GetDailySales(ctx context.Context, days int) ([]model
 	var reports []models.DailySalesReport
 	for rows.Next() {
 		var report models.DailySalesReport
-		if err := rows.Scan(&report.Date, &report.OrderCount, &report.TotalSales, &report.AvgOrderVal); err != nil {
+		if err := rows.Scan(&report.Date, &report.OrderCount, &report.TotalSales, &report.AvgOrderVal, &report.Refunded); err != nil {
 			return nil, err
 		}
 		reports = append(reports, report)
@@ -49,15 +60,16 @@ func (r *ReportRepository) GetDailySales(ctx context.Context, days int) ([]model
 func (r *ReportRepository) GetWeeklySales(ctx context.Context, weeks int) ([]models.WeeklySalesReport, error) {
 	query := `
 		SELECT 
-			DATE_TRUNC('week', created_at) as week_start,
-			DATE_TRUNC('week', created_at) + INTERVAL '6 days' as week_end,
+			DATE_TRUNC('week', o.created_at) as week_start,
+			DATE_TRUNC('week', o.created_at) + INTERVAL '6 days' as week_end,
 			COUNT(*) as order_count,
-			COALESCE(SUM(total), 0) as total_sales,
-			COALESCE(AVG(total), 0) as avg_order_value
-		FROM orders
-		WHERE created_at >= CURRENT_DATE - $1::interval
-		AND status NOT IN ('cancelled')
-		GROUP BY DATE_TRUNC('week', created_at)
+			COALESCE(SUM(o.total - COALESCE(r.refunded, 0)), 0) as total_sales,
+			COALESCE(AVG(o.total - COALESCE(r.refunded, 0)), 0) as avg_order_value,
+			COALESCE(SUM(r.refunded), 0) as refunded
+		FROM orders o` + refundedByOrder + `
+		WHERE o.created_at >= CURRENT_DATE - $1::interval
+		AND o.status NOT IN ('cancelled')
+		GROUP BY DATE_TRUNC('week', o.created_at)
 		ORDER BY week_start DESC
 	`
 	rows, err := r.db.Query(ctx, query, time.Duration(weeks*7)*24*time.Hour)
@@ -69,7 +81,7 @@ func (r *ReportRepository) GetWeeklySales(ctx context.Context, weeks int) ([]mod
 	var reports []models.WeeklySalesReport
 	for rows.Next() {
 		var report models.WeeklySalesReport
-		if err := rows.Scan(&report.WeekStart, &report.WeekEnd, &report.OrderCount, &report.TotalSales, &report.AvgOrderVal); err != nil {
+		if err := rows.Scan(&report.WeekStart, &report.WeekEnd, &report.OrderCount, &report.TotalSales, &report.AvgOrderVal, &report.Refunded); err != nil {
 			return nil, err
 		}
 		reports = append(reports, report)
@@ -104,3 +116,46 @@ func (r *ReportRepository) GetOrdersByStatus(ctx context.Context) ([]models.Orde
 	return reports, nil
 }
 
+// paidOrderStatuses are the statuses of orders that have been paid for
+const paidOrderStatuses = `('paid', 'processing', 'printing', 'ready', 'shipped', 'delivered')`
+
+// GetVATSummary totals the VAT on paid orders placed in [from, to) by rate and exemption
+func (r *ReportRepository) GetVATSummary(ctx context.Context, from, to time.Time) (*models.VATReport, error) {
+	report := &models.VATReport{From: from, To: to, Lines: []models.VATReportLine{}}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM orders
+		WHERE created_at >= $1 AND created_at < $2 AND status IN `+paidOrderStatuses,
+		from, to,
+	).Scan(&report.OrderCount)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT oi.tax_rate, COALESCE(oi.tax_exemption, ''), COUNT(*),
+			COALESCE(SUM(oi.net_amount), 0), COALESCE(SUM(oi.tax), 0)
+		FROM order_items oi
+		JOIN orders o ON o.id = oi.order_id
+		WHERE o.created_at >= $1 AND o.created_at < $2 AND o.status IN `+paidOrderStatuses+`
+		GROUP BY oi.tax_rate, COALESCE(oi.tax_exemption, '')
+		ORDER BY oi.tax_rate DESC, 2`,
+		from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.VATReportLine
+		if err := rows.Scan(&line.TaxRate, &line.Exemption, &line.ItemCount, &line.NetAmount, &line.Tax); err != nil {
+			return nil, err
+		}
+		report.Lines = append(report.Lines, line)
+		report.NetAmount = report.NetAmount.Add(line.NetAmount)
+		report.Tax = report.Tax.Add(line.Tax)
+	}
+	return report, rows.Err()
+}
+
@@ -41,8 +41,8 @@ func (r *ShippingConfigRepository) Get(ctx context.Context) (*models.ShippingCon
 		if errors.Is(err, pgx.ErrNoRows) || err.Error() == "no rows in result set" {
 			return &models.ShippingConfig{
 				ID:                    uuid.New(),
-				ShippingFee:           5000.00,
-				FreeShippingThreshold: 50000.00,
+				ShippingFee:           models.Kobo(500000),
+				FreeShippingThreshold: models.Kobo(5000000),
 				CreatedAt:             time.Now().Format(time.RFC3339),
 				UpdatedAt:             time.Now().Format(time.RFC3339),
 			}, nil
@@ -51,8 +51,8 @@ func (r *ShippingConfigRepository) Get(ctx context.Context) (*models.ShippingCon
 		// This prevents the API from crashing if migrations haven't been run
 		return &models.ShippingConfig{
 			ID:                    uuid.New(),
-			ShippingFee:           5000.00,
-			FreeShippingThreshold: 50000.00,
+			ShippingFee:           models.Kobo(500000),
+			FreeShippingThreshold: models.Kobo(5000000),
 			CreatedAt:             time.Now().Format(time.RFC3339),
 			UpdatedAt:             time.Now().Format(time.RFC3339),
 		}, nil
@@ -62,7 +62,7 @@ func (r *ShippingConfigRepository) Get(ctx context.Context) (*models.ShippingCon
 }
 
 // Update updates the shipping configuration
-func (r *ShippingConfigRepository) Update(ctx context.Context, id uuid.UUID, shippingFee, freeShippingThreshold float64) error {
+func (r *ShippingConfigRepository) Update(ctx context.Context, id uuid.UUID, shippingFee, freeShippingThreshold models.Money) error {
 	query := `
 		UPDATE shipping_config
 		SET shipping_fee = $1,
@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type TaxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTaxRepository(db *pgxpool.Pool) *TaxRepository {
+	return &TaxRepository{db: db}
+}
+
+// GetSettings returns the tax settings, or the defaults (prices exclude tax) if
+// none have been saved
+func (r *TaxRepository) GetSettings(ctx context.Context) (*models.TaxSettings, error) {
+	var settings models.TaxSettings
+	err := r.db.QueryRow(ctx,
+		`SELECT id, prices_include_tax, created_at, updated_at FROM tax_settings LIMIT 1`,
+	).Scan(&settings.ID, &settings.PricesIncludeTax, &settings.CreatedAt, &settings.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		now := time.Now()
+		return &models.TaxSettings{ID: uuid.New(), CreatedAt: now, UpdatedAt: now}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSettings saves whether prices include tax
+func (r *TaxRepository) UpdateSettings(ctx context.Context, pricesIncludeTax bool) error {
+	tag, err := r.db.Exec(ctx,
+		`UPDATE tax_settings SET prices_include_tax = $1, updated_at = NOW()`,
+		pricesIncludeTax,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		_, err = r.db.Exec(ctx, `INSERT INTO tax_settings (prices_include_tax) VALUES ($1)`, pricesIncludeTax)
+	}
+	return err
+}
+
+const taxRateColumns = `id, name, rate, effective_from, created_at, updated_at`
+
+func scanTaxRate(row pgx.Row) (*models.TaxRate, error) {
+	var rate models.TaxRate
+	err := row.Scan(&rate.ID, &rate.Name, &rate.Rate, &rate.EffectiveFrom, &rate.CreatedAt, &rate.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+// GetRates lists every tax rate, latest first
+func (r *TaxRepository) GetRates(ctx context.Context) ([]models.TaxRate, error) {
+	rows, err := r.db.Query(ctx, `SELECT `+taxRateColumns+` FROM tax_rates ORDER BY effective_from DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.TaxRate
+	for rows.Next() {
+		var rate models.TaxRate
+		if err := rows.Scan(&rate.ID, &rate.Name, &rate.Rate, &rate.EffectiveFrom, &rate.CreatedAt, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+// GetRateByID returns a tax rate, or nil if there is none
+func (r *TaxRepository) GetRateByID(ctx context.Context, id uuid.UUID) (*models.TaxRate, error) {
+	return scanTaxRate(r.db.QueryRow(ctx, `SELECT `+taxRateColumns+` FROM tax_rates WHERE id = $1`, id))
+}
+
+// GetRateByEffectiveFrom returns the tax rate that starts at exactly t, or nil
+func (r *TaxRepository) GetRateByEffectiveFrom(ctx context.Context, t time.Time) (*models.TaxRate, error) {
+	return scanTaxRate(r.db.QueryRow(ctx, `SELECT `+taxRateColumns+` FROM tax_rates WHERE effective_from = $1`, t))
+}
+
+// RateAt returns the tax rate in force at t, or nil if no rate had started by then
+func (r *TaxRepository) RateAt(ctx context.Context, t time.Time) (*models.TaxRate, error) {
+	return scanTaxRate(r.db.QueryRow(ctx, `
+		SELECT `+taxRateColumns+` FROM tax_rates
+		WHERE effective_from <= $1
+		ORDER BY effective_from DESC LIMIT 1`,
+		t,
+	))
+}
+
+func (r *TaxRepository) CreateRate(ctx context.Context, rate *models.TaxRate) error {
+	rate.ID = uuid.New()
+	rate.CreatedAt = time.Now()
+	rate.UpdatedAt = rate.CreatedAt
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO tax_rates (id, name, rate, effective_from, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`,
+		rate.ID, rate.Name, rate.Rate, rate.EffectiveFrom, rate.CreatedAt,
+	)
+	return err
+}
+
+// UpdateRate replaces a tax rate. It returns false if there is no such rate.
+func (r *TaxRepository) UpdateRate(ctx context.Context, rate *models.TaxRate) (bool, error) {
+	rate.UpdatedAt = time.Now()
+	tag, err := r.db.Exec(ctx, `
+		UPDATE tax_rates SET name = $2, rate = $3, effective_from = $4, updated_at = $5
+		WHERE id = $1`,
+		rate.ID, rate.Name, rate.Rate, rate.EffectiveFrom, rate.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteRate deletes a tax rate. It returns false if there is no such rate.
+func (r *TaxRepository) DeleteRate(ctx context.Context, id uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM tax_rates WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetCustomerProfile returns a customer's tax details, or nil if none are on file
+func (r *TaxRepository) GetCustomerProfile(ctx context.Context, userID uuid.UUID) (*models.CustomerTaxProfile, error) {
+	var p models.CustomerTaxProfile
+	err := r.db.QueryRow(ctx, `
+		SELECT user_id, COALESCE(company_name, ''), tin, tax_exempt, updated_by, created_at, updated_at
+		FROM customer_tax_profiles WHERE user_id = $1`,
+		userID,
+	).Scan(&p.UserID, &p.CompanyName, &p.TIN, &p.TaxExempt, &p.UpdatedBy, &p.CreatedAt, &p.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveCustomerProfile creates or replaces a customer's tax details
+func (r *TaxRepository) SaveCustomerProfile(ctx context.Context, p *models.CustomerTaxProfile) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO customer_tax_profiles (user_id, company_name, tin, tax_exempt, updated_by, created_at, updated_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, NOW(), NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			company_name = EXCLUDED.company_name, tin = EXCLUDED.tin, tax_exempt = EXCLUDED.tax_exempt,
+			updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING created_at, updated_at`,
+		p.UserID, p.CompanyName, p.TIN, p.TaxExempt, p.UpdatedBy,
+	).Scan(&p.CreatedAt, &p.UpdatedAt)
+}
+
+// DeleteCustomerProfile removes a customer's tax details. It returns false if
+// there were none.
+func (r *TaxRepository) DeleteCustomerProfile(ctx context.Context, userID uuid.UUID) (bool, error) {
+	tag, err := r.db.Exec(ctx, `DELETE FROM customer_tax_profiles WHERE user_id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
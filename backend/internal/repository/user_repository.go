@@ -228,9 +228,15 @@ func (r *UserRepository) CleanupExpiredTwoFactorSessions(ctx context.Context) er
 	return err
 }
 
-// GetAllCustomerEmails returns a list of all customer email addresses
+// GetAllCustomerEmails returns the email addresses of all customers who have not
+// opted out of marketing emails
 func (r *UserRepository) GetAllCustomerEmails(ctx context.Context) ([]string, error) {
-	query := `SELECT email FROM users WHERE role = 'customer' ORDER BY email`
+	query := `
+		SELECT u.email FROM users u
+		LEFT JOIN notification_preferences np ON np.user_id = u.id
+		WHERE u.role = 'customer' AND COALESCE(np.marketing, true)
+		ORDER BY u.email
+	`
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quikprint/backend/internal/models"
+)
+
+type WebhookEventRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWebhookEventRepository(db *pgxpool.Pool) *WebhookEventRepository {
+	return &WebhookEventRepository{db: db}
+}
+
+const webhookEventColumns = `id, provider, event_key, event_type, COALESCE(reference, ''), payload::text, status, error,
+	deliveries, received_at, processed_at`
+
+func scanWebhookEvent(row pgx.Row) (*models.PaymentWebhookEvent, error) {
+	var e models.PaymentWebhookEvent
+	var payload string
+	err := row.Scan(
+		&e.ID, &e.Provider, &e.EventKey, &e.EventType, &e.Reference, &payload, &e.Status, &e.Error,
+		&e.Deliveries, &e.ReceivedAt, &e.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	e.Payload = []byte(payload)
+	return &e, nil
+}
+
+// Record stores a webhook delivery. If an event with the same key was already
+// received, its delivery count is bumped and the stored event is returned with
+// duplicate set; the original payload is kept.
+func (r *WebhookEventRepository) Record(ctx context.Context, event *models.PaymentWebhookEvent) (duplicate bool, err error) {
+	query := `
+		INSERT INTO payment_webhook_events (id, provider, event_key, event_type, reference, payload, status, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (provider, event_key) DO UPDATE SET deliveries = payment_webhook_events.deliveries + 1
+		RETURNING ` + webhookEventColumns + `, (xmax <> 0)`
+
+	var payload string
+	err = r.db.QueryRow(ctx, query,
+		uuid.New(), event.Provider, event.EventKey, event.EventType, event.Reference, string(event.Payload),
+		models.WebhookEventReceived, time.Now(),
+	).Scan(
+		&event.ID, &event.Provider, &event.EventKey, &event.EventType, &event.Reference, &payload, &event.Status, &event.Error,
+		&event.Deliveries, &event.ReceivedAt, &event.ProcessedAt, &duplicate,
+	)
+	if err != nil {
+		return false, err
+	}
+	event.Payload = []byte(payload)
+	return duplicate, nil
+}
+
+// MarkResult records the outcome of processing an event
+func (r *WebhookEventRepository) MarkResult(ctx context.Context, id uuid.UUID, status models.WebhookEventStatus, errMsg string) error {
+	var errVal *string
+	if errMsg != "" {
+		errVal = &errMsg
+	}
+	query := `UPDATE payment_webhook_events SET status = $2, error = $3, processed_at = $4 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, id, status, errVal, time.Now())
+	return err
+}
+
+func (r *WebhookEventRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.PaymentWebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` FROM payment_webhook_events WHERE id = $1`
+	event, err := scanWebhookEvent(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return event, err
+}
+
+// GetAll returns stored events newest first, optionally filtered by status and reference
+func (r *WebhookEventRepository) GetAll(ctx context.Context, status, reference string, limit, offset int) ([]models.PaymentWebhookEvent, error) {
+	query := `SELECT ` + webhookEventColumns + ` FROM payment_webhook_events WHERE 1=1`
+	args := []interface{}{}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if reference != "" {
+		args = append(args, reference)
+		query += fmt.Sprintf(" AND reference = $%d", len(args))
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY received_at DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.PaymentWebhookEvent
+	for rows.Next() {
+		e, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
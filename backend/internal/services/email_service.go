@@ -2,16 +2,29 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"html/template"
 	"net/smtp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
 )
 
-// EmailService handles sending emails via SMTP
+// defaultEmailMaxAttempts is how many delivery attempts a queued email gets before
+// it is dead-lettered
+const defaultEmailMaxAttempts = 5
+
+// lagosTime is West Africa Time, which customer-facing times in emails are shown in
+var lagosTime = time.FixedZone("WAT", 60*60)
+
+// EmailService renders emails and queues them in the outbox. Delivery over SMTP is
+// done by the EmailWorker via Deliver.
 type EmailService struct {
 	host     string
 	port     int
@@ -19,10 +32,14 @@ type EmailService struct {
 	password string
 	from     string
 	fromName string
+	outbox   *repository.EmailOutboxRepository
+	// tlsConfig overrides the TLS settings for Deliver; nil verifies the server
+	// against the system roots
+	tlsConfig *tls.Config
 }
 
 // NewEmailService creates a new email service
-func NewEmailService(host string, port int, username, password, from, fromName string) *EmailService {
+func NewEmailService(host string, port int, username, password, from, fromName string, outbox *repository.EmailOutboxRepository) *EmailService {
 	return &EmailService{
 		host:     host,
 		port:     port,
@@ -30,6 +47,7 @@ func NewEmailService(host string, port int, username, password, from, fromName s
 		password: password,
 		from:     from,
 		fromName: fromName,
+		outbox:   outbox,
 	}
 }
 
@@ -38,12 +56,33 @@ func (s *EmailService) IsConfigured() bool {
 	return s.host != "" && s.username != "" && s.password != ""
 }
 
-// SendEmail sends an email using SMTP with TLS
+// Account identifies the SMTP account messages are sent through; the worker rate
+// limits per account
+func (s *EmailService) Account() string {
+	return s.username
+}
+
+// SendEmail queues an email for delivery by the email worker
 func (s *EmailService) SendEmail(to, subject, htmlBody string) error {
 	if !s.IsConfigured() {
 		return fmt.Errorf("SMTP not configured")
 	}
 
+	return s.outbox.Enqueue(context.Background(), &models.EmailOutboxMessage{
+		Recipient:   to,
+		Subject:     subject,
+		HTMLBody:    htmlBody,
+		SMTPAccount: s.Account(),
+		MaxAttempts: defaultEmailMaxAttempts,
+	})
+}
+
+// Deliver sends an email immediately using SMTP with TLS
+func (s *EmailService) Deliver(to, subject, htmlBody string) error {
+	if !s.IsConfigured() {
+		return fmt.Errorf("SMTP not configured")
+	}
+
 	// Build email headers
 	headers := make(map[string]string)
 	headers["From"] = fmt.Sprintf("%s <%s>", s.fromName, s.from)
@@ -60,8 +99,11 @@ func (s *EmailService) SendEmail(to, subject, htmlBody string) error {
 	msg.WriteString(htmlBody)
 
 	// Connect with TLS (Hostinger uses port 465 with SSL)
-	tlsConfig := &tls.Config{
-		ServerName: s.host,
+	tlsConfig := s.tlsConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{
+			ServerName: s.host,
+		}
 	}
 
 	conn, err := tls.Dial("tcp", fmt.Sprintf("%s:%d", s.host, s.port), tlsConfig)
@@ -107,31 +149,40 @@ func (s *EmailService) SendEmail(to, subject, htmlBody string) error {
 	return client.Quit()
 }
 
-// SendBulkEmail sends the same email to multiple recipients
-func (s *EmailService) SendBulkEmail(recipients []string, subject, htmlBody string) (int, []error) {
-	successCount := 0
-	var errors []error
-
-	for _, to := range recipients {
-		if err := s.SendEmail(to, subject, htmlBody); err != nil {
-			errors = append(errors, fmt.Errorf("failed to send to %s: %w", to, err))
-		} else {
-			successCount++
+// SendOrderConfirmation sends order confirmation email to customer
+func (s *EmailService) SendOrderConfirmation(order *models.Order, customerEmail string) error {
+	// Items are described as they were ordered, not as the products are now
+	items := make([]map[string]interface{}, len(order.Items))
+	for i, item := range order.Items {
+		var options []string
+		name := ""
+		if item.Snapshot != nil {
+			name = item.Snapshot.ProductName
+			for _, opt := range item.Snapshot.Options {
+				options = append(options, opt.Name+": "+opt.Value)
+			}
+		}
+		items[i] = map[string]interface{}{
+			"Name":     name,
+			"Options":  strings.Join(options, ", "),
+			"Quantity": item.Quantity,
+			"Total":    fmt.Sprintf("₦%s", item.TotalPrice),
 		}
 	}
 
-	return successCount, errors
-}
-
-// SendOrderConfirmation sends order confirmation email to customer
-func (s *EmailService) SendOrderConfirmation(order *models.Order, customerEmail string) error {
 	data := map[string]interface{}{
 		"OrderNumber": order.OrderNumber,
-		"Total":       fmt.Sprintf("₦%.2f", order.Total),
-		"Subtotal":    fmt.Sprintf("₦%.2f", order.Subtotal),
-		"Shipping":    fmt.Sprintf("₦%.2f", order.Shipping),
-		"VAT":         fmt.Sprintf("₦%.2f", order.Tax),
+		"Total":       fmt.Sprintf("₦%s", order.Total),
+		"Subtotal":    fmt.Sprintf("₦%s", order.Subtotal),
+		"Shipping":    fmt.Sprintf("₦%s", order.Shipping),
+		"VAT":         "",
+		"VATLabel":    vatLabel(order),
 		"ItemCount":   len(order.Items),
+		"Items":       items,
+	}
+
+	if !order.Tax.IsZero() {
+		data["VAT"] = fmt.Sprintf("₦%s", order.Tax)
 	}
 
 	html, err := s.renderTemplate("order_confirmation", data)
@@ -142,6 +193,27 @@ func (s *EmailService) SendOrderConfirmation(order *models.Order, customerEmail
 	return s.SendEmail(customerEmail, fmt.Sprintf("Order Confirmation - %s", order.OrderNumber), html)
 }
 
+// vatLabel describes the VAT on an order, with its rate when every taxed line has
+// the same one, e.g. "VAT (7.5%)" or "Includes VAT (7.5%)"
+func vatLabel(order *models.Order) string {
+	rates := make(map[float64]bool)
+	for _, item := range order.Items {
+		if item.TaxRate > 0 {
+			rates[item.TaxRate] = true
+		}
+	}
+	label := "VAT"
+	if len(rates) == 1 {
+		for rate := range rates {
+			label += fmt.Sprintf(" (%s%%)", strconv.FormatFloat(rate, 'f', -1, 64))
+		}
+	}
+	if order.PricesIncludeTax {
+		label = "Includes " + label
+	}
+	return label
+}
+
 // SendOrderStatusUpdate sends order status update email to customer
 func (s *EmailService) SendOrderStatusUpdate(order *models.Order, customerEmail, statusMessage string) error {
 	data := map[string]interface{}{
@@ -176,7 +248,7 @@ func (s *EmailService) SendWelcomeEmail(email, firstName string) error {
 func (s *EmailService) SendPaymentConfirmation(order *models.Order, customerEmail string) error {
 	data := map[string]interface{}{
 		"OrderNumber": order.OrderNumber,
-		"Total":       fmt.Sprintf("₦%.2f", order.Total),
+		"Total":       fmt.Sprintf("₦%s", order.Total),
 	}
 
 	html, err := s.renderTemplate("payment_confirmation", data)
@@ -187,8 +259,29 @@ func (s *EmailService) SendPaymentConfirmation(order *models.Order, customerEmai
 	return s.SendEmail(customerEmail, fmt.Sprintf("Payment Confirmed - %s", order.OrderNumber), html)
 }
 
-// SendBroadcast sends a broadcast email to multiple recipients
-func (s *EmailService) SendBroadcast(recipients []string, subject, content string) (int, []error) {
+// SendPaymentReminder reminds a customer to pay for an order before it is cancelled
+func (s *EmailService) SendPaymentReminder(order *models.Order, customerEmail string, dueAt time.Time) error {
+	data := map[string]interface{}{
+		"OrderNumber": order.OrderNumber,
+		"Total":       fmt.Sprintf("₦%s", order.Total),
+		"DueAt":       dueAt.In(lagosTime).Format("Monday 2 January, 3:04 PM"),
+	}
+
+	html, err := s.renderTemplate("payment_reminder", data)
+	if err != nil {
+		return err
+	}
+
+	return s.SendEmail(customerEmail, fmt.Sprintf("Payment Reminder - %s", order.OrderNumber), html)
+}
+
+// SendBroadcast renders a broadcast once and queues it for every recipient. Use the
+// returned broadcast's ID to follow delivery progress.
+func (s *EmailService) SendBroadcast(recipients []string, subject, content string, createdBy *uuid.UUID) (*models.EmailBroadcast, error) {
+	if !s.IsConfigured() {
+		return nil, fmt.Errorf("SMTP not configured")
+	}
+
 	data := map[string]interface{}{
 		"Subject": subject,
 		"Content": template.HTML(content), // Allow HTML content
@@ -196,10 +289,19 @@ func (s *EmailService) SendBroadcast(recipients []string, subject, content strin
 
 	html, err := s.renderTemplate("broadcast", data)
 	if err != nil {
-		return 0, []error{err}
+		return nil, err
+	}
+
+	broadcast := &models.EmailBroadcast{
+		Subject:   subject,
+		Content:   content,
+		CreatedBy: createdBy,
+	}
+	if err := s.outbox.EnqueueBroadcast(context.Background(), broadcast, recipients, html, s.Account(), defaultEmailMaxAttempts); err != nil {
+		return nil, err
 	}
 
-	return s.SendBulkEmail(recipients, subject, html)
+	return broadcast, nil
 }
 
 // renderTemplate renders an email template with data
@@ -25,9 +25,12 @@ var emailTemplates = map[string]string{
         <div class="order-details">
             <h3>Order #{{.OrderNumber}}</h3>
             <p><strong>Items:</strong> {{.ItemCount}} item(s)</p>
+            {{range .Items}}
+            <p>{{.Name}} &times; {{.Quantity}} &mdash; {{.Total}}{{if .Options}}<br><small>{{.Options}}</small>{{end}}</p>
+            {{end}}
             <p><strong>Subtotal:</strong> {{.Subtotal}}</p>
             <p><strong>Shipping:</strong> {{.Shipping}}</p>
-            <p><strong>VAT (7.5%):</strong> {{.VAT}}</p>
+            {{if .VAT}}<p><strong>{{.VATLabel}}:</strong> {{.VAT}}</p>{{end}}
             <hr>
             <p class="total">Total: {{.Total}}</p>
         </div>
@@ -142,6 +145,41 @@ var emailTemplates = map[string]string{
         <p>Lagos, Nigeria | info@quikprint.ng</p>
     </div>
 </body>
+</html>`,
+
+	"payment_reminder": `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="utf-8">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { background: #d97706; color: white; padding: 20px; text-align: center; border-radius: 8px 8px 0 0; }
+        .content { background: #f8fafc; padding: 20px; border: 1px solid #e2e8f0; }
+        .footer { background: #1e293b; color: #94a3b8; padding: 15px; text-align: center; border-radius: 0 0 8px 8px; font-size: 12px; }
+        .payment-box { background: white; padding: 15px; border-radius: 6px; margin: 15px 0; text-align: center; }
+        .amount { font-size: 28px; font-weight: bold; color: #d97706; }
+        .btn { display: inline-block; background: #2563eb; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px; margin-top: 15px; }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>Your Order Is Waiting for Payment</h1>
+    </div>
+    <div class="content">
+        <p>We're holding your order, but we haven't received payment for it yet.</p>
+        <div class="payment-box">
+            <p>Order #{{.OrderNumber}}</p>
+            <p class="amount">{{.Total}}</p>
+            <p>Please pay by <strong>{{.DueAt}}</strong></p>
+        </div>
+        <p>Orders that are not paid by then are cancelled automatically. If you've already paid, you can ignore this email.</p>
+        <a href="https://quikprint.ng/account" class="btn">Complete Payment</a>
+    </div>
+    <div class="footer">
+        <p>QuikPrint NG - Professional Printing Services</p>
+        <p>Lagos, Nigeria | info@quikprint.ng</p>
+    </div>
+</body>
 </html>`,
 
 	"broadcast": `<!DOCTYPE html>
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+const (
+	emailWorkerPollInterval = 5 * time.Second
+	emailRetryBaseDelay     = 30 * time.Second
+	emailRetryMaxDelay      = time.Hour
+	// A message left in sending this long belongs to a worker that died mid-send
+	emailStaleClaimAfter = 10 * time.Minute
+	emailStaleCheckEvery = time.Minute
+)
+
+// emailOutbox is the part of the outbox repository the worker uses
+type emailOutbox interface {
+	ClaimDue(ctx context.Context, limit int) ([]models.EmailOutboxMessage, error)
+	Touch(ctx context.Context, id uuid.UUID, attempts int) (bool, error)
+	MarkSent(ctx context.Context, id uuid.UUID) error
+	MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, nextAttempt *time.Time) error
+	Release(ctx context.Context, id uuid.UUID) error
+	ReleaseStale(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// EmailWorker drains the email outbox with a pool of workers. Failed deliveries are
+// retried with exponential backoff and dead-lettered after the message's
+// MaxAttempts. Sends are rate limited per SMTP account.
+type EmailWorker struct {
+	outbox        emailOutbox
+	emailService  *EmailService
+	workers       int
+	ratePerMinute int
+	limitersMu    sync.Mutex
+	limiters      map[string]*rateLimiter
+	wg            sync.WaitGroup
+}
+
+// NewEmailWorker creates a worker pool of the given size. ratePerMinute caps sends
+// per SMTP account; zero or less means unlimited.
+func NewEmailWorker(outbox *repository.EmailOutboxRepository, emailService *EmailService, workers, ratePerMinute int) *EmailWorker {
+	return newEmailWorker(outbox, emailService, workers, ratePerMinute)
+}
+
+func newEmailWorker(outbox emailOutbox, emailService *EmailService, workers, ratePerMinute int) *EmailWorker {
+	if workers < 1 {
+		workers = 1
+	}
+	return &EmailWorker{
+		outbox:        outbox,
+		emailService:  emailService,
+		workers:       workers,
+		ratePerMinute: ratePerMinute,
+		limiters:      make(map[string]*rateLimiter),
+	}
+}
+
+// Start launches the workers, and a loop that puts back messages left in sending
+// by crashed instances. They stop when ctx is cancelled; call Wait to block until
+// in-flight sends have finished.
+func (w *EmailWorker) Start(ctx context.Context) {
+	w.releaseStale(ctx)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(emailStaleCheckEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.releaseStale(ctx)
+			}
+		}
+	}()
+
+	for i := 0; i < w.workers; i++ {
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			w.run(ctx)
+		}()
+	}
+}
+
+// Wait blocks until all workers have exited
+func (w *EmailWorker) Wait() {
+	w.wg.Wait()
+}
+
+func (w *EmailWorker) releaseStale(ctx context.Context) {
+	n, err := w.outbox.ReleaseStale(ctx, emailStaleClaimAfter)
+	if err != nil && ctx.Err() == nil {
+		fmt.Printf("ERROR: Failed to release stale outbox messages: %v\n", err)
+	} else if n > 0 {
+		fmt.Printf("DEBUG: Released %d stale outbox messages\n", n)
+	}
+}
+
+// run claims one message at a time, so each worker holds at most one claim while
+// it waits for the rate limiter
+func (w *EmailWorker) run(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		messages, err := w.outbox.ClaimDue(ctx, 1)
+		if err != nil && ctx.Err() == nil {
+			fmt.Printf("ERROR: Failed to claim outbox messages: %v\n", err)
+		}
+
+		if len(messages) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(emailWorkerPollInterval):
+			}
+			continue
+		}
+
+		w.process(ctx, &messages[0])
+	}
+}
+
+// process delivers one claimed message and records the outcome. Status updates use
+// a fresh context so a shutdown mid-send doesn't leave the message stuck in sending.
+func (w *EmailWorker) process(ctx context.Context, msg *models.EmailOutboxMessage) {
+	if err := w.limiter(msg.SMTPAccount).Wait(ctx); err != nil {
+		// Shutting down: hand the message back untouched
+		if err := w.outbox.Release(context.Background(), msg.ID); err != nil {
+			fmt.Printf("ERROR: Failed to release outbox message %s: %v\n", msg.ID, err)
+		}
+		return
+	}
+
+	// The claim may have gone stale while waiting, and been handed to another worker
+	held, err := w.outbox.Touch(context.Background(), msg.ID, msg.Attempts)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to renew claim on outbox message %s: %v\n", msg.ID, err)
+		return
+	}
+	if !held {
+		return
+	}
+
+	err = w.emailService.Deliver(msg.Recipient, msg.Subject, msg.HTMLBody)
+	if err == nil {
+		if err := w.outbox.MarkSent(context.Background(), msg.ID); err != nil {
+			fmt.Printf("ERROR: Failed to mark outbox message %s sent: %v\n", msg.ID, err)
+		}
+		return
+	}
+
+	var next *time.Time
+	if msg.Attempts < msg.MaxAttempts {
+		at := time.Now().Add(emailRetryDelay(msg.Attempts))
+		next = &at
+		fmt.Printf("DEBUG: Email to %s failed (attempt %d/%d), retrying at %s: %v\n", msg.Recipient, msg.Attempts, msg.MaxAttempts, at.Format(time.RFC3339), err)
+	} else {
+		fmt.Printf("ERROR: Email to %s dead-lettered after %d attempts: %v\n", msg.Recipient, msg.Attempts, err)
+	}
+
+	if err := w.outbox.MarkFailed(context.Background(), msg.ID, err.Error(), next); err != nil {
+		fmt.Printf("ERROR: Failed to record outbox failure for %s: %v\n", msg.ID, err)
+	}
+}
+
+func (w *EmailWorker) limiter(account string) *rateLimiter {
+	w.limitersMu.Lock()
+	defer w.limitersMu.Unlock()
+
+	l, ok := w.limiters[account]
+	if !ok {
+		l = newRateLimiter(w.ratePerMinute)
+		w.limiters[account] = l
+	}
+	return l
+}
+
+// emailRetryDelay returns the backoff before the next attempt: the base delay
+// doubled for every attempt made so far, capped, with up to 20% jitter so a batch
+// of failures doesn't retry in lockstep
+func emailRetryDelay(attempts int) time.Duration {
+	delay := emailRetryBaseDelay
+	for i := 1; i < attempts && delay < emailRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > emailRetryMaxDelay {
+		delay = emailRetryMaxDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// rateLimiter spaces calls evenly so that at most perMinute pass each minute
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	if perMinute <= 0 {
+		return &rateLimiter{}
+	}
+	return &rateLimiter{interval: time.Minute / time.Duration(perMinute)}
+}
+
+// Wait blocks until the caller's slot comes up or ctx is cancelled
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l.interval == 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	slot := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,504 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+)
+
+// fakeSMTP is an in-process SMTP server over TLS that records what it is sent.
+// Recipients for which reject returns true are refused at RCPT.
+type fakeSMTP struct {
+	listener net.Listener
+	reject   func(to string) bool
+
+	mu        sync.Mutex
+	delivered []fakeDelivery
+}
+
+type fakeDelivery struct {
+	To   string
+	Data string
+	At   time.Time
+}
+
+func startFakeSMTP(t *testing.T) (*fakeSMTP, *EmailService) {
+	t.Helper()
+
+	cert, roots := selfSignedCert(t)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &fakeSMTP{listener: listener, reject: func(string) bool { return false }}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	svc := NewEmailService("127.0.0.1", port, "user", "secret", "shop@example.com", "Shop", nil)
+	svc.tlsConfig = &tls.Config{ServerName: "127.0.0.1", RootCAs: roots}
+	return srv, svc
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) { tp.PrintfLine("%s", line) }
+
+	reply("220 fake ESMTP")
+	var to string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			reply("250-fake")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			reply("235 2.7.0 Authenticated")
+		case "MAIL":
+			reply("250 2.1.0 OK")
+		case "RCPT":
+			to = strings.TrimSuffix(strings.TrimPrefix(line[len("RCPT TO:"):], "<"), ">")
+			if s.reject(to) {
+				reply("550 5.1.1 Mailbox unavailable")
+				continue
+			}
+			reply("250 2.1.5 OK")
+		case "DATA":
+			reply("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, fakeDelivery{To: to, Data: string(data), At: time.Now()})
+			s.mu.Unlock()
+			reply("250 2.0.0 Queued")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Not implemented")
+		}
+	}
+}
+
+func (s *fakeSMTP) deliveries() []fakeDelivery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]fakeDelivery(nil), s.delivered...)
+}
+
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+// fakeOutbox is an in-memory emailOutbox with the same claim rules as the
+// repository
+type fakeOutbox struct {
+	mu       sync.Mutex
+	messages []*models.EmailOutboxMessage
+	lockedAt map[uuid.UUID]time.Time
+}
+
+func newFakeOutbox() *fakeOutbox {
+	return &fakeOutbox{lockedAt: make(map[uuid.UUID]time.Time)}
+}
+
+func (o *fakeOutbox) add(to string, maxAttempts int) *models.EmailOutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	msg := &models.EmailOutboxMessage{
+		ID:            uuid.New(),
+		Recipient:     to,
+		Subject:       "Hello " + to,
+		HTMLBody:      "<p>Hi</p>",
+		SMTPAccount:   "user",
+		Status:        models.EmailOutboxPending,
+		MaxAttempts:   maxAttempts,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	o.messages = append(o.messages, msg)
+	return msg
+}
+
+// get returns a copy of a message as it is now
+func (o *fakeOutbox) get(id uuid.UUID) models.EmailOutboxMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, m := range o.messages {
+		if m.ID == id {
+			return *m
+		}
+	}
+	return models.EmailOutboxMessage{}
+}
+
+func (o *fakeOutbox) find(id uuid.UUID) *models.EmailOutboxMessage {
+	for _, m := range o.messages {
+		if m.ID == id {
+			return m
+		}
+	}
+	return nil
+}
+
+func (o *fakeOutbox) ClaimDue(ctx context.Context, limit int) ([]models.EmailOutboxMessage, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var claimed []models.EmailOutboxMessage
+	for _, m := range o.messages {
+		if len(claimed) == limit {
+			break
+		}
+		if m.Status == models.EmailOutboxPending && !m.NextAttemptAt.After(time.Now()) {
+			m.Status = models.EmailOutboxSending
+			m.Attempts++
+			o.lockedAt[m.ID] = time.Now()
+			claimed = append(claimed, *m)
+		}
+	}
+	return claimed, nil
+}
+
+func (o *fakeOutbox) Touch(ctx context.Context, id uuid.UUID, attempts int) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	if m == nil || m.Status != models.EmailOutboxSending || m.Attempts != attempts {
+		return false, nil
+	}
+	o.lockedAt[id] = time.Now()
+	return true, nil
+}
+
+func (o *fakeOutbox) MarkSent(ctx context.Context, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	now := time.Now()
+	m.Status = models.EmailOutboxSent
+	m.SentAt = &now
+	m.LastError = nil
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(ctx context.Context, id uuid.UUID, errMsg string, nextAttempt *time.Time) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	m := o.find(id)
+	m.LastError = &errMsg
+	if nextAttempt == nil {
+		m.Status = models.EmailOutboxDead
+		return nil
+	}
+	m.Status = models.EmailOutboxPending
+	m.NextAttemptAt = *nextAttempt
+	return nil
+}
+
+func (o *fakeOutbox) Release(ctx context.Context, id uuid.UUID) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if m := o.find(id); m.Status == models.EmailOutboxSending {
+		m.Status = models.EmailOutboxPending
+		if m.Attempts > 0 {
+			m.Attempts--
+		}
+	}
+	return nil
+}
+
+func (o *fakeOutbox) ReleaseStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var n int64
+	for _, m := range o.messages {
+		if m.Status == models.EmailOutboxSending && time.Since(o.lockedAt[m.ID]) >= olderThan {
+			m.Status = models.EmailOutboxPending
+			n++
+		}
+	}
+	return n, nil
+}
+
+// waitFor polls cond until it holds, failing the test after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func claimOne(t *testing.T, outbox *fakeOutbox) models.EmailOutboxMessage {
+	t.Helper()
+	claimed, _ := outbox.ClaimDue(context.Background(), 1)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d messages, want 1", len(claimed))
+	}
+	return claimed[0]
+}
+
+func TestEmailWorkerDelivers(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	outbox := newFakeOutbox()
+	a := outbox.add("a@example.com", 3)
+	b := outbox.add("b@example.com", 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newEmailWorker(outbox, svc, 2, 0)
+	w.Start(ctx)
+	waitFor(t, "both messages to be sent", func() bool {
+		return outbox.get(a.ID).Status == models.EmailOutboxSent && outbox.get(b.ID).Status == models.EmailOutboxSent
+	})
+	cancel()
+	w.Wait()
+
+	got := map[string]string{}
+	for _, d := range srv.deliveries() {
+		got[d.To] = d.Data
+	}
+	for _, msg := range []*models.EmailOutboxMessage{a, b} {
+		data, ok := got[msg.Recipient]
+		if !ok {
+			t.Fatalf("no delivery to %s", msg.Recipient)
+		}
+		if !strings.Contains(data, "Subject: "+msg.Subject) || !strings.Contains(data, msg.HTMLBody) {
+			t.Errorf("delivery to %s = %q, want subject and body", msg.Recipient, data)
+		}
+	}
+	if n := len(srv.deliveries()); n != 2 {
+		t.Errorf("%d deliveries, want 2", n)
+	}
+}
+
+func TestEmailWorkerRetriesWithBackoff(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	srv.reject = func(string) bool { return true }
+	outbox := newFakeOutbox()
+	msg := outbox.add("bounce@example.com", 3)
+	w := newEmailWorker(outbox, svc, 1, 0)
+
+	claimed := claimOne(t, outbox)
+	before := time.Now()
+	w.process(context.Background(), &claimed)
+
+	got := outbox.get(msg.ID)
+	if got.Status != models.EmailOutboxPending {
+		t.Fatalf("status = %s, want pending", got.Status)
+	}
+	if got.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", got.Attempts)
+	}
+	if got.LastError == nil || !strings.Contains(*got.LastError, "550") {
+		t.Errorf("last error = %v, want the SMTP rejection", got.LastError)
+	}
+	delay := got.NextAttemptAt.Sub(before)
+	if delay < emailRetryBaseDelay || delay > emailRetryBaseDelay*6/5+time.Second {
+		t.Errorf("retry in %s, want %s plus up to 20%% jitter", delay, emailRetryBaseDelay)
+	}
+}
+
+func TestEmailRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		base     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{5, 8 * time.Minute},
+		{8, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := emailRetryDelay(tt.attempts)
+			if got < tt.base || got > tt.base+tt.base/5 {
+				t.Fatalf("emailRetryDelay(%d) = %s, want %s to %s", tt.attempts, got, tt.base, tt.base+tt.base/5)
+			}
+		}
+	}
+}
+
+func TestEmailWorkerDeadLettersAfterMaxAttempts(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	srv.reject = func(string) bool { return true }
+	outbox := newFakeOutbox()
+	msg := outbox.add("bounce@example.com", 2)
+	w := newEmailWorker(outbox, svc, 1, 0)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		// Make the retry due now rather than after the backoff
+		outbox.mu.Lock()
+		outbox.find(msg.ID).NextAttemptAt = time.Now()
+		outbox.mu.Unlock()
+
+		claimed := claimOne(t, outbox)
+		w.process(context.Background(), &claimed)
+	}
+
+	got := outbox.get(msg.ID)
+	if got.Status != models.EmailOutboxDead {
+		t.Fatalf("status = %s after %d attempts, want dead", got.Status, got.Attempts)
+	}
+	if got.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", got.Attempts)
+	}
+	if claimed, _ := outbox.ClaimDue(context.Background(), 1); len(claimed) != 0 {
+		t.Errorf("dead message was claimed again")
+	}
+}
+
+func TestEmailWorkerRateLimitsPerAccount(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	outbox := newFakeOutbox()
+	for i := 0; i < 3; i++ {
+		outbox.add("user"+string(rune('a'+i))+"@example.com", 3)
+	}
+
+	// 600 a minute is one every 100ms
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newEmailWorker(outbox, svc, 3, 600)
+	w.Start(ctx)
+	waitFor(t, "three deliveries", func() bool { return len(srv.deliveries()) == 3 })
+	cancel()
+	w.Wait()
+
+	deliveries := srv.deliveries()
+	if spread := deliveries[2].At.Sub(deliveries[0].At); spread < 180*time.Millisecond {
+		t.Errorf("three sends took %s, want them spaced 100ms apart", spread)
+	}
+
+	// Another account has its own budget
+	l := w.limiter("user")
+	other := w.limiter("other")
+	if l == other {
+		t.Fatal("accounts share a limiter")
+	}
+	start := time.Now()
+	if err := other.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited > 50*time.Millisecond {
+		t.Errorf("first send on a new account waited %s", waited)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	l := newRateLimiter(0)
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if waited := time.Since(start); waited > 50*time.Millisecond {
+		t.Errorf("unlimited limiter waited %s", waited)
+	}
+}
+
+func TestEmailWorkerReleasesOnShutdown(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	outbox := newFakeOutbox()
+	first := outbox.add("first@example.com", 3)
+	second := outbox.add("second@example.com", 3)
+
+	// One a minute: the second message waits on the limiter until shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newEmailWorker(outbox, svc, 1, 1)
+	w.Start(ctx)
+	waitFor(t, "the second message to be claimed", func() bool {
+		return outbox.get(first.ID).Status == models.EmailOutboxSent && outbox.get(second.ID).Status == models.EmailOutboxSending
+	})
+	cancel()
+	w.Wait()
+
+	got := outbox.get(second.ID)
+	if got.Status != models.EmailOutboxPending {
+		t.Errorf("status = %s after shutdown, want pending", got.Status)
+	}
+	if got.Attempts != 0 {
+		t.Errorf("attempts = %d, want the unsent attempt not counted", got.Attempts)
+	}
+	if n := len(srv.deliveries()); n != 1 {
+		t.Errorf("%d deliveries, want 1", n)
+	}
+}
+
+func TestEmailWorkerSkipsLostClaim(t *testing.T) {
+	srv, svc := startFakeSMTP(t)
+	outbox := newFakeOutbox()
+	msg := outbox.add("slow@example.com", 3)
+	w := newEmailWorker(outbox, svc, 1, 0)
+
+	// A peer took the claim for stale and another worker claimed it again
+	claimed := claimOne(t, outbox)
+	outbox.ReleaseStale(context.Background(), 0)
+	claimOne(t, outbox)
+
+	w.process(context.Background(), &claimed)
+	if n := len(srv.deliveries()); n != 0 {
+		t.Errorf("%d deliveries from a lost claim, want 0", n)
+	}
+	if got := outbox.get(msg.ID); got.Status != models.EmailOutboxSending || got.Attempts != 2 {
+		t.Errorf("message = %s/%d, want left to the new claim", got.Status, got.Attempts)
+	}
+}
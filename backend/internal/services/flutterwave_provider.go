@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quikprint/backend/internal/models"
+)
+
+const flutterwaveBaseURL = "https://api.flutterwave.com/v3"
+
+// FlutterwaveProvider implements PaymentProvider for Flutterwave. Flutterwave's API
+// takes and returns amounts in naira, so amounts are converted at this boundary.
+type FlutterwaveProvider struct {
+	secretKey   string
+	webhookHash string
+	httpClient  *http.Client
+}
+
+// NewFlutterwaveProvider creates a Flutterwave provider. webhookHash is the secret
+// hash configured on the Flutterwave dashboard and sent back in the verif-hash header.
+func NewFlutterwaveProvider(secretKey, webhookHash string) *FlutterwaveProvider {
+	return &FlutterwaveProvider{
+		secretKey:   secretKey,
+		webhookHash: webhookHash,
+		httpClient:  &http.Client{},
+	}
+}
+
+type flutterwaveInitRequest struct {
+	TxRef       string            `json:"tx_ref"`
+	Amount      float64           `json:"amount"`
+	Currency    string            `json:"currency"`
+	RedirectURL string            `json:"redirect_url,omitempty"`
+	Customer    flutterwaveParty  `json:"customer"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+type flutterwaveParty struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type flutterwaveInitResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Link string `json:"link"`
+	} `json:"data"`
+}
+
+type flutterwaveTransaction struct {
+	ID                int64   `json:"id"`
+	TxRef             string  `json:"tx_ref"`
+	Amount            float64 `json:"amount"`
+	Currency          string  `json:"currency"`
+	Status            string  `json:"status"`
+	ProcessorResponse string  `json:"processor_response"`
+}
+
+type flutterwaveVerifyResponse struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message"`
+	Data    flutterwaveTransaction `json:"data"`
+}
+
+type flutterwaveRefundResponse struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		ID             int64   `json:"id"`
+		AmountRefunded float64 `json:"amount_refunded"`
+		Status         string  `json:"status"`
+	} `json:"data"`
+}
+
+type flutterwaveWebhookPayload struct {
+	Event string `json:"event"`
+	Data  struct {
+		ID                int64   `json:"id"`
+		TxRef             string  `json:"tx_ref"`
+		FlwRef            string  `json:"flw_ref"`
+		Amount            float64 `json:"amount"`
+		AmountRefunded    float64 `json:"amount_refunded"`
+		Currency          string  `json:"currency"`
+		Status            string  `json:"status"`
+		ProcessorResponse string  `json:"processor_response"`
+		Reference         string  `json:"reference"`
+	} `json:"data"`
+}
+
+func (p *FlutterwaveProvider) Name() string {
+	return "flutterwave"
+}
+
+func (p *FlutterwaveProvider) InitializePayment(ctx context.Context, req *PaymentInitRequest) (*PaymentInitResult, error) {
+	body := flutterwaveInitRequest{
+		TxRef:       req.Reference,
+		Amount:      models.Kobo(req.AmountKobo).Float64(),
+		Currency:    req.Currency,
+		RedirectURL: req.CallbackURL,
+		Customer:    flutterwaveParty{Email: req.Email, Name: req.CustomerName},
+		Meta:        req.Metadata,
+	}
+
+	var flwResp flutterwaveInitResponse
+	if err := p.do(ctx, "POST", "/payments", body, &flwResp); err != nil {
+		return nil, err
+	}
+	if flwResp.Status != "success" {
+		return nil, fmt.Errorf("flutterwave error: %s", flwResp.Message)
+	}
+
+	return &PaymentInitResult{
+		AuthorizationURL: flwResp.Data.Link,
+		Reference:        req.Reference,
+	}, nil
+}
+
+func (p *FlutterwaveProvider) VerifyPayment(ctx context.Context, reference string) (*PaymentVerification, error) {
+	tx, raw, err := p.transaction(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaymentVerification{
+		Reference:       tx.TxRef,
+		Status:          flutterwaveChargeStatus(tx.Status),
+		ProviderStatus:  tx.Status,
+		AmountKobo:      models.MoneyFromFloat(tx.Amount).Minor(),
+		Currency:        tx.Currency,
+		GatewayResponse: tx.ProcessorResponse,
+		Raw:             raw,
+	}, nil
+}
+
+// Refund looks up the Flutterwave transaction for the reference, since refunds are
+// made against Flutterwave's transaction ID
+func (p *FlutterwaveProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	tx, _, err := p.transaction(ctx, req.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{}
+	if req.AmountKobo > 0 {
+		body["amount"] = models.Kobo(req.AmountKobo).Float64()
+	}
+	if req.Reason != "" {
+		body["comments"] = req.Reason
+	}
+
+	var flwResp flutterwaveRefundResponse
+	if err := p.do(ctx, "POST", fmt.Sprintf("/transactions/%d/refund", tx.ID), body, &flwResp); err != nil {
+		return nil, err
+	}
+	if flwResp.Status != "success" {
+		return nil, fmt.Errorf("flutterwave error: %s", flwResp.Message)
+	}
+
+	raw, _ := json.Marshal(flwResp)
+	return &RefundResult{
+		ProviderRefundID: fmt.Sprintf("%d", flwResp.Data.ID),
+		Status:           flwResp.Data.Status,
+		AmountKobo:       models.MoneyFromFloat(flwResp.Data.AmountRefunded).Minor(),
+		Raw:              string(raw),
+	}, nil
+}
+
+// VerifyWebhook compares the verif-hash header with the configured secret hash
+func (p *FlutterwaveProvider) VerifyWebhook(header http.Header, body []byte) bool {
+	if p.webhookHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(header.Get("verif-hash")), []byte(p.webhookHash)) == 1
+}
+
+func (p *FlutterwaveProvider) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var payload flutterwaveWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	event := &WebhookEvent{
+		Event:      payload.Event,
+		Key:        fmt.Sprintf("%s:%d:%s", payload.Event, payload.Data.ID, payload.Data.Status),
+		Reference:  payload.Data.TxRef,
+		AmountKobo: models.MoneyFromFloat(payload.Data.Amount).Minor(),
+		Currency:   payload.Data.Currency,
+		Detail:     payload.Data.ProcessorResponse,
+	}
+
+	switch {
+	case payload.Event == "charge.completed":
+		if flutterwaveChargeStatus(payload.Data.Status) == ChargeSuccess {
+			event.Kind = WebhookChargeSuccess
+		} else {
+			event.Kind = WebhookChargeFailed
+		}
+	case strings.HasPrefix(payload.Event, "refund."):
+		event.RefundID = fmt.Sprintf("%d", payload.Data.ID)
+		event.AmountKobo = models.MoneyFromFloat(payload.Data.AmountRefunded).Minor()
+		switch refundStatus(payload.Data.Status) {
+		case models.RefundStatusProcessed:
+			event.Kind = WebhookRefundProcessed
+		case models.RefundStatusFailed:
+			event.Kind = WebhookRefundFailed
+		default:
+			event.Kind = WebhookOther
+		}
+	case strings.HasPrefix(payload.Event, "transfer."):
+		event.Kind = WebhookTransfer
+		event.Reference = payload.Data.Reference
+		event.Detail = fmt.Sprintf("%s (%s)", payload.Data.Reference, payload.Data.Status)
+	default:
+		event.Kind = WebhookOther
+	}
+
+	return event, nil
+}
+
+func (p *FlutterwaveProvider) transaction(ctx context.Context, reference string) (*flutterwaveTransaction, string, error) {
+	var flwResp flutterwaveVerifyResponse
+	if err := p.do(ctx, "GET", "/transactions/verify_by_reference?tx_ref="+url.QueryEscape(reference), nil, &flwResp); err != nil {
+		return nil, "", err
+	}
+	if flwResp.Status != "success" {
+		return nil, "", fmt.Errorf("flutterwave error: %s", flwResp.Message)
+	}
+
+	raw, _ := json.Marshal(flwResp)
+	return &flwResp.Data, string(raw), nil
+}
+
+// flutterwaveChargeStatus maps a Flutterwave transaction status
+func flutterwaveChargeStatus(status string) ChargeStatus {
+	switch status {
+	case "successful":
+		return ChargeSuccess
+	case "failed", "cancelled":
+		return ChargeFailed
+	default:
+		return ChargePending
+	}
+}
+
+// do sends a request to the Flutterwave API and decodes the JSON response into out
+func (p *FlutterwaveProvider) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, flutterwaveBaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.secretKey)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("DEBUG: Flutterwave response status: %d\n", resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp map[string]interface{}
+		if err := json.Unmarshal(respBody, &errResp); err == nil {
+			if message, ok := errResp["message"]; ok {
+				return fmt.Errorf("flutterwave error: %v (status %d)", message, resp.StatusCode)
+			}
+		}
+		return fmt.Errorf("flutterwave error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse flutterwave response: %v, body: %s", err, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+const (
+	notificationQueueSize   = 256
+	notificationSendTimeout = 30 * time.Second
+)
+
+// orderStatusMessages holds the customer-facing message for each status change
+// we email about. Other statuses (pending, awaiting payment, paid) are covered by
+// the order and payment confirmation emails.
+var orderStatusMessages = map[models.OrderStatus]string{
+	models.OrderStatusProcessing: "We've started working on your order.",
+	models.OrderStatusPrinting:   "Your order is now being printed.",
+	models.OrderStatusReady:      "Your order is ready.",
+	models.OrderStatusShipped:    "Your order has been shipped and is on its way to you.",
+	models.OrderStatusDelivered:  "Your order has been delivered. Thank you for choosing QuikPrint NG!",
+	models.OrderStatusCancelled:  "Your order has been cancelled. If you have any questions, please contact us.",
+}
+
+// NotificationService turns order, payment and account events into customer emails.
+// Events are queued on a buffered channel and handled by a background dispatcher so
+// HTTP requests never wait on email rendering or the database. Every outcome is
+// written to the notification log.
+type NotificationService struct {
+	emailService     *EmailService
+	userRepo         *repository.UserRepository
+	orderRepo        *repository.OrderRepository
+	notificationRepo *repository.NotificationRepository
+	events           chan models.NotificationEvent
+	wg               sync.WaitGroup
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(emailService *EmailService, userRepo *repository.UserRepository, orderRepo *repository.OrderRepository, notificationRepo *repository.NotificationRepository) *NotificationService {
+	return &NotificationService{
+		emailService:     emailService,
+		userRepo:         userRepo,
+		orderRepo:        orderRepo,
+		notificationRepo: notificationRepo,
+		events:           make(chan models.NotificationEvent, notificationQueueSize),
+	}
+}
+
+// Start launches the dispatcher. When ctx is cancelled the dispatcher sends whatever
+// is still queued and exits; call Wait to block until it has finished.
+func (s *NotificationService) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			select {
+			case event := <-s.events:
+				s.dispatch(event)
+			case <-ctx.Done():
+				s.drain()
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until the dispatcher has exited
+func (s *NotificationService) Wait() {
+	s.wg.Wait()
+}
+
+func (s *NotificationService) drain() {
+	for {
+		select {
+		case event := <-s.events:
+			s.dispatch(event)
+		default:
+			return
+		}
+	}
+}
+
+// Publish queues an event without blocking. If the queue is full the event is
+// dropped and logged rather than holding up the caller.
+func (s *NotificationService) Publish(event models.NotificationEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	select {
+	case s.events <- event:
+	default:
+		fmt.Printf("ERROR: Notification queue full, dropping %s event for order %s user %s\n", event.Type, event.OrderID, event.UserID)
+	}
+}
+
+// OrderCreated publishes an order confirmation event
+func (s *NotificationService) OrderCreated(order *models.Order) {
+	s.Publish(models.NotificationEvent{
+		Type:    models.EventOrderCreated,
+		UserID:  order.UserID,
+		OrderID: order.ID,
+		Status:  order.Status,
+	})
+}
+
+// OrderStatusChanged publishes a status change event. It matches
+// repository.StatusChangeHook so it can be registered on the order repository.
+func (s *NotificationService) OrderStatusChanged(orderID uuid.UUID, from, to models.OrderStatus, note string) {
+	if _, ok := orderStatusMessages[to]; !ok {
+		return
+	}
+	s.Publish(models.NotificationEvent{
+		Type:       models.EventOrderStatusChanged,
+		OrderID:    orderID,
+		FromStatus: from,
+		Status:     to,
+		Note:       note,
+	})
+}
+
+// PaymentSucceeded publishes a payment confirmation event
+func (s *NotificationService) PaymentSucceeded(orderID uuid.UUID) {
+	s.Publish(models.NotificationEvent{
+		Type:    models.EventPaymentSucceeded,
+		OrderID: orderID,
+		Status:  models.OrderStatusPaid,
+	})
+}
+
+// PaymentReminder publishes a reminder that an unpaid order will be cancelled at dueAt
+func (s *NotificationService) PaymentReminder(orderID uuid.UUID, dueAt time.Time) {
+	s.Publish(models.NotificationEvent{
+		Type:    models.EventPaymentReminder,
+		OrderID: orderID,
+		DueAt:   dueAt,
+	})
+}
+
+// UserRegistered publishes a welcome event
+func (s *NotificationService) UserRegistered(userID uuid.UUID) {
+	s.Publish(models.NotificationEvent{
+		Type:   models.EventUserRegistered,
+		UserID: userID,
+	})
+}
+
+func (s *NotificationService) dispatch(event models.NotificationEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationSendTimeout)
+	defer cancel()
+
+	entry := &models.NotificationLog{
+		EventType: event.Type,
+		Channel:   "email",
+	}
+
+	if err := s.send(ctx, event, entry); err != nil {
+		msg := err.Error()
+		entry.Status = models.NotificationFailed
+		entry.Error = &msg
+		fmt.Printf("ERROR: Failed to send %s notification: %v\n", event.Type, err)
+	}
+
+	if err := s.notificationRepo.CreateLog(ctx, entry); err != nil {
+		fmt.Printf("ERROR: Failed to write notification log: %v\n", err)
+	}
+}
+
+// send resolves the recipient for an event and sends the matching email. It fills
+// in entry as it goes and sets the status for sent and skipped notifications.
+func (s *NotificationService) send(ctx context.Context, event models.NotificationEvent, entry *models.NotificationLog) error {
+	var order *models.Order
+	userID := event.UserID
+
+	if event.Type != models.EventUserRegistered {
+		var err error
+		order, err = s.orderRepo.GetByID(ctx, event.OrderID)
+		if err != nil {
+			return fmt.Errorf("failed to load order %s: %w", event.OrderID, err)
+		}
+		if order == nil {
+			return fmt.Errorf("order %s not found", event.OrderID)
+		}
+		entry.OrderID = &order.ID
+		userID = order.UserID
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load user %s: %w", userID, err)
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", userID)
+	}
+	entry.UserID = &user.ID
+	entry.Recipient = user.Email
+
+	if order != nil {
+		prefs, err := s.notificationRepo.GetPreferences(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load notification preferences: %w", err)
+		}
+		if !prefs.OrderUpdates {
+			return s.skip(entry, "customer opted out of order updates")
+		}
+	}
+
+	if !s.emailService.IsConfigured() {
+		return s.skip(entry, "SMTP not configured")
+	}
+
+	switch event.Type {
+	case models.EventUserRegistered:
+		err = s.emailService.SendWelcomeEmail(user.Email, user.FirstName)
+	case models.EventOrderCreated:
+		err = s.emailService.SendOrderConfirmation(order, user.Email)
+	case models.EventPaymentSucceeded:
+		err = s.emailService.SendPaymentConfirmation(order, user.Email)
+	case models.EventPaymentReminder:
+		if !order.Status.IsUnpaid() {
+			return s.skip(entry, "order paid or cancelled since the reminder was scheduled")
+		}
+		err = s.emailService.SendPaymentReminder(order, user.Email, event.DueAt)
+	case models.EventOrderStatusChanged:
+		// The order may have moved on since the event; describe the change we were told about
+		order.Status = event.Status
+		err = s.emailService.SendOrderStatusUpdate(order, user.Email, orderStatusMessages[event.Status])
+	default:
+		return fmt.Errorf("unknown notification event %s", event.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Delivery itself is tracked in the email outbox
+	entry.Status = models.NotificationQueued
+	return nil
+}
+
+func (s *NotificationService) skip(entry *models.NotificationLog, reason string) error {
+	entry.Status = models.NotificationSkipped
+	entry.Error = &reason
+	return nil
+}
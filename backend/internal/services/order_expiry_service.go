@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// orderExpiryBatchSize caps the orders handled per pass for each kind of work
+const orderExpiryBatchSize = 200
+
+// OrderExpiryService cancels orders that are not paid within the payment window and
+// reminds customers before that happens. Cancellation goes through the normal
+// status lifecycle as the system actor, which also releases any coupon the order
+// reserved.
+type OrderExpiryService struct {
+	orderRepo *repository.OrderRepository
+	notifier  *NotificationService
+	window    time.Duration
+	// reminders are how long before the deadline to remind, in whole minutes,
+	// longest first
+	reminders []time.Duration
+	interval  time.Duration
+	wg        sync.WaitGroup
+}
+
+// NewOrderExpiryService creates the expiry job. Unpaid orders are cancelled window
+// after they were placed, with a reminder at each of the reminders offsets before
+// that. Reminders are recorded by their offset in minutes, so offsets that are
+// not a whole number of minutes, or not inside the window, are ignored, as are
+// repeats. A zero window disables expiry.
+func NewOrderExpiryService(orderRepo *repository.OrderRepository, notifier *NotificationService, window, interval time.Duration, reminders []time.Duration) *OrderExpiryService {
+	var valid []time.Duration
+	seen := make(map[time.Duration]bool)
+	for _, d := range reminders {
+		if d > 0 && d < window && d%time.Minute == 0 && !seen[d] {
+			seen[d] = true
+			valid = append(valid, d)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool { return valid[i] > valid[j] })
+
+	return &OrderExpiryService{
+		orderRepo: orderRepo,
+		notifier:  notifier,
+		window:    window,
+		reminders: valid,
+		interval:  interval,
+	}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (s *OrderExpiryService) Start(ctx context.Context) {
+	if s.window <= 0 || s.interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the job has exited
+func (s *OrderExpiryService) Wait() {
+	s.wg.Wait()
+}
+
+// RunOnce sends the reminders that are due and cancels expired orders
+func (s *OrderExpiryService) RunOnce(ctx context.Context) {
+	now := time.Now()
+	deadline := now.Add(-s.window)
+
+	for i, before := range s.reminders {
+		// Orders within `before` of their deadline. A later reminder takes over
+		// from this one, so an order never gets two reminders in one pass.
+		to := deadline.Add(before)
+		from := deadline
+		if i+1 < len(s.reminders) {
+			from = deadline.Add(s.reminders[i+1])
+		}
+		s.remind(ctx, from, to, before)
+	}
+
+	orders, err := s.orderRepo.GetUnpaidCreatedBefore(ctx, deadline, orderExpiryBatchSize)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load expired orders: %v\n", err)
+		return
+	}
+
+	cancelled := 0
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return
+		}
+		note := fmt.Sprintf("Cancelled automatically: not paid within %s", formatWindow(s.window))
+		err := s.orderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusCancelled, note, models.SystemActor)
+		var transitionErr *models.StatusTransitionError
+		if errors.As(err, &transitionErr) {
+			// Paid or cancelled since it was loaded
+			continue
+		}
+		if err != nil {
+			fmt.Printf("ERROR: Failed to cancel expired order %s: %v\n", order.OrderNumber, err)
+			continue
+		}
+		cancelled++
+	}
+	if cancelled > 0 {
+		fmt.Printf("DEBUG: Cancelled %d unpaid orders\n", cancelled)
+	}
+}
+
+func (s *OrderExpiryService) remind(ctx context.Context, from, to time.Time, before time.Duration) {
+	minutesBefore := int(before / time.Minute)
+	orders, err := s.orderRepo.GetUnpaidDueReminder(ctx, from, to, minutesBefore, orderExpiryBatchSize)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load orders due a payment reminder: %v\n", err)
+		return
+	}
+
+	for _, order := range orders {
+		recorded, err := s.orderRepo.RecordPaymentReminder(ctx, order.ID, minutesBefore)
+		if err != nil {
+			fmt.Printf("ERROR: Failed to record payment reminder for order %s: %v\n", order.OrderNumber, err)
+			continue
+		}
+		if recorded {
+			s.notifier.PaymentReminder(order.ID, order.CreatedAt.Add(s.window))
+		}
+	}
+}
+
+// formatWindow describes the payment window in whole hours or days
+func formatWindow(d time.Duration) string {
+	hours := int(d / time.Hour)
+	if hours >= 24 && hours%24 == 0 {
+		return fmt.Sprintf("%d days", hours/24)
+	}
+	return fmt.Sprintf("%d hours", hours)
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewOrderExpiryServiceReminders(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    time.Duration
+		reminders []time.Duration
+		want      []time.Duration
+	}{
+		{
+			name:      "longest first",
+			window:    48 * time.Hour,
+			reminders: []time.Duration{4 * time.Hour, 24 * time.Hour},
+			want:      []time.Duration{24 * time.Hour, 4 * time.Hour},
+		},
+		{
+			name:      "under an hour kept apart from an hour",
+			window:    48 * time.Hour,
+			reminders: []time.Duration{30 * time.Minute, time.Hour, 90 * time.Minute},
+			want:      []time.Duration{90 * time.Minute, time.Hour, 30 * time.Minute},
+		},
+		{
+			name:      "repeats dropped",
+			window:    48 * time.Hour,
+			reminders: []time.Duration{4 * time.Hour, 240 * time.Minute},
+			want:      []time.Duration{4 * time.Hour},
+		},
+		{
+			name:      "part minutes dropped",
+			window:    48 * time.Hour,
+			reminders: []time.Duration{90 * time.Second, time.Hour},
+			want:      []time.Duration{time.Hour},
+		},
+		{
+			name:      "outside the window dropped",
+			window:    24 * time.Hour,
+			reminders: []time.Duration{0, -time.Hour, 24 * time.Hour, 48 * time.Hour, 4 * time.Hour},
+			want:      []time.Duration{4 * time.Hour},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewOrderExpiryService(nil, nil, tt.window, time.Minute, tt.reminders)
+			if !reflect.DeepEqual(s.reminders, tt.want) {
+				t.Errorf("reminders = %v, want %v", s.reminders, tt.want)
+			}
+		})
+	}
+}
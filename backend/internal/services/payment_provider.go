@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"net/http"
+)
+
+// PaymentProvider is a payment gateway. Amounts are always in kobo (minor units)
+// regardless of what the gateway's API uses.
+type PaymentProvider interface {
+	// Name is the identifier stored on payments and used in webhook URLs
+	Name() string
+	InitializePayment(ctx context.Context, req *PaymentInitRequest) (*PaymentInitResult, error)
+	VerifyPayment(ctx context.Context, reference string) (*PaymentVerification, error)
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+	// VerifyWebhook reports whether a webhook delivery really came from the gateway
+	VerifyWebhook(header http.Header, body []byte) bool
+	ParseWebhook(body []byte) (*WebhookEvent, error)
+}
+
+type PaymentInitRequest struct {
+	Email        string
+	CustomerName string
+	AmountKobo   int64
+	Currency     string
+	Reference    string
+	CallbackURL  string
+	Metadata     map[string]string
+}
+
+type PaymentInitResult struct {
+	AuthorizationURL string
+	AccessCode       string
+	Reference        string
+}
+
+// ChargeStatus is a gateway's transaction status mapped onto what we act on
+type ChargeStatus string
+
+const (
+	ChargeSuccess ChargeStatus = "success"
+	ChargeFailed  ChargeStatus = "failed"
+	ChargePending ChargeStatus = "pending"
+)
+
+type PaymentVerification struct {
+	Reference string
+	Status    ChargeStatus
+	// ProviderStatus is the gateway's own status string, e.g. "abandoned"
+	ProviderStatus  string
+	AmountKobo      int64
+	Currency        string
+	GatewayResponse string
+	Raw             string
+}
+
+// RefundRequest refunds AmountKobo of the payment with the given reference; zero
+// refunds the full amount
+type RefundRequest struct {
+	Reference  string
+	AmountKobo int64
+	Reason     string
+}
+
+type RefundResult struct {
+	ProviderRefundID string
+	Status           string
+	AmountKobo       int64
+	Raw              string
+}
+
+type WebhookEventKind string
+
+const (
+	WebhookChargeSuccess   WebhookEventKind = "charge_success"
+	WebhookChargeFailed    WebhookEventKind = "charge_failed"
+	WebhookRefundProcessed WebhookEventKind = "refund_processed"
+	WebhookRefundFailed    WebhookEventKind = "refund_failed"
+	WebhookTransfer        WebhookEventKind = "transfer"
+	WebhookOther           WebhookEventKind = "other"
+)
+
+// WebhookEvent is a gateway webhook normalised across providers
+type WebhookEvent struct {
+	Kind WebhookEventKind
+	// Event is the gateway's own event name
+	Event string
+	// Key identifies the event across redeliveries
+	Key string
+	// Reference is the reference of the payment the event is about
+	Reference string
+	// RefundID is the provider's refund ID, for refund events
+	RefundID   string
+	AmountKobo int64
+	Currency   string
+	// Detail is a human-readable status from the gateway, e.g. the gateway response
+	// for charges or the transfer code for transfers
+	Detail string
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// reconcileBatchSize caps the payments checked per run so a backlog is worked
+// through over several runs rather than hammering the provider APIs at once
+const reconcileBatchSize = 200
+
+// PaymentReconciler finds payments left pending (the customer closed the browser
+// before the callback and the webhook was missed) and asks their provider what
+// happened. Each run is recorded with one item per payment checked, which doubles
+// as the report of amounts that disagree with the order.
+type PaymentReconciler struct {
+	paymentService *PaymentService
+	paymentRepo    *repository.PaymentRepository
+	orderRepo      *repository.OrderRepository
+	reconRepo      *repository.ReconciliationRepository
+	settlement     *PaymentSettlementService
+	interval       time.Duration
+	minAge         time.Duration
+	expireAfter    time.Duration
+	wg             sync.WaitGroup
+}
+
+// NewPaymentReconciler creates a reconciler. Payments are checked once they are
+// minAge old and expired once they are expireAfter old without completing; Start
+// runs a reconciliation every interval.
+func NewPaymentReconciler(
+	paymentService *PaymentService,
+	paymentRepo *repository.PaymentRepository,
+	orderRepo *repository.OrderRepository,
+	reconRepo *repository.ReconciliationRepository,
+	settlement *PaymentSettlementService,
+	interval, minAge, expireAfter time.Duration,
+) *PaymentReconciler {
+	return &PaymentReconciler{
+		paymentService: paymentService,
+		paymentRepo:    paymentRepo,
+		orderRepo:      orderRepo,
+		reconRepo:      reconRepo,
+		settlement:     settlement,
+		interval:       interval,
+		minAge:         minAge,
+		expireAfter:    expireAfter,
+	}
+}
+
+// Start runs a reconciliation every interval until ctx is cancelled. A zero
+// interval disables the schedule.
+func (r *PaymentReconciler) Start(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				run, err := r.Run(ctx, models.ReconciliationTriggerScheduled)
+				if errors.Is(err, models.ErrReconciliationRunning) {
+					continue
+				}
+				if err != nil {
+					fmt.Printf("ERROR: Payment reconciliation failed: %v\n", err)
+					continue
+				}
+				if run.Checked > 0 {
+					fmt.Printf("DEBUG: Payment reconciliation checked %d payments: %d settled, %d failed, %d expired, %d mismatched, %d errors\n",
+						run.Checked, run.Settled, run.Failed, run.Expired, run.Mismatches, run.Errors)
+				}
+			}
+		}
+	}()
+}
+
+// Wait blocks until the scheduler has exited
+func (r *PaymentReconciler) Wait() {
+	r.wg.Wait()
+}
+
+// Run reconciles one batch of pending payments. Only one run happens at a time
+// across all instances; ErrReconciliationRunning is returned if another holds the lock.
+func (r *PaymentReconciler) Run(ctx context.Context, trigger string) (*models.ReconciliationRun, error) {
+	release, acquired, err := r.reconRepo.TryLock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, models.ErrReconciliationRunning
+	}
+	defer release()
+
+	payments, err := r.paymentRepo.GetPendingCreatedBefore(ctx, time.Now().Add(-r.minAge), reconcileBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := r.reconRepo.CreateRun(ctx, trigger)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range payments {
+		if ctx.Err() != nil {
+			break
+		}
+		item := r.reconcile(ctx, &payments[i])
+		item.RunID = run.ID
+		run.Count(item.Outcome)
+		if err := r.reconRepo.AddItem(ctx, item); err != nil {
+			fmt.Printf("ERROR: Failed to record reconciliation of %s: %v\n", item.Reference, err)
+		}
+		run.Items = append(run.Items, *item)
+	}
+
+	// Record the totals even if the run was cut short by shutdown
+	if err := r.reconRepo.FinishRun(context.Background(), run); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// reconcile checks one payment with its provider and settles it accordingly
+func (r *PaymentReconciler) reconcile(ctx context.Context, payment *models.Payment) *models.ReconciliationItem {
+	item := &models.ReconciliationItem{
+		PaymentID:      payment.ID,
+		OrderID:        payment.OrderID,
+		Provider:       payment.Provider,
+		Reference:      payment.ProviderReference,
+		ExpectedAmount: payment.Amount,
+		Currency:       payment.Currency,
+	}
+	stale := time.Since(payment.CreatedAt) > r.expireAfter
+
+	order, err := r.orderRepo.GetByID(ctx, payment.OrderID)
+	if err != nil {
+		return r.fail(item, err)
+	}
+	if order != nil {
+		item.ExpectedAmount = order.Total
+	}
+
+	provider, err := r.paymentService.Provider(payment.Provider)
+	if err != nil {
+		return r.fail(item, err)
+	}
+
+	verification, err := provider.VerifyPayment(ctx, payment.ProviderReference)
+	if err != nil {
+		if stale {
+			// Typically a reference the customer never opened, which the provider
+			// does not know about
+			return r.expire(ctx, payment, item, "", fmt.Sprintf("Could not be verified: %v", err))
+		}
+		return r.fail(item, err)
+	}
+
+	item.ProviderStatus = verification.ProviderStatus
+	providerAmount := models.Kobo(verification.AmountKobo)
+	item.ProviderAmount = &providerAmount
+	if verification.Currency != "" {
+		item.Currency = verification.Currency
+	}
+
+	switch verification.Status {
+	case ChargeSuccess:
+		err := r.settlement.SettleSuccess(ctx, payment, verification.AmountKobo, verification.Currency, verification.Raw, "Payment confirmed by reconciliation", models.SystemActor)
+		if errors.Is(err, ErrPaymentAmountMismatch) {
+			item.Outcome = models.ReconciliationMismatch
+			item.Detail = err.Error()
+			return item
+		}
+		if err != nil {
+			return r.fail(item, err)
+		}
+		item.Outcome = models.ReconciliationSettled
+	case ChargeFailed:
+		note := fmt.Sprintf("Payment failed: %s (found by reconciliation)", verification.ProviderStatus)
+		if err := r.settlement.SettleFailure(ctx, payment, verification.Raw, note, models.SystemActor); err != nil {
+			return r.fail(item, err)
+		}
+		item.Outcome = models.ReconciliationFailed
+		item.Detail = verification.GatewayResponse
+	default:
+		if stale {
+			return r.expire(ctx, payment, item, verification.Raw, fmt.Sprintf("Still %s after %s", verification.ProviderStatus, r.expireAfter))
+		}
+		item.Outcome = models.ReconciliationPending
+	}
+	return item
+}
+
+func (r *PaymentReconciler) expire(ctx context.Context, payment *models.Payment, item *models.ReconciliationItem, raw, detail string) *models.ReconciliationItem {
+	if err := r.settlement.Expire(ctx, payment, raw, "Payment reference expired", models.SystemActor); err != nil {
+		return r.fail(item, err)
+	}
+	item.Outcome = models.ReconciliationExpired
+	item.Detail = detail
+	return item
+}
+
+func (r *PaymentReconciler) fail(item *models.ReconciliationItem, err error) *models.ReconciliationItem {
+	fmt.Printf("ERROR: Failed to reconcile payment %s: %v\n", item.Reference, err)
+	item.Outcome = models.ReconciliationError
+	item.Detail = err.Error()
+	return item
+}
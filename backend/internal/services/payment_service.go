@@ -1,149 +1,56 @@
 package services
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"sort"
 )
 
-const paystackBaseURL = "https://api.paystack.co"
-
+// PaymentService holds the configured payment providers and picks one for each
+// payment: the one requested, or the configured default.
 type PaymentService struct {
-	secretKey  string
-	publicKey  string
-	httpClient *http.Client
+	providers       map[string]PaymentProvider
+	defaultProvider string
 }
 
-func NewPaymentService(secretKey, publicKey string) *PaymentService {
-	return &PaymentService{
-		secretKey:  secretKey,
-		publicKey:  publicKey,
-		httpClient: &http.Client{},
-	}
-}
-
-type PaystackInitRequest struct {
-	Email     string            `json:"email"`
-	Amount    int               `json:"amount"` // Amount in kobo (smallest currency unit)
-	Reference string            `json:"reference"`
-	Callback  string            `json:"callback_url,omitempty"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
-}
-
-type PaystackInitResponse struct {
-	Status  bool   `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		AuthorizationURL string `json:"authorization_url"`
-		AccessCode       string `json:"access_code"`
-		Reference        string `json:"reference"`
-	} `json:"data"`
-}
-
-type PaystackVerifyResponse struct {
-	Status  bool   `json:"status"`
-	Message string `json:"message"`
-	Data    struct {
-		ID              int    `json:"id"`
-		Status          string `json:"status"`
-		Reference       string `json:"reference"`
-		Amount          int    `json:"amount"`
-		GatewayResponse string `json:"gateway_response"`
-		PaidAt          string `json:"paid_at"`
-		Channel         string `json:"channel"`
-		Currency        string `json:"currency"`
-		Customer        struct {
-			Email string `json:"email"`
-		} `json:"customer"`
-		Metadata map[string]interface{} `json:"metadata"`
-	} `json:"data"`
-}
-
-func (s *PaymentService) InitializePayment(req *PaystackInitRequest) (*PaystackInitResponse, error) {
-	jsonData, err := json.Marshal(req)
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Printf("DEBUG: Sending to Paystack: %s\n", string(jsonData))
-
-	httpReq, err := http.NewRequest("POST", paystackBaseURL+"/transaction/initialize", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+s.secretKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	fmt.Printf("DEBUG: Paystack response status: %d\n", resp.StatusCode)
-	fmt.Printf("DEBUG: Paystack response body: %s\n", string(body))
-
-	// Check for non-200 status first
-	if resp.StatusCode != 200 {
-		fmt.Printf("DEBUG: Paystack returned error status: %d\n", resp.StatusCode)
-
-		// Try to parse the error response
-		var errResp map[string]interface{}
-		if err := json.Unmarshal(body, &errResp); err == nil {
-			if message, ok := errResp["message"]; ok {
-				return nil, fmt.Errorf("paystack error: %v (status %d)", message, resp.StatusCode)
-			}
+// NewPaymentService creates a payment service. The first provider is used as the
+// default unless defaultProvider names another registered provider.
+func NewPaymentService(defaultProvider string, providers ...PaymentProvider) *PaymentService {
+	s := &PaymentService{providers: make(map[string]PaymentProvider)}
+	for _, p := range providers {
+		if s.defaultProvider == "" {
+			s.defaultProvider = p.Name()
 		}
-		return nil, fmt.Errorf("paystack error: status %d, body: %s", resp.StatusCode, string(body))
+		s.providers[p.Name()] = p
 	}
-
-	var paystackResp PaystackInitResponse
-	if err := json.Unmarshal(body, &paystackResp); err != nil {
-		return nil, fmt.Errorf("failed to parse paystack response: %v, body: %s", err, string(body))
-	}
-
-	if !paystackResp.Status {
-		return nil, fmt.Errorf("paystack error: %s", paystackResp.Message)
+	if _, ok := s.providers[defaultProvider]; ok {
+		s.defaultProvider = defaultProvider
 	}
-
-	return &paystackResp, nil
+	return s
 }
 
-func (s *PaymentService) VerifyPayment(reference string) (*PaystackVerifyResponse, error) {
-	httpReq, err := http.NewRequest("GET", paystackBaseURL+"/transaction/verify/"+reference, nil)
-	if err != nil {
-		return nil, err
+// Provider returns the named provider, or the default provider when name is empty
+func (s *PaymentService) Provider(name string) (PaymentProvider, error) {
+	if name == "" {
+		name = s.defaultProvider
 	}
-
-	httpReq.Header.Set("Authorization", "Bearer "+s.secretKey)
-
-	resp, err := s.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var paystackResp PaystackVerifyResponse
-	if err := json.Unmarshal(body, &paystackResp); err != nil {
-		return nil, err
+	p, ok := s.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not available", name)
 	}
+	return p, nil
+}
 
-	return &paystackResp, nil
+// DefaultProvider returns the name of the default provider
+func (s *PaymentService) DefaultProvider() string {
+	return s.defaultProvider
 }
 
-func (s *PaymentService) GetPublicKey() string {
-	return s.publicKey
+// Providers returns the names of the available providers
+func (s *PaymentService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// ErrPaymentAmountMismatch is returned when a provider reports a successful charge
+// whose amount or currency differs from the order. Such payments are left pending
+// for staff to review rather than marking the order paid.
+var ErrPaymentAmountMismatch = errors.New("payment amount does not match order total")
+
+// PaymentSettlementService applies the outcome of a charge to the payment and its
+// order. It is shared by payment verification and webhooks so both settle payments
+// the same way, and settling an already-settled payment is a no-op.
+type PaymentSettlementService struct {
+	paymentRepo *repository.PaymentRepository
+	orderRepo   *repository.OrderRepository
+	notifier    *NotificationService
+}
+
+// NewPaymentSettlementService creates a new payment settlement service
+func NewPaymentSettlementService(paymentRepo *repository.PaymentRepository, orderRepo *repository.OrderRepository, notifier *NotificationService) *PaymentSettlementService {
+	return &PaymentSettlementService{
+		paymentRepo: paymentRepo,
+		orderRepo:   orderRepo,
+		notifier:    notifier,
+	}
+}
+
+// SettleSuccess records a successful charge of amountKobo and marks the order paid.
+// The amount and currency must match the order; otherwise ErrPaymentAmountMismatch
+// is returned and nothing is changed. Payments that already succeeded, including
+// refunded ones, are left alone.
+func (s *PaymentSettlementService) SettleSuccess(ctx context.Context, payment *models.Payment, amountKobo int64, currency, rawResponse, note string, actorID uuid.UUID) error {
+	switch payment.Status {
+	case models.PaymentStatusPending, models.PaymentStatusFailed, models.PaymentStatusExpired:
+	default:
+		return nil
+	}
+
+	order, err := s.orderRepo.GetByID(ctx, payment.OrderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		return models.ErrOrderNotFound
+	}
+
+	expected := order.Total.Minor()
+	if amountKobo != expected || !strings.EqualFold(currency, payment.Currency) {
+		return fmt.Errorf("%w: charged %d %s, expected %d %s", ErrPaymentAmountMismatch, amountKobo, currency, expected, payment.Currency)
+	}
+
+	if err := s.paymentRepo.UpdateStatus(ctx, payment.ProviderReference, models.PaymentStatusSuccess, rawResponse); err != nil {
+		return err
+	}
+	payment.Status = models.PaymentStatusSuccess
+
+	err = s.orderRepo.UpdateStatus(ctx, order.ID, models.OrderStatusPaid, note, actorID)
+	var transitionErr *models.StatusTransitionError
+	if errors.As(err, &transitionErr) {
+		// Already paid through another path, or cancelled meanwhile; the payment is
+		// recorded either way
+		fmt.Printf("DEBUG: Order %s not marked paid: %v\n", order.ID, err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.notifier.PaymentSucceeded(order.ID)
+	return nil
+}
+
+// SettleFailure records a failed charge and sends the order back to pending so the
+// customer can retry. A payment that has already succeeded is left alone.
+func (s *PaymentSettlementService) SettleFailure(ctx context.Context, payment *models.Payment, rawResponse, note string, actorID uuid.UUID) error {
+	return s.close(ctx, payment, models.PaymentStatusFailed, rawResponse, note, actorID)
+}
+
+// Expire closes a payment the customer never completed and sends the order back to
+// pending. A later successful charge on the same reference still settles.
+func (s *PaymentSettlementService) Expire(ctx context.Context, payment *models.Payment, rawResponse, note string, actorID uuid.UUID) error {
+	return s.close(ctx, payment, models.PaymentStatusExpired, rawResponse, note, actorID)
+}
+
+// close moves a pending payment to a final unpaid status
+func (s *PaymentSettlementService) close(ctx context.Context, payment *models.Payment, status models.PaymentStatus, rawResponse, note string, actorID uuid.UUID) error {
+	if payment.Status != models.PaymentStatusPending {
+		return nil
+	}
+
+	if rawResponse != "" {
+		if err := s.paymentRepo.UpdateStatus(ctx, payment.ProviderReference, status, rawResponse); err != nil {
+			return err
+		}
+	} else if err := s.paymentRepo.SetStatus(ctx, payment.ProviderReference, status); err != nil {
+		return err
+	}
+	payment.Status = status
+
+	err := s.orderRepo.UpdateStatus(ctx, payment.OrderID, models.OrderStatusPending, note, actorID)
+	var transitionErr *models.StatusTransitionError
+	if err != nil && !errors.As(err, &transitionErr) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const paystackBaseURL = "https://api.paystack.co"
+
+// PaystackProvider implements PaymentProvider for Paystack
+type PaystackProvider struct {
+	secretKey  string
+	publicKey  string
+	httpClient *http.Client
+}
+
+func NewPaystackProvider(secretKey, publicKey string) *PaystackProvider {
+	return &PaystackProvider{
+		secretKey:  secretKey,
+		publicKey:  publicKey,
+		httpClient: &http.Client{},
+	}
+}
+
+type paystackInitRequest struct {
+	Email     string            `json:"email"`
+	Amount    int64             `json:"amount"` // Amount in kobo (smallest currency unit)
+	Currency  string            `json:"currency,omitempty"`
+	Reference string            `json:"reference"`
+	Callback  string            `json:"callback_url,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+type paystackInitResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		AuthorizationURL string `json:"authorization_url"`
+		AccessCode       string `json:"access_code"`
+		Reference        string `json:"reference"`
+	} `json:"data"`
+}
+
+type paystackVerifyResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		ID              int64  `json:"id"`
+		Status          string `json:"status"`
+		Reference       string `json:"reference"`
+		Amount          int64  `json:"amount"`
+		GatewayResponse string `json:"gateway_response"`
+		PaidAt          string `json:"paid_at"`
+		Channel         string `json:"channel"`
+		Currency        string `json:"currency"`
+		Customer        struct {
+			Email string `json:"email"`
+		} `json:"customer"`
+		Metadata map[string]interface{} `json:"metadata"`
+	} `json:"data"`
+}
+
+type paystackRefundRequest struct {
+	Transaction  string `json:"transaction"`
+	Amount       int64  `json:"amount,omitempty"`
+	MerchantNote string `json:"merchant_note,omitempty"`
+}
+
+type paystackRefundResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		ID     int64  `json:"id"`
+		Amount int64  `json:"amount"`
+		Status string `json:"status"`
+	} `json:"data"`
+}
+
+type paystackWebhookPayload struct {
+	Event string `json:"event"`
+	Data  struct {
+		ID                   int64  `json:"id"`
+		Reference            string `json:"reference"`
+		TransactionReference string `json:"transaction_reference"`
+		TransferCode         string `json:"transfer_code"`
+		Amount               int64  `json:"amount"`
+		Currency             string `json:"currency"`
+		Status               string `json:"status"`
+		Channel              string `json:"channel"`
+		PaidAt               string `json:"paid_at"`
+		GatewayResponse      string `json:"gateway_response"`
+	} `json:"data"`
+}
+
+func (p *PaystackProvider) Name() string {
+	return "paystack"
+}
+
+func (p *PaystackProvider) GetPublicKey() string {
+	return p.publicKey
+}
+
+func (p *PaystackProvider) InitializePayment(ctx context.Context, req *PaymentInitRequest) (*PaymentInitResult, error) {
+	body := paystackInitRequest{
+		Email:     req.Email,
+		Amount:    req.AmountKobo,
+		Currency:  req.Currency,
+		Reference: req.Reference,
+		Callback:  req.CallbackURL,
+		Metadata:  req.Metadata,
+	}
+
+	var paystackResp paystackInitResponse
+	if err := p.do(ctx, "POST", "/transaction/initialize", body, &paystackResp); err != nil {
+		return nil, err
+	}
+	if !paystackResp.Status {
+		return nil, fmt.Errorf("paystack error: %s", paystackResp.Message)
+	}
+
+	return &PaymentInitResult{
+		AuthorizationURL: paystackResp.Data.AuthorizationURL,
+		AccessCode:       paystackResp.Data.AccessCode,
+		Reference:        paystackResp.Data.Reference,
+	}, nil
+}
+
+func (p *PaystackProvider) VerifyPayment(ctx context.Context, reference string) (*PaymentVerification, error) {
+	var paystackResp paystackVerifyResponse
+	if err := p.do(ctx, "GET", "/transaction/verify/"+reference, nil, &paystackResp); err != nil {
+		return nil, err
+	}
+
+	raw, _ := json.Marshal(paystackResp)
+	return &PaymentVerification{
+		Reference:       paystackResp.Data.Reference,
+		Status:          paystackChargeStatus(paystackResp.Data.Status),
+		ProviderStatus:  paystackResp.Data.Status,
+		AmountKobo:      paystackResp.Data.Amount,
+		Currency:        paystackResp.Data.Currency,
+		GatewayResponse: paystackResp.Data.GatewayResponse,
+		Raw:             string(raw),
+	}, nil
+}
+
+func (p *PaystackProvider) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	body := paystackRefundRequest{
+		Transaction:  req.Reference,
+		Amount:       req.AmountKobo,
+		MerchantNote: req.Reason,
+	}
+
+	var paystackResp paystackRefundResponse
+	if err := p.do(ctx, "POST", "/refund", body, &paystackResp); err != nil {
+		return nil, err
+	}
+	if !paystackResp.Status {
+		return nil, fmt.Errorf("paystack error: %s", paystackResp.Message)
+	}
+
+	raw, _ := json.Marshal(paystackResp)
+	return &RefundResult{
+		ProviderRefundID: fmt.Sprintf("%d", paystackResp.Data.ID),
+		Status:           paystackResp.Data.Status,
+		AmountKobo:       paystackResp.Data.Amount,
+		Raw:              string(raw),
+	}, nil
+}
+
+// VerifyWebhook checks the x-paystack-signature header, an HMAC-SHA512 of the body
+// keyed with the secret key
+func (p *PaystackProvider) VerifyWebhook(header http.Header, body []byte) bool {
+	mac := hmac.New(sha512.New, []byte(p.secretKey))
+	mac.Write(body)
+	expectedSig := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(header.Get("x-paystack-signature")), []byte(expectedSig))
+}
+
+func (p *PaystackProvider) ParseWebhook(body []byte) (*WebhookEvent, error) {
+	var payload paystackWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+
+	// Refund events carry the payment's reference as the transaction reference
+	reference := payload.Data.Reference
+	if payload.Data.TransactionReference != "" {
+		reference = payload.Data.TransactionReference
+	}
+
+	// Paystack resends the same payload when a delivery fails, so the event name plus
+	// the object's ID (or the reference when there is no ID) is stable
+	key := fmt.Sprintf("%s:%s", payload.Event, reference)
+	if payload.Data.ID != 0 {
+		key = fmt.Sprintf("%s:%d", payload.Event, payload.Data.ID)
+	}
+
+	event := &WebhookEvent{
+		Event:      payload.Event,
+		Key:        key,
+		Reference:  reference,
+		AmountKobo: payload.Data.Amount,
+		Currency:   payload.Data.Currency,
+		Detail:     payload.Data.GatewayResponse,
+	}
+
+	switch {
+	case payload.Event == "charge.success":
+		event.Kind = WebhookChargeSuccess
+	case payload.Event == "charge.failed":
+		event.Kind = WebhookChargeFailed
+	case payload.Event == "refund.processed":
+		event.Kind = WebhookRefundProcessed
+		event.RefundID = fmt.Sprintf("%d", payload.Data.ID)
+	case payload.Event == "refund.failed":
+		event.Kind = WebhookRefundFailed
+		event.RefundID = fmt.Sprintf("%d", payload.Data.ID)
+	case strings.HasPrefix(payload.Event, "transfer."):
+		event.Kind = WebhookTransfer
+		event.Detail = fmt.Sprintf("%s (%s)", payload.Data.TransferCode, payload.Data.Status)
+	default:
+		event.Kind = WebhookOther
+	}
+
+	return event, nil
+}
+
+// paystackChargeStatus maps a Paystack transaction status. Abandoned and reversed
+// transactions count as failed so the customer can try again.
+func paystackChargeStatus(status string) ChargeStatus {
+	switch status {
+	case "success":
+		return ChargeSuccess
+	case "ongoing", "pending", "processing", "queued":
+		return ChargePending
+	default:
+		return ChargeFailed
+	}
+}
+
+// do sends a request to the Paystack API and decodes the JSON response into out
+func (p *PaystackProvider) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("DEBUG: Sending to Paystack %s %s: %s\n", method, path, string(jsonData))
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, paystackBaseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("Authorization", "Bearer "+p.secretKey)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("DEBUG: Paystack response status: %d\n", resp.StatusCode)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Try to parse the error response
+		var errResp map[string]interface{}
+		if err := json.Unmarshal(respBody, &errResp); err == nil {
+			if message, ok := errResp["message"]; ok {
+				return fmt.Errorf("paystack error: %v (status %d)", message, resp.StatusCode)
+			}
+		}
+		return fmt.Errorf("paystack error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to parse paystack response: %v, body: %s", err, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// priceChangeBatchSize caps the scheduled price changes applied per pass
+const priceChangeBatchSize = 50
+
+// PriceChangeService makes bulk price updates, applying them straight away or
+// recording them to be applied by its job when they take effect
+type PriceChangeService struct {
+	priceChangeRepo *repository.PriceChangeRepository
+	productRepo     *repository.ProductRepository
+	interval        time.Duration
+	wg              sync.WaitGroup
+}
+
+// NewPriceChangeService creates the service. Its job looks for scheduled changes
+// that are due every interval; a zero interval disables it.
+func NewPriceChangeService(priceChangeRepo *repository.PriceChangeRepository, productRepo *repository.ProductRepository, interval time.Duration) *PriceChangeService {
+	return &PriceChangeService{
+		priceChangeRepo: priceChangeRepo,
+		productRepo:     productRepo,
+		interval:        interval,
+	}
+}
+
+// Preview works out the new base and tier prices a bulk update would give each
+// selected product, and what is wrong with them, without changing anything
+func (s *PriceChangeService) Preview(ctx context.Context, req *models.BulkUpdatePriceRequest) (*models.BulkPricePreview, error) {
+	products, err := s.productRepo.Select(ctx, req.ProductIDs, req.CategoryID, req.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &models.PriceChange{UpdateType: req.UpdateType, Value: req.Value}
+	preview := &models.BulkPricePreview{Products: []models.BulkPricePreviewProduct{}}
+	selected := make(map[uuid.UUID]bool, len(products))
+	for _, product := range products {
+		selected[product.ID] = true
+		newPrice := change.Adjust(product.BasePrice)
+		item := models.BulkPricePreviewProduct{
+			ProductID:  product.ID,
+			Name:       product.Name,
+			CostPrice:  product.CostPrice,
+			OldPrice:   product.BasePrice,
+			NewPrice:   newPrice,
+			Violations: models.PriceViolations(newPrice, product.CostPrice),
+		}
+		if change.AdjustsTiers() {
+			for _, tier := range product.PricingTiers {
+				tierPrice := change.Adjust(tier.Price)
+				t := models.BulkPricePreviewTier{
+					TierID:     tier.ID,
+					MinQty:     tier.MinQty,
+					MaxQty:     tier.MaxQty,
+					OldPrice:   tier.Price,
+					NewPrice:   tierPrice,
+					Violations: models.PriceViolations(tierPrice, product.CostPrice),
+				}
+				item.Tiers = append(item.Tiers, t)
+			}
+		}
+		if item.Violated() {
+			preview.ViolationCount++
+		}
+		preview.Products = append(preview.Products, item)
+	}
+	preview.ProductCount = len(preview.Products)
+
+	for _, productID := range req.ProductIDs {
+		if !selected[productID] {
+			preview.FailedIDs = append(preview.FailedIDs, productID.String())
+		}
+	}
+	return preview, nil
+}
+
+// Submit records a bulk price update for the selected products as one price
+// change. It is applied now unless it takes effect in the future. It fails with
+// a PriceViolationError if any of the prices it would set are not allowed.
+// Scheduled changes are checked again when they are applied.
+func (s *PriceChangeService) Submit(ctx context.Context, req *models.BulkUpdatePriceRequest, actor uuid.UUID) (*models.BulkUpdatePriceResponse, error) {
+	preview, err := s.Preview(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if preview.Blocks(req.AllowBelowCost) {
+		return nil, &models.PriceViolationError{Preview: preview}
+	}
+
+	response := &models.BulkUpdatePriceResponse{
+		FailedCount: len(preview.FailedIDs),
+		FailedIDs:   preview.FailedIDs,
+	}
+	change := &models.PriceChange{
+		UpdateType:     req.UpdateType,
+		Value:          req.Value,
+		Reason:         req.Reason,
+		EffectiveAt:    time.Now(),
+		CreatedBy:      &actor,
+		AllowBelowCost: req.AllowBelowCost,
+	}
+	if req.EffectiveAt != nil && req.EffectiveAt.After(change.EffectiveAt) {
+		change.EffectiveAt = *req.EffectiveAt
+	}
+	for _, product := range preview.Products {
+		change.ProductIDs = append(change.ProductIDs, product.ProductID)
+	}
+	if len(change.ProductIDs) == 0 {
+		return response, nil
+	}
+
+	if err := s.priceChangeRepo.Create(ctx, change); err != nil {
+		return nil, err
+	}
+	response.PriceChangeID = &change.ID
+	response.Status = models.PriceChangeScheduled
+
+	if change.EffectiveAt.After(time.Now()) {
+		response.ScheduledCount = len(change.ProductIDs)
+		return response, nil
+	}
+
+	updated, err := s.priceChangeRepo.Apply(ctx, change.ID)
+	if err != nil {
+		return nil, err
+	}
+	response.Status = models.PriceChangeApplied
+	response.UpdatedCount = updated
+	return response, nil
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (s *PriceChangeService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the job has exited
+func (s *PriceChangeService) Wait() {
+	s.wg.Wait()
+}
+
+// RunOnce applies the scheduled price changes that are due. Those that would now
+// set prices that are not allowed are blocked instead.
+func (s *PriceChangeService) RunOnce(ctx context.Context) {
+	ids, err := s.priceChangeRepo.GetDue(ctx, time.Now(), priceChangeBatchSize)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to load due price changes: %v\n", err)
+		return
+	}
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		_, err := s.priceChangeRepo.Apply(ctx, id)
+		if errors.Is(err, models.ErrPriceChangeNotScheduled) {
+			// Cancelled or applied since it was loaded
+			continue
+		}
+		var violationErr *models.PriceViolationError
+		if errors.As(err, &violationErr) {
+			fmt.Printf("ERROR: Blocked scheduled price change %s: %v\n", id, err)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("ERROR: Failed to apply price change %s: %v\n", id, err)
+		}
+	}
+}
@@ -2,9 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/formula"
 	"github.com/quikprint/backend/internal/models"
 	"github.com/quikprint/backend/internal/repository"
 )
@@ -12,62 +18,168 @@ import (
 type PricingService struct {
 	productRepo *repository.ProductRepository
 	pricingRepo *repository.PricingRepository
+	groupRepo   *repository.CustomerGroupRepository
 }
 
-func NewPricingService(productRepo *repository.ProductRepository, pricingRepo *repository.PricingRepository) *PricingService {
-	return &PricingService{productRepo: productRepo, pricingRepo: pricingRepo}
+func NewPricingService(productRepo *repository.ProductRepository, pricingRepo *repository.PricingRepository, groupRepo *repository.CustomerGroupRepository) *PricingService {
+	return &PricingService{productRepo: productRepo, pricingRepo: pricingRepo, groupRepo: groupRepo}
 }
 
+// CalculatePrice prices an item for a customer. It returns nil if the product
+// does not exist, and ErrProductUnavailable if it is not on sale.
 func (s *PricingService) CalculatePrice(ctx context.Context, req *models.CalculatePriceRequest) (*models.PriceBreakdown, error) {
-	product, err := s.productRepo.GetByID(ctx, req.ProductID)
+	product, err := s.availableProduct(ctx, req.ProductID)
 	if err != nil || product == nil {
 		return nil, err
 	}
+	formulas, err := s.pricingRepo.GetPricingFormulas(ctx, req.ProductID, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.calculate(ctx, product, req, formulas, nil)
+}
+
+// Snapshot prices an order line like CalculatePrice and records what it was priced
+// from, to be stored with the order. It returns nil if the product does not exist.
+func (s *PricingService) Snapshot(ctx context.Context, req *models.CalculatePriceRequest) (*models.OrderItemSnapshot, error) {
+	product, err := s.availableProduct(ctx, req.ProductID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	formulas, err := s.pricingRepo.GetPricingFormulas(ctx, req.ProductID, false)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &models.OrderItemSnapshot{}
+	breakdown, err := s.calculate(ctx, product, req, formulas, snapshot)
+	if err != nil || breakdown == nil {
+		return nil, err
+	}
+	now := time.Now()
+	snapshot.Breakdown = breakdown
+	snapshot.PricedAt = &now
+	return snapshot, nil
+}
+
+// DryRun prices each sample using formulas in place of the product's published
+// formulas, without saving anything. Samples that cannot be priced carry the
+// reason instead of a breakdown. Products that are not on sale can be priced.
+// It returns nil if the product does not exist.
+func (s *PricingService) DryRun(ctx context.Context, productID uuid.UUID, formulas []models.PricingFormula, samples []models.FormulaSample) ([]models.FormulaDryRunResult, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+
+	results := make([]models.FormulaDryRunResult, len(samples))
+	for i, sample := range samples {
+		results[i].FormulaSample = sample
+		breakdown, err := s.calculate(ctx, product, &models.CalculatePriceRequest{
+			ProductID:     productID,
+			Configuration: sample.Configuration,
+			Quantity:      sample.Quantity,
+		}, formulas, nil)
+		if IsPricingInputError(err) {
+			results[i].Error = err.Error()
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i].Breakdown = breakdown
+	}
+	return results, nil
+}
+
+// IsPricingInputError reports whether a price calculation failed because of the
+// item configuration, or the product, rather than the server
+func IsPricingInputError(err error) bool {
+	var configErr *models.ConfigurationError
+	return errors.As(err, &configErr) || errors.Is(err, models.ErrPricingFormula) || errors.Is(err, models.ErrProductUnavailable)
+}
+
+// availableProduct loads a product customers can buy. It returns nil if there is
+// no such product, and ErrProductUnavailable if it is not on sale.
+func (s *PricingService) availableProduct(ctx context.Context, productID uuid.UUID) (*models.Product, error) {
+	product, err := s.productRepo.GetByID(ctx, productID)
+	if err != nil || product == nil {
+		return nil, err
+	}
+	if !product.IsAvailable() {
+		return nil, models.ErrProductUnavailable
+	}
+	return product, nil
+}
+
+// calculate prices an order line of product. When snapshot is not nil, the
+// product, options, charged rules and formulas the price came from are recorded
+// in it.
+func (s *PricingService) calculate(ctx context.Context, product *models.Product, req *models.CalculatePriceRequest, formulas []models.PricingFormula, snapshot *models.OrderItemSnapshot) (*models.PriceBreakdown, error) {
+	err := product.ValidateConfiguration(req.Configuration, req.Quantity)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot != nil {
+		snapshot.ProductName = product.Name
+		snapshot.ProductSlug = product.Slug
+		snapshot.Options = product.OptionLabels(req.Configuration)
+		snapshot.Formulas = formulas
+	}
+
+	// A customer in a group is priced from the group's price list
+	var group *models.GroupPricing
+	if req.UserID != uuid.Nil {
+		group, err = s.groupRepo.GetPricing(ctx, req.UserID, product.ID, product.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	basePrice := product.BasePrice
+	if group != nil && group.Price != nil {
+		basePrice = *group.Price
+	}
 
 	breakdown := &models.PriceBreakdown{
-		BasePrice:       product.BasePrice,
-		OptionModifiers: make(map[string]float64),
-		AddOns:          make(map[string]float64),
+		BasePrice:       basePrice,
+		OptionModifiers: make(map[string]models.Money),
+		AddOns:          make(map[string]models.Money),
+	}
+
+	dimPricing, err := s.pricingRepo.GetDimensionalPricing(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Calculate option modifiers from product options
-	for _, option := range product.Options {
+	for i := range product.Options {
+		option := &product.Options[i]
+		if dimPricing != nil && isSizeOption(option) {
+			// The size is priced by area below, not by its value
+			continue
+		}
 		if val, ok := req.Configuration[option.ID]; ok {
-			switch option.Type {
-			case models.OptionTypeSelect, models.OptionTypeRadio:
-				if strVal, ok := val.(string); ok {
-					for _, opt := range option.Options {
-						if opt.Value == strVal && opt.PriceModifier != nil {
-							breakdown.OptionModifiers[option.Name] = *opt.PriceModifier
-						}
-					}
-				}
-			case models.OptionTypeDimension:
-				// Handle dimensional pricing
-				if floatVal, ok := val.(float64); ok {
-					breakdown.OptionModifiers[option.Name] = floatVal
-				}
+			if price, ok := optionPrice(option, val); ok {
+				breakdown.OptionModifiers[option.Name] = price
 			}
 		}
 	}
 
 	// Check for dimensional pricing
-	dimPricing, _ := s.pricingRepo.GetDimensionalPricing(ctx, req.ProductID)
 	if dimPricing != nil {
-		width := getFloatFromConfig(req.Configuration, "width")
-		height := getFloatFromConfig(req.Configuration, "height")
-		if width > 0 && height > 0 {
-			area := width * height
-			dimCost := area * dimPricing.RatePerUnit
-			if dimCost < dimPricing.MinCharge {
-				dimCost = dimPricing.MinCharge
-			}
-			breakdown.DimensionalCost = dimCost
+		if err := applyDimensionalPricing(product, dimPricing, req.Configuration, breakdown); err != nil {
+			return nil, err
 		}
 	}
 
 	// Check for quantity-based pricing tiers
 	tiers, _ := s.pricingRepo.GetPricingTiers(ctx, req.ProductID)
+	if group != nil {
+		breakdown.CustomerGroup = group.GroupName
+		if group.ProductSpecific() {
+			// A negotiated price stands in for the catalogue tiers
+			tiers = group.Tiers
+		}
+	}
 	quantity := req.Quantity
 	if quantity == 0 {
 		quantity = getIntFromConfig(req.Configuration, "quantity")
@@ -83,64 +195,516 @@ func (s *PricingService) CalculatePrice(ctx context.Context, req *models.Calcula
 		}
 	}
 
-	// Get pricing rules (setup fees, rush fees, etc.)
-	rules, _ := s.pricingRepo.GetPricingRules(ctx, req.ProductID)
-	for _, rule := range rules {
-		switch rule.RuleType {
-		case "setup_fee":
-			breakdown.SetupFee = rule.Value
-		case "rush_fee":
-			if isRush, ok := req.Configuration["rush"].(bool); ok && isRush {
-				breakdown.RushFee = rule.Value
-			}
-		}
-	}
-
 	// Calculate subtotal
 	subtotal := breakdown.BasePrice
 	for _, mod := range breakdown.OptionModifiers {
-		subtotal += mod
+		subtotal = subtotal.Add(mod)
 	}
 
 	// Use quantity price if available, otherwise use base calculation
-	if breakdown.QuantityPrice > 0 {
+	if breakdown.QuantityPrice.IsPositive() {
 		subtotal = breakdown.QuantityPrice
 		for _, mod := range breakdown.OptionModifiers {
-			subtotal += mod
+			subtotal = subtotal.Add(mod)
 		}
 	}
 
 	// Add dimensional cost
-	if breakdown.DimensionalCost > 0 {
+	if breakdown.DimensionalCost.IsPositive() {
 		subtotal = breakdown.DimensionalCost
 		for _, mod := range breakdown.OptionModifiers {
-			subtotal += mod
+			subtotal = subtotal.Add(mod)
+		}
+		for _, price := range breakdown.Finishing {
+			subtotal = subtotal.Add(price)
 		}
 	}
 
-	breakdown.Subtotal = subtotal
-	breakdown.Total = subtotal + breakdown.SetupFee + breakdown.RushFee
+	// Multiply by quantity to get the cost of all units
+	// Note: quantity is used for pricing tier selection, so the line covers all units
+	lineQty := req.Quantity
+	if lineQty < 1 {
+		lineQty = 1
+	}
+	breakdown.Subtotal = subtotal.Times(lineQty)
 
-	// Add add-ons
-	for name, price := range breakdown.AddOns {
-		breakdown.Total += price
-		_ = name
+	// Formulas, when the product has them, replace all of the above and price the
+	// line directly
+	if len(formulas) > 0 {
+		line, err := evaluateFormulas(product, req, formulas, lineQty, breakdown)
+		if err != nil {
+			return nil, err
+		}
+		breakdown.Subtotal = line
+		subtotal = line.Div(lineQty)
 	}
 
-	// Multiply by quantity to get total cost for all units
-	// Note: quantity is used for pricing tier selection, so final total should include all units
-	if req.Quantity > 0 {
-		breakdown.Subtotal *= float64(req.Quantity)
-		breakdown.Total *= float64(req.Quantity)
+	// A group's category discount comes off the line before add-ons and fees
+	if group != nil && !group.ProductSpecific() && group.CategoryDiscount > 0 {
+		breakdown.GroupDiscount = breakdown.Subtotal.Percent(group.CategoryDiscount)
+		breakdown.Subtotal = breakdown.Subtotal.Sub(breakdown.GroupDiscount)
+		subtotal = subtotal.Sub(subtotal.Percent(group.CategoryDiscount))
+	}
+
+	// Add-ons are priced per unit, percentages on the unit subtotal
+	if err := s.applyAddOns(ctx, req, subtotal, breakdown); err != nil {
+		return nil, err
+	}
+	unitPrice := subtotal
+	items := breakdown.Subtotal
+	for _, price := range breakdown.AddOns {
+		unitPrice = unitPrice.Add(price)
+		items = items.Add(price.Times(lineQty))
+	}
+
+	// Pricing rules (setup fees, rush fees, minimum charge) apply to the whole line
+	rules, err := s.pricingRepo.GetPricingRules(ctx, req.ProductID)
+	if err != nil {
+		return nil, err
+	}
+	isRush, _ := req.Configuration["rush"].(bool)
+	applyPricingRules(rules, isRush, items, breakdown)
+	if snapshot != nil {
+		snapshot.Rules = chargedRules(rules, breakdown.Rules)
 	}
 
 	// Debug logging for pricing calculation
-	fmt.Printf("DEBUG PRICING: ProductID=%s, Quantity=%d, UnitPrice=%.2f, SetupFee=%.2f, RushFee=%.2f, FinalTotal=%.2f\n",
-		req.ProductID, req.Quantity, subtotal, breakdown.SetupFee, breakdown.RushFee, breakdown.Total)
+	fmt.Printf("DEBUG PRICING: ProductID=%s, Quantity=%d, UnitPrice=%s, SetupFee=%s, RushFee=%s, MinimumCharge=%s, FinalTotal=%s\n",
+		req.ProductID, req.Quantity, unitPrice, breakdown.SetupFee, breakdown.RushFee, breakdown.MinimumCharge, breakdown.Total)
 
 	return breakdown, nil
 }
 
+// Variables and functions available to pricing formulas, besides the built-ins
+var (
+	formulaVars = map[string]bool{
+		"quantity": true, "base_price": true, "tier_price": true, "dimensional_cost": true,
+		"finishing": true, "options": true, "width": true, "height": true, "area": true, "rush": true, "total": true,
+	}
+	formulaFuncs = map[string]bool{"config": true, "has": true, "option": true}
+)
+
+// ParseFormula parses a pricing formula and checks that it only uses the
+// variables and functions available to pricing formulas
+func ParseFormula(expression string) (*formula.Expr, error) {
+	expr, err := formula.Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+	vars, funcs := expr.Names()
+	for _, name := range vars {
+		if !formulaVars[name] {
+			return nil, fmt.Errorf("unknown variable %q", name)
+		}
+	}
+	for _, name := range funcs {
+		if !formulaFuncs[name] {
+			return nil, fmt.Errorf("unknown function %q", name)
+		}
+	}
+	return expr, nil
+}
+
+// evaluateFormulas evaluates formulas in order, recording each contribution in
+// the breakdown, and returns the line subtotal they add up to. Each formula can
+// read the running total of those before it as `total`. A negative subtotal is an
+// ErrPricingFormula. Prices are read from the breakdown so far, so base_price is a
+// customer group's fixed price when there is one.
+func evaluateFormulas(product *models.Product, req *models.CalculatePriceRequest, formulas []models.PricingFormula, quantity int, breakdown *models.PriceBreakdown) (models.Money, error) {
+	var options, finishing models.Money
+	for _, mod := range breakdown.OptionModifiers {
+		options = options.Add(mod)
+	}
+	for _, price := range breakdown.Finishing {
+		finishing = finishing.Add(price)
+	}
+	width := formula.Number(getFloatFromConfig(req.Configuration, "width"))
+	height := formula.Number(getFloatFromConfig(req.Configuration, "height"))
+	isRush, _ := req.Configuration["rush"].(bool)
+
+	vars := map[string]formula.Value{
+		"quantity":         big.NewRat(int64(quantity), 1),
+		"base_price":       breakdown.BasePrice.Rat(),
+		"tier_price":       breakdown.QuantityPrice.Rat(),
+		"dimensional_cost": breakdown.DimensionalCost.Rat(),
+		"finishing":        finishing.Rat(),
+		"options":          options.Rat(),
+		"width":            width,
+		"height":           height,
+		"area":             new(big.Rat).Mul(width, height),
+		"rush":             isRush,
+	}
+	env := &formula.Env{Vars: vars, Funcs: map[string]formula.Func{
+		"config": func(args []formula.Value) (formula.Value, error) {
+			key, err := stringArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return configValue(req.Configuration, key)
+		},
+		"has": func(args []formula.Value) (formula.Value, error) {
+			key, err := stringArg(args)
+			if err != nil {
+				return nil, err
+			}
+			val, ok := req.Configuration[key]
+			return ok && val != nil, nil
+		},
+		"option": func(args []formula.Value) (formula.Value, error) {
+			id, err := stringArg(args)
+			if err != nil {
+				return nil, err
+			}
+			return optionModifier(product, req.Configuration, id)
+		},
+	}}
+
+	var total models.Money
+	for _, f := range formulas {
+		vars["total"] = total.Rat()
+		expr, err := ParseFormula(f.Expression)
+		if err != nil {
+			return models.Money{}, fmt.Errorf("%w: %s: %v", models.ErrPricingFormula, f.Label, err)
+		}
+		result, err := expr.EvalNumber(env)
+		if err != nil {
+			return models.Money{}, fmt.Errorf("%w: %s: %v", models.ErrPricingFormula, f.Label, err)
+		}
+		amount := models.MoneyFromRat(result)
+		breakdown.Formula = append(breakdown.Formula, models.AppliedFormula{FormulaID: f.ID, Label: f.Label, Amount: amount})
+		total = total.Add(amount)
+	}
+	// A step may take something off, but the line as a whole cannot pay the customer
+	if total.IsNegative() {
+		return models.Money{}, fmt.Errorf("%w: the formulas price the line at %s, below zero", models.ErrPricingFormula, total)
+	}
+	return total, nil
+}
+
+func stringArg(args []formula.Value) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("takes 1 argument, got %d", len(args))
+	}
+	s, ok := args[0].(string)
+	if !ok {
+		return "", fmt.Errorf("needs a string argument")
+	}
+	return s, nil
+}
+
+// configValue returns a configuration value for use in a formula
+func configValue(config map[string]interface{}, key string) (formula.Value, error) {
+	switch v := config[key].(type) {
+	case nil:
+		return nil, fmt.Errorf("%q is not set", key)
+	case float64:
+		return formula.Number(v), nil
+	case int:
+		return big.NewRat(int64(v), 1), nil
+	case string, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("%q is not a number, string or boolean", key)
+}
+
+// optionPrice returns what the value chosen for an option adds to the unit price,
+// and whether it adds anything
+func optionPrice(option *models.ProductOption, val interface{}) (models.Money, bool) {
+	switch option.Type {
+	case models.OptionTypeSelect, models.OptionTypeRadio, models.OptionTypeCheckbox:
+		var price models.Money
+		priced := false
+		for _, opt := range option.Selected(val) {
+			if opt.PriceModifier != nil {
+				price = price.Add(*opt.PriceModifier)
+				priced = true
+			}
+		}
+		return price, priced
+	case models.OptionTypeQuantity:
+		if n, ok := models.WholeNumber(val); ok && option.PricePerUnit != nil {
+			return option.PricePerUnit.Times(n), true
+		}
+	case models.OptionTypeDimension:
+		// Handle dimensional pricing
+		if floatVal, ok := val.(float64); ok {
+			return models.MoneyFromFloat(floatVal), true
+		}
+	}
+	return models.Money{}, false
+}
+
+// optionModifier returns what the value chosen for a product option adds to the
+// unit price, or zero if nothing priced is chosen
+func optionModifier(product *models.Product, config map[string]interface{}, id string) (formula.Value, error) {
+	for i := range product.Options {
+		option := &product.Options[i]
+		if option.ID != id {
+			continue
+		}
+		price, _ := optionPrice(option, config[id])
+		return price.Rat(), nil
+	}
+	return nil, fmt.Errorf("product has no option %q", id)
+}
+
+// isSizeOption reports whether an option is the width or height of a piece priced
+// by area
+func isSizeOption(option *models.ProductOption) bool {
+	return option.Type == models.OptionTypeDimension &&
+		(option.ID == models.ConfigWidth || option.ID == models.ConfigHeight)
+}
+
+// sizeUnit returns the length unit the width or height in a configuration is
+// given in: the unit of the product's option for it, if it has one, or the
+// dimensional pricing's size unit
+func sizeUnit(product *models.Product, dp *models.DimensionalPricing, key string) string {
+	for i := range product.Options {
+		option := &product.Options[i]
+		if option.ID == key && isSizeOption(option) && option.Unit != nil {
+			if unit, ok := models.LengthUnit(*option.Unit); ok {
+				return unit
+			}
+		}
+	}
+	return dp.SizeUnit
+}
+
+// applyDimensionalPricing prices the width by height piece in the configuration
+// into breakdown.DimensionalCost, and the edge finishing chosen for it into
+// breakdown.Finishing. A size outside the product's limits, or an unknown
+// finishing or edge, fails with a *models.ConfigurationError.
+func applyDimensionalPricing(product *models.Product, dp *models.DimensionalPricing, config map[string]interface{}, breakdown *models.PriceBreakdown) error {
+	width := getFloatFromConfig(config, models.ConfigWidth)
+	height := getFloatFromConfig(config, models.ConfigHeight)
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	width = models.ConvertLength(width, sizeUnit(product, dp, models.ConfigWidth), dp.SizeUnit)
+	height = models.ConvertLength(height, sizeUnit(product, dp, models.ConfigHeight), dp.SizeUnit)
+
+	errs := &models.ConfigurationError{}
+	checkSize(errs, models.ConfigWidth, width, dp.MinWidth, dp.MaxWidth, dp.SizeUnit)
+	checkSize(errs, models.ConfigHeight, height, dp.MinHeight, dp.MaxHeight, dp.SizeUnit)
+	finishes := selectedFinishing(errs, dp, config[models.ConfigFinishing])
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+
+	// Bleed is printed and charged for on every edge
+	rateUnit, ok := models.AreaLengthUnit(dp.Unit)
+	if !ok {
+		rateUnit = dp.SizeUnit
+	}
+	printedWidth := models.ConvertLength(width+2*dp.Bleed, dp.SizeUnit, rateUnit)
+	printedHeight := models.ConvertLength(height+2*dp.Bleed, dp.SizeUnit, rateUnit)
+	dimCost := models.AmountAtRate(dp.RatePerUnit, printedWidth*printedHeight)
+	if dimCost.LessThan(dp.MinCharge) {
+		dimCost = dp.MinCharge
+	}
+	breakdown.DimensionalCost = dimCost
+
+	for i := range dp.Finishings {
+		f := &dp.Finishings[i]
+		if edges, ok := finishes[f.ID]; ok && len(edges) > 0 {
+			if breakdown.Finishing == nil {
+				breakdown.Finishing = make(map[string]models.Money)
+			}
+			breakdown.Finishing[f.Name] = f.Price(width, height, edges)
+		}
+	}
+	return nil
+}
+
+func checkSize(errs *models.ConfigurationError, field string, size float64, min, max *float64, unit string) {
+	switch {
+	case min != nil && size < *min:
+		errs.Fields = append(errs.Fields, models.FieldError{Field: field, Message: fmt.Sprintf("must be at least %g %s", *min, unit)})
+	case max != nil && size > *max:
+		errs.Fields = append(errs.Fields, models.FieldError{Field: field, Message: fmt.Sprintf("must be at most %g %s", *max, unit)})
+	}
+}
+
+// selectedFinishing reads the configuration's finishing, which maps finishing IDs
+// to true for every edge, false for none, or a list of edges, into the edges to
+// finish with each
+func selectedFinishing(errs *models.ConfigurationError, dp *models.DimensionalPricing, val interface{}) map[string]map[string]bool {
+	chosen, _ := val.(map[string]interface{})
+	ids := make([]string, 0, len(chosen))
+	for id := range chosen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	allEdges := []interface{}{models.EdgeTop, models.EdgeBottom, models.EdgeLeft, models.EdgeRight}
+	selected := make(map[string]map[string]bool, len(chosen))
+	for _, id := range ids {
+		known := false
+		for _, f := range dp.Finishings {
+			known = known || f.ID == id
+		}
+		if !known {
+			errs.Fields = append(errs.Fields, models.FieldError{Field: models.ConfigFinishing, Message: fmt.Sprintf("%q is not a finishing of this product", id)})
+			continue
+		}
+
+		var list []interface{}
+		switch v := chosen[id].(type) {
+		case bool:
+			if v {
+				list = allEdges
+			}
+		case []interface{}:
+			list = v
+		default:
+			errs.Fields = append(errs.Fields, models.FieldError{Field: models.ConfigFinishing, Message: fmt.Sprintf("%s must be true, false or a list of edges", id)})
+			continue
+		}
+
+		edges := make(map[string]bool, len(list))
+		for _, item := range list {
+			switch edge, _ := item.(string); edge {
+			case models.EdgeTop, models.EdgeBottom, models.EdgeLeft, models.EdgeRight:
+				edges[edge] = true
+			default:
+				errs.Fields = append(errs.Fields, models.FieldError{Field: models.ConfigFinishing, Message: fmt.Sprintf("%s: %v is not an edge; use top, bottom, left or right", id, item)})
+			}
+		}
+		selected[id] = edges
+	}
+	return selected
+}
+
+// applyAddOns prices the add-ons listed under "addOns" in the configuration into
+// breakdown.AddOns. Selecting an add-on the product does not have, or one that is
+// disabled, fails with a *models.ConfigurationError.
+func (s *PricingService) applyAddOns(ctx context.Context, req *models.CalculatePriceRequest, unitSubtotal models.Money, breakdown *models.PriceBreakdown) error {
+	selected, _ := req.Configuration[models.ConfigAddOns].([]interface{})
+	if len(selected) == 0 {
+		return nil
+	}
+
+	addOns, err := s.pricingRepo.GetAllAddOns(ctx, req.ProductID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]models.AddOn, len(addOns))
+	for _, a := range addOns {
+		byID[a.ID.String()] = a
+	}
+
+	errs := &models.ConfigurationError{}
+	seen := make(map[string]bool)
+	for _, v := range selected {
+		id, _ := v.(string)
+		addOn, ok := byID[id]
+		if !ok || !addOn.Enabled {
+			errs.Fields = append(errs.Fields, models.FieldError{
+				Field:   models.ConfigAddOns,
+				Message: fmt.Sprintf("%v is not available for this product", v),
+			})
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var amount models.Money
+		switch addOn.Type {
+		case models.AddOnTypePercentage:
+			amount = unitSubtotal.Percent(addOn.PriceModifier)
+		default:
+			amount = models.MoneyFromFloat(addOn.PriceModifier)
+		}
+		breakdown.AddOns[addOn.Name] = breakdown.AddOns[addOn.Name].Add(amount)
+	}
+	if len(errs.Fields) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// applyPricingRules charges the setup and rush fees once on top of the line's items
+// and then raises the total to the highest minimum charge. Rush fees only apply
+// when rush is set.
+func applyPricingRules(rules []models.PricingRule, rush bool, items models.Money, breakdown *models.PriceBreakdown) {
+	total := items
+	var minimum *models.PricingRule
+	for i, rule := range rules {
+		var amount models.Money
+		switch rule.RuleType {
+		case models.PricingRuleSetupFee:
+			amount = ruleAmount(rule, items)
+			breakdown.SetupFee = breakdown.SetupFee.Add(amount)
+		case models.PricingRuleRushFee:
+			if !rush {
+				continue
+			}
+			amount = ruleAmount(rule, items)
+			breakdown.RushFee = breakdown.RushFee.Add(amount)
+		case models.PricingRuleMinimumCharge:
+			if minimum == nil || rule.Value > minimum.Value {
+				minimum = &rules[i]
+			}
+			continue
+		default:
+			continue
+		}
+		total = total.Add(amount)
+		breakdown.Rules = append(breakdown.Rules, appliedRule(rule, amount))
+	}
+
+	if minimum != nil {
+		floor := models.MoneyFromFloat(minimum.Value)
+		if total.LessThan(floor) {
+			breakdown.MinimumCharge = floor.Sub(total)
+			breakdown.Rules = append(breakdown.Rules, appliedRule(*minimum, breakdown.MinimumCharge))
+			total = floor
+		}
+	}
+	breakdown.Total = total
+}
+
+// chargedRules returns the rules that were charged, in the order they were
+func chargedRules(rules []models.PricingRule, charged []models.AppliedRule) []models.PricingRule {
+	byID := make(map[uuid.UUID]models.PricingRule, len(rules))
+	for _, rule := range rules {
+		byID[rule.ID] = rule
+	}
+	var used []models.PricingRule
+	for _, applied := range charged {
+		used = append(used, byID[applied.RuleID])
+	}
+	return used
+}
+
+func ruleAmount(rule models.PricingRule, items models.Money) models.Money {
+	if rule.ValueType == models.RuleValuePercentage {
+		return items.Percent(rule.Value)
+	}
+	return models.MoneyFromFloat(rule.Value)
+}
+
+// appliedRule labels a charged rule with its description, or a default label
+func appliedRule(rule models.PricingRule, amount models.Money) models.AppliedRule {
+	label := rule.Description
+	if label == "" {
+		switch rule.RuleType {
+		case models.PricingRuleSetupFee:
+			label = "Setup fee"
+		case models.PricingRuleRushFee:
+			label = "Rush fee"
+		case models.PricingRuleMinimumCharge:
+			label = "Minimum charge"
+		}
+		if rule.ValueType == models.RuleValuePercentage {
+			label += fmt.Sprintf(" (%s%%)", strconv.FormatFloat(rule.Value, 'f', -1, 64))
+		}
+	}
+	return models.AppliedRule{RuleID: rule.ID, RuleType: rule.RuleType, Label: label, Amount: amount}
+}
+
 func getFloatFromConfig(config map[string]interface{}, key string) float64 {
 	if val, ok := config[key]; ok {
 		if f, ok := val.(float64); ok {
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quikprint/backend/internal/models"
+)
+
+func TestEvaluateFormulas(t *testing.T) {
+	product := &models.Product{}
+	req := &models.CalculatePriceRequest{Configuration: map[string]interface{}{}}
+
+	tests := []struct {
+		name        string
+		expressions []string
+		want        models.Money
+		wantErr     bool
+	}{
+		{"single formula", []string{"base_price * quantity"}, models.Kobo(500000), false},
+		{"negative step", []string{"base_price * quantity", "-total / 10"}, models.Kobo(450000), false},
+		{"zero", []string{"base_price * quantity", "-total"}, models.Kobo(0), false},
+		{"below zero", []string{"base_price * quantity", "-800 * quantity"}, models.Money{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var formulas []models.PricingFormula
+			for i, expr := range tt.expressions {
+				formulas = append(formulas, models.PricingFormula{Position: i + 1, Label: "Step", Expression: expr})
+			}
+			got, err := evaluateFormulas(product, req, formulas, 10, &models.PriceBreakdown{BasePrice: models.Kobo(50000)})
+			if tt.wantErr {
+				if !errors.Is(err, models.ErrPricingFormula) {
+					t.Fatalf("error = %v, want ErrPricingFormula", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if got.Cmp(tt.want) != 0 {
+				t.Errorf("= %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyDimensionalPricing(t *testing.T) {
+	cm := "cm"
+	sizeOptions := func(unit *string) []models.ProductOption {
+		return []models.ProductOption{
+			{ID: models.ConfigWidth, Name: "Width", Type: models.OptionTypeDimension, Unit: unit},
+			{ID: models.ConfigHeight, Name: "Height", Type: models.OptionTypeDimension, Unit: unit},
+		}
+	}
+	four, one := 4.0, 1.0
+	grommets := models.EdgeFinishing{ID: "grommets", Name: "Grommets", Rate: 50, Spacing: 1}
+
+	tests := []struct {
+		name          string
+		unit          *string
+		dp            func(dp *models.DimensionalPricing)
+		config        map[string]interface{}
+		wantCost      models.Money
+		wantFinishing map[string]models.Money
+		wantErr       string
+	}{
+		{
+			// 91.44 × 60.96 cm is 3 × 2 ft, printed at 3.5 × 2.5 ft with bleed
+			name:     "cm size at a sqft rate with bleed",
+			unit:     &cm,
+			config:   map[string]interface{}{"width": 91.44, "height": 60.96},
+			wantCost: models.Kobo(87500),
+		},
+		{
+			// 36 × 24 in with 3 in of bleed each side is 42 × 30 in, 3.5 × 2.5 ft
+			name:     "cm size in inches at a sqft rate",
+			unit:     &cm,
+			dp:       func(dp *models.DimensionalPricing) { dp.SizeUnit = "in"; dp.Bleed = 3 },
+			config:   map[string]interface{}{"width": 91.44, "height": 60.96},
+			wantCost: models.Kobo(87500),
+		},
+		{
+			name:     "size options without a unit use the size unit",
+			config:   map[string]interface{}{"width": 3.0, "height": 2.0},
+			wantCost: models.Kobo(87500),
+		},
+		{
+			// 1.5 × 1.5 ft printed is ₦225
+			name:     "minimum charge",
+			unit:     &cm,
+			dp:       func(dp *models.DimensionalPricing) { dp.MinCharge = models.Kobo(50000) },
+			config:   map[string]interface{}{"width": 30.48, "height": 30.48},
+			wantCost: models.Kobo(50000),
+		},
+		{
+			name:     "at the maximum width",
+			unit:     &cm,
+			dp:       func(dp *models.DimensionalPricing) { dp.MaxWidth = &four },
+			config:   map[string]interface{}{"width": 121.92, "height": 60.96},
+			wantCost: models.Kobo(112500),
+		},
+		{
+			name:    "over the maximum width",
+			unit:    &cm,
+			dp:      func(dp *models.DimensionalPricing) { dp.MaxWidth = &four },
+			config:  map[string]interface{}{"width": 150.0, "height": 60.96},
+			wantErr: "width: must be at most 4 ft",
+		},
+		{
+			name:    "under the minimum height",
+			unit:    &cm,
+			dp:      func(dp *models.DimensionalPricing) { dp.MinHeight = &one },
+			config:  map[string]interface{}{"width": 91.44, "height": 20.0},
+			wantErr: "height: must be at least 1 ft",
+		},
+		{
+			// A grommet at each corner and every foot along the 3 × 2 ft edges,
+			// not counting the bleed
+			name: "grommets on every edge",
+			unit: &cm,
+			dp:   func(dp *models.DimensionalPricing) { dp.Finishings = []models.EdgeFinishing{grommets} },
+			config: map[string]interface{}{"width": 91.44, "height": 60.96,
+				"finishing": map[string]interface{}{"grommets": true}},
+			wantCost:      models.Kobo(87500),
+			wantFinishing: map[string]models.Money{"Grommets": models.Kobo(10 * 5000)},
+		},
+		{
+			name: "grommets on two edges share the corner",
+			unit: &cm,
+			dp:   func(dp *models.DimensionalPricing) { dp.Finishings = []models.EdgeFinishing{grommets} },
+			config: map[string]interface{}{"width": 91.44, "height": 60.96,
+				"finishing": map[string]interface{}{"grommets": []interface{}{"top", "left"}}},
+			wantCost:      models.Kobo(87500),
+			wantFinishing: map[string]models.Money{"Grommets": models.Kobo(6 * 5000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &models.Product{Options: sizeOptions(tt.unit)}
+			dp := &models.DimensionalPricing{RatePerUnit: 100, Unit: "sqft", SizeUnit: "ft", Bleed: 0.25}
+			if tt.dp != nil {
+				tt.dp(dp)
+			}
+			breakdown := &models.PriceBreakdown{}
+
+			err := applyDimensionalPricing(product, dp, tt.config, breakdown)
+			if tt.wantErr != "" {
+				var configErr *models.ConfigurationError
+				if !errors.As(err, &configErr) || len(configErr.Fields) != 1 {
+					t.Fatalf("error = %v, want one field error", err)
+				}
+				field := configErr.Fields[0]
+				if got := field.Field + ": " + field.Message; got != tt.wantErr {
+					t.Errorf("error = %q, want %q", got, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if breakdown.DimensionalCost.Cmp(tt.wantCost) != 0 {
+				t.Errorf("dimensional cost = %s, want %s", breakdown.DimensionalCost, tt.wantCost)
+			}
+			if len(breakdown.Finishing) != len(tt.wantFinishing) {
+				t.Fatalf("finishing = %v, want %v", breakdown.Finishing, tt.wantFinishing)
+			}
+			for name, want := range tt.wantFinishing {
+				if got := breakdown.Finishing[name]; got.Cmp(want) != 0 {
+					t.Errorf("%s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
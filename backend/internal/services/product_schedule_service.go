@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// ProductScheduleService publishes and archives products at the times set for them
+type ProductScheduleService struct {
+	productRepo *repository.ProductRepository
+	interval    time.Duration
+	wg          sync.WaitGroup
+}
+
+// NewProductScheduleService creates the service. Its job looks for products due
+// to be published or archived every interval; a zero interval disables it.
+func NewProductScheduleService(productRepo *repository.ProductRepository, interval time.Duration) *ProductScheduleService {
+	return &ProductScheduleService{productRepo: productRepo, interval: interval}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (s *ProductScheduleService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.RunOnce(ctx)
+			}
+		}
+	}()
+}
+
+// Wait blocks until the job has exited
+func (s *ProductScheduleService) Wait() {
+	s.wg.Wait()
+}
+
+// RunOnce publishes and then archives the products that are due. A product due
+// for both is left archived.
+func (s *ProductScheduleService) RunOnce(ctx context.Context) {
+	now := time.Now()
+	published, err := s.productRepo.PublishDue(ctx, now)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to publish scheduled products: %v\n", err)
+		return
+	}
+	archived, err := s.productRepo.UnpublishDue(ctx, now)
+	if err != nil {
+		fmt.Printf("ERROR: Failed to archive scheduled products: %v\n", err)
+		return
+	}
+	if published > 0 || archived > 0 {
+		fmt.Printf("DEBUG: Published %d and archived %d scheduled product(s)\n", published, archived)
+	}
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/pdf"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+var (
+	// ErrNothingToQuote is returned when a quote is asked for with no items and
+	// an empty cart
+	ErrNothingToQuote = errors.New("nothing to quote: add items or fill the cart")
+	// ErrQuoteProductNotFound is returned when a product to be quoted does not exist
+	ErrQuoteProductNotFound = errors.New("one or more products in the quote were not found")
+)
+
+// QuoteService prices quotes, holds their prices until they expire, and turns
+// them into orders at the quoted prices
+type QuoteService struct {
+	pricingService     *PricingService
+	taxService         *TaxService
+	quoteRepo          *repository.QuoteRepository
+	orderRepo          *repository.OrderRepository
+	cartRepo           *repository.CartRepository
+	shippingConfigRepo *repository.ShippingConfigRepository
+	validity           time.Duration
+}
+
+// NewQuoteService creates a quote service whose quotes are valid for validity
+func NewQuoteService(
+	pricingService *PricingService,
+	taxService *TaxService,
+	quoteRepo *repository.QuoteRepository,
+	orderRepo *repository.OrderRepository,
+	cartRepo *repository.CartRepository,
+	shippingConfigRepo *repository.ShippingConfigRepository,
+	validity time.Duration,
+) *QuoteService {
+	return &QuoteService{
+		pricingService:     pricingService,
+		taxService:         taxService,
+		quoteRepo:          quoteRepo,
+		orderRepo:          orderRepo,
+		cartRepo:           cartRepo,
+		shippingConfigRepo: shippingConfigRepo,
+		validity:           validity,
+	}
+}
+
+// Create prices the items, or the customer's cart when there are none, and saves
+// them as a quote. Items that cannot be priced fail the whole quote; pricing input
+// errors can be recognised with IsPricingInputError, and a missing product gives
+// ErrQuoteProductNotFound.
+func (s *QuoteService) Create(ctx context.Context, userID uuid.UUID, req *models.CreateQuoteRequest) (*models.Quote, error) {
+	lines := req.Items
+	if len(lines) == 0 {
+		cartItems, err := s.cartRepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range cartItems {
+			lines = append(lines, models.CreateOrderItemRequest{
+				ProductID:     item.ProductID,
+				Quantity:      item.Quantity,
+				Configuration: item.Configuration,
+			})
+		}
+	}
+	if len(lines) == 0 {
+		return nil, ErrNothingToQuote
+	}
+
+	quote := &models.Quote{
+		UserID:     userID,
+		Notes:      req.Notes,
+		ShareToken: newShareToken(),
+		ExpiresAt:  time.Now().Add(s.validity),
+	}
+	for _, line := range lines {
+		snapshot, err := s.pricingService.Snapshot(ctx, &models.CalculatePriceRequest{
+			ProductID:     line.ProductID,
+			Configuration: line.Configuration,
+			Quantity:      line.Quantity,
+			UserID:        userID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if snapshot == nil {
+			return nil, ErrQuoteProductNotFound
+		}
+
+		total := snapshot.Breakdown.Total
+		quote.Items = append(quote.Items, models.QuoteItem{
+			ProductID:     line.ProductID,
+			Quantity:      line.Quantity,
+			Configuration: line.Configuration,
+			UnitPrice:     total.Div(line.Quantity),
+			TotalPrice:    total,
+			Snapshot:      snapshot,
+		})
+		quote.Subtotal = quote.Subtotal.Add(total)
+	}
+
+	if err := s.quoteRepo.Create(ctx, quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// Convert places an order for the quote at its quoted prices. Shipping, tax and
+// any coupon are worked out as for any other order. It fails with
+// models.ErrQuoteExpired or models.ErrQuoteConverted if the quote cannot be
+// ordered, and with a coupon error if the coupon does not apply.
+func (s *QuoteService) Convert(ctx context.Context, quote *models.Quote, req *models.ConvertQuoteRequest) (*models.Order, error) {
+	if err := quote.Convertible(time.Now()); err != nil {
+		return nil, err
+	}
+
+	order := &models.Order{
+		UserID:          quote.UserID,
+		Status:          models.OrderStatusAwaitingPayment,
+		Items:           orderItems(quote),
+		Subtotal:        quote.Subtotal,
+		ShippingAddress: req.ShippingAddress,
+	}
+	if err := s.taxService.Prepare(ctx, order); err != nil {
+		return nil, err
+	}
+
+	shippingConfig, err := s.shippingConfigRepo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order.Shipping = shippingConfig.ShippingFor(order.Subtotal)
+	if err := s.orderRepo.CreateFromQuote(ctx, order, req.CouponCode, quote.ID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// orderItems copies a quote's lines, at their quoted prices, to order lines
+// whose snapshots carry the quote number
+func orderItems(quote *models.Quote) []models.OrderItem {
+	items := make([]models.OrderItem, len(quote.Items))
+	for i, item := range quote.Items {
+		snapshot := *item.Snapshot
+		snapshot.QuoteNumber = quote.QuoteNumber
+		items[i] = models.OrderItem{
+			ProductID:     item.ProductID,
+			Quantity:      item.Quantity,
+			Configuration: item.Configuration,
+			UnitPrice:     item.UnitPrice,
+			TotalPrice:    item.TotalPrice,
+			Snapshot:      &snapshot,
+		}
+	}
+	return items
+}
+
+// RenderPDF lays the quote out as a printable PDF
+func (s *QuoteService) RenderPDF(quote *models.Quote) []byte {
+	const (
+		left      = pdf.Margin
+		right     = pdf.PageWidth - pdf.Margin
+		qtyRight  = 360.0
+		unitRight = 450.0
+		nameWidth = 260.0
+	)
+	naira := func(m models.Money) string { return "NGN " + m.String() }
+
+	doc := pdf.New()
+	doc.Text(left, "QuikPrint NG", 20, true)
+	doc.TextRight(right, "QUOTE", 20, true)
+	doc.Down(18)
+	doc.Text(left, "Professional Printing Services - Lagos, Nigeria", 9, false)
+	doc.Down(30)
+
+	doc.Text(left, "Quote number:", 10, true)
+	doc.Text(left+90, quote.QuoteNumber, 10, false)
+	doc.Down(14)
+	doc.Text(left, "Issued:", 10, true)
+	doc.Text(left+90, quote.CreatedAt.Format("2 January 2006"), 10, false)
+	doc.Down(14)
+	doc.Text(left, "Valid until:", 10, true)
+	doc.Text(left+90, quote.ExpiresAt.Format("2 January 2006 15:04 MST"), 10, false)
+	if quote.Status != models.QuoteStatusOpen {
+		doc.Down(14)
+		doc.Text(left, "Status:", 10, true)
+		doc.Text(left+90, string(quote.Status), 10, false)
+	}
+	doc.Down(30)
+
+	doc.Text(left, "Item", 10, true)
+	doc.TextRight(qtyRight, "Qty", 10, true)
+	doc.TextRight(unitRight, "Unit price", 10, true)
+	doc.TextRight(right, "Total", 10, true)
+	doc.Rule(left, right)
+	doc.Down(20)
+
+	for _, item := range quote.Items {
+		name := item.ProductID.String()
+		var options []models.OptionLabel
+		if item.Snapshot != nil {
+			name = item.Snapshot.ProductName
+			options = item.Snapshot.Options
+		}
+		nameLines := pdf.Wrap(name, 10, true, nameWidth)
+		for i, line := range nameLines {
+			doc.Text(left, line, 10, true)
+			if i == 0 {
+				doc.TextRight(qtyRight, fmt.Sprintf("%d", item.Quantity), 10, false)
+				doc.TextRight(unitRight, naira(item.UnitPrice), 10, false)
+				doc.TextRight(right, naira(item.TotalPrice), 10, false)
+			}
+			doc.Down(13)
+		}
+		for _, opt := range options {
+			for _, line := range pdf.Wrap(opt.Name+": "+opt.Value, 8, false, nameWidth-10) {
+				doc.Text(left+10, line, 8, false)
+				doc.Down(11)
+			}
+		}
+		doc.Down(8)
+	}
+
+	doc.Rule(left, right)
+	doc.Down(20)
+	doc.TextRight(unitRight, "Subtotal", 11, true)
+	doc.TextRight(right, naira(quote.Subtotal), 11, true)
+	doc.Down(30)
+
+	if quote.Notes != "" {
+		doc.Text(left, "Notes", 10, true)
+		doc.Down(14)
+		for _, line := range pdf.Wrap(quote.Notes, 9, false, right-left) {
+			doc.Text(left, line, 9, false)
+			doc.Down(12)
+		}
+		doc.Down(16)
+	}
+
+	for _, line := range pdf.Wrap(
+		"Prices are held until the date above. Shipping, VAT and any discounts are worked out when the quote is ordered.", 8, false, right-left,
+	) {
+		doc.Text(left, line, 8, false)
+		doc.Down(11)
+	}
+	return doc.Bytes()
+}
+
+// newShareToken returns a random token for a quote's share link
+func newShareToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
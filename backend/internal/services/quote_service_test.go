@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+)
+
+func TestOrderItemsFromOverriddenQuote(t *testing.T) {
+	quoted := &models.OrderItemSnapshot{
+		ProductName: "A5 Flyer",
+		Breakdown:   &models.PriceBreakdown{Subtotal: models.Kobo(1000000), Total: models.Kobo(1050000)},
+	}
+	override := models.QuotePriceOverride{
+		ID:            uuid.New(),
+		PreviousPrice: models.Kobo(1050000),
+		Price:         models.Kobo(900000),
+		Reason:        "Repeat customer",
+		CreatedBy:     uuid.New(),
+		CreatedAt:     time.Now(),
+	}
+	quote := &models.Quote{
+		QuoteNumber: "QT-20260301-0001",
+		Items: []models.QuoteItem{{
+			ProductID:  uuid.New(),
+			Quantity:   100,
+			UnitPrice:  models.Kobo(9000),
+			TotalPrice: models.Kobo(900000),
+			Snapshot:   quoted.WithOverride(override),
+		}},
+	}
+
+	items := orderItems(quote)
+	if len(items) != 1 {
+		t.Fatalf("got %d order items, want 1", len(items))
+	}
+	item := items[0]
+	if item.TotalPrice.Cmp(models.Kobo(900000)) != 0 || item.UnitPrice.Cmp(models.Kobo(9000)) != 0 {
+		t.Errorf("item priced %s (%s each), want the override of 9000.00 (90.00 each)", item.TotalPrice, item.UnitPrice)
+	}
+	snapshot := item.Snapshot
+	if snapshot.Breakdown.Total.Cmp(item.TotalPrice) != 0 || snapshot.Breakdown.Subtotal.Cmp(item.TotalPrice) != 0 {
+		t.Errorf("snapshot breakdown = %s subtotal, %s total; want both %s", snapshot.Breakdown.Subtotal, snapshot.Breakdown.Total, item.TotalPrice)
+	}
+	if snapshot.Override == nil || snapshot.Override.PreviousPrice.Cmp(models.Kobo(1050000)) != 0 ||
+		snapshot.Override.Reason != "Repeat customer" || snapshot.Override.CreatedBy != override.CreatedBy {
+		t.Errorf("snapshot override = %+v, want the previous price, reason and actor", snapshot.Override)
+	}
+	if snapshot.QuoteNumber != quote.QuoteNumber {
+		t.Errorf("quote number = %q, want %q", snapshot.QuoteNumber, quote.QuoteNumber)
+	}
+
+	// The quote's own snapshots are left as they were
+	if quoted.Breakdown.Total.Cmp(models.Kobo(1050000)) != 0 || quoted.Override != nil {
+		t.Error("overriding changed the snapshot it was made from")
+	}
+	if quote.Items[0].Snapshot.QuoteNumber != "" {
+		t.Error("converting changed the quote's snapshot")
+	}
+}
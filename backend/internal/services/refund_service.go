@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// RefundService issues refunds through the payment's provider and keeps the payment
+// and order in step with them. Refunds issued here and refunds reported by provider
+// webhooks (including ones made from the provider's dashboard) end up in the same
+// refunds table.
+type RefundService struct {
+	paymentService *PaymentService
+	paymentRepo    *repository.PaymentRepository
+	refundRepo     *repository.RefundRepository
+	orderRepo      *repository.OrderRepository
+}
+
+// NewRefundService creates a new refund service
+func NewRefundService(paymentService *PaymentService, paymentRepo *repository.PaymentRepository, refundRepo *repository.RefundRepository, orderRepo *repository.OrderRepository) *RefundService {
+	return &RefundService{
+		paymentService: paymentService,
+		paymentRepo:    paymentRepo,
+		refundRepo:     refundRepo,
+		orderRepo:      orderRepo,
+	}
+}
+
+// Refund refunds amount naira of a payment, or whatever has not been refunded yet
+// when amount is zero. A refund that takes the payment to fully refunded cancels
+// the order; a partial refund leaves the order open.
+func (s *RefundService) Refund(ctx context.Context, payment *models.Payment, amount models.Money, reason string, actorID uuid.UUID) (*models.Refund, error) {
+	provider, err := s.paymentService.Provider(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &models.Refund{
+		PaymentID: payment.ID,
+		OrderID:   payment.OrderID,
+		Provider:  provider.Name(),
+		Amount:    amount,
+		Currency:  payment.Currency,
+		Reason:    reason,
+		CreatedBy: &actorID,
+	}
+	if err := s.refundRepo.Reserve(ctx, refund); err != nil {
+		return nil, err
+	}
+
+	result, err := provider.Refund(ctx, &RefundRequest{
+		Reference:  payment.ProviderReference,
+		AmountKobo: refund.Amount.Minor(),
+		Reason:     reason,
+	})
+	if err != nil {
+		fmt.Printf("ERROR: %s refund for %s failed: %v\n", provider.Name(), payment.ProviderReference, err)
+		if markErr := s.refundRepo.SetResult(ctx, refund.ID, models.RefundStatusFailed, "", "", err.Error()); markErr != nil {
+			fmt.Printf("ERROR: Failed to record refund failure: %v\n", markErr)
+		}
+		return nil, fmt.Errorf("refund failed: %w", err)
+	}
+
+	// Providers usually accept a refund and process it later; the refund webhook
+	// confirms it. The refund counts against the payment from now on either way.
+	refund.Status = refundStatus(result.Status)
+	refund.ProviderRefundID = result.ProviderRefundID
+	if err := s.refundRepo.SetResult(ctx, refund.ID, refund.Status, result.ProviderRefundID, result.Raw, ""); err != nil {
+		return nil, err
+	}
+
+	if err := s.applyToPayment(ctx, payment, actorID); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// ReconcileWebhook applies a refund webhook to the matching refund, recording
+// refunds that were made outside QuikPrint
+func (s *RefundService) ReconcileWebhook(ctx context.Context, payment *models.Payment, event *WebhookEvent, raw string) error {
+	status := models.RefundStatusProcessed
+	if event.Kind == WebhookRefundFailed {
+		status = models.RefundStatusFailed
+	}
+
+	var refund *models.Refund
+	var err error
+	if event.RefundID != "" {
+		refund, err = s.refundRepo.GetByProviderRefundID(ctx, payment.Provider, event.RefundID)
+		if err != nil {
+			return err
+		}
+		if refund == nil {
+			refund, err = s.refundRepo.ClaimUnmatched(ctx, payment.ID, models.Kobo(event.AmountKobo), event.RefundID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if refund == nil {
+		if status == models.RefundStatusFailed {
+			fmt.Printf("DEBUG: Failed refund %s for %s has no matching refund\n", event.RefundID, payment.ProviderReference)
+			return nil
+		}
+
+		// Refunded from the provider's dashboard
+		refund = &models.Refund{
+			PaymentID: payment.ID,
+			OrderID:   payment.OrderID,
+			Provider:  payment.Provider,
+			Amount:    models.Kobo(event.AmountKobo),
+			Currency:  payment.Currency,
+			Reason:    fmt.Sprintf("Refunded outside QuikPrint (%s)", payment.Provider),
+			Status:    status,
+		}
+		if err := s.refundRepo.Reserve(ctx, refund); err != nil {
+			return err
+		}
+	}
+
+	if err := s.refundRepo.SetResult(ctx, refund.ID, status, event.RefundID, raw, ""); err != nil {
+		return err
+	}
+	return s.applyToPayment(ctx, payment, models.SystemActor)
+}
+
+// applyToPayment sets the payment's status from the refunds recorded against it
+// and cancels the order once the payment is fully refunded
+func (s *RefundService) applyToPayment(ctx context.Context, payment *models.Payment, actorID uuid.UUID) error {
+	refunded, err := s.refundRepo.RefundedAmount(ctx, payment.ID)
+	if err != nil {
+		return err
+	}
+
+	status := models.PaymentStatusPartiallyRefunded
+	switch {
+	case refunded.IsZero():
+		status = models.PaymentStatusSuccess
+	case !refunded.LessThan(payment.Amount):
+		status = models.PaymentStatusRefunded
+	}
+
+	if status != payment.Status {
+		if err := s.paymentRepo.SetStatus(ctx, payment.ProviderReference, status); err != nil {
+			return err
+		}
+		payment.Status = status
+	}
+
+	if status != models.PaymentStatusRefunded {
+		return nil
+	}
+
+	err = s.orderRepo.UpdateStatus(ctx, payment.OrderID, models.OrderStatusCancelled, "Payment fully refunded", actorID)
+	var transitionErr *models.StatusTransitionError
+	if errors.As(err, &transitionErr) {
+		// Already cancelled, or delivered; the refund stands either way
+		fmt.Printf("DEBUG: Order %s not cancelled after refund: %v\n", payment.OrderID, err)
+		return nil
+	}
+	return err
+}
+
+// refundStatus maps a provider's refund status
+func refundStatus(status string) models.RefundStatus {
+	switch status {
+	case "processed", "completed", "successful":
+		return models.RefundStatusProcessed
+	case "failed":
+		return models.RefundStatusFailed
+	default:
+		return models.RefundStatusPending
+	}
+}
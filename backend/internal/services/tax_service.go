@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/quikprint/backend/internal/models"
+	"github.com/quikprint/backend/internal/repository"
+)
+
+// TaxService decides how each order line is taxed
+type TaxService struct {
+	taxRepo     *repository.TaxRepository
+	productRepo *repository.ProductRepository
+}
+
+func NewTaxService(taxRepo *repository.TaxRepository, productRepo *repository.ProductRepository) *TaxService {
+	return &TaxService{taxRepo: taxRepo, productRepo: productRepo}
+}
+
+// Prepare sets the rate each line of a new order is taxed at, or why it is
+// exempt, using the rate in force now, and records the customer's TIN. The tax
+// itself is worked out by models.Order.ApplyTax once the discount is known.
+//
+// When prices include tax, an exempt customer does not pay the tax in them: their
+// lines and their snapshot breakdowns are reduced to the price before tax, and the
+// order subtotal with them, so Prepare must be called before shipping is worked
+// out from the subtotal.
+func (s *TaxService) Prepare(ctx context.Context, order *models.Order) error {
+	settings, err := s.taxRepo.GetSettings(ctx)
+	if err != nil {
+		return err
+	}
+	rate, err := s.taxRepo.RateAt(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	profile, err := s.taxRepo.GetCustomerProfile(ctx, order.UserID)
+	if err != nil {
+		return err
+	}
+
+	order.PricesIncludeTax = settings.PricesIncludeTax
+	if profile != nil {
+		order.CustomerTIN = profile.TIN
+	}
+	customerExempt := profile != nil && profile.TaxExempt
+
+	var subtotal models.Money
+	for i := range order.Items {
+		item := &order.Items[i]
+		product, err := s.productRepo.GetByID(ctx, item.ProductID)
+		if err != nil {
+			return err
+		}
+
+		item.TaxRate = 0
+		item.TaxExemption = ""
+		switch {
+		case product != nil && product.TaxExempt:
+			item.TaxExemption = models.TaxExemptProduct
+		case product != nil && product.CategoryTaxExempt:
+			item.TaxExemption = models.TaxExemptCategory
+		case customerExempt:
+			item.TaxExemption = models.TaxExemptCustomer
+			if rate != nil && settings.PricesIncludeTax {
+				item.RemoveIncludedTax(rate.Rate)
+			}
+		case rate != nil:
+			item.TaxRate = rate.Rate
+		}
+		subtotal = subtotal.Add(item.TotalPrice)
+	}
+	order.Subtotal = subtotal
+	return nil
+}
+
+// PublicSettings returns how prices are shown to customers and the rate in force now
+func (s *TaxService) PublicSettings(ctx context.Context) (*models.PublicTaxSettings, error) {
+	settings, err := s.taxRepo.GetSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := s.taxRepo.RateAt(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &models.PublicTaxSettings{PricesIncludeTax: settings.PricesIncludeTax, Rate: rate}, nil
+}
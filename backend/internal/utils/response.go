@@ -32,6 +32,15 @@ func ErrorResponse(c *gin.Context, statusCode int, message string) {
 	})
 }
 
+// ErrorResponseWithData sends an error along with details the client can act on
+func ErrorResponseWithData(c *gin.Context, statusCode int, message string, data interface{}) {
+	c.JSON(statusCode, APIResponse{
+		Success: false,
+		Data:    data,
+		Error:   message,
+	})
+}
+
 func ValidationErrorResponse(c *gin.Context, message string) {
 	c.JSON(400, APIResponse{
 		Success: false,
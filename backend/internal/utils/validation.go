@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/quikprint/backend/internal/models"
+)
+
+// RegisterValidators teaches gin's validator about custom request types. Money is
+// validated as its amount in minor units, so tags such as gt=0 and min=0 work on it.
+func RegisterValidators() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+		if m, ok := field.Interface().(models.Money); ok {
+			return m.Minor()
+		}
+		return nil
+	}, models.Money{})
+}
@@ -0,0 +1,24 @@
+-- Per-customer notification opt-outs. A missing row means the defaults (everything on).
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    order_updates BOOLEAN NOT NULL DEFAULT true,
+    marketing BOOLEAN NOT NULL DEFAULT true,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Log of every notification the dispatcher handled, including failures and skips
+CREATE TABLE notification_log (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_type VARCHAR(50) NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    channel VARCHAR(20) NOT NULL DEFAULT 'email',
+    recipient VARCHAR(255),
+    status VARCHAR(20) NOT NULL CHECK (status IN ('sent', 'failed', 'skipped')),
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_notification_log_created_at ON notification_log(created_at DESC);
+CREATE INDEX idx_notification_log_user_id ON notification_log(user_id);
+CREATE INDEX idx_notification_log_status ON notification_log(status);
@@ -0,0 +1,7 @@
+DELETE FROM notification_log WHERE status = 'queued';
+ALTER TABLE notification_log DROP CONSTRAINT IF EXISTS notification_log_status_check;
+ALTER TABLE notification_log ADD CONSTRAINT notification_log_status_check
+    CHECK (status IN ('sent', 'failed', 'skipped'));
+
+DROP TABLE IF EXISTS email_outbox;
+DROP TABLE IF EXISTS email_broadcasts;
@@ -0,0 +1,36 @@
+-- Broadcast campaigns; each recipient gets a row in email_outbox
+CREATE TABLE email_broadcasts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    subject VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    total_recipients INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Outbound email queue drained by the email workers
+CREATE TABLE email_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    broadcast_id UUID REFERENCES email_broadcasts(id) ON DELETE CASCADE,
+    recipient VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    html_body TEXT NOT NULL,
+    smtp_account VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sending', 'sent', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    locked_at TIMESTAMP WITH TIME ZONE,
+    sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_email_outbox_due ON email_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_email_outbox_broadcast_id ON email_outbox(broadcast_id, status);
+
+-- Notifications are now handed to the outbox rather than sent inline
+ALTER TABLE notification_log DROP CONSTRAINT IF EXISTS notification_log_status_check;
+ALTER TABLE notification_log ADD CONSTRAINT notification_log_status_check
+    CHECK (status IN ('queued', 'sent', 'failed', 'skipped'));
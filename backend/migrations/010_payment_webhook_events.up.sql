@@ -0,0 +1,19 @@
+-- Every webhook delivery we accept, keyed so retried deliveries are recognised.
+-- Raw payloads are kept for audit and so events can be replayed.
+CREATE TABLE payment_webhook_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    provider VARCHAR(20) NOT NULL DEFAULT 'paystack',
+    event_key VARCHAR(255) NOT NULL UNIQUE,
+    event_type VARCHAR(50) NOT NULL,
+    reference VARCHAR(100),
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'received' CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+    error TEXT,
+    deliveries INTEGER NOT NULL DEFAULT 1,
+    received_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_payment_webhook_events_reference ON payment_webhook_events(reference);
+CREATE INDEX idx_payment_webhook_events_status ON payment_webhook_events(status);
+CREATE INDEX idx_payment_webhook_events_received_at ON payment_webhook_events(received_at DESC);
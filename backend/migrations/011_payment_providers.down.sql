@@ -0,0 +1,7 @@
+ALTER TABLE payment_webhook_events DROP CONSTRAINT IF EXISTS payment_webhook_events_provider_event_key_key;
+ALTER TABLE payment_webhook_events ADD CONSTRAINT payment_webhook_events_event_key_key UNIQUE (event_key);
+
+ALTER INDEX idx_payments_provider_reference RENAME TO idx_payments_paystack_ref;
+ALTER TABLE payments DROP COLUMN IF EXISTS provider;
+ALTER TABLE payments RENAME COLUMN provider_response TO paystack_response;
+ALTER TABLE payments RENAME COLUMN provider_reference TO paystack_ref;
@@ -0,0 +1,9 @@
+-- Generalise payments from Paystack to any provider
+ALTER TABLE payments RENAME COLUMN paystack_ref TO provider_reference;
+ALTER TABLE payments RENAME COLUMN paystack_response TO provider_response;
+ALTER TABLE payments ADD COLUMN provider VARCHAR(20) NOT NULL DEFAULT 'paystack';
+ALTER INDEX idx_payments_paystack_ref RENAME TO idx_payments_provider_reference;
+
+-- Webhook event keys are only unique within a provider
+ALTER TABLE payment_webhook_events DROP CONSTRAINT IF EXISTS payment_webhook_events_event_key_key;
+ALTER TABLE payment_webhook_events ADD CONSTRAINT payment_webhook_events_provider_event_key_key UNIQUE (provider, event_key);
@@ -0,0 +1,23 @@
+-- Each refund against a payment, whether issued from the admin API or found
+-- through a provider webhook (e.g. refunded from the provider's dashboard)
+CREATE TABLE refunds (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE RESTRICT,
+    provider VARCHAR(20) NOT NULL,
+    provider_refund_id VARCHAR(100),
+    amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+    currency VARCHAR(10) NOT NULL DEFAULT 'NGN',
+    reason TEXT NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processed', 'failed')),
+    provider_response JSONB,
+    error TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    processed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_refunds_payment_id ON refunds(payment_id);
+CREATE INDEX idx_refunds_order_id ON refunds(order_id);
+CREATE UNIQUE INDEX idx_refunds_provider_refund_id ON refunds(provider, provider_refund_id) WHERE provider_refund_id IS NOT NULL;
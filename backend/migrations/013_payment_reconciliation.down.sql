@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_payments_status_created_at;
+DROP TABLE IF EXISTS reconciliation_items;
+DROP TABLE IF EXISTS reconciliation_runs;
@@ -0,0 +1,36 @@
+-- Each pass of the payment reconciler over pending payments
+CREATE TABLE reconciliation_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('scheduled', 'manual', 'command')),
+    checked INTEGER NOT NULL DEFAULT 0,
+    settled INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    expired INTEGER NOT NULL DEFAULT 0,
+    pending INTEGER NOT NULL DEFAULT 0,
+    mismatches INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+-- What the reconciler found for each payment it checked
+CREATE TABLE reconciliation_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    run_id UUID NOT NULL REFERENCES reconciliation_runs(id) ON DELETE CASCADE,
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE CASCADE,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL,
+    reference VARCHAR(100) NOT NULL,
+    outcome VARCHAR(20) NOT NULL CHECK (outcome IN ('settled', 'failed', 'expired', 'pending', 'mismatch', 'error')),
+    provider_status VARCHAR(50),
+    expected_amount DECIMAL(10, 2) NOT NULL,
+    provider_amount DECIMAL(10, 2),
+    currency VARCHAR(10),
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_reconciliation_runs_started_at ON reconciliation_runs(started_at DESC);
+CREATE INDEX idx_reconciliation_items_run_id ON reconciliation_items(run_id);
+CREATE INDEX idx_reconciliation_items_outcome ON reconciliation_items(outcome);
+CREATE INDEX idx_payments_status_created_at ON payments(status, created_at);
@@ -0,0 +1,10 @@
+-- Payment reminders already sent for unpaid orders, one per reminder offset, so a
+-- reminder goes out once even with several API instances running the expiry job
+CREATE TABLE order_payment_reminders (
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    hours_before INTEGER NOT NULL,
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (order_id, hours_before)
+);
+
+CREATE INDEX idx_orders_status_created_at ON orders(status, created_at);
@@ -0,0 +1,2 @@
+ALTER TABLE pricing_rules DROP CONSTRAINT IF EXISTS pricing_rules_minimum_charge_flat;
+ALTER TABLE pricing_rules DROP COLUMN IF EXISTS value_type;
@@ -0,0 +1,7 @@
+-- Pricing rules can be a flat amount or a percentage of the line; minimum charges
+-- are always flat
+ALTER TABLE pricing_rules
+    ADD COLUMN value_type VARCHAR(20) NOT NULL DEFAULT 'flat' CHECK (value_type IN ('flat', 'percentage'));
+
+ALTER TABLE pricing_rules
+    ADD CONSTRAINT pricing_rules_minimum_charge_flat CHECK (rule_type <> 'minimum_charge' OR value_type = 'flat');
@@ -0,0 +1,15 @@
+-- Per-product pricing formulas. Published formulas replace the base price, quantity
+-- tiers and dimensional pricing; each is evaluated in position order and its result
+-- added to the line subtotal. Drafts are only used by dry runs.
+CREATE TABLE pricing_formulas (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL DEFAULT 0,
+    label VARCHAR(100) NOT NULL,
+    expression TEXT NOT NULL,
+    published BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_pricing_formulas_product_id ON pricing_formulas(product_id, position);
@@ -0,0 +1,8 @@
+ALTER TABLE dimensional_pricing
+    DROP COLUMN IF EXISTS finishings,
+    DROP COLUMN IF EXISTS bleed,
+    DROP COLUMN IF EXISTS max_height,
+    DROP COLUMN IF EXISTS min_height,
+    DROP COLUMN IF EXISTS max_width,
+    DROP COLUMN IF EXISTS min_width,
+    DROP COLUMN IF EXISTS size_unit;
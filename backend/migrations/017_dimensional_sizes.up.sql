@@ -0,0 +1,21 @@
+-- Dimensional pricing sizes: the unit sizes are entered in, size limits, bleed and
+-- edge finishing priced by length
+ALTER TABLE dimensional_pricing
+    ADD COLUMN size_unit VARCHAR(10),
+    ADD COLUMN min_width DECIMAL(10, 3),
+    ADD COLUMN max_width DECIMAL(10, 3),
+    ADD COLUMN min_height DECIMAL(10, 3),
+    ADD COLUMN max_height DECIMAL(10, 3),
+    ADD COLUMN bleed DECIMAL(10, 3) NOT NULL DEFAULT 0,
+    ADD COLUMN finishings JSONB NOT NULL DEFAULT '[]';
+
+-- Sizes were always taken to be in the rate's own unit, so keep pricing the same
+UPDATE dimensional_pricing SET size_unit = CASE unit
+    WHEN 'sqmm' THEN 'mm'
+    WHEN 'sqcm' THEN 'cm'
+    WHEN 'sqm' THEN 'm'
+    WHEN 'sqft' THEN 'ft'
+    ELSE 'in'
+END;
+
+ALTER TABLE dimensional_pricing ALTER COLUMN size_unit SET NOT NULL;
@@ -0,0 +1,4 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS snapshot,
+    DROP COLUMN IF EXISTS product_slug,
+    DROP COLUMN IF EXISTS product_name;
@@ -0,0 +1,14 @@
+-- Order items keep the product and pricing they were ordered with, so later
+-- product and pricing changes do not rewrite order history
+ALTER TABLE order_items
+    ADD COLUMN product_name VARCHAR(255),
+    ADD COLUMN product_slug VARCHAR(255),
+    ADD COLUMN snapshot JSONB;
+
+-- Existing items can only be given the product as it is now
+UPDATE order_items oi SET product_name = p.name, product_slug = p.slug
+FROM products p WHERE p.id = oi.product_id;
+
+ALTER TABLE order_items
+    ALTER COLUMN product_name SET NOT NULL,
+    ALTER COLUMN product_slug SET NOT NULL;
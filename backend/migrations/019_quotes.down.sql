@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS quote_price_overrides;
+DROP TABLE IF EXISTS quote_items;
+DROP TABLE IF EXISTS quotes;
+DROP FUNCTION IF EXISTS generate_quote_number();
+DROP SEQUENCE IF EXISTS quote_number_seq;
@@ -0,0 +1,53 @@
+-- Saved quotes: prices frozen until the quote expires, shareable by token and
+-- convertible into an order
+CREATE SEQUENCE quote_number_seq START 1000;
+
+CREATE OR REPLACE FUNCTION generate_quote_number()
+RETURNS VARCHAR(20) AS $$
+BEGIN
+    RETURN 'QT-' || EXTRACT(YEAR FROM CURRENT_DATE)::TEXT || '-' || LPAD(nextval('quote_number_seq')::TEXT, 6, '0');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TABLE quotes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    quote_number VARCHAR(20) UNIQUE NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'converted')),
+    subtotal DECIMAL(10, 2) NOT NULL,
+    notes TEXT,
+    share_token VARCHAR(64) UNIQUE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_quotes_user_id ON quotes(user_id);
+
+CREATE TABLE quote_items (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    quote_id UUID NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL,
+    configuration JSONB NOT NULL DEFAULT '{}',
+    unit_price DECIMAL(10, 2) NOT NULL,
+    total_price DECIMAL(10, 2) NOT NULL,
+    snapshot JSONB NOT NULL
+);
+
+CREATE INDEX idx_quote_items_quote_id ON quote_items(quote_id);
+
+-- Audit trail of staff price changes
+CREATE TABLE quote_price_overrides (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    quote_item_id UUID NOT NULL REFERENCES quote_items(id) ON DELETE CASCADE,
+    previous_price DECIMAL(10, 2) NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    reason TEXT NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_quote_price_overrides_item_id ON quote_price_overrides(quote_item_id);
@@ -0,0 +1,17 @@
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS net_amount,
+    DROP COLUMN IF EXISTS tax,
+    DROP COLUMN IF EXISTS tax_exemption,
+    DROP COLUMN IF EXISTS tax_rate;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS customer_tin,
+    DROP COLUMN IF EXISTS prices_include_tax;
+
+DROP TABLE IF EXISTS customer_tax_profiles;
+
+ALTER TABLE categories DROP COLUMN IF EXISTS tax_exempt;
+ALTER TABLE products DROP COLUMN IF EXISTS tax_exempt;
+
+DROP TABLE IF EXISTS tax_settings;
+DROP TABLE IF EXISTS tax_rates;
@@ -0,0 +1,53 @@
+-- VAT: rates by effective date, exempt products, categories and customers, and
+-- the tax charged on each order line
+
+-- The rate in force at a time is the one with the latest effective_from not after it
+CREATE TABLE tax_rates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) NOT NULL,
+    rate DECIMAL(5, 2) NOT NULL CHECK (rate >= 0 AND rate < 100),
+    effective_from TIMESTAMP WITH TIME ZONE NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE tax_settings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+INSERT INTO tax_settings (prices_include_tax)
+SELECT FALSE
+WHERE NOT EXISTS (SELECT 1 FROM tax_settings);
+
+ALTER TABLE products ADD COLUMN tax_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE categories ADD COLUMN tax_exempt BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Business details of a customer; only staff can make a customer exempt
+CREATE TABLE customer_tax_profiles (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    company_name VARCHAR(255),
+    tin VARCHAR(20) NOT NULL,
+    tax_exempt BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+ALTER TABLE orders
+    ADD COLUMN prices_include_tax BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN customer_tin VARCHAR(20);
+
+-- net_amount is the line after its share of the order discount, excluding tax
+ALTER TABLE order_items
+    ADD COLUMN tax_rate DECIMAL(5, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN tax_exemption VARCHAR(20) CHECK (tax_exemption IN ('product', 'category', 'customer')),
+    ADD COLUMN tax DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    ADD COLUMN net_amount DECIMAL(10, 2);
+
+-- No tax was charged before now
+UPDATE order_items SET net_amount = total_price;
+
+ALTER TABLE order_items ALTER COLUMN net_amount SET NOT NULL;
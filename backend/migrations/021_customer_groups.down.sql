@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS customer_group_pricing_tiers;
+DROP TABLE IF EXISTS customer_group_product_prices;
+DROP TABLE IF EXISTS customer_group_category_discounts;
+DROP TABLE IF EXISTS customer_group_members;
+DROP TABLE IF EXISTS customer_groups;
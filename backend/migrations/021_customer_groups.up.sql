@@ -0,0 +1,46 @@
+-- Customer groups with negotiated price lists for B2B accounts. A customer
+-- belongs to at most one group.
+CREATE TABLE customer_groups (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(100) UNIQUE NOT NULL,
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE customer_group_members (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    group_id UUID NOT NULL REFERENCES customer_groups(id) ON DELETE CASCADE,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_customer_group_members_group_id ON customer_group_members(group_id);
+
+-- A percentage off every product in a category
+CREATE TABLE customer_group_category_discounts (
+    group_id UUID NOT NULL REFERENCES customer_groups(id) ON DELETE CASCADE,
+    category_id UUID NOT NULL REFERENCES categories(id) ON DELETE CASCADE,
+    percent DECIMAL(5, 2) NOT NULL CHECK (percent > 0 AND percent <= 100),
+    PRIMARY KEY (group_id, category_id)
+);
+
+-- A fixed unit price in place of the product's base price and quantity tiers
+CREATE TABLE customer_group_product_prices (
+    group_id UUID NOT NULL REFERENCES customer_groups(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price DECIMAL(10, 2) NOT NULL CHECK (price > 0),
+    PRIMARY KEY (group_id, product_id)
+);
+
+-- Quantity tiers in place of the product's own
+CREATE TABLE customer_group_pricing_tiers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    group_id UUID NOT NULL REFERENCES customer_groups(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    min_qty INTEGER NOT NULL,
+    max_qty INTEGER NOT NULL,
+    price DECIMAL(10, 2) NOT NULL
+);
+
+CREATE INDEX idx_customer_group_pricing_tiers_group_product ON customer_group_pricing_tiers(group_id, product_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS product_price_history;
+DROP TABLE IF EXISTS price_change_products;
+DROP TABLE IF EXISTS price_changes;
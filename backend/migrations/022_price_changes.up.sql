@@ -0,0 +1,40 @@
+-- Price changes: bulk updates to product base prices, applied immediately or at a
+-- scheduled time, and the history of every base price change
+CREATE TABLE price_changes (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    update_type VARCHAR(20) NOT NULL CHECK (update_type IN ('set', 'increase', 'decrease', 'percentage', 'rollback')),
+    value DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'applied', 'cancelled', 'rolled_back')),
+    effective_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    applied_at TIMESTAMP WITH TIME ZONE,
+    rollback_of UUID REFERENCES price_changes(id) ON DELETE SET NULL,
+    rolled_back_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    rolled_back_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_price_changes_due ON price_changes(effective_at) WHERE status = 'scheduled';
+
+CREATE TABLE price_change_products (
+    price_change_id UUID NOT NULL REFERENCES price_changes(id) ON DELETE CASCADE,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    PRIMARY KEY (price_change_id, product_id)
+);
+
+-- changed_by is NULL for changes made by the system
+CREATE TABLE product_price_history (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price_change_id UUID REFERENCES price_changes(id) ON DELETE SET NULL,
+    old_price DECIMAL(10, 2) NOT NULL,
+    new_price DECIMAL(10, 2) NOT NULL,
+    reason TEXT,
+    changed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_product_price_history_product_id ON product_price_history(product_id, changed_at DESC);
+CREATE INDEX idx_product_price_history_price_change_id ON product_price_history(price_change_id);
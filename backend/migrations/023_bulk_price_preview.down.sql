@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS price_change_tiers;
+ALTER TABLE products DROP COLUMN IF EXISTS cost_price;
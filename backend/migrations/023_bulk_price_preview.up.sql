@@ -0,0 +1,14 @@
+-- What a product costs to make, so price updates that would sell it at a loss
+-- can be flagged; and the quantity tier prices each price change set
+ALTER TABLE products ADD COLUMN cost_price DECIMAL(10, 2) CHECK (cost_price >= 0);
+
+-- tier_id is not a foreign key: replacing a product's tiers gives them new IDs,
+-- and the old ones are kept so a rollback can tell they are gone
+CREATE TABLE price_change_tiers (
+    price_change_id UUID NOT NULL REFERENCES price_changes(id) ON DELETE CASCADE,
+    tier_id UUID NOT NULL,
+    product_id UUID NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    old_price DECIMAL(10, 2) NOT NULL,
+    new_price DECIMAL(10, 2) NOT NULL,
+    PRIMARY KEY (price_change_id, tier_id)
+);
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_products_turnaround_days;
+DROP INDEX IF EXISTS idx_products_created_at;
+DROP INDEX IF EXISTS idx_products_base_price;
+DROP INDEX IF EXISTS idx_products_search_vector;
+ALTER TABLE products DROP COLUMN IF EXISTS turnaround_days;
+ALTER TABLE products DROP COLUMN IF EXISTS search_vector;
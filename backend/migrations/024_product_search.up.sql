@@ -0,0 +1,18 @@
+-- Full-text search over a product's name, descriptions and features, weighted
+-- in that order
+ALTER TABLE products ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+    setweight(to_tsvector('english', COALESCE(short_description, '') || ' ' || COALESCE(description, '')), 'B') ||
+    setweight(jsonb_to_tsvector('english', COALESCE(features, '[]'), '["string"]'), 'C')
+) STORED;
+
+-- The longest turnaround in days, taken from the last number in the turnaround
+-- text ("3-5 business days" is 5), so products can be filtered by it
+ALTER TABLE products ADD COLUMN turnaround_days INTEGER GENERATED ALWAYS AS (
+    SUBSTRING(turnaround FROM '(\d+)\D*$')::INTEGER
+) STORED;
+
+CREATE INDEX idx_products_search_vector ON products USING GIN (search_vector);
+CREATE INDEX idx_products_base_price ON products(base_price, id);
+CREATE INDEX idx_products_created_at ON products(created_at, id);
+CREATE INDEX idx_products_turnaround_days ON products(turnaround_days);
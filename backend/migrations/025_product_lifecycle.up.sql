@@ -0,0 +1,14 @@
+-- Products are staged as drafts, published, and archived when taken off sale.
+-- publish_at and unpublish_at schedule those changes. Deleted products are kept,
+-- with deleted_at set, so orders for them still resolve.
+ALTER TABLE products
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'published', 'archived')),
+    ADD COLUMN publish_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN unpublish_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE,
+    ADD CONSTRAINT products_schedule_order CHECK (unpublish_at IS NULL OR publish_at IS NULL OR unpublish_at > publish_at);
+
+CREATE INDEX idx_products_status ON products(status) WHERE deleted_at IS NULL;
+CREATE INDEX idx_products_publish_at ON products(publish_at) WHERE publish_at IS NOT NULL;
+CREATE INDEX idx_products_unpublish_at ON products(unpublish_at) WHERE unpublish_at IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Categories nest under a parent, and are shown in display_order among their
+-- siblings. A category with subcategories cannot be deleted until they are moved.
+ALTER TABLE categories
+    ADD COLUMN parent_id UUID REFERENCES categories(id) ON DELETE RESTRICT,
+    ADD COLUMN display_order INTEGER NOT NULL DEFAULT 0 CHECK (display_order >= 0),
+    ADD CONSTRAINT categories_not_own_parent CHECK (parent_id <> id);
+
+-- Existing categories keep their alphabetical order
+UPDATE categories c SET display_order = o.position
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY name) - 1 AS position FROM categories) o
+WHERE o.id = c.id;
+
+CREATE INDEX idx_categories_parent_id ON categories(parent_id, display_order);
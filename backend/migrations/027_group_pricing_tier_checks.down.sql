@@ -0,0 +1,4 @@
+ALTER TABLE customer_group_pricing_tiers
+    DROP CONSTRAINT IF EXISTS customer_group_pricing_tiers_range_check,
+    DROP CONSTRAINT IF EXISTS customer_group_pricing_tiers_min_qty_check,
+    DROP CONSTRAINT IF EXISTS customer_group_pricing_tiers_price_check;
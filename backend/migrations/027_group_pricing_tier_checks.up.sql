@@ -0,0 +1,7 @@
+-- Group quantity tiers follow the same rules as group fixed prices: a positive
+-- price, and a range that does not end before it starts (a max_qty of 0 has no
+-- upper limit). Overlapping ranges are rejected when the tiers are saved.
+ALTER TABLE customer_group_pricing_tiers
+    ADD CONSTRAINT customer_group_pricing_tiers_price_check CHECK (price > 0),
+    ADD CONSTRAINT customer_group_pricing_tiers_min_qty_check CHECK (min_qty >= 1),
+    ADD CONSTRAINT customer_group_pricing_tiers_range_check CHECK (max_qty = 0 OR max_qty >= min_qty);
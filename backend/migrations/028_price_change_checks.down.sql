@@ -0,0 +1,6 @@
+UPDATE price_changes SET status = 'cancelled' WHERE status = 'blocked';
+ALTER TABLE price_changes
+    DROP CONSTRAINT IF EXISTS price_changes_status_check,
+    ADD CONSTRAINT price_changes_status_check
+        CHECK (status IN ('scheduled', 'applied', 'cancelled', 'rolled_back')),
+    DROP COLUMN IF EXISTS allow_below_cost;
@@ -0,0 +1,9 @@
+-- Scheduled price changes are checked again when they are applied, against the
+-- prices at that time. allow_below_cost keeps what the change was submitted with,
+-- and a change whose prices are no longer allowed is left 'blocked'.
+ALTER TABLE price_changes ADD COLUMN allow_below_cost BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE price_changes
+    DROP CONSTRAINT IF EXISTS price_changes_status_check,
+    ADD CONSTRAINT price_changes_status_check
+        CHECK (status IN ('scheduled', 'applied', 'cancelled', 'rolled_back', 'blocked'));
@@ -0,0 +1,3 @@
+ALTER TABLE pricing_rules
+    DROP COLUMN IF EXISTS updated_at,
+    DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,5 @@
+-- When each pricing rule was last changed, so an order snapshot can record which
+-- version of a rule it was charged
+ALTER TABLE pricing_rules
+    ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
@@ -0,0 +1,3 @@
+DELETE FROM order_payment_reminders WHERE minutes_before % 60 <> 0;
+UPDATE order_payment_reminders SET minutes_before = minutes_before / 60;
+ALTER TABLE order_payment_reminders RENAME COLUMN minutes_before TO hours_before;
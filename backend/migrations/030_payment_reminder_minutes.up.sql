@@ -0,0 +1,4 @@
+-- Payment reminder offsets are kept in minutes, so reminders less than an hour,
+-- or not a whole number of hours, before the deadline do not share a key
+ALTER TABLE order_payment_reminders RENAME COLUMN hours_before TO minutes_before;
+UPDATE order_payment_reminders SET minutes_before = minutes_before * 60;
@@ -0,0 +1,9 @@
+// Package migrations embeds the SQL schema migrations so the binaries can apply
+// and check them without the source tree. Files are named
+// NNN_description.up.sql and NNN_description.down.sql.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
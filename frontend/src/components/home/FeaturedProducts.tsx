@@ -7,10 +7,10 @@ import { getImageUrl } from '@/lib/utils';
 import { Loader2 } from 'lucide-react';
 
 export function FeaturedProducts() {
-  const { data: productsData, isLoading } = useProducts();
+  const { data: productsData, isLoading } = useProducts({ sort: 'popularity', limit: 4 });
 
-  // Take first 4 products as featured
-  const featuredProducts = productsData?.slice(0, 4) || [];
+  // The 4 best-selling products are featured
+  const featuredProducts = productsData?.products || [];
 
   return (
     <section className="section-padding bg-secondary/50">
@@ -161,23 +161,19 @@ export function Header() {
   const { data: user } = useUser();
   const isAuthenticated = hasToken && !!user;
 
-  // Fetch products for search
-  const { data: productsData } = useProducts();
-
   const debouncedQuery = useDebounce(searchQuery, 300);
 
-  // Map and filter products based on search query
+  // Search products as the user types
+  const { data: productsData } = useProducts(
+    { search: debouncedQuery.trim(), limit: 8 },
+    !!debouncedQuery.trim()
+  );
+
+  // Map the matching products
   const searchResults: Product[] = useMemo(() => {
     if (!debouncedQuery.trim() || !productsData) return [];
 
-    const query = debouncedQuery.toLowerCase();
-    return productsData
-      .filter(
-        (product) =>
-          product.name.toLowerCase().includes(query) ||
-          (product.description || '').toLowerCase().includes(query) ||
-          (product.category || '').toLowerCase().includes(query)
-      )
+    return productsData.products
       .map((p) => ({
         id: p.id,
         name: p.name,
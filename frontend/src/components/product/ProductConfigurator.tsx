@@ -16,7 +16,8 @@ import { formatCurrency, formatPrice } from '@/lib/currency';
 // Dimensional pricing configuration passed from parent
 export interface DimensionalPricingConfig {
   ratePerUnit: number;
-  unit: 'sqft' | 'sqin' | 'sqm' | 'sqcm';
+  unit: 'sqft' | 'sqin' | 'sqm' | 'sqcm' | 'sqmm';
+  sizeUnit?: string;
   minCharge: number;
 }
 
@@ -68,6 +69,7 @@ export function ProductConfigurator({ product, dimensionalPricing }: ProductConf
       case 'sqin': return 'sq in';
       case 'sqm': return 'sq m';
       case 'sqcm': return 'sq cm';
+      case 'sqmm': return 'sq mm';
       default: return unit;
     }
   };
@@ -199,8 +201,18 @@ export function ProductConfigurator({ product, dimensionalPricing }: ProductConf
     }));
   };
 
+  // Options whose dependency is not met are not part of the configuration
+  const isAvailable = (option: ProductOption) =>
+    !option.dependsOn || option.dependsOn.values.includes(String(configuration[option.dependsOn.option]));
+
   const handleAddToCart = () => {
-    addItem(product, quantity, configuration, totalPrice);
+    const itemConfiguration = { ...configuration };
+    product.options.forEach((option) => {
+      if (!isAvailable(option)) {
+        delete itemConfiguration[option.id];
+      }
+    });
+    addItem(product, quantity, itemConfiguration, totalPrice);
     toast.success(`${product.name} added to cart!`);
     navigate('/cart');
   };
@@ -367,7 +379,7 @@ export function ProductConfigurator({ product, dimensionalPricing }: ProductConf
                 className="pr-12"
               />
               <span className="absolute right-3 top-1/2 -translate-y-1/2 text-sm text-muted-foreground">
-                {dimensionalPricing.unit.replace('sq', '')}
+                {dimensionalPricing.sizeUnit || dimensionalPricing.unit.replace('sq', '')}
               </span>
             </div>
           </div>
@@ -385,7 +397,7 @@ export function ProductConfigurator({ product, dimensionalPricing }: ProductConf
                 className="pr-12"
               />
               <span className="absolute right-3 top-1/2 -translate-y-1/2 text-sm text-muted-foreground">
-                {dimensionalPricing.unit.replace('sq', '')}
+                {dimensionalPricing.sizeUnit || dimensionalPricing.unit.replace('sq', '')}
               </span>
             </div>
           </div>
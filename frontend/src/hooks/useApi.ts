@@ -44,6 +44,7 @@ import {
   type ApplyCouponRequest,
   type CreateCouponRequest,
   type UpdateShippingConfigRequest,
+  type ProductSearchParams,
 } from '@/services/api';
 
 // ==================== QUERY KEYS ====================
@@ -77,10 +78,11 @@ export const queryKeys = {
 
 // ==================== PRODUCTS HOOKS ====================
 
-export function useProducts(params?: { category?: string; search?: string }) {
+export function useProducts(params?: ProductSearchParams, enabled = true) {
   return useQuery({
     queryKey: [...queryKeys.products, params],
     queryFn: () => productsApi.getAll(params),
+    enabled,
   });
 }
 
@@ -350,10 +352,10 @@ export function useDeleteCategory() {
   });
 }
 
-export function useAdminProducts() {
+export function useAdminProducts(params?: ProductSearchParams) {
   return useQuery({
-    queryKey: queryKeys.adminProducts,
-    queryFn: () => adminApi.getProducts(),
+    queryKey: [...queryKeys.adminProducts, params],
+    queryFn: () => adminApi.getProducts(params),
     enabled: !!getAuthToken(),
   });
 }
@@ -13,7 +13,7 @@ import { paymentsApi, ApiError, getAuthToken } from '@/services/api';
 
 export default function CheckoutPage() {
   const navigate = useNavigate();
-  const { items, subtotal, discountAmount } = useCart();
+  const { items, subtotal, discountAmount, couponCode } = useCart();
   const [isSubmitting, setIsSubmitting] = useState(false);
   const createOrder = useCreateOrder();
 
@@ -155,11 +155,11 @@ export default function CheckoutPage() {
         total,
       });
 
-      // Create order in backend with discount
+      // Create order in backend; the coupon is re-validated and redeemed server-side
       const order = await createOrder.mutateAsync({
         shippingAddress,
         items: orderItems,
-        discount: discountAmount,
+        couponCode: couponCode ?? undefined,
       });
 
       console.log('Order created:', order.id, 'Total:', order.total);
@@ -192,7 +192,8 @@ export default function CheckoutPage() {
         console.log('Redirecting to Paystack...');
         
         // Validate that we have a proper Paystack key before trying SDK
-        if (paystackKey && paystackKey !== 'pk_test_your_paystack_public_key') {
+        // Only Paystack has an inline SDK; other providers use their hosted checkout
+        if (payment.provider === 'paystack' && paystackKey && paystackKey !== 'pk_test_your_paystack_public_key') {
           // Try to load and use Paystack SDK as primary method
           const loadPaystackScript = () => {
             return new Promise<void>((resolve, reject) => {
@@ -20,7 +20,7 @@ export default function PaymentCallbackPage() {
   const [reference, setReference] = useState<string | null>(null);
   const verifyOnceRef = useRef(false);
 
-  // Extract ?reference from Paystack callback URL
+  // Extract the payment reference from the provider's callback URL
   useEffect(() => {
     // Only verify once per page load
     if (verifyOnceRef.current) {
@@ -28,7 +28,8 @@ export default function PaymentCallbackPage() {
     }
 
     const searchParams = new URLSearchParams(location.search);
-    const ref = searchParams.get('reference') || searchParams.get('trxref');
+    // Paystack sends reference/trxref, Flutterwave sends tx_ref
+    const ref = searchParams.get('reference') || searchParams.get('trxref') || searchParams.get('tx_ref');
 
     if (!ref) {
       setStatus('failed');
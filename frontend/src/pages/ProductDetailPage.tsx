@@ -42,7 +42,8 @@ export default function ProductDetailPage() {
   const dimensionalPricing: DimensionalPricingConfig | null = productPricing?.dimensionalPricing
     ? {
         ratePerUnit: productPricing.dimensionalPricing.ratePerUnit,
-        unit: productPricing.dimensionalPricing.unit as 'sqft' | 'sqin' | 'sqm' | 'sqcm',
+        unit: productPricing.dimensionalPricing.unit as 'sqft' | 'sqin' | 'sqm' | 'sqcm' | 'sqmm',
+        sizeUnit: productPricing.dimensionalPricing.sizeUnit,
         minCharge: productPricing.dimensionalPricing.minCharge,
       }
     : null;
@@ -50,7 +50,7 @@ export default function ProductsPage() {
   const { data: categoriesData, isLoading: categoriesLoading } = useCategories();
 
   // Map API responses to frontend types
-  const products = productsData?.map(mapProductResponse) || [];
+  const products = productsData?.products.map(mapProductResponse) || [];
   const categories = categoriesData?.map(mapCategoryResponse) || [];
 
   const currentCategory = category
@@ -22,8 +22,8 @@ export default function AdminEmailPage() {
   const [broadcastResult, setBroadcastResult] = useState<{
     success: boolean;
     message: string;
-    successCount?: number;
-    failedCount?: number;
+    broadcastId?: string;
+    totalRecipients?: number;
   } | null>(null);
 
   const handleSendTestEmail = async (e: React.FormEvent) => {
@@ -56,8 +56,8 @@ export default function AdminEmailPage() {
       setBroadcastResult({
         success: true,
         message: result.message,
-        successCount: result.successCount,
-        failedCount: result.failedCount,
+        broadcastId: result.broadcastId,
+        totalRecipients: result.totalRecipients,
       });
       setBroadcastSubject('');
       setBroadcastContent('');
@@ -217,10 +217,10 @@ export default function AdminEmailPage() {
                   {broadcastResult.success ? <CheckCircle2 className="h-4 w-4" /> : <AlertCircle className="h-4 w-4" />}
                   <span className="font-medium">{broadcastResult.message}</span>
                 </div>
-                {broadcastResult.successCount !== undefined && (
+                {broadcastResult.broadcastId && (
                   <div className="text-sm">
-                    <p>Successfully sent: {broadcastResult.successCount}</p>
-                    <p>Failed: {broadcastResult.failedCount}</p>
+                    <p>Recipients: {broadcastResult.totalRecipients}</p>
+                    <p>Emails are being delivered in the background.</p>
                   </div>
                 )}
               </div>
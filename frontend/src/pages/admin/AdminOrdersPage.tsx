@@ -225,7 +225,10 @@ export default function AdminOrdersPage() {
                   {selectedOrder.items?.map((item, idx) => (
                     <div key={idx} className="p-3 flex justify-between items-center">
                       <div>
-                        <p className="font-medium">{item.product_name}</p>
+                        <p className="font-medium">{item.snapshot?.productName || item.product_name}</p>
+                        {item.snapshot?.options?.map((opt) => (
+                          <p key={opt.optionId} className="text-sm text-muted-foreground">{opt.name}: {opt.value}</p>
+                        ))}
                         <p className="text-sm text-muted-foreground">Qty: {item.quantity}</p>
                         {item.file_url && (
                           <a
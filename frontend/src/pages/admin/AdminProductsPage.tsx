@@ -56,7 +56,7 @@ interface PricingTierFormData {
 // Dimensional Pricing for area-based pricing
 interface DimensionalPricingFormData {
   ratePerUnit: string;
-  unit: 'sqft' | 'sqin' | 'sqm' | 'sqcm';
+  unit: 'sqft' | 'sqin' | 'sqm' | 'sqcm' | 'sqmm';
   minCharge: string;
 }
 
@@ -103,7 +103,9 @@ const emptyForm: FormData = {
 };
 
 export default function AdminProductsPage() {
-  const { data: products, isLoading } = useAdminProducts();
+  // Filtering below is done in the browser over the first 100 products
+  const { data: productsPage, isLoading } = useAdminProducts({ limit: 100 });
+  const products = productsPage?.products;
   const { data: categories } = useAdminCategories();
   const createMutation = useCreateProduct();
   const updateMutation = useUpdateProduct();
@@ -252,7 +254,7 @@ export default function AdminProductsPage() {
           pricingType: 'dimensional',
           dimensionalPricing: {
             ratePerUnit: dp.ratePerUnit.toString(),
-            unit: dp.unit as 'sqft' | 'sqin' | 'sqm' | 'sqcm',
+            unit: dp.unit as 'sqft' | 'sqin' | 'sqm' | 'sqcm' | 'sqmm',
             minCharge: dp.minCharge.toString(),
           },
         }));
@@ -459,9 +461,18 @@ export default function AdminProductsPage() {
       try {
         if (formData.pricingType === 'dimensional') {
           // Set dimensional pricing
+          // Keep the size settings this form does not edit
+          const existing = productPricing?.dimensionalPricing;
           await setDimensionalPricingMutation.mutateAsync({
             productId: editingProduct.id,
             data: {
+              sizeUnit: existing?.sizeUnit,
+              minWidth: existing?.minWidth,
+              maxWidth: existing?.maxWidth,
+              minHeight: existing?.minHeight,
+              maxHeight: existing?.maxHeight,
+              bleed: existing?.bleed,
+              finishings: existing?.finishings,
               ratePerUnit: parseFloat(formData.dimensionalPricing.ratePerUnit) || 0,
               unit: formData.dimensionalPricing.unit,
               minCharge: parseFloat(formData.dimensionalPricing.minCharge) || 0,
@@ -826,7 +837,7 @@ export default function AdminProductsPage() {
                 value={formData.dimensionalPricing.unit}
                 onValueChange={(value) => setFormData(prev => ({
                   ...prev,
-                  dimensionalPricing: { ...prev.dimensionalPricing, unit: value as 'sqft' | 'sqin' | 'sqm' | 'sqcm' },
+                  dimensionalPricing: { ...prev.dimensionalPricing, unit: value as 'sqft' | 'sqin' | 'sqm' | 'sqcm' | 'sqmm' },
                 }))}
               >
                 <SelectTrigger>
@@ -836,6 +847,7 @@ export default function AdminProductsPage() {
                   <SelectItem value="sqin">Square Inches (sqin)</SelectItem>
                   <SelectItem value="sqft">Square Feet (sqft)</SelectItem>
                   <SelectItem value="sqcm">Square Centimeters (sqcm)</SelectItem>
+                  <SelectItem value="sqmm">Square Millimeters (sqmm)</SelectItem>
                   <SelectItem value="sqm">Square Meters (sqm)</SelectItem>
                 </SelectContent>
               </Select>
@@ -1093,7 +1105,7 @@ export default function AdminProductsPage() {
 
           {/* Results count */}
           <div className="text-sm text-muted-foreground">
-            Showing {filteredProducts.length} of {products?.length || 0} products
+            Showing {filteredProducts.length} of {productsPage?.total || 0} products
           </div>
         </div>
       </div>
@@ -208,6 +208,30 @@ export const twoFactorApi = {
     }),
 };
 
+// ==================== NOTIFICATION PREFERENCES API ====================
+
+export interface NotificationPreferences {
+  userId: string;
+  orderUpdates: boolean;
+  marketing: boolean;
+  updatedAt: string;
+}
+
+export interface UpdateNotificationPreferencesRequest {
+  orderUpdates?: boolean;
+  marketing?: boolean;
+}
+
+export const notificationPreferencesApi = {
+  get: () => request<NotificationPreferences>('/auth/notification-preferences'),
+
+  update: (data: UpdateNotificationPreferencesRequest) =>
+    request<NotificationPreferences>('/auth/notification-preferences', {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+};
+
 // ==================== PRODUCTS API ====================
 
 export interface ProductResponse {
@@ -225,11 +249,20 @@ export interface ProductResponse {
   features: string[];
   turnaround: string;
   minQuantity: number;
+  taxExempt: boolean;
+  categoryTaxExempt: boolean;
+  costPrice?: number; // Only returned by admin endpoints
+  status: ProductStatus; // Customers only ever see published products
+  publishAt?: string;
+  unpublishAt?: string;
+  deletedAt?: string;
   pricingTiers?: PricingTier[];
   createdAt: string;
   updatedAt: string;
 }
 
+export type ProductStatus = 'draft' | 'published' | 'archived';
+
 export interface PricingTier {
   id: string;
   productId: string;
@@ -238,14 +271,46 @@ export interface PricingTier {
   price: number;
 }
 
+export type ProductSort = 'relevance' | 'name' | 'price_asc' | 'price_desc' | 'newest' | 'popularity';
+
+export interface ProductSearchParams {
+  status?: ProductStatus | 'deleted'; // Admin listing only
+  search?: string;
+  category?: string | string[]; // Category slugs; subcategories are included
+  minPrice?: number;
+  maxPrice?: number;
+  maxTurnaroundDays?: number;
+  sort?: ProductSort; // Defaults to relevance when searching, otherwise name
+  cursor?: string; // nextCursor from the previous page, with the same params
+  limit?: number; // Default 24, at most 100
+}
+
+export interface ProductPage {
+  products: ProductResponse[];
+  total: number;
+  nextCursor?: string; // Absent on the last page
+}
+
+function productSearchQuery(params?: ProductSearchParams): string {
+  const query = new URLSearchParams();
+  if (params?.status) query.set('status', params.status);
+  if (params?.search) query.set('search', params.search);
+  if (params?.category) {
+    query.set('category', Array.isArray(params.category) ? params.category.join(',') : params.category);
+  }
+  if (params?.minPrice !== undefined) query.set('minPrice', String(params.minPrice));
+  if (params?.maxPrice !== undefined) query.set('maxPrice', String(params.maxPrice));
+  if (params?.maxTurnaroundDays) query.set('maxTurnaroundDays', String(params.maxTurnaroundDays));
+  if (params?.sort) query.set('sort', params.sort);
+  if (params?.cursor) query.set('cursor', params.cursor);
+  if (params?.limit) query.set('limit', String(params.limit));
+  const queryString = query.toString();
+  return queryString ? `?${queryString}` : '';
+}
+
 export const productsApi = {
-  getAll: (params?: { category?: string; search?: string }) => {
-    const query = new URLSearchParams();
-    if (params?.category) query.set('category', params.category);
-    if (params?.search) query.set('search', params.search);
-    const queryString = query.toString();
-    return request<ProductResponse[]>(`/products${queryString ? `?${queryString}` : ''}`);
-  },
+  getAll: (params?: ProductSearchParams) =>
+    request<ProductPage>(`/products${productSearchQuery(params)}`),
 
   getBySlug: (slug: string) =>
     request<ProductResponse>(`/products/${slug}`),
@@ -259,9 +324,27 @@ export interface CategoryResponse {
   slug: string;
   description: string;
   image: string;
-  productCount: number;
+  parentId?: string;
+  displayOrder: number;
+  depth: number; // 0 for top-level categories
+  taxExempt: boolean;
+  productCount: number; // Published products, including those in subcategories
   createdAt: string;
   updatedAt: string;
+  path?: CategoryCrumb[]; // Top level down to this category; single-category responses only
+  children?: CategoryResponse[]; // From GET /categories/:slug only
+}
+
+export interface CategoryCrumb {
+  id: string;
+  name: string;
+  slug: string;
+}
+
+// Returned as the error data when deleting a category that is still in use
+export interface CategoryInUse {
+  subcategoryCount: number;
+  productCount: number;
 }
 
 export const categoriesApi = {
@@ -330,6 +413,23 @@ export interface OrderItemResponse {
   total_price: number;
   selected_options: Record<string, string>;
   file_url?: string;
+  snapshot?: OrderItemSnapshot; // The product and pricing as ordered
+  taxRate: number;
+  taxExemption?: 'product' | 'category' | 'customer';
+  tax: number;
+  netAmount: number; // The line after its share of the discount, excluding tax
+}
+
+export interface OrderItemSnapshot {
+  productName: string;
+  productSlug: string;
+  options?: { optionId: string; name: string; value: string }[];
+  breakdown?: PriceBreakdown;
+  rules?: PricingRuleResponse[];
+  formulas?: PricingFormulaResponse[];
+  pricedAt?: string;
+  quoteNumber?: string; // Set when the item was ordered from a quote
+  override?: QuotePriceOverride; // The last staff price change to the quote line
 }
 
 export interface OrderResponse {
@@ -343,6 +443,8 @@ export interface OrderResponse {
   tax: number;
   vat?: number;
   total: number;
+  pricesIncludeTax: boolean;
+  customerTin?: string;
   shipping_address: string | {
     name: string;
     street: string;
@@ -369,7 +471,7 @@ export interface CreateOrderRequest {
     country: string;
   };
   items: CreateOrderItemRequest[];
-  discount?: number;
+  couponCode?: string;
 }
 
 export interface CreateOrderItemRequest {
@@ -391,6 +493,100 @@ export const ordersApi = {
     }),
 };
 
+// ==================== QUOTES API ====================
+
+export type QuoteStatus = 'open' | 'expired' | 'converted';
+
+export interface QuotePriceOverride {
+  id: string;
+  quoteItemId: string;
+  previousPrice: number;
+  price: number;
+  reason: string;
+  createdBy: string;
+  createdAt: string;
+}
+
+export interface QuoteItem {
+  id: string;
+  quoteId: string;
+  productId: string;
+  quantity: number;
+  configuration: Record<string, unknown>;
+  unitPrice: number;
+  totalPrice: number;
+  snapshot: OrderItemSnapshot;
+  overrides?: QuotePriceOverride[]; // Admin only
+}
+
+export interface Quote {
+  id: string;
+  quoteNumber: string;
+  userId: string;
+  status: QuoteStatus;
+  items: QuoteItem[];
+  subtotal: number;
+  notes?: string;
+  shareToken?: string; // Only shown to the quote's owner
+  expiresAt: string;
+  orderId?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface CreateQuoteRequest {
+  items?: CreateOrderItemRequest[]; // Leave empty to quote the cart
+  notes?: string;
+}
+
+export interface ConvertQuoteRequest {
+  shippingAddress: CreateOrderRequest['shippingAddress'];
+  couponCode?: string;
+}
+
+/**
+ * Download a quote PDF as a Blob
+ */
+async function requestPDF(endpoint: string): Promise<Blob> {
+  const token = getAuthToken();
+  const headers: HeadersInit = {};
+  if (token) {
+    headers['Authorization'] = `Bearer ${token}`;
+  }
+
+  const response = await fetch(`${API_BASE_URL}${endpoint}`, { headers });
+  if (!response.ok) {
+    const data = await response.json().catch(() => null);
+    throw new ApiError(response.status, data?.error || 'Failed to download quote', data);
+  }
+  return response.blob();
+}
+
+export const quotesApi = {
+  getAll: () => request<Quote[]>('/quotes'),
+
+  getById: (id: string) => request<Quote>(`/quotes/${id}`),
+
+  create: (data: CreateQuoteRequest) =>
+    request<Quote>('/quotes', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  convert: (id: string, data: ConvertQuoteRequest) =>
+    request<OrderResponse>(`/quotes/${id}/convert`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  downloadPDF: (id: string) => requestPDF(`/quotes/${id}/pdf`),
+
+  // Anyone with a share link can view the quote
+  getShared: (token: string) => request<Quote>(`/quotes/shared/${token}`),
+
+  sharedPDFUrl: (token: string) => `${API_BASE_URL}/quotes/shared/${token}/pdf`,
+};
+
 // ==================== PRICING API ====================
 
 export interface PricingRequest {
@@ -422,13 +618,20 @@ export const pricingApi = {
 
 export interface InitializePaymentRequest {
   orderId: string;
+  provider?: string;
 }
 
 export interface PaymentResponse {
+  provider: string;
   authorization_url: string;
   reference: string;
 }
 
+export interface PaymentProvidersResponse {
+  providers: string[];
+  default: string;
+}
+
 export interface VerifyPaymentResponse {
   status: string;
   reference: string;
@@ -445,6 +648,8 @@ export const paymentsApi = {
 
   verify: (reference: string) =>
     request<VerifyPaymentResponse>(`/payments/verify/${reference}`),
+
+  getProviders: () => request<PaymentProvidersResponse>('/payments/providers'),
 };
 
 // ==================== FILE UPLOAD API ====================
@@ -507,6 +712,7 @@ export interface DailySalesReport {
   date: string;
   orderCount: number;
   totalSales: number;
+  refunded: number;
 }
 
 export interface WeeklySalesReport {
@@ -514,6 +720,7 @@ export interface WeeklySalesReport {
   weekEnd: string;
   orderCount: number;
   totalSales: number;
+  refunded: number;
 }
 
 export interface OrdersByStatusReport {
@@ -568,6 +775,8 @@ export interface CreateCategoryRequest {
   slug: string;
   description?: string;
   image?: string;
+  taxExempt?: boolean;
+  parentId?: string;
 }
 
 export interface UpdateCategoryRequest {
@@ -575,6 +784,14 @@ export interface UpdateCategoryRequest {
   slug?: string;
   description?: string;
   image?: string;
+  taxExempt?: boolean;
+  parentId?: string;
+  moveToTop?: boolean;
+}
+
+export interface ReorderCategoriesRequest {
+  parentId?: string; // Omit for the top level
+  categoryIds: string[];
 }
 
 export interface CreateProductRequest {
@@ -589,6 +806,11 @@ export interface CreateProductRequest {
   features?: string[];
   turnaround?: string;
   minQuantity?: number;
+  taxExempt?: boolean;
+  costPrice?: number;
+  status?: ProductStatus; // Defaults to published
+  publishAt?: string;
+  unpublishAt?: string;
 }
 
 export interface UpdateProductRequest {
@@ -603,6 +825,89 @@ export interface UpdateProductRequest {
   features?: string[];
   turnaround?: string;
   minQuantity?: number;
+  taxExempt?: boolean;
+  costPrice?: number; // 0 clears the cost
+  status?: ProductStatus;
+  priceReason?: string; // Recorded in the price history when basePrice changes
+}
+
+export interface TaxRate {
+  id: string;
+  name: string;
+  rate: number; // Per cent
+  effectiveFrom: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface TaxRateRequest {
+  name: string;
+  rate: number;
+  effectiveFrom: string;
+}
+
+export interface TaxSettings {
+  id: string;
+  pricesIncludeTax: boolean;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface CustomerTaxProfile {
+  userId: string;
+  companyName?: string;
+  tin: string;
+  taxExempt: boolean;
+  updatedBy?: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface UpdateCustomerTaxProfileRequest {
+  companyName?: string;
+  tin: string; // 12345678-0001 or 10 digits
+  taxExempt: boolean;
+}
+
+export interface VATReportLine {
+  taxRate: number;
+  exemption?: 'product' | 'category' | 'customer';
+  itemCount: number;
+  netAmount: number;
+  tax: number;
+}
+
+export interface CustomerGroup {
+  id: string;
+  name: string;
+  description?: string;
+  memberCount: number;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface CustomerGroupMember {
+  userId: string;
+  email: string;
+  firstName: string;
+  lastName: string;
+  addedBy?: string;
+  addedAt: string;
+}
+
+export interface CustomerGroupPriceList {
+  categoryDiscounts: { categoryId: string; categoryName: string; percent: number }[];
+  productPrices: { productId: string; productName: string; price: number }[];
+  pricingTiers: PricingTierResponse[];
+}
+
+export interface VATReport {
+  from: string;
+  to: string;
+  orderCount: number;
+  lines: VATReportLine[];
+  netAmount: number;
+  tax: number;
 }
 
 export interface UpdateOrderStatusRequest {
@@ -611,18 +916,176 @@ export interface UpdateOrderStatusRequest {
   adminNotes?: string;
 }
 
+export interface OrderTransitionsResponse {
+  orderId: string;
+  status: string;
+  allowedTransitions: string[];
+}
+
+// Products must match every selector given, and at least one is required
 export interface BulkUpdatePriceRequest {
-  productIds: string[];
+  productIds?: string[];
+  categoryId?: string;
+  search?: string; // Matches product names
   updateType: 'set' | 'increase' | 'decrease' | 'percentage';
   value: number;
+  effectiveAt?: string; // A future time schedules the change instead of applying it now
+  reason?: string;
+  allowBelowCost?: boolean;
 }
 
+export type PriceViolation = 'negative_price' | 'below_cost';
+
+export interface BulkPricePreviewTier {
+  tierId: string;
+  minQty: number;
+  maxQty: number;
+  oldPrice: number;
+  newPrice: number;
+  violations?: PriceViolation[];
+}
+
+export interface BulkPricePreviewProduct {
+  productId: string;
+  name: string;
+  costPrice?: number;
+  oldPrice: number;
+  newPrice: number; // May be negative
+  tiers?: BulkPricePreviewTier[];
+  violations?: PriceViolation[];
+}
+
+// Also the error data when bulkUpdatePrice is refused for its violations
+export interface BulkPricePreview {
+  products: BulkPricePreviewProduct[];
+  productCount: number;
+  violationCount: number;
+  failedIds?: string[];
+}
+
+export type PriceChangeStatus = 'scheduled' | 'applied' | 'cancelled' | 'rolled_back';
+
 export interface BulkUpdatePriceResponse {
+  priceChangeId?: string;
+  status?: PriceChangeStatus;
   updatedCount: number;
+  scheduledCount?: number;
   failedCount: number;
   failedIds?: string[];
 }
 
+export interface ProductPriceHistory {
+  id: string;
+  productId: string;
+  productName: string;
+  priceChangeId?: string;
+  oldPrice: number;
+  newPrice: number;
+  reason?: string;
+  changedBy?: string; // Absent for changes made by the system
+  changedByName?: string;
+  changedAt: string;
+}
+
+export interface PriceChangeTier {
+  tierId: string;
+  productId: string;
+  oldPrice: number;
+  newPrice: number;
+}
+
+export interface PriceChange {
+  id: string;
+  updateType: 'set' | 'increase' | 'decrease' | 'percentage' | 'rollback';
+  value: number;
+  reason?: string;
+  status: PriceChangeStatus;
+  effectiveAt: string;
+  appliedAt?: string;
+  rollbackOf?: string;
+  rolledBackBy?: string;
+  rolledBackAt?: string;
+  createdBy?: string;
+  productIds: string[];
+  history?: ProductPriceHistory[];
+  tiers?: PriceChangeTier[];
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface PaymentRecord {
+  id: string;
+  orderId: string;
+  provider: string;
+  providerReference: string;
+  amount: number;
+  currency: string;
+  status: 'pending' | 'success' | 'failed' | 'refunded' | 'partially_refunded' | 'expired';
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface Refund {
+  id: string;
+  paymentId: string;
+  orderId: string;
+  provider: string;
+  providerRefundId: string;
+  amount: number;
+  currency: string;
+  reason: string;
+  status: 'pending' | 'processed' | 'failed';
+  error?: string;
+  createdBy?: string;
+  createdAt: string;
+  updatedAt: string;
+  processedAt?: string;
+}
+
+export interface CreateRefundRequest {
+  // Omit to refund whatever has not been refunded yet
+  amount?: number;
+  reason: string;
+}
+
+export interface PaymentRefundsResponse {
+  payment: PaymentRecord;
+  refunds: Refund[];
+  refundedAmount: number;
+  refundableAmount: number;
+}
+
+export interface ReconciliationItem {
+  id: string;
+  runId: string;
+  paymentId: string;
+  orderId: string;
+  provider: string;
+  reference: string;
+  outcome: 'settled' | 'failed' | 'expired' | 'pending' | 'mismatch' | 'error';
+  providerStatus: string;
+  expectedAmount: number;
+  providerAmount?: number;
+  currency: string;
+  detail: string;
+  createdAt: string;
+}
+
+export interface ReconciliationRun {
+  id: string;
+  trigger: 'scheduled' | 'manual' | 'command';
+  checked: number;
+  settled: number;
+  failed: number;
+  expired: number;
+  pending: number;
+  mismatches: number;
+  errors: number;
+  startedAt: string;
+  finishedAt?: string;
+  items?: ReconciliationItem[];
+}
+
 export const adminApi = {
   // Dashboard
   getDashboardStats: () => request<DashboardStats>('/admin/dashboard'),
@@ -637,6 +1100,43 @@ export const adminApi = {
   getOrdersByStatusReport: () =>
     request<OrdersByStatusReport[]>('/admin/reports/orders-by-status'),
 
+  // Dates are YYYY-MM-DD and both included; defaults to the previous month
+  getVATReport: (from?: string, to?: string) => {
+    const query = new URLSearchParams();
+    if (from) query.set('from', from);
+    if (to) query.set('to', to);
+    const queryString = query.toString();
+    return request<VATReport>(`/admin/reports/vat${queryString ? `?${queryString}` : ''}`);
+  },
+
+  // Tax
+  getTaxSettings: () => request<TaxSettings>('/admin/tax-settings'),
+
+  updateTaxSettings: (data: { pricesIncludeTax: boolean }) =>
+    request<TaxSettings>('/admin/tax-settings', {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  getTaxRates: () => request<TaxRate[]>('/admin/tax-rates'),
+
+  createTaxRate: (data: TaxRateRequest) =>
+    request<TaxRate>('/admin/tax-rates', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  updateTaxRate: (id: string, data: TaxRateRequest) =>
+    request<TaxRate>(`/admin/tax-rates/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deleteTaxRate: (id: string) =>
+    request<void>(`/admin/tax-rates/${id}`, {
+      method: 'DELETE',
+    }),
+
   // Categories
   getCategories: () => request<CategoryResponse[]>('/admin/categories'),
 
@@ -652,13 +1152,22 @@ export const adminApi = {
       body: JSON.stringify(data),
     }),
 
-  deleteCategory: (id: string) =>
-    request<void>(`/admin/categories/${id}`, {
-      method: 'DELETE',
+  reorderCategories: (data: ReorderCategoriesRequest) =>
+    request<CategoryResponse[]>('/admin/categories/reorder', {
+      method: 'PUT',
+      body: JSON.stringify(data),
     }),
 
+  // Without reassignTo, deleting a category with subcategories or products fails
+  deleteCategory: (id: string, reassignTo?: string) =>
+    request<void>(
+      `/admin/categories/${id}${reassignTo ? `?reassignTo=${encodeURIComponent(reassignTo)}` : ''}`,
+      { method: 'DELETE' }
+    ),
+
   // Products
-  getProducts: () => request<ProductResponse[]>('/admin/products'),
+  getProducts: (params?: ProductSearchParams) =>
+    request<ProductPage>(`/admin/products${productSearchQuery(params)}`),
 
   createProduct: (data: CreateProductRequest) =>
     request<ProductResponse>('/admin/products', {
@@ -672,11 +1181,24 @@ export const adminApi = {
       body: JSON.stringify(data),
     }),
 
+  // Deleted products are kept for past orders and can be restored
   deleteProduct: (id: string) =>
     request<void>(`/admin/products/${id}`, {
       method: 'DELETE',
     }),
 
+  restoreProduct: (id: string) =>
+    request<ProductResponse>(`/admin/products/${id}/restore`, {
+      method: 'POST',
+    }),
+
+  // Replaces both times; leaving one out clears it
+  setProductSchedule: (id: string, data: { publishAt?: string; unpublishAt?: string }) =>
+    request<ProductResponse>(`/admin/products/${id}/schedule`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
   // Bulk Operations
   bulkUpdatePrice: (data: BulkUpdatePriceRequest) =>
     request<BulkUpdatePriceResponse>('/admin/products/bulk-update-price', {
@@ -684,6 +1206,31 @@ export const adminApi = {
       body: JSON.stringify(data),
     }),
 
+  previewBulkUpdatePrice: (data: BulkUpdatePriceRequest) =>
+    request<BulkPricePreview>('/admin/products/bulk-update-price/dry-run', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  getPriceHistory: (productId: string) =>
+    request<ProductPriceHistory[]>(`/admin/products/${productId}/price-history`),
+
+  // Price changes
+  getPriceChanges: (status?: PriceChangeStatus) =>
+    request<PriceChange[]>(`/admin/price-changes${status ? `?status=${status}` : ''}`),
+
+  getPriceChange: (id: string) => request<PriceChange>(`/admin/price-changes/${id}`),
+
+  cancelPriceChange: (id: string) =>
+    request<void>(`/admin/price-changes/${id}/cancel`, { method: 'POST' }),
+
+  // Fails with 409 and the changed products if any price has changed since
+  rollbackPriceChange: (id: string, reason: string) =>
+    request<PriceChange>(`/admin/price-changes/${id}/rollback`, {
+      method: 'POST',
+      body: JSON.stringify({ reason }),
+    }),
+
   // Orders
   getOrders: (status?: string) =>
     request<AdminOrderResponse[]>(`/admin/orders${status ? `?status=${status}` : ''}`),
@@ -694,9 +1241,133 @@ export const adminApi = {
       body: JSON.stringify(data),
     }),
 
+  getOrderTransitions: (id: string) =>
+    request<OrderTransitionsResponse>(`/admin/orders/${id}/transitions`),
+
+  // Quotes
+  getQuotes: (status?: QuoteStatus) =>
+    request<Quote[]>(`/admin/quotes${status ? `?status=${status}` : ''}`),
+
+  getQuote: (id: string) => request<Quote>(`/admin/quotes/${id}`),
+
+  overrideQuotePrice: (quoteId: string, itemId: string, data: { totalPrice: number; reason: string }) =>
+    request<Quote>(`/admin/quotes/${quoteId}/items/${itemId}/price`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  // Payments & refunds
+  getOrderPayments: (orderId: string) =>
+    request<PaymentRecord[]>(`/admin/orders/${orderId}/payments`),
+
+  getPaymentRefunds: (paymentId: string) =>
+    request<PaymentRefundsResponse>(`/admin/payments/${paymentId}/refunds`),
+
+  createRefund: (paymentId: string, data: CreateRefundRequest) =>
+    request<Refund>(`/admin/payments/${paymentId}/refunds`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  getReconciliationRuns: () =>
+    request<ReconciliationRun[]>('/admin/payments/reconciliation-runs'),
+
+  getReconciliationRun: (id: string, outcome?: string) =>
+    request<ReconciliationRun>(`/admin/payments/reconciliation-runs/${id}${outcome ? `?outcome=${outcome}` : ''}`),
+
+  runReconciliation: () =>
+    request<ReconciliationRun>('/admin/payments/reconciliation-runs', { method: 'POST' }),
+
   // Customers
   getCustomers: () => request<CustomerResponse[]>('/admin/customers'),
 
+  getCustomerTaxProfile: (userId: string) =>
+    request<CustomerTaxProfile>(`/admin/customers/${userId}/tax-profile`),
+
+  updateCustomerTaxProfile: (userId: string, data: UpdateCustomerTaxProfileRequest) =>
+    request<CustomerTaxProfile>(`/admin/customers/${userId}/tax-profile`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deleteCustomerTaxProfile: (userId: string) =>
+    request<void>(`/admin/customers/${userId}/tax-profile`, {
+      method: 'DELETE',
+    }),
+
+  // Customer groups
+  getCustomerGroups: () => request<CustomerGroup[]>('/admin/customer-groups'),
+
+  getCustomerGroup: (id: string) => request<CustomerGroup>(`/admin/customer-groups/${id}`),
+
+  createCustomerGroup: (data: { name: string; description?: string }) =>
+    request<CustomerGroup>('/admin/customer-groups', {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  updateCustomerGroup: (id: string, data: { name: string; description?: string }) =>
+    request<CustomerGroup>(`/admin/customer-groups/${id}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deleteCustomerGroup: (id: string) =>
+    request<void>(`/admin/customer-groups/${id}`, {
+      method: 'DELETE',
+    }),
+
+  getCustomerGroupMembers: (id: string) =>
+    request<CustomerGroupMember[]>(`/admin/customer-groups/${id}/members`),
+
+  // Moves the customer out of any other group
+  addCustomerGroupMember: (id: string, userId: string) =>
+    request<void>(`/admin/customer-groups/${id}/members`, {
+      method: 'POST',
+      body: JSON.stringify({ userId }),
+    }),
+
+  removeCustomerGroupMember: (id: string, userId: string) =>
+    request<void>(`/admin/customer-groups/${id}/members/${userId}`, {
+      method: 'DELETE',
+    }),
+
+  getCustomerGroupPrices: (id: string) =>
+    request<CustomerGroupPriceList>(`/admin/customer-groups/${id}/prices`),
+
+  setGroupCategoryDiscount: (id: string, categoryId: string, percent: number) =>
+    request<CustomerGroupPriceList>(`/admin/customer-groups/${id}/category-discounts/${categoryId}`, {
+      method: 'PUT',
+      body: JSON.stringify({ percent }),
+    }),
+
+  deleteGroupCategoryDiscount: (id: string, categoryId: string) =>
+    request<void>(`/admin/customer-groups/${id}/category-discounts/${categoryId}`, {
+      method: 'DELETE',
+    }),
+
+  setGroupProductPrice: (id: string, productId: string, price: number) =>
+    request<CustomerGroupPriceList>(`/admin/customer-groups/${id}/product-prices/${productId}`, {
+      method: 'PUT',
+      body: JSON.stringify({ price }),
+    }),
+
+  deleteGroupProductPrice: (id: string, productId: string) =>
+    request<void>(`/admin/customer-groups/${id}/product-prices/${productId}`, {
+      method: 'DELETE',
+    }),
+
+  setGroupPricingTiers: (id: string, productId: string, tiers: SetPricingTierRequest[]) =>
+    request<PricingTierResponse[]>(`/admin/customer-groups/${id}/pricing-tiers/${productId}`, {
+      method: 'PUT',
+      body: JSON.stringify(tiers),
+    }),
+
+  deleteGroupPricingTiers: (id: string, productId: string) =>
+    request<void>(`/admin/customer-groups/${id}/pricing-tiers/${productId}`, {
+      method: 'DELETE',
+    }),
+
   // User Management (RBAC)
   getUsers: () => request<UserResponse[]>('/admin/users'),
 
@@ -732,6 +1403,75 @@ export const adminApi = {
     request<void>(`/admin/products/${productId}/pricing-tiers`, {
       method: 'DELETE',
     }),
+
+  // Add-ons
+  getAddOns: (productId: string) =>
+    request<AddOnResponse[]>(`/admin/products/${productId}/add-ons`),
+
+  createAddOn: (productId: string, data: AddOnRequest) =>
+    request<AddOnResponse>(`/admin/products/${productId}/add-ons`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  updateAddOn: (productId: string, addOnId: string, data: AddOnRequest) =>
+    request<AddOnResponse>(`/admin/products/${productId}/add-ons/${addOnId}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deleteAddOn: (productId: string, addOnId: string) =>
+    request<void>(`/admin/products/${productId}/add-ons/${addOnId}`, {
+      method: 'DELETE',
+    }),
+
+  // Pricing Rules (setup fees, rush fees, minimum charge)
+  getPricingRules: (productId: string) =>
+    request<PricingRuleResponse[]>(`/admin/products/${productId}/pricing-rules`),
+
+  createPricingRule: (productId: string, data: PricingRuleRequest) =>
+    request<PricingRuleResponse>(`/admin/products/${productId}/pricing-rules`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  updatePricingRule: (productId: string, ruleId: string, data: PricingRuleRequest) =>
+    request<PricingRuleResponse>(`/admin/products/${productId}/pricing-rules/${ruleId}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deletePricingRule: (productId: string, ruleId: string) =>
+    request<void>(`/admin/products/${productId}/pricing-rules/${ruleId}`, {
+      method: 'DELETE',
+    }),
+
+  // Pricing Formulas
+  getPricingFormulas: (productId: string) =>
+    request<PricingFormulaResponse[]>(`/admin/products/${productId}/pricing-formulas`),
+
+  createPricingFormula: (productId: string, data: PricingFormulaRequest) =>
+    request<PricingFormulaResponse>(`/admin/products/${productId}/pricing-formulas`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
+
+  updatePricingFormula: (productId: string, formulaId: string, data: PricingFormulaRequest) =>
+    request<PricingFormulaResponse>(`/admin/products/${productId}/pricing-formulas/${formulaId}`, {
+      method: 'PUT',
+      body: JSON.stringify(data),
+    }),
+
+  deletePricingFormula: (productId: string, formulaId: string) =>
+    request<void>(`/admin/products/${productId}/pricing-formulas/${formulaId}`, {
+      method: 'DELETE',
+    }),
+
+  dryRunPricingFormulas: (productId: string, data: FormulaDryRunRequest) =>
+    request<FormulaDryRunResult[]>(`/admin/products/${productId}/pricing-formulas/dry-run`, {
+      method: 'POST',
+      body: JSON.stringify(data),
+    }),
 };
 
 // ==================== DIMENSIONAL PRICING TYPES ====================
@@ -740,14 +1480,36 @@ export interface DimensionalPricingResponse {
   id: string;
   productId: string;
   ratePerUnit: number;
-  unit: string; // "sqft", "sqin", "sqm", "sqcm"
+  unit: string; // "sqmm", "sqcm", "sqm", "sqin", "sqft"
+  sizeUnit: string; // "mm", "cm", "m", "in", "ft"
   minCharge: number;
+  minWidth?: number;
+  maxWidth?: number;
+  minHeight?: number;
+  maxHeight?: number;
+  bleed: number;
+  finishings: EdgeFinishing[];
+}
+
+// An edge finish priced per size unit of edge, or per piece every `spacing` apart
+export interface EdgeFinishing {
+  id: string;
+  name: string;
+  rate: number;
+  spacing?: number;
 }
 
 export interface SetDimensionalPricingRequest {
   ratePerUnit: number;
   unit: string;
+  sizeUnit?: string;
   minCharge: number;
+  minWidth?: number;
+  maxWidth?: number;
+  minHeight?: number;
+  maxHeight?: number;
+  bleed?: number;
+  finishings?: EdgeFinishing[];
 }
 
 export interface ProductPricingResponse {
@@ -775,17 +1537,89 @@ export interface AddOnResponse {
   id: string;
   productId: string;
   name: string;
-  type: string;
+  type: 'flat' | 'percentage';
   priceModifier: number;
   enabled: boolean;
 }
 
+export interface AddOnRequest {
+  name: string;
+  type: 'flat' | 'percentage';
+  priceModifier: number;
+  enabled?: boolean;
+}
+
+export type PricingRuleType = 'minimum_charge' | 'setup_fee' | 'rush_fee';
+
 export interface PricingRuleResponse {
   id: string;
   productId: string;
-  ruleType: string;
+  ruleType: PricingRuleType;
+  valueType: 'flat' | 'percentage';
   value: number;
   description: string;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface PricingRuleRequest {
+  ruleType: PricingRuleType;
+  valueType?: 'flat' | 'percentage';
+  value: number;
+  description?: string;
+}
+
+export interface PricingFormulaResponse {
+  id: string;
+  productId: string;
+  position: number;
+  label: string;
+  expression: string;
+  published: boolean;
+  createdAt: string;
+  updatedAt: string;
+}
+
+export interface PricingFormulaRequest {
+  position: number;
+  label: string;
+  expression: string;
+  published: boolean;
+}
+
+export interface FormulaSample {
+  configuration: Record<string, unknown>;
+  quantity: number;
+}
+
+export interface FormulaDryRunRequest {
+  // Omit to test the product's saved formulas, drafts included
+  formulas?: PricingFormulaRequest[];
+  samples: FormulaSample[];
+}
+
+export interface FormulaDryRunResult extends FormulaSample {
+  breakdown?: PriceBreakdown;
+  error?: string;
+}
+
+// Option modifiers, finishing and add-ons are per unit; the rest is for the line
+export interface PriceBreakdown {
+  basePrice: number;
+  optionModifiers: Record<string, number>;
+  dimensionalCost: number;
+  finishing?: Record<string, number>;
+  quantityPrice: number;
+  formula?: { formulaId: string; label: string; amount: number }[];
+  addOns?: Record<string, number>;
+  setupFee: number;
+  rushFee: number;
+  minimumCharge: number;
+  rules?: { ruleId: string; ruleType: string; label: string; amount: number }[];
+  customerGroup?: string; // The group whose price list was used
+  groupDiscount: number; // The group's category discount on the line
+  subtotal: number;
+  total: number;
 }
 
 // ==================== ANNOUNCEMENT TYPES ====================
@@ -934,10 +1768,40 @@ export interface BroadcastEmailRequest {
 
 export interface BroadcastEmailResponse {
   message: string;
-  totalSent: number;
-  successCount: number;
-  failedCount: number;
-  errors?: string[];
+  broadcastId: string;
+  totalRecipients: number;
+}
+
+export interface EmailBroadcast {
+  id: string;
+  subject: string;
+  content?: string;
+  totalRecipients: number;
+  createdBy?: string;
+  createdAt: string;
+}
+
+export interface EmailOutboxMessage {
+  id: string;
+  broadcastId?: string;
+  recipient: string;
+  subject: string;
+  status: 'pending' | 'sending' | 'sent' | 'dead';
+  attempts: number;
+  maxAttempts: number;
+  nextAttemptAt: string;
+  lastError?: string;
+  sentAt?: string;
+  createdAt: string;
+}
+
+export interface EmailBroadcastProgress extends EmailBroadcast {
+  pending: number;
+  sending: number;
+  sent: number;
+  failed: number;
+  complete: boolean;
+  failures: EmailOutboxMessage[];
 }
 
 export const emailApi = {
@@ -957,6 +1821,16 @@ export const emailApi = {
       method: 'POST',
       body: JSON.stringify(data),
     }),
+
+  // Broadcast delivery progress
+  getBroadcasts: () => request<EmailBroadcast[]>('/admin/email/broadcasts'),
+
+  getBroadcast: (id: string) => request<EmailBroadcastProgress>(`/admin/email/broadcasts/${id}`),
+
+  resendBroadcastFailures: (id: string) =>
+    request<{ message: string; requeued: number }>(`/admin/email/broadcasts/${id}/resend`, {
+      method: 'POST',
+    }),
 };
 
 // ==================== COUPONS API ====================
@@ -1077,3 +1951,11 @@ export const shippingConfigApi = {
       body: JSON.stringify(data),
     }),
 };
+
+// ==================== TAX API ====================
+
+export const taxApi = {
+  // Public: whether prices include VAT, and the rate in force
+  getSettings: () =>
+    request<{ pricesIncludeTax: boolean; rate: TaxRate | null }>('/tax-settings'),
+};
@@ -11,6 +11,13 @@ export interface ProductOption {
   max?: number;
   step?: number;
   unit?: string;
+  pricePerUnit?: number;
+  required?: boolean;
+  // Only available while another option has one of these values
+  dependsOn?: {
+    option: string;
+    values: string[];
+  };
 }
 
 export interface Product {